@@ -0,0 +1,164 @@
+package address
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidCountry = errors.New("country_code must be a two letter ISO code")
+)
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// directionals are the canonical forms of street directionals.
+var directionals = map[string]string{
+	"north": "N", "south": "S", "east": "E", "west": "W",
+	"northeast": "NE", "northwest": "NW", "southeast": "SE", "southwest": "SW",
+	"n": "N", "s": "S", "e": "E", "w": "W", "ne": "NE", "nw": "NW", "se": "SE", "sw": "SW",
+}
+
+// streetSuffixes are the abbreviations of common street suffixes, used in
+// countries whose rule abbreviates them.
+var streetSuffixes = map[string]string{
+	"street": "St", "st": "St", "avenue": "Ave", "ave": "Ave", "av": "Ave",
+	"boulevard": "Blvd", "blvd": "Blvd", "road": "Rd", "rd": "Rd", "drive": "Dr", "dr": "Dr",
+	"lane": "Ln", "ln": "Ln", "court": "Ct", "ct": "Ct", "place": "Pl", "pl": "Pl",
+	"terrace": "Ter", "ter": "Ter", "parkway": "Pkwy", "pkwy": "Pkwy", "highway": "Hwy",
+	"hwy": "Hwy", "circle": "Cir", "cir": "Cir", "square": "Sq", "sq": "Sq", "trail": "Trl",
+	"trl": "Trl", "way": "Way", "alley": "Aly", "crescent": "Cres", "expressway": "Expy",
+}
+
+// unitTypes are the canonical forms of unit designators.
+var unitTypes = map[string]string{
+	"apartment": "Apt", "apt": "Apt", "suite": "Ste", "ste": "Ste", "unit": "Unit",
+	"floor": "Fl", "fl": "Fl", "room": "Rm", "rm": "Rm", "building": "Bldg", "bldg": "Bldg",
+	"department": "Dept", "dept": "Dept", "#": "#",
+}
+
+// Normalize rewrites a into the canonical form of its country and checks it
+// against the country's rule: whitespace is collapsed, directionals, unit
+// types and, where the rule says so, street suffixes are abbreviated, street
+// and unit numbers uppercased, regions stored as their code and postal codes
+// formatted. Formatted is filled in last.
+func Normalize(a *types.UserPhysicalAddress) error {
+	a.CountryCode = strings.ToUpper(clean(a.CountryCode))
+	if !countryPattern.MatchString(a.CountryCode) {
+		return ErrInvalidCountry
+	}
+	rule := RuleFor(a.CountryCode)
+
+	a.FullName = clean(a.FullName)
+	a.Phone = clean(a.Phone)
+	a.StreetNumber = strings.ToUpper(strings.ReplaceAll(clean(a.StreetNumber), " ", ""))
+	a.Directional = canonical(directionals, strings.TrimSuffix(clean(a.Directional), "."))
+	a.Street = clean(a.Street)
+	a.Suffix = clean(a.Suffix)
+	if rule.AbbreviateStreet {
+		a.Suffix = canonical(streetSuffixes, strings.TrimSuffix(a.Suffix, "."))
+	}
+	a.UnitType = canonical(unitTypes, strings.TrimSuffix(clean(a.UnitType), "."))
+	a.UnitNumber = strings.ToUpper(strings.TrimPrefix(clean(a.UnitNumber), "#"))
+	a.City = clean(a.City)
+	a.Region = clean(a.Region)
+	a.ZipCode = strings.ToUpper(strings.ReplaceAll(clean(a.ZipCode), " ", ""))
+
+	if a.Street == "" {
+		return fmt.Errorf("%w: street is required", ErrInvalidAddress)
+	}
+	if a.UnitNumber != "" && a.UnitType == "" {
+		a.UnitType = "Unit"
+	}
+	if rule.RequireCity && a.City == "" {
+		return fmt.Errorf("%w: city is required in %s", ErrInvalidAddress, countryName(rule, a.CountryCode))
+	}
+	if rule.RequireRegion && a.Region == "" {
+		return fmt.Errorf("%w: region is required in %s", ErrInvalidAddress, countryName(rule, a.CountryCode))
+	}
+	if a.Region != "" && rule.Regions != nil {
+		code, ok := rule.Regions[strings.ToLower(a.Region)]
+		if !ok {
+			return fmt.Errorf("%w: unknown region %q in %s", ErrInvalidAddress, a.Region, countryName(rule, a.CountryCode))
+		}
+		a.Region = code
+	}
+	if a.ZipCode == "" {
+		if rule.RequirePostal {
+			return fmt.Errorf("%w: zip_code is required in %s", ErrInvalidAddress, countryName(rule, a.CountryCode))
+		}
+	} else {
+		if rule.postal != nil {
+			a.ZipCode = rule.postal(a.ZipCode)
+		}
+		if rule.PostalPattern != nil && !rule.PostalPattern.MatchString(a.ZipCode) {
+			return fmt.Errorf("%w: zip_code must look like %s", ErrInvalidAddress, rule.PostalExample)
+		}
+	}
+
+	a.Formatted = Format(*a)
+	return nil
+}
+
+// Format lays a out as written on a label in its country, without empty
+// lines. The last line is the country name in capitals.
+func Format(a types.UserPhysicalAddress) string {
+	rule := RuleFor(a.CountryCode)
+	replacer := strings.NewReplacer(
+		"%N", a.FullName,
+		"%A", StreetLine(a),
+		"%C", a.City,
+		"%S", a.Region,
+		"%Z", a.ZipCode,
+	)
+	lines := []string{}
+	for _, line := range strings.Split(rule.Layout, "\n") {
+		line = strings.Trim(clean(replacer.Replace(line)), " ,")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, strings.ToUpper(countryName(rule, a.CountryCode)))
+	return strings.Join(lines, "\n")
+}
+
+// StreetLine joins the street parts of a, the number before or after the
+// street as is usual in its country, followed by the unit.
+func StreetLine(a types.UserPhysicalAddress) string {
+	parts := []string{a.Directional, a.Street, a.Suffix}
+	if RuleFor(a.CountryCode).NumberAfterStreet {
+		parts = append(parts, a.StreetNumber)
+	} else {
+		parts = append([]string{a.StreetNumber}, parts...)
+	}
+	line := clean(strings.Join(parts, " "))
+	if unit := clean(a.UnitType + " " + a.UnitNumber); a.UnitNumber != "" {
+		line += ", " + unit
+	}
+	return line
+}
+
+// clean trims s and collapses runs of whitespace into single spaces.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// canonical returns the canonical form of value in forms, or value itself
+// when it has none.
+func canonical(forms map[string]string, value string) string {
+	if form, ok := forms[strings.ToLower(value)]; ok {
+		return form
+	}
+	return value
+}
+
+func countryName(rule Rule, code string) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return code
+}
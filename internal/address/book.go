@@ -0,0 +1,123 @@
+package address
+
+import (
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Address kinds of the address book.
+const (
+	KindBilling  = "billing"
+	KindShipping = "shipping"
+)
+
+// Save normalizes a and stores it in its owner's address book. An address
+// made primary for a kind takes over from the customer's previous one, and
+// a customer with addresses of a kind always has a primary one. The rules
+// run under a lock of the customer so concurrent saves cannot leave two
+// primaries.
+func Save(db *gorm.DB, a *types.UserPhysicalAddress) error {
+	if err := Normalize(a); err != nil {
+		return err
+	}
+	// an address is primary only for the kinds it is used for
+	a.PrimaryBilling = a.PrimaryBilling && a.IsBilling
+	a.PrimaryShipping = a.PrimaryShipping && a.IsShipping
+	a.Active = true
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, a.UserID); err != nil {
+			return err
+		}
+		for _, kind := range []string{KindBilling, KindShipping} {
+			used, primary := flags(a, kind)
+			if !*used {
+				continue
+			}
+			if *primary {
+				if err := tx.Model(&types.UserPhysicalAddress{}).
+					Where("user_id = ? AND id <> ?", a.UserID, a.ID).
+					Update(primaryColumn(kind), false).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			var count int64
+			if err := tx.Model(&types.UserPhysicalAddress{}).
+				Where("user_id = ? AND id <> ? AND "+primaryColumn(kind)+" = ?", a.UserID, a.ID, true).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			*primary = count == 0
+		}
+		if err := tx.Save(a).Error; err != nil {
+			return err
+		}
+		return promote(tx, a.UserID)
+	})
+}
+
+// Remove deletes a from the address book. Another address of each kind a was
+// primary for, the oldest, becomes primary in its place.
+func Remove(db *gorm.DB, a types.UserPhysicalAddress) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, a.UserID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&types.UserPhysicalAddress{}, a.ID).Error; err != nil {
+			return err
+		}
+		return promote(tx, a.UserID)
+	})
+}
+
+// Primary returns the primary address of kind of a customer, or nil when
+// the customer has none.
+func Primary(db *gorm.DB, userID uint, kind string) (*types.UserPhysicalAddress, error) {
+	var addresses []types.UserPhysicalAddress
+	if err := db.Where("user_id = ? AND active = ? AND "+primaryColumn(kind)+" = ?", userID, true, true).
+		Limit(1).Find(&addresses).Error; err != nil || len(addresses) == 0 {
+		return nil, err
+	}
+	return &addresses[0], nil
+}
+
+// promote makes the oldest address of each kind primary where the customer
+// was left without one.
+func promote(tx *gorm.DB, userID uint) error {
+	for _, kind := range []string{KindBilling, KindShipping} {
+		primary, err := Primary(tx, userID, kind)
+		if err != nil {
+			return err
+		}
+		if primary != nil {
+			continue
+		}
+		oldest := tx.Model(&types.UserPhysicalAddress{}).Select("id").
+			Where("user_id = ? AND active = ? AND is_"+kind+" = ?", userID, true, true).
+			Order("id").Limit(1)
+		if err := tx.Model(&types.UserPhysicalAddress{}).Where("id IN (?)", oldest).
+			Update(primaryColumn(kind), true).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock takes the row of the customer so address book changes of one
+// customer run one at a time.
+func lock(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&types.User{}, userID).Error
+}
+
+func flags(a *types.UserPhysicalAddress, kind string) (used, primary *bool) {
+	if kind == KindBilling {
+		return &a.IsBilling, &a.PrimaryBilling
+	}
+	return &a.IsShipping, &a.PrimaryShipping
+}
+
+func primaryColumn(kind string) string {
+	return "primary_" + kind
+}
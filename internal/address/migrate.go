@@ -0,0 +1,50 @@
+package address
+
+import (
+	"fmt"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// primarySQL sets the primary flag of a kind, given as its column and the
+// matching is_ column, on one address per customer: the old primary address
+// if it is used for the kind, the oldest address of the kind otherwise.
+const primarySQL = `UPDATE user_physical_addresses SET %[1]s = id IN (
+	SELECT DISTINCT ON (user_id) id FROM user_physical_addresses
+	WHERE active AND %[2]s
+	ORDER BY user_id, "primary" DESC, id
+)`
+
+// Migrate moves address books kept in the old layout, with a single primary
+// flag and numeric street and unit numbers, to the current one. It is
+// registered as a tenant setup function, so it runs after AutoMigrate has
+// added the new columns and turned the numbers into strings, and before the
+// tenant serves requests. Tables without the old primary column are left
+// alone, which makes it safe to run repeatedly.
+func Migrate(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&types.UserPhysicalAddress{}, "primary") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, kind := range []string{KindBilling, KindShipping} {
+			if err := tx.Exec(fmt.Sprintf(primarySQL, primaryColumn(kind), "is_"+kind)).Error; err != nil {
+				return err
+			}
+		}
+
+		// numbers were integers, with 0 standing for none
+		statements := []string{
+			"UPDATE user_physical_addresses SET unit_number = '' WHERE unit_number = '0'",
+			"UPDATE order_addresses SET unit_number = '' WHERE unit_number = '0'",
+			"ALTER TABLE user_physical_addresses ALTER COLUMN street_number DROP NOT NULL",
+			`ALTER TABLE user_physical_addresses DROP COLUMN "primary"`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
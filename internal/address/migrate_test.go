@@ -0,0 +1,79 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+func TestMigrateOldPrimary(t *testing.T) {
+	db := testdb.Open(t)
+	// the column the old layout kept the primary address in
+	if err := db.Exec(`ALTER TABLE user_physical_addresses ADD COLUMN "primary" boolean DEFAULT false`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	users := []types.User{{FullName: "Ada", Email: "ada@example.com"}, {FullName: "Bob", Email: "bob@example.com"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	ada, bob := users[0].ID, users[1].ID
+	addresses := []types.UserPhysicalAddress{
+		{UserID: ada, Street: "Old St", ZipCode: "1", CountryCode: "US", Active: true, IsBilling: true, IsShipping: true, UnitNumber: "0"},
+		{UserID: ada, Street: "Home St", ZipCode: "2", CountryCode: "US", Active: true, IsBilling: true, IsShipping: true, UnitNumber: "4"},
+		{UserID: ada, Street: "Work St", ZipCode: "3", CountryCode: "US", Active: true, IsShipping: true},
+		{UserID: bob, Street: "Bob St", ZipCode: "4", CountryCode: "US", Active: true, IsShipping: true},
+		{UserID: bob, Street: "Billing St", ZipCode: "5", CountryCode: "US", Active: true, IsBilling: true},
+	}
+	if err := db.Create(&addresses).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Ada's home and Bob's first address were primary
+	if err := db.Exec(`UPDATE user_physical_addresses SET "primary" = true WHERE id IN ?`, []uint{addresses[1].ID, addresses[3].ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasColumn(&types.UserPhysicalAddress{}, "primary") {
+		t.Error("the primary column was not dropped")
+	}
+	for _, c := range []struct {
+		user    uint
+		kind    string
+		address types.UserPhysicalAddress
+	}{
+		{ada, KindBilling, addresses[1]},
+		{ada, KindShipping, addresses[1]},
+		{bob, KindShipping, addresses[3]},
+		{bob, KindBilling, addresses[4]},
+	} {
+		primary, err := Primary(db, c.user, c.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if primary == nil || primary.ID != c.address.ID {
+			t.Errorf("primary %s address of user %d is %v, want %s", c.kind, c.user, primary, c.address.Street)
+		}
+	}
+
+	var count int64
+	db.Model(&types.UserPhysicalAddress{}).Where("primary_billing OR primary_shipping").Count(&count)
+	if count != 3 {
+		t.Errorf("%d addresses are primary, want 3", count)
+	}
+	var old types.UserPhysicalAddress
+	if err := db.First(&old, addresses[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.UnitNumber != "" {
+		t.Errorf("unit number %q, want none", old.UnitNumber)
+	}
+
+	// running it again leaves the migrated book alone
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,202 @@
+package address
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Rule is how addresses are written in a country. Layout lays the address
+// out line by line with %N for the name, %A for the street line, %C for the
+// city, %S for the region and %Z for the postal code. Regions maps the
+// names and codes of the country's regions, lowercase, to the code stored;
+// without it any region is accepted.
+type Rule struct {
+	Name              string
+	PostalPattern     *regexp.Regexp
+	PostalExample     string
+	RequirePostal     bool
+	RequireCity       bool
+	RequireRegion     bool
+	Regions           map[string]string
+	NumberAfterStreet bool
+	AbbreviateStreet  bool
+	Layout            string
+
+	// postal rewrites a postal code, already uppercase and without spaces,
+	// into the country's canonical form.
+	postal func(string) string
+}
+
+// defaultRule is used for countries without a rule of their own.
+var defaultRule = Rule{
+	RequireCity: true,
+	Layout:      "%N\n%A\n%C %Z",
+}
+
+var rules = map[string]Rule{
+	"US": {
+		Name:             "United States",
+		PostalPattern:    regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+		PostalExample:    "12345 or 12345-6789",
+		RequirePostal:    true,
+		RequireCity:      true,
+		RequireRegion:    true,
+		Regions:          regions(usStates),
+		AbbreviateStreet: true,
+		Layout:           "%N\n%A\n%C, %S %Z",
+		postal:           splitAt(5, "-"),
+	},
+	"CA": {
+		Name:             "Canada",
+		PostalPattern:    regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`),
+		PostalExample:    "K1A 0B1",
+		RequirePostal:    true,
+		RequireCity:      true,
+		RequireRegion:    true,
+		Regions:          regions(caProvinces),
+		AbbreviateStreet: true,
+		Layout:           "%N\n%A\n%C %S %Z",
+		postal:           splitAt(3, " "),
+	},
+	"GB": {
+		Name:          "United Kingdom",
+		PostalPattern: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+		PostalExample: "SW1A 1AA",
+		RequirePostal: true,
+		RequireCity:   true,
+		Layout:        "%N\n%A\n%C\n%Z",
+		postal:        splitLast(3, " "),
+	},
+	"AU": {
+		Name:          "Australia",
+		PostalPattern: regexp.MustCompile(`^\d{4}$`),
+		PostalExample: "2000",
+		RequirePostal: true,
+		RequireCity:   true,
+		RequireRegion: true,
+		Regions:       regions(auStates),
+		Layout:        "%N\n%A\n%C %S %Z",
+	},
+	"DE": {
+		Name:              "Germany",
+		PostalPattern:     regexp.MustCompile(`^\d{5}$`),
+		PostalExample:     "10115",
+		RequirePostal:     true,
+		RequireCity:       true,
+		NumberAfterStreet: true,
+		Layout:            "%N\n%A\n%Z %C",
+	},
+	"FR": {
+		Name:          "France",
+		PostalPattern: regexp.MustCompile(`^\d{5}$`),
+		PostalExample: "75001",
+		RequirePostal: true,
+		RequireCity:   true,
+		Layout:        "%N\n%A\n%Z %C",
+	},
+	"NL": {
+		Name:              "Netherlands",
+		PostalPattern:     regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+		PostalExample:     "1012 AB",
+		RequirePostal:     true,
+		RequireCity:       true,
+		NumberAfterStreet: true,
+		Layout:            "%N\n%A\n%Z %C",
+		postal:            splitAt(4, " "),
+	},
+	"IN": {
+		Name:          "India",
+		PostalPattern: regexp.MustCompile(`^\d{6}$`),
+		PostalExample: "110001",
+		RequirePostal: true,
+		RequireCity:   true,
+		RequireRegion: true,
+		Layout:        "%N\n%A\n%C %Z\n%S",
+	},
+	"JP": {
+		Name:          "Japan",
+		PostalPattern: regexp.MustCompile(`^\d{3}-\d{4}$`),
+		PostalExample: "100-0001",
+		RequirePostal: true,
+		RequireCity:   true,
+		RequireRegion: true,
+		Layout:        "%N\n%A\n%C, %S %Z",
+		postal:        splitAt(3, "-"),
+	},
+	"NP": {
+		Name:          "Nepal",
+		PostalPattern: regexp.MustCompile(`^\d{5}$`),
+		PostalExample: "44600",
+		RequireCity:   true,
+		Layout:        "%N\n%A\n%C %Z",
+	},
+}
+
+// RuleFor returns the rule of a country code.
+func RuleFor(countryCode string) Rule {
+	if rule, ok := rules[strings.ToUpper(countryCode)]; ok {
+		return rule
+	}
+	return defaultRule
+}
+
+// splitAt inserts sep after the first n characters of codes longer than n.
+func splitAt(n int, sep string) func(string) string {
+	return func(code string) string {
+		code = strings.ReplaceAll(code, sep, "")
+		if len(code) <= n {
+			return code
+		}
+		return code[:n] + sep + code[n:]
+	}
+}
+
+// splitLast inserts sep before the last n characters of codes longer than n.
+func splitLast(n int, sep string) func(string) string {
+	return func(code string) string {
+		if len(code) <= n {
+			return code
+		}
+		return code[:len(code)-n] + sep + code[len(code)-n:]
+	}
+}
+
+// regions indexes codes and names of regions, lowercase, by both.
+func regions(names map[string]string) map[string]string {
+	index := map[string]string{}
+	for code, name := range names {
+		index[strings.ToLower(code)] = code
+		index[strings.ToLower(name)] = code
+	}
+	return index
+}
+
+var usStates = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+	"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia",
+	"FL": "Florida", "GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois",
+	"IN": "Indiana", "IA": "Iowa", "KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana",
+	"ME": "Maine", "MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota",
+	"MS": "Mississippi", "MO": "Missouri", "MT": "Montana", "NE": "Nebraska", "NV": "Nevada",
+	"NH": "New Hampshire", "NJ": "New Jersey", "NM": "New Mexico", "NY": "New York",
+	"NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio", "OK": "Oklahoma", "OR": "Oregon",
+	"PA": "Pennsylvania", "RI": "Rhode Island", "SC": "South Carolina", "SD": "South Dakota",
+	"TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont", "VA": "Virginia",
+	"WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+	"PR": "Puerto Rico", "GU": "Guam", "VI": "Virgin Islands", "AS": "American Samoa",
+	"MP": "Northern Mariana Islands", "AA": "Armed Forces Americas", "AE": "Armed Forces Europe",
+	"AP": "Armed Forces Pacific",
+}
+
+var caProvinces = map[string]string{
+	"AB": "Alberta", "BC": "British Columbia", "MB": "Manitoba", "NB": "New Brunswick",
+	"NL": "Newfoundland and Labrador", "NS": "Nova Scotia", "NT": "Northwest Territories",
+	"NU": "Nunavut", "ON": "Ontario", "PE": "Prince Edward Island", "QC": "Quebec",
+	"SK": "Saskatchewan", "YT": "Yukon",
+}
+
+var auStates = map[string]string{
+	"ACT": "Australian Capital Territory", "NSW": "New South Wales", "NT": "Northern Territory",
+	"QLD": "Queensland", "SA": "South Australia", "TAS": "Tasmania", "VIC": "Victoria",
+	"WA": "Western Australia",
+}
@@ -0,0 +1,266 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Subscription statuses.
+const (
+	StatusTrialing  = "trialing"
+	StatusActive    = "active"
+	StatusPastDue   = "past_due"
+	StatusCancelled = "cancelled"
+)
+
+// Billing intervals.
+const (
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+var (
+	ErrPlanInactive        = errors.New("plan is not available")
+	ErrAlreadySubscribed   = errors.New("vendor already has a subscription")
+	ErrNotSubscribed       = errors.New("vendor has no subscription")
+	ErrSamePlan            = errors.New("subscription is already on this plan")
+	ErrCurrencyMismatch    = errors.New("plans are billed in different currencies")
+	ErrPaymentRequired     = errors.New("a payment provider and method are required for paid plans")
+	ErrInvalidSubState     = errors.New("subscription is not in a state that allows this operation")
+	ErrInvalidInvoiceState = errors.New("invoice is not in a state that allows this operation")
+)
+
+// ValidInterval reports whether interval is a supported billing interval.
+func ValidInterval(interval string) bool {
+	return interval == IntervalMonth || interval == IntervalYear
+}
+
+// periodEnd is the end of the billing period of interval starting at start.
+func periodEnd(start time.Time, interval string) time.Time {
+	if interval == IntervalYear {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// PaymentDetails tell how a subscription is charged, Method is a provider
+// specific token.
+type PaymentDetails struct {
+	Provider string
+	Method   string
+}
+
+// Subscribe puts the vendor on plan. Plans with trial days start trialing and
+// are first charged when the trial ends, other paid plans are invoiced right
+// away. A cancelled subscription is replaced.
+func Subscribe(ctx context.Context, db *gorm.DB, vendorID uint, plan types.Plan, payment PaymentDetails, now time.Time) (types.VendorSubscription, error) {
+	if !plan.Active {
+		return types.VendorSubscription{}, ErrPlanInactive
+	}
+	if plan.Price > 0 && (payment.Provider == "" || payment.Method == "") {
+		return types.VendorSubscription{}, ErrPaymentRequired
+	}
+
+	sub := types.VendorSubscription{}
+	var invoice *types.VendorInvoice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("vendor_id = ?", vendorID).First(&sub).Error
+		if err == nil && sub.Status != StatusCancelled {
+			return ErrAlreadySubscribed
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		sub.VendorID = vendorID
+		sub.PlanID = plan.ID
+		sub.Plan = plan
+		sub.CurrentPeriodStart = now
+		sub.CancelAtPeriodEnd = false
+		sub.CancelledAt = nil
+		sub.TrialEndsAt = nil
+		sub.PaymentProvider = payment.Provider
+		sub.PaymentMethod = payment.Method
+		if plan.TrialDays > 0 {
+			trialEnd := now.AddDate(0, 0, plan.TrialDays)
+			sub.Status = StatusTrialing
+			sub.TrialEndsAt = &trialEnd
+			sub.CurrentPeriodEnd = trialEnd
+		} else {
+			sub.Status = StatusActive
+			sub.CurrentPeriodEnd = periodEnd(now, plan.Interval)
+		}
+		if err := tx.Omit("Plan").Save(&sub).Error; err != nil {
+			return err
+		}
+
+		if sub.Status == StatusActive && plan.Price > 0 {
+			created, err := createInvoice(tx, &sub, plan.Currency, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, []types.VendorInvoiceLine{
+				{Description: plan.Name + " plan", Amount: plan.Price},
+			}, now)
+			if err != nil {
+				return err
+			}
+			invoice = &created
+		}
+		return nil
+	})
+	if err != nil {
+		return sub, err
+	}
+
+	if invoice != nil && invoice.Status == InvoiceOpen {
+		if err := Charge(ctx, db, invoice, now); err != nil && !isPaymentError(err) {
+			return sub, err
+		}
+		if err := db.Preload("Plan").First(&sub, sub.ID).Error; err != nil {
+			return sub, err
+		}
+	}
+	return sub, nil
+}
+
+// ChangePlan moves an active subscription to plan right away. The unused part
+// of the current period is credited at the old price and the same part is
+// charged at the new price. The difference is invoiced now when positive and
+// kept as credit for the next invoices otherwise. Switching to a plan with
+// another interval starts a new period. Trialing subscriptions just switch.
+func ChangePlan(ctx context.Context, db *gorm.DB, sub *types.VendorSubscription, plan types.Plan, now time.Time) (*types.VendorInvoice, error) {
+	if !plan.Active {
+		return nil, ErrPlanInactive
+	}
+
+	var invoice *types.VendorInvoice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan").First(sub, sub.ID).Error; err != nil {
+			return err
+		}
+		if sub.PlanID == plan.ID {
+			return ErrSamePlan
+		}
+
+		switch sub.Status {
+		case StatusTrialing:
+			sub.PlanID = plan.ID
+			sub.Plan = plan
+			return tx.Omit("Plan").Save(sub).Error
+		case StatusActive:
+		default:
+			return ErrInvalidSubState
+		}
+
+		old := sub.Plan
+		if old.Price > 0 && plan.Price > 0 && old.Currency != plan.Currency {
+			return ErrCurrencyMismatch
+		}
+		if plan.Price > 0 && (sub.PaymentProvider == "" || sub.PaymentMethod == "") {
+			return ErrPaymentRequired
+		}
+
+		credit := prorate(old.Price, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, now)
+		lines := []types.VendorInvoiceLine{}
+		if credit > 0 {
+			lines = append(lines, types.VendorInvoiceLine{Description: "Unused time on " + old.Name + " plan", Amount: -credit})
+		}
+
+		periodStart, end := now, sub.CurrentPeriodEnd
+		var charge int64
+		if plan.Interval == old.Interval {
+			charge = prorate(plan.Price, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, now)
+		} else {
+			end = periodEnd(now, plan.Interval)
+			charge = plan.Price
+			sub.CurrentPeriodStart = now
+			sub.CurrentPeriodEnd = end
+		}
+		if charge > 0 {
+			lines = append(lines, types.VendorInvoiceLine{Description: "Remaining time on " + plan.Name + " plan", Amount: charge})
+		}
+
+		sub.PlanID = plan.ID
+		sub.Plan = plan
+		if net := charge - credit; net < 0 {
+			sub.Credit += -net
+		}
+		if err := tx.Omit("Plan").Save(sub).Error; err != nil {
+			return err
+		}
+
+		if charge > credit {
+			created, err := createInvoice(tx, sub, plan.Currency, periodStart, end, lines, now)
+			if err != nil {
+				return err
+			}
+			invoice = &created
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if invoice != nil && invoice.Status == InvoiceOpen {
+		if err := Charge(ctx, db, invoice, now); err != nil && !isPaymentError(err) {
+			return invoice, err
+		}
+		if err := db.Preload("Plan").First(sub, sub.ID).Error; err != nil {
+			return invoice, err
+		}
+	}
+	return invoice, nil
+}
+
+// prorate is the part of price matching what is left of the period at now.
+func prorate(price int64, start, end, now time.Time) int64 {
+	total := end.Sub(start)
+	remaining := end.Sub(now)
+	if price <= 0 || total <= 0 || remaining <= 0 {
+		return 0
+	}
+	if remaining > total {
+		remaining = total
+	}
+	return price * int64(remaining/time.Second) / int64(total/time.Second)
+}
+
+// Cancel stops the subscription at the end of the current period, or right
+// away when immediately is set.
+func Cancel(db *gorm.DB, sub *types.VendorSubscription, immediately bool, now time.Time) error {
+	if sub.Status == StatusCancelled {
+		return ErrInvalidSubState
+	}
+	updates := map[string]interface{}{"cancel_at_period_end": true}
+	if immediately {
+		updates["status"] = StatusCancelled
+		updates["cancelled_at"] = now
+	}
+	res := db.Model(&types.VendorSubscription{}).
+		Where("id = ? AND status = ?", sub.ID, sub.Status).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidSubState
+	}
+	return db.Preload("Plan").First(sub, sub.ID).Error
+}
+
+// Resume undoes a cancellation that has not taken effect yet.
+func Resume(db *gorm.DB, sub *types.VendorSubscription) error {
+	res := db.Model(&types.VendorSubscription{}).
+		Where("id = ? AND status <> ? AND cancel_at_period_end = ?", sub.ID, StatusCancelled, true).
+		Update("cancel_at_period_end", false)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidSubState
+	}
+	return db.Preload("Plan").First(sub, sub.ID).Error
+}
@@ -0,0 +1,51 @@
+package billing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProrate(t *testing.T) {
+	start := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	day := 24 * time.Hour
+	cases := []struct {
+		name       string
+		price      int64
+		start, end time.Time
+		now        time.Time
+		want       int64
+	}{
+		{"half the period left", 3000, start, end, start.Add(15 * day), 1500},
+		{"rounded down", 1000, start, end, start.Add(20 * day), 333},
+		{"whole period left", 3000, start, end, start, 3000},
+		{"before the period", 3000, start, end, start.Add(-day), 3000},
+		{"period over", 3000, start, end, end, 0},
+		{"after the period", 3000, start, end, end.Add(day), 0},
+		{"free plan", 0, start, end, start.Add(15 * day), 0},
+		{"empty period", 3000, start, start, start, 0},
+	}
+	for _, c := range cases {
+		if got := prorate(c.price, c.start, c.end, c.now); got != c.want {
+			t.Errorf("%s: %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPeriodEnd(t *testing.T) {
+	cases := []struct {
+		start    time.Time
+		interval string
+		want     time.Time
+	}{
+		{time.Date(2026, time.June, 15, 9, 0, 0, 0, time.UTC), IntervalMonth, time.Date(2026, time.July, 15, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC), IntervalMonth, time.Date(2026, time.March, 3, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, time.June, 15, 9, 0, 0, 0, time.UTC), IntervalYear, time.Date(2027, time.June, 15, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2028, time.February, 29, 9, 0, 0, 0, time.UTC), IntervalYear, time.Date(2029, time.March, 1, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if got := periodEnd(c.start, c.interval); !got.Equal(c.want) {
+			t.Errorf("%s period from %s ends %s, want %s", c.interval, c.start, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invoice statuses. A failed invoice gave up after MaxAttempts charges.
+const (
+	InvoiceOpen   = "open"
+	InvoicePaid   = "paid"
+	InvoiceFailed = "failed"
+	InvoiceVoid   = "void"
+)
+
+const (
+	// MaxAttempts is how many times an invoice is charged before the
+	// subscription is cancelled.
+	MaxAttempts = 3
+	// RetryDelay is the wait between two charges of an invoice.
+	RetryDelay = 24 * time.Hour
+)
+
+var ErrPaymentFailed = errors.New("subscription payment failed")
+
+// isPaymentError reports whether err only tells that the charge did not go
+// through, which is recorded on the invoice rather than returned to callers.
+func isPaymentError(err error) bool {
+	return errors.Is(err, ErrPaymentFailed)
+}
+
+// createInvoice opens an invoice for lines. Credit left on the subscription
+// is taken off first, an invoice it covers in full is paid right away.
+func createInvoice(tx *gorm.DB, sub *types.VendorSubscription, currency string, start, end time.Time, lines []types.VendorInvoiceLine, now time.Time) (types.VendorInvoice, error) {
+	var amount int64
+	for _, line := range lines {
+		amount += line.Amount
+	}
+	if sub.Credit > 0 && amount > 0 {
+		applied := sub.Credit
+		if applied > amount {
+			applied = amount
+		}
+		lines = append(lines, types.VendorInvoiceLine{Description: "Account credit", Amount: -applied})
+		amount -= applied
+		sub.Credit -= applied
+		if err := tx.Model(&types.VendorSubscription{}).Where("id = ?", sub.ID).
+			Update("credit", sub.Credit).Error; err != nil {
+			return types.VendorInvoice{}, err
+		}
+	}
+
+	invoice := types.VendorInvoice{
+		Number:         "INV-" + strings.ToUpper(randomString.GenerateSecureToken(5)),
+		VendorID:       sub.VendorID,
+		SubscriptionID: sub.ID,
+		Status:         InvoiceOpen,
+		Amount:         amount,
+		Currency:       currency,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		NextAttemptAt:  &now,
+		Lines:          lines,
+	}
+	if amount <= 0 {
+		invoice.Status = InvoicePaid
+		invoice.PaidAt = &now
+		invoice.NextAttemptAt = nil
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return invoice, err
+	}
+	return invoice, nil
+}
+
+// Charge collects an open invoice through the subscription's payment
+// provider. A failed charge puts the subscription past due and is retried
+// after RetryDelay, the subscription is cancelled after MaxAttempts. The
+// returned error wraps ErrPaymentFailed when the charge did not go through.
+func Charge(ctx context.Context, db *gorm.DB, invoice *types.VendorInvoice, now time.Time) error {
+	if invoice.Status != InvoiceOpen {
+		return ErrInvalidInvoiceState
+	}
+	sub := types.VendorSubscription{}
+	if err := db.First(&sub, invoice.SubscriptionID).Error; err != nil {
+		return err
+	}
+
+	res, chargeErr := collect(ctx, db, sub, invoice)
+	if chargeErr == nil {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			updated := tx.Model(&types.VendorInvoice{}).
+				Where("id = ? AND status = ?", invoice.ID, InvoiceOpen).
+				Updates(map[string]interface{}{
+					"status":             InvoicePaid,
+					"attempts":           invoice.Attempts + 1,
+					"paid_at":            now,
+					"next_attempt_at":    nil,
+					"provider_reference": res.ProviderReference,
+				})
+			if updated.Error != nil {
+				return updated.Error
+			}
+			if updated.RowsAffected == 0 {
+				return ErrInvalidInvoiceState
+			}
+			return tx.Model(&types.VendorSubscription{}).
+				Where("id = ? AND status = ?", sub.ID, StatusPastDue).
+				Update("status", StatusActive).Error
+		})
+		if err != nil {
+			return err
+		}
+		return db.Preload("Lines").First(invoice, invoice.ID).Error
+	}
+
+	attempts := invoice.Attempts + 1
+	err := db.Transaction(func(tx *gorm.DB) error {
+		invoiceUpdates := map[string]interface{}{
+			"attempts":   attempts,
+			"last_error": chargeErr.Error(),
+		}
+		subUpdates := map[string]interface{}{"status": StatusPastDue}
+		if attempts >= MaxAttempts {
+			invoiceUpdates["status"] = InvoiceFailed
+			invoiceUpdates["next_attempt_at"] = nil
+			subUpdates = map[string]interface{}{"status": StatusCancelled, "cancelled_at": now}
+		} else {
+			invoiceUpdates["next_attempt_at"] = now.Add(RetryDelay)
+		}
+
+		updated := tx.Model(&types.VendorInvoice{}).
+			Where("id = ? AND status = ?", invoice.ID, InvoiceOpen).
+			Updates(invoiceUpdates)
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return ErrInvalidInvoiceState
+		}
+		return tx.Model(&types.VendorSubscription{}).
+			Where("id = ? AND status IN ?", sub.ID, []string{StatusActive, StatusPastDue}).
+			Updates(subUpdates).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := db.Preload("Lines").First(invoice, invoice.ID).Error; err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrPaymentFailed, chargeErr)
+}
+
+// collect authorizes and captures the invoice amount. Platform providers are
+// configured in the main database like tenants configure theirs.
+func collect(ctx context.Context, db *gorm.DB, sub types.VendorSubscription, invoice *types.VendorInvoice) (payment.Result, error) {
+	if sub.PaymentProvider == "" || sub.PaymentMethod == "" {
+		return payment.Result{}, ErrPaymentRequired
+	}
+	provider, err := payment.ForTenant(db, sub.PaymentProvider)
+	if err != nil {
+		return payment.Result{}, err
+	}
+
+	auth, err := provider.Authorize(ctx, payment.AuthorizeRequest{
+		Amount:        invoice.Amount,
+		Currency:      invoice.Currency,
+		PaymentMethod: sub.PaymentMethod,
+		Reference:     fmt.Sprintf("%s-%d", invoice.Number, invoice.Attempts+1),
+	})
+	if err != nil {
+		return auth, err
+	}
+	if _, err := provider.Capture(ctx, auth.ProviderReference, invoice.Amount); err != nil {
+		return auth, err
+	}
+	return auth, nil
+}
+
+// Renew moves every subscription whose period ended into the next one and
+// invoices it, or cancels it when asked to stop at the period end. Open
+// invoices due for another attempt are charged again. It returns the number
+// of subscriptions renewed or cancelled.
+func Renew(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	var due []types.VendorSubscription
+	if err := db.Where("status IN ? AND current_period_end <= ?", []string{StatusTrialing, StatusActive}, now).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, sub := range due {
+		invoice, renewed, err := renew(db, sub.ID, now)
+		if err != nil {
+			return count, err
+		}
+		if !renewed {
+			continue
+		}
+		count++
+		if invoice != nil {
+			if err := Charge(ctx, db, invoice, now); err != nil && !isPaymentError(err) {
+				return count, err
+			}
+		}
+	}
+
+	var retries []types.VendorInvoice
+	if err := db.Where("status = ? AND next_attempt_at <= ?", InvoiceOpen, now).Find(&retries).Error; err != nil {
+		return count, err
+	}
+	for i := range retries {
+		if err := Charge(ctx, db, &retries[i], now); err != nil && !isPaymentError(err) && !errors.Is(err, ErrInvalidInvoiceState) {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// renew advances one subscription, it reports false when another run already
+// did.
+func renew(db *gorm.DB, id uint, now time.Time) (*types.VendorInvoice, bool, error) {
+	var invoice *types.VendorInvoice
+	renewed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		sub := types.VendorSubscription{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan").First(&sub, id).Error; err != nil {
+			return err
+		}
+		if (sub.Status != StatusTrialing && sub.Status != StatusActive) || sub.CurrentPeriodEnd.After(now) {
+			return nil
+		}
+		renewed = true
+
+		if sub.CancelAtPeriodEnd {
+			return tx.Model(&sub).Omit("Plan").Updates(map[string]interface{}{
+				"status":       StatusCancelled,
+				"cancelled_at": now,
+			}).Error
+		}
+
+		sub.Status = StatusActive
+		sub.CurrentPeriodStart = sub.CurrentPeriodEnd
+		sub.CurrentPeriodEnd = periodEnd(sub.CurrentPeriodStart, sub.Plan.Interval)
+		if err := tx.Omit("Plan").Save(&sub).Error; err != nil {
+			return err
+		}
+		if sub.Plan.Price <= 0 {
+			return nil
+		}
+
+		created, err := createInvoice(tx, &sub, sub.Plan.Currency, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, []types.VendorInvoiceLine{
+			{Description: sub.Plan.Name + " plan", Amount: sub.Plan.Price},
+		}, now)
+		if err != nil {
+			return err
+		}
+		if created.Status == InvoiceOpen {
+			invoice = &created
+		}
+		return nil
+	})
+	return invoice, renewed, err
+}
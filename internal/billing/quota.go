@@ -0,0 +1,146 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/media"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Resources limited by plans.
+const (
+	ResourceProducts = "products"
+	ResourceStaff    = "staff"
+	ResourceOrders   = "orders"
+	ResourceStorage  = "storage"
+)
+
+// FreePlanCode is the plan applied to vendors without a live subscription.
+// Without such a plan they are not limited.
+const FreePlanCode = "free"
+
+// LimitError tells that the tenant used everything its plan allows of
+// Resource.
+type LimitError struct {
+	Resource string
+	Limit    int64
+	Plan     string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("plan %s allows %d %s", e.Plan, e.Limit, e.Resource)
+}
+
+// Usage is how much of a resource a tenant uses against its plan limit, a
+// Limit of zero meaning unlimited.
+type Usage struct {
+	Resource string `json:"resource"`
+	Used     int64  `json:"used"`
+	Limit    int64  `json:"limit"`
+}
+
+// PlanFor is the plan limiting the tenant. It reports false when the tenant
+// is not limited.
+func PlanFor(main *gorm.DB, tenantID string) (types.Plan, bool, error) {
+	vendor := types.Vendor{}
+	if err := main.Where("tenant_id = ?", tenantID).First(&vendor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.Plan{}, false, nil
+		}
+		return types.Plan{}, false, err
+	}
+
+	sub := types.VendorSubscription{}
+	err := main.Preload("Plan").Where("vendor_id = ? AND status <> ?", vendor.ID, StatusCancelled).First(&sub).Error
+	if err == nil {
+		return sub.Plan, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.Plan{}, false, err
+	}
+
+	plan := types.Plan{}
+	if err := main.Where("code = ?", FreePlanCode).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.Plan{}, false, nil
+		}
+		return types.Plan{}, false, err
+	}
+	return plan, true, nil
+}
+
+// limitOf is the plan limit for resource, zero meaning unlimited. Storage is
+// counted in bytes.
+func limitOf(plan types.Plan, resource string) int64 {
+	switch resource {
+	case ResourceProducts:
+		return plan.MaxProducts
+	case ResourceStaff:
+		return plan.MaxStaff
+	case ResourceOrders:
+		return plan.MaxOrdersPerMonth
+	case ResourceStorage:
+		return plan.MaxStorageMB << 20
+	}
+	return 0
+}
+
+// used is how much of resource the tenant uses, orders are counted since the
+// start of the month. Stores have no staff accounts yet, so none are counted
+// and the staff limit cannot be reached.
+func used(db *gorm.DB, resource string, now time.Time) (int64, error) {
+	var count int64
+	switch resource {
+	case ResourceProducts:
+		err := db.Model(&types.Product{}).Count(&count).Error
+		return count, err
+	case ResourceOrders:
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		err := db.Model(&types.Order{}).Where("created_at >= ?", monthStart).Count(&count).Error
+		return count, err
+	case ResourceStorage:
+		return media.UsedBytes(db)
+	}
+	return 0, nil
+}
+
+// Check returns a *LimitError when the tenant cannot create one more of
+// resource on its plan.
+func Check(main, db *gorm.DB, tenantID, resource string, now time.Time) error {
+	plan, limited, err := PlanFor(main, tenantID)
+	if err != nil || !limited {
+		return err
+	}
+	limit := limitOf(plan, resource)
+	if limit == 0 {
+		return nil
+	}
+	count, err := used(db, resource, now)
+	if err != nil {
+		return err
+	}
+	if count >= limit {
+		return &LimitError{Resource: resource, Limit: limit, Plan: plan.Code}
+	}
+	return nil
+}
+
+// Usages reports the tenant's usage of every resource against its plan.
+func Usages(main, db *gorm.DB, tenantID string, now time.Time) ([]Usage, error) {
+	plan, _, err := PlanFor(main, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	usages := []Usage{}
+	for _, resource := range []string{ResourceProducts, ResourceStaff, ResourceOrders, ResourceStorage} {
+		count, err := used(db, resource, now)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, Usage{Resource: resource, Used: count, Limit: limitOf(plan, resource)})
+	}
+	return usages, nil
+}
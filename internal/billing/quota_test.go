@@ -0,0 +1,26 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+func TestLimitOf(t *testing.T) {
+	plan := types.Plan{MaxProducts: 100, MaxStaff: 3, MaxOrdersPerMonth: 500, MaxStorageMB: 2}
+	cases := []struct {
+		resource string
+		want     int64
+	}{
+		{ResourceProducts, 100},
+		{ResourceStaff, 3},
+		{ResourceOrders, 500},
+		{ResourceStorage, 2 << 20},
+		{"unknown", 0},
+	}
+	for _, c := range cases {
+		if got := limitOf(plan, c.resource); got != c.want {
+			t.Errorf("%s limit %d, want %d", c.resource, got, c.want)
+		}
+	}
+}
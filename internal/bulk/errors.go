@@ -0,0 +1,89 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// ErrorFile writes the failed rows of job to w in the format of the uploaded
+// file: the original CSV records with an extra error column, or the original
+// JSON products with an extra errors list. The file can be fixed and
+// uploaded again as is.
+func ErrorFile(db *gorm.DB, job types.ImportJob, w io.Writer) error {
+	var failed []types.ImportRow
+	if err := db.Where("job_id = ? AND status = ?", job.ID, RowFailed).Order("id").Find(&failed).Error; err != nil {
+		return err
+	}
+	messages := map[int][]string{}
+	for _, row := range failed {
+		message := row.Error
+		if job.Format == FormatJSON && row.SKU != "" {
+			message = row.SKU + ": " + message
+		}
+		messages[row.Line] = append(messages[row.Line], message)
+	}
+
+	switch job.Format {
+	case FormatCSV:
+		return csvErrorFile(job.Data, messages, w)
+	case FormatJSON:
+		return jsonErrorFile(job.Data, messages, w)
+	}
+	return ErrInvalidFormat
+}
+
+func csvErrorFile(data []byte, messages map[int][]string, w io.Writer) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	writer := csv.NewWriter(w)
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(append(header, "error")); err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		if message, ok := messages[line]; ok {
+			if err := writer.Write(append(record, strings.Join(message, "; "))); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func jsonErrorFile(data []byte, messages map[int][]string, w io.Writer) error {
+	// keep numbers as written instead of rounding them through float64
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var products []map[string]interface{}
+	if err := decoder.Decode(&products); err != nil {
+		return err
+	}
+	out := []map[string]interface{}{}
+	for i, product := range products {
+		if message, ok := messages[i+1]; ok {
+			product["errors"] = message
+			out = append(out, product)
+		}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
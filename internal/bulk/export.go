@@ -0,0 +1,163 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of products loaded per query while exporting.
+const exportBatchSize = 200
+
+// Export streams the whole catalog to w in format, in the same layout Import
+// reads, so importing an export reproduces the catalog.
+func Export(db *gorm.DB, format string, w io.Writer) error {
+	if format != FormatCSV && format != FormatJSON {
+		return ErrInvalidFormat
+	}
+
+	categories := map[uint]string{}
+	var cats []types.Category
+	if err := db.Select("id", "handle").Find(&cats).Error; err != nil {
+		return err
+	}
+	for _, category := range cats {
+		categories[category.ID] = category.Handle
+	}
+	taxClasses := map[uint]string{}
+	var classes []types.TaxClass
+	if err := db.Select("id", "code").Find(&classes).Error; err != nil {
+		return err
+	}
+	for _, class := range classes {
+		taxClasses[class.ID] = class.Code
+	}
+
+	var write func(p Product) error
+	var finish func() error
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Columns); err != nil {
+			return err
+		}
+		write = func(p Product) error {
+			for _, row := range productRows(p) {
+				if err := writer.Write(csvRecord(row)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case FormatJSON:
+		// write the array by hand so products never pile up in memory
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		first := true
+		write = func(p Product) error {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			data, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		finish = func() error {
+			_, err := io.WriteString(w, "]\n")
+			return err
+		}
+	}
+
+	var products []types.Product
+	err := db.Preload("Tags").Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Variants.Options").Order("id").FindInBatches(&products, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, product := range products {
+			if err := write(exportProduct(product, categories, taxClasses)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+	return finish()
+}
+
+// exportProduct converts a product with its tags, variants and options
+// loaded to the file layout.
+func exportProduct(product types.Product, categories, taxClasses map[uint]string) Product {
+	p := Product{
+		Handle:      product.Handle,
+		Title:       product.Title,
+		Description: product.Description,
+		Price:       product.Price,
+		Published:   product.Published,
+		Tags:        []string{},
+		Variants:    []Variant{},
+	}
+	if product.CategoryID != nil {
+		p.Category = categories[*product.CategoryID]
+	}
+	if product.TaxClassID != nil {
+		p.TaxClass = taxClasses[*product.TaxClassID]
+	}
+	for _, tag := range product.Tags {
+		p.Tags = append(p.Tags, tag.Name)
+	}
+	for _, variant := range product.Variants {
+		v := Variant{SKU: variant.SKU, Title: variant.Title, Price: variant.Price, Weight: variant.Weight}
+		for _, option := range variant.Options {
+			if v.Options == nil {
+				v.Options = map[string]string{}
+			}
+			v.Options[option.Name] = option.Value
+		}
+		p.Variants = append(p.Variants, v)
+	}
+	return p
+}
+
+// productRows flattens a product to one row per variant.
+func productRows(p Product) []Row {
+	row := Row{
+		Handle:      strings.TrimSpace(p.Handle),
+		Title:       strings.TrimSpace(p.Title),
+		Description: p.Description,
+		Price:       p.Price,
+		Published:   p.Published,
+		Category:    p.Category,
+		TaxClass:    p.TaxClass,
+		Tags:        p.Tags,
+	}
+	if len(p.Variants) == 0 {
+		return []Row{row}
+	}
+	rows := make([]Row, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		variant := row
+		variant.SKU = strings.TrimSpace(v.SKU)
+		variant.VariantTitle = v.Title
+		variant.VariantPrice = v.Price
+		variant.Weight = v.Weight
+		variant.Options = v.Options
+		rows = append(rows, variant)
+	}
+	return rows
+}
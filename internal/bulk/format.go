@@ -0,0 +1,224 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Columns are the CSV columns in export order. Every row is one variant and
+// repeats the fields of its product; rows without a sku only describe the
+// product. Tags are separated by "|" and options are written as
+// "Color=Red|Size=M". Only handle is required in the header.
+var Columns = []string{
+	"handle", "title", "description", "price", "published", "category", "tax_class",
+	"tags", "sku", "variant_title", "variant_price", "weight", "options",
+}
+
+var (
+	ErrInvalidFormat = errors.New("format must be csv or json")
+	ErrInvalidFile   = errors.New("invalid import file")
+)
+
+// Row is one product variant as read from an import file. Category is the
+// category handle and TaxClass the tax class code.
+type Row struct {
+	Line         int               `json:"-"`
+	Handle       string            `json:"handle" validate:"required,max=255"`
+	Title        string            `json:"title" validate:"required,max=255"`
+	Description  string            `json:"description"`
+	Price        int64             `json:"price" validate:"gte=0"`
+	Published    bool              `json:"published"`
+	Category     string            `json:"category" validate:"max=255"`
+	TaxClass     string            `json:"tax_class" validate:"max=50"`
+	Tags         []string          `json:"tags" validate:"dive,required,max=100"`
+	SKU          string            `json:"sku" validate:"max=100"`
+	VariantTitle string            `json:"variant_title" validate:"max=255"`
+	VariantPrice int64             `json:"variant_price" validate:"gte=0"`
+	Weight       int               `json:"weight" validate:"gte=0"`
+	Options      map[string]string `json:"options" validate:"dive,keys,required,max=100,endkeys,required,max=100"`
+
+	// parseErr is set when a field could not be read at all
+	parseErr error
+}
+
+// Product and Variant are the JSON file layout, one object per product.
+type (
+	Variant struct {
+		SKU     string            `json:"sku"`
+		Title   string            `json:"title"`
+		Price   int64             `json:"price"`
+		Weight  int               `json:"weight"`
+		Options map[string]string `json:"options,omitempty"`
+	}
+	Product struct {
+		Handle      string    `json:"handle"`
+		Title       string    `json:"title"`
+		Description string    `json:"description"`
+		Price       int64     `json:"price"`
+		Published   bool      `json:"published"`
+		Category    string    `json:"category,omitempty"`
+		TaxClass    string    `json:"tax_class,omitempty"`
+		Tags        []string  `json:"tags"`
+		Variants    []Variant `json:"variants"`
+	}
+)
+
+// ParseRows reads every row of an import file. Rows with unreadable fields
+// are returned with a parse error so they can be reported; only a file that
+// cannot be read at all fails.
+func ParseRows(format string, r io.Reader) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSON:
+		return parseJSON(r)
+	}
+	return nil, ErrInvalidFormat
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := index["handle"]; !ok {
+		return nil, fmt.Errorf("%w: missing handle column", ErrInvalidFile)
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, csvRow(line, record, index))
+	}
+	return rows, nil
+}
+
+// csvRow maps a CSV record to a row by the column names of the header.
+func csvRow(line int, record []string, index map[string]int) Row {
+	field := func(name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := Row{
+		Line:         line,
+		Handle:       field("handle"),
+		Title:        field("title"),
+		Description:  field("description"),
+		Category:     field("category"),
+		TaxClass:     field("tax_class"),
+		SKU:          field("sku"),
+		VariantTitle: field("variant_title"),
+	}
+
+	var errs []string
+	parseInt := func(name string) int64 {
+		value := field(name)
+		if value == "" {
+			return 0
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs = append(errs, name+" must be a whole number")
+		}
+		return n
+	}
+	row.Price = parseInt("price")
+	row.VariantPrice = parseInt("variant_price")
+	row.Weight = int(parseInt("weight"))
+	if published := field("published"); published != "" {
+		b, err := strconv.ParseBool(published)
+		if err != nil {
+			errs = append(errs, "published must be true or false")
+		}
+		row.Published = b
+	}
+	for _, tag := range strings.Split(field("tags"), "|") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			row.Tags = append(row.Tags, tag)
+		}
+	}
+	if options := field("options"); options != "" {
+		row.Options = map[string]string{}
+		for _, option := range strings.Split(options, "|") {
+			name, value, ok := strings.Cut(option, "=")
+			if !ok {
+				errs = append(errs, "options must look like Name=Value|Name=Value")
+				break
+			}
+			row.Options[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	if len(errs) > 0 {
+		row.parseErr = errors.New(strings.Join(errs, "; "))
+	}
+	return row
+}
+
+func parseJSON(r io.Reader) ([]Row, error) {
+	var products []Product
+	if err := json.NewDecoder(r).Decode(&products); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	rows := []Row{}
+	for i, p := range products {
+		for _, row := range productRows(p) {
+			row.Line = i + 1
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// csvRecord is the inverse of csvRow.
+func csvRecord(row Row) []string {
+	options := make([]string, 0, len(row.Options))
+	for name, value := range row.Options {
+		options = append(options, name+"="+value)
+	}
+	sort.Strings(options)
+	return []string{
+		row.Handle,
+		row.Title,
+		row.Description,
+		strconv.FormatInt(row.Price, 10),
+		strconv.FormatBool(row.Published),
+		row.Category,
+		row.TaxClass,
+		strings.Join(row.Tags, "|"),
+		row.SKU,
+		row.VariantTitle,
+		strconv.FormatInt(row.VariantPrice, 10),
+		strconv.Itoa(row.Weight),
+		strings.Join(options, "|"),
+	}
+}
@@ -0,0 +1,404 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/search"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/validators"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Job statuses.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Row statuses.
+const (
+	RowCreated = "created"
+	RowUpdated = "updated"
+	RowFailed  = "failed"
+)
+
+// staleAfter is how long a running job may go without progress before
+// another worker takes it over, e.g. after a restart.
+const staleAfter = 10 * time.Minute
+
+// validate checks rows with the same rules and messages as request bodies.
+var validate = validators.NewValidator()
+
+// Enqueue stores an uploaded file as a queued import job. The file is parsed
+// up front so an unreadable file is refused right away.
+func Enqueue(db *gorm.DB, format, filename string, data []byte) (*types.ImportJob, error) {
+	rows, err := ParseRows(format, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidFile)
+	}
+	job := types.ImportJob{
+		Format:    format,
+		Filename:  filename,
+		Data:      data,
+		Status:    JobQueued,
+		TotalRows: len(rows),
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RunPending processes the queued import jobs of a tenant one after another
+// and returns how many it ran. main and tenantID are used to enforce the
+// product limit of the tenant's plan.
+func RunPending(main, db *gorm.DB, tenantID string, now time.Time) (int, error) {
+	ran := 0
+	for {
+		job, err := claim(db, now)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ran, nil
+		}
+		if err != nil {
+			return ran, err
+		}
+		ran++
+
+		if err := run(main, db, tenantID, job, now); err != nil {
+			db.Model(job).Updates(map[string]interface{}{
+				"status":      JobFailed,
+				"error":       err.Error(),
+				"finished_at": time.Now(),
+			})
+		}
+	}
+}
+
+// claim marks the oldest queued or stalled job as running. The update only
+// succeeds when the job is unchanged since it was read, so two workers never
+// run the same job.
+func claim(db *gorm.DB, now time.Time) (*types.ImportJob, error) {
+	for {
+		var job types.ImportJob
+		err := db.Where("status = ? OR (status = ? AND updated_at < ?)", JobQueued, JobRunning, now.Add(-staleAfter)).
+			Order("id").First(&job).Error
+		if err != nil {
+			return nil, err
+		}
+
+		res := db.Model(&types.ImportJob{}).
+			Where("id = ? AND status = ? AND updated_at = ?", job.ID, job.Status, job.UpdatedAt).
+			Updates(map[string]interface{}{
+				"status":     JobRunning,
+				"processed":  0,
+				"created":    0,
+				"updated":    0,
+				"failed":     0,
+				"error":      "",
+				"started_at": now,
+			})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return &job, db.First(&job, job.ID).Error
+		}
+	}
+}
+
+// run imports every row of job, one product at a time, recording the outcome
+// of each row and the job's progress as it goes.
+func run(main, db *gorm.DB, tenantID string, job *types.ImportJob, now time.Time) error {
+	rows, err := ParseRows(job.Format, bytes.NewReader(job.Data))
+	if err != nil {
+		return err
+	}
+	// a job taken over from a stalled worker starts from scratch
+	if err := db.Where("job_id = ?", job.ID).Delete(&types.ImportRow{}).Error; err != nil {
+		return err
+	}
+
+	imp := importer{main: main, db: db, tenantID: tenantID, now: now, job: job, seen: map[string]bool{}}
+	for _, group := range groupRows(rows) {
+		results := imp.importProduct(group)
+		if err := db.Create(&results).Error; err != nil {
+			return err
+		}
+
+		counts := map[string]int{}
+		for _, result := range results {
+			counts[result.Status]++
+		}
+		err := db.Model(job).Updates(map[string]interface{}{
+			"processed": gorm.Expr("processed + ?", len(results)),
+			"created":   gorm.Expr("created + ?", counts[RowCreated]),
+			"updated":   gorm.Expr("updated + ?", counts[RowUpdated]),
+			"failed":    gorm.Expr("failed + ?", counts[RowFailed]),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Model(job).Updates(map[string]interface{}{
+		"status":      JobCompleted,
+		"finished_at": time.Now(),
+	}).Error
+}
+
+// groupRows splits rows into the rows of each product, keyed by handle, in
+// the order the products first appear. Rows that leave the title empty take
+// the product fields from the first row of their product, so files that only
+// fill them in once per product import as expected.
+func groupRows(rows []Row) [][]Row {
+	order := []string{}
+	groups := map[string][]Row{}
+	for _, row := range rows {
+		group, ok := groups[row.Handle]
+		if !ok {
+			order = append(order, row.Handle)
+		}
+		if ok && row.Title == "" {
+			first := group[0]
+			row.Title = first.Title
+			row.Description = first.Description
+			row.Price = first.Price
+			row.Published = first.Published
+			row.Category = first.Category
+			row.TaxClass = first.TaxClass
+			row.Tags = first.Tags
+		}
+		groups[row.Handle] = append(group, row)
+	}
+
+	out := make([][]Row, 0, len(order))
+	for _, handle := range order {
+		out = append(out, groups[handle])
+	}
+	return out
+}
+
+type importer struct {
+	main     *gorm.DB
+	db       *gorm.DB
+	tenantID string
+	now      time.Time
+	job      *types.ImportJob
+	// seen holds the skus imported so far, to catch duplicates in the file
+	seen map[string]bool
+}
+
+// importProduct upserts the product of a group of rows and its variants by
+// sku. Invalid rows are skipped; the product fields come from the first
+// valid row. It returns the outcome of every row.
+func (imp *importer) importProduct(group []Row) []types.ImportRow {
+	results := make([]types.ImportRow, len(group))
+	valid := []int{}
+	for i, row := range group {
+		results[i] = types.ImportRow{JobID: imp.job.ID, Line: row.Line, Handle: row.Handle, SKU: row.SKU, Status: RowFailed}
+		if err := imp.check(row); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if row.SKU != "" {
+			imp.seen[row.SKU] = true
+		}
+		valid = append(valid, i)
+	}
+	if len(valid) == 0 {
+		return results
+	}
+
+	fail := func(message string) []types.ImportRow {
+		for _, i := range valid {
+			results[i].Status = RowFailed
+			results[i].Error = message
+		}
+		return results
+	}
+
+	first := group[valid[0]]
+	var categoryID, taxClassID *uint
+	if first.Category != "" {
+		category := types.Category{}
+		if err := imp.db.Where("handle = ?", first.Category).First(&category).Error; err != nil {
+			return fail("unknown category " + first.Category)
+		}
+		categoryID = &category.ID
+	}
+	if first.TaxClass != "" {
+		class := types.TaxClass{}
+		if err := imp.db.Where("code = ?", first.TaxClass).First(&class).Error; err != nil {
+			return fail("unknown tax class " + first.TaxClass)
+		}
+		taxClassID = &class.ID
+	}
+
+	var productID uint
+	err := imp.db.Transaction(func(tx *gorm.DB) error {
+		product := types.Product{}
+		err := tx.Unscoped().Where("handle = ?", first.Handle).First(&product).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		created := err != nil || product.DeletedAt.Valid
+		if created {
+			if err := billing.Check(imp.main, tx, imp.tenantID, billing.ResourceProducts, imp.now); err != nil {
+				return err
+			}
+		}
+
+		product.DeletedAt = gorm.DeletedAt{}
+		product.Title = first.Title
+		product.Handle = first.Handle
+		product.Description = first.Description
+		product.Price = first.Price
+		product.Published = first.Published
+		product.CategoryID = categoryID
+		product.TaxClassID = taxClassID
+		if err := tx.Unscoped().Save(&product).Error; err != nil {
+			return err
+		}
+		productID = product.ID
+
+		// replace tags
+		if err := tx.Where("product_id = ?", product.ID).Delete(&types.ProductTag{}).Error; err != nil {
+			return err
+		}
+		tags := []types.ProductTag{}
+		for _, name := range first.Tags {
+			tags = append(tags, types.ProductTag{ProductID: product.ID, Name: name})
+		}
+		if len(tags) > 0 {
+			if err := tx.Create(&tags).Error; err != nil {
+				return err
+			}
+		}
+
+		// upsert variants by sku, new ones go after the existing ones
+		var position int64
+		if err := tx.Model(&types.ProductVariant{}).Where("product_id = ?", product.ID).Count(&position).Error; err != nil {
+			return err
+		}
+		for _, i := range valid {
+			row := group[i]
+			results[i].Status = RowUpdated
+			if created {
+				results[i].Status = RowCreated
+			}
+			if row.SKU == "" {
+				continue
+			}
+
+			variant := types.ProductVariant{}
+			if err := tx.Unscoped().Where("sku = ?", row.SKU).First(&variant).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if variant.ID == 0 || variant.DeletedAt.Valid || variant.ProductID != product.ID {
+				results[i].Status = RowCreated
+				variant.Position = int(position)
+				position++
+			}
+			variant.DeletedAt = gorm.DeletedAt{}
+			variant.ProductID = product.ID
+			variant.SKU = row.SKU
+			variant.Title = row.VariantTitle
+			variant.Price = row.VariantPrice
+			variant.Weight = row.Weight
+			if err := tx.Unscoped().Save(&variant).Error; err != nil {
+				return err
+			}
+			if err := catalog.SetVariantOptions(tx, &variant, row.Options); err != nil {
+				return err
+			}
+		}
+
+		if err := catalog.SyncProductCollections(tx, product.ID); err != nil {
+			return err
+		}
+		if err := search.Index(tx, product.ID); err != nil {
+			return err
+		}
+		event := hook.ProductUpdated
+		if created {
+			event = hook.ProductCreated
+		}
+		return hook.PublishProduct(tx, event, product.ID)
+	})
+
+	var limitErr *billing.LimitError
+	if errors.As(err, &limitErr) {
+		return fail("plan limit reached: " + limitErr.Error())
+	}
+	if err != nil {
+		return fail("error saving product")
+	}
+	for _, i := range valid {
+		results[i].ProductID = &productID
+	}
+	return results
+}
+
+// check validates a row on its own and against the catalog: its sku may not
+// repeat within the file or belong to a product with another handle.
+func (imp *importer) check(row Row) error {
+	if row.parseErr != nil {
+		return row.parseErr
+	}
+	if err := validate.Validate(&row); err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			if messages, ok := httpErr.Message.(map[string]string); ok {
+				return errors.New(joinMessages(messages))
+			}
+		}
+		return err
+	}
+	if row.SKU == "" {
+		return nil
+	}
+	if imp.seen[row.SKU] {
+		return errors.New("sku appears more than once in the file")
+	}
+	var count int64
+	err := imp.db.Model(&types.ProductVariant{}).
+		Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+		Where("product_variants.sku = ? AND products.handle <> ?", row.SKU, row.Handle).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("sku belongs to another product")
+	}
+	return nil
+}
+
+// joinMessages flattens validation messages to "field: message; ..." in
+// field order.
+func joinMessages(messages map[string]string) string {
+	fields := make([]string, 0, len(messages))
+	for field := range messages {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+": "+messages[field])
+	}
+	return strings.Join(parts, "; ")
+}
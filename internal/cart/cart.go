@@ -0,0 +1,272 @@
+package cart
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// Cart statuses.
+const (
+	StatusOpen      = "open"
+	StatusMerged    = "merged"
+	StatusConverted = "converted"
+)
+
+// How long an untouched cart is kept before the cleanup job removes it.
+const (
+	GuestTTL    = 7 * 24 * time.Hour
+	CustomerTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrCartNotFound       = errors.New("cart not found")
+	ErrVariantUnavailable = errors.New("variant is not available")
+)
+
+// ByToken returns the open cart with the given token.
+func ByToken(db *gorm.DB, token string) (types.Cart, error) {
+	cart := types.Cart{}
+	if token == "" {
+		return cart, ErrCartNotFound
+	}
+	if err := db.Preload("Items").Where("token = ? AND status = ?", token, StatusOpen).First(&cart).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cart, ErrCartNotFound
+		}
+		return cart, err
+	}
+	return cart, nil
+}
+
+// ForUser returns the open cart of a customer, creating one if needed.
+func ForUser(db *gorm.DB, userID uint) (types.Cart, error) {
+	cart := types.Cart{}
+	err := db.Preload("Items").Where("user_id = ? AND status = ?", userID, StatusOpen).First(&cart).Error
+	if err == nil {
+		return cart, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cart, err
+	}
+	return New(db, &userID)
+}
+
+// New creates an empty cart. userID is nil for guest carts.
+func New(db *gorm.DB, userID *uint) (types.Cart, error) {
+	cart := types.Cart{
+		Token:  randomString.GenerateSecureToken(24),
+		UserID: userID,
+		Status: StatusOpen,
+	}
+	stamp(&cart, time.Now())
+	err := db.Create(&cart).Error
+	return cart, err
+}
+
+// Touch records activity on the cart and pushes back its expiry.
+func Touch(db *gorm.DB, cart *types.Cart) error {
+	stamp(cart, time.Now())
+	return db.Model(cart).Updates(map[string]interface{}{
+		"last_activity_at": cart.LastActivityAt,
+		"expires_at":       cart.ExpiresAt,
+	}).Error
+}
+
+func stamp(cart *types.Cart, now time.Time) {
+	cart.LastActivityAt = now
+	if cart.UserID != nil {
+		cart.ExpiresAt = now.Add(CustomerTTL)
+	} else {
+		cart.ExpiresAt = now.Add(GuestTTL)
+	}
+}
+
+// SetEmail records the address of a guest, so an abandoned cart can be
+// followed up.
+func SetEmail(db *gorm.DB, cart *types.Cart, email string) error {
+	cart.Email = strings.ToLower(strings.TrimSpace(email))
+	if err := db.Model(cart).Update("email", cart.Email).Error; err != nil {
+		return err
+	}
+	return Touch(db, cart)
+}
+
+// AddItem adds quantity units of a variant to the cart.
+func AddItem(db *gorm.DB, cart *types.Cart, variantID uint, quantity int) error {
+	current := 0
+	for _, item := range cart.Items {
+		if item.VariantID == variantID {
+			current = item.Quantity
+		}
+	}
+	return SetQuantity(db, cart, variantID, current+quantity)
+}
+
+// SetQuantity sets the quantity of a variant in the cart, removing the line
+// when quantity is zero or less. The variant must be for sale and in stock.
+func SetQuantity(db *gorm.DB, cart *types.Cart, variantID uint, quantity int) error {
+	if quantity <= 0 {
+		if err := db.Where("cart_id = ? AND variant_id = ?", cart.ID, variantID).Delete(&types.CartItem{}).Error; err != nil {
+			return err
+		}
+		return Touch(db, cart)
+	}
+
+	variant, product, err := loadVariant(db, variantID)
+	if err != nil {
+		return err
+	}
+	available, err := inventory.Available(db, variantID)
+	if err != nil {
+		return err
+	}
+	if quantity > available {
+		return inventory.ErrInsufficientStock
+	}
+
+	pricer, err := currency.NewPricer(db, cart.Currency)
+	if err != nil {
+		return err
+	}
+
+	item := types.CartItem{}
+	err = db.Where("cart_id = ? AND variant_id = ?", cart.ID, variantID).First(&item).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	item.CartID = cart.ID
+	item.VariantID = variantID
+	item.Quantity = quantity
+	item.UnitPrice = pricer.Price(variant.ID, catalog.VariantPrice(product, variant))
+	if err := db.Save(&item).Error; err != nil {
+		return err
+	}
+	return Touch(db, cart)
+}
+
+// SetCurrency switches the cart to an enabled currency and reprices its
+// lines in it.
+func SetCurrency(db *gorm.DB, cart *types.Cart, code string) error {
+	code = currency.NormalizeCode(code)
+	enabled, err := currency.Enabled(db, code)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currency.ErrCurrencyNotEnabled
+	}
+	pricer, err := currency.NewPricer(db, code)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range cart.Items {
+			item := &cart.Items[i]
+			variant, product, err := loadVariant(tx, item.VariantID)
+			if errors.Is(err, ErrVariantUnavailable) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			item.UnitPrice = pricer.Price(variant.ID, catalog.VariantPrice(product, variant))
+			if err := tx.Model(item).Update("unit_price", item.UnitPrice).Error; err != nil {
+				return err
+			}
+		}
+		cart.Currency = pricer.To
+		if err := tx.Model(cart).Update("currency", cart.Currency).Error; err != nil {
+			return err
+		}
+		return Touch(tx, cart)
+	})
+}
+
+// Merge moves the lines of a guest cart into a customer cart when the guest
+// logs in. Quantities of lines present in both are added up and capped at
+// the available stock; a line of the customer is never lowered or removed
+// by the merge, even when the stock has run out since. The guest cart is
+// closed afterwards.
+func Merge(db *gorm.DB, guest, customer *types.Cart) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		quantities := map[uint]int{}
+		for _, item := range customer.Items {
+			quantities[item.VariantID] = item.Quantity
+		}
+
+		for _, item := range guest.Items {
+			existing := quantities[item.VariantID]
+			available, err := inventory.Available(tx, item.VariantID)
+			if err != nil {
+				return err
+			}
+			quantity := min(existing+item.Quantity, available)
+			if quantity <= existing {
+				// nothing more can be added, the customer's line stays as it is
+				continue
+			}
+			err = SetQuantity(tx, customer, item.VariantID, quantity)
+			if err != nil && !errors.Is(err, ErrVariantUnavailable) {
+				return err
+			}
+		}
+
+		// codes entered as a guest carry over
+		if err := tx.Exec(`INSERT INTO cart_coupons (cart_id, code, created_at)
+			SELECT ?, code, created_at FROM cart_coupons WHERE cart_id = ?
+			ON CONFLICT DO NOTHING`, customer.ID, guest.ID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("cart_id = ?", guest.ID).Delete(&types.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(guest).Update("status", StatusMerged).Error
+	})
+}
+
+// ExpireAbandoned deletes open and merged carts whose expiry has passed and
+// returns how many were removed.
+func ExpireAbandoned(db *gorm.DB, now time.Time) (int64, error) {
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		statuses := []string{StatusOpen, StatusMerged}
+		expired := tx.Model(&types.Cart{}).Select("id").Where("status IN ? AND expires_at <= ?", statuses, now)
+		if err := tx.Where("cart_id IN (?)", expired).Delete(&types.CartItem{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("status IN ? AND expires_at <= ?", statuses, now).Delete(&types.Cart{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return removed, err
+}
+
+// loadVariant returns a variant together with its product, failing with
+// ErrVariantUnavailable when either is gone or the product is not published.
+func loadVariant(db *gorm.DB, variantID uint) (types.ProductVariant, types.Product, error) {
+	variant := types.ProductVariant{}
+	product := types.Product{}
+	if err := db.First(&variant, variantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return variant, product, ErrVariantUnavailable
+		}
+		return variant, product, err
+	}
+	if err := db.Where("published = ?", true).First(&product, variant.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return variant, product, ErrVariantUnavailable
+		}
+		return variant, product, err
+	}
+	return variant, product, nil
+}
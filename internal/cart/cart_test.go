@@ -0,0 +1,120 @@
+package cart
+
+import (
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// stock creates a published product with one variant and quantity units of
+// it on hand, and returns the variant and the location holding them.
+func stock(t *testing.T, db *gorm.DB, quantity int) (types.ProductVariant, types.StockLocation) {
+	t.Helper()
+	product := types.Product{Title: "Mug", Handle: "mug", Price: 1200, Published: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	variant := types.ProductVariant{ProductID: product.ID, SKU: "MUG-1", Price: 1200}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatal(err)
+	}
+	warehouse := types.Warehouse{Name: "Main", Code: "MAIN", Active: true}
+	if err := db.Create(&warehouse).Error; err != nil {
+		t.Fatal(err)
+	}
+	location := types.StockLocation{WarehouseID: warehouse.ID, Name: "Bin", Code: "BIN", Active: true}
+	if err := db.Create(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Adjust(db, types.StockMovement{VariantID: variant.ID, LocationID: location.ID, Quantity: quantity, Reason: inventory.ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+	return variant, location
+}
+
+// quantity returns the quantity of a variant in a cart, 0 without a line.
+func quantity(t *testing.T, db *gorm.DB, cart types.Cart, variantID uint) int {
+	t.Helper()
+	var items []types.CartItem
+	if err := db.Where("cart_id = ? AND variant_id = ?", cart.ID, variantID).Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 {
+		return 0
+	}
+	return items[0].Quantity
+}
+
+func TestMergeCapsAtStock(t *testing.T) {
+	db := testdb.Open(t)
+	variant, _ := stock(t, db, 5)
+
+	user := types.User{FullName: "Ada", Email: "ada@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	customer, err := ForUser(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddItem(db, &customer, variant.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+	guest, err := New(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddItem(db, &guest, variant.ID, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	customer, _ = ForUser(db, user.ID)
+	guest, _ = ByToken(db, guest.Token)
+	if err := Merge(db, &guest, &customer); err != nil {
+		t.Fatal(err)
+	}
+	if got := quantity(t, db, customer, variant.ID); got != 5 {
+		t.Errorf("merged quantity %d, want the 5 in stock", got)
+	}
+}
+
+func TestMergeKeepsLineOutOfStock(t *testing.T) {
+	db := testdb.Open(t)
+	variant, location := stock(t, db, 2)
+
+	user := types.User{FullName: "Ada", Email: "ada@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	customer, err := ForUser(db, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddItem(db, &customer, variant.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	guest, err := New(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddItem(db, &guest, variant.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last units are written off before the guest signs in
+	if err := inventory.Adjust(db, types.StockMovement{VariantID: variant.ID, LocationID: location.ID, Quantity: -2, Reason: inventory.ReasonDamaged}); err != nil {
+		t.Fatal(err)
+	}
+
+	customer, _ = ForUser(db, user.ID)
+	guest, _ = ByToken(db, guest.Token)
+	if err := Merge(db, &guest, &customer); err != nil {
+		t.Fatal(err)
+	}
+	if got := quantity(t, db, customer, variant.ID); got != 2 {
+		t.Errorf("customer line has %d units after merging out of stock, want the 2 it had", got)
+	}
+}
@@ -0,0 +1,39 @@
+package cart
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownCode = errors.New("unknown discount code")
+
+// ApplyCode enters a coupon code on the cart. Whether it actually applies is
+// decided every time the cart is priced, the summary explains why not.
+func ApplyCode(db *gorm.DB, cart *types.Cart, code string) error {
+	code = promotion.NormalizeCode(code)
+	var count int64
+	if err := db.Model(&types.Promotion{}).Where("code = ?", code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnknownCode
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.CartCoupon{CartID: cart.ID, Code: code}).Error; err != nil {
+		return err
+	}
+	return Touch(db, cart)
+}
+
+// RemoveCode takes a coupon code off the cart.
+func RemoveCode(db *gorm.DB, cart *types.Cart, code string) error {
+	if err := db.Where("cart_id = ? AND code = ?", cart.ID, promotion.NormalizeCode(code)).
+		Delete(&types.CartCoupon{}).Error; err != nil {
+		return err
+	}
+	return Touch(db, cart)
+}
@@ -0,0 +1,161 @@
+package cart
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+type (
+	// Line is a cart item priced at the current catalog price.
+	Line struct {
+		VariantID         uint   `json:"variant_id"`
+		ProductID         uint   `json:"product_id"`
+		SKU               string `json:"sku"`
+		Title             string `json:"title"`
+		Quantity          int    `json:"quantity"`
+		UnitPrice         int64  `json:"unit_price"`
+		PreviousUnitPrice int64  `json:"previous_unit_price,omitempty"`
+		LineTotal         int64  `json:"line_total"`
+		Discount          int64  `json:"discount"`
+		Available         int    `json:"available"`
+		Purchasable       bool   `json:"purchasable"`
+		Notice            string `json:"notice,omitempty"`
+	}
+	// Summary is the priced view of a cart returned to the storefront, in
+	// minor units of Currency. Discounts explains every promotion applied, Rejected every entered
+	// code that did not apply.
+	Summary struct {
+		Token         string               `json:"token"`
+		UserID        *uint                `json:"user_id,omitempty"`
+		Email         string               `json:"email,omitempty"`
+		Status        string               `json:"status"`
+		Currency      string               `json:"currency"`
+		Lines         []Line               `json:"lines"`
+		ItemCount     int                  `json:"item_count"`
+		Subtotal      int64                `json:"subtotal"`
+		Discounts     []promotion.Applied  `json:"discounts"`
+		Rejected      []promotion.Rejected `json:"rejected_codes,omitempty"`
+		DiscountTotal int64                `json:"discount_total"`
+		FreeShipping  bool                 `json:"free_shipping"`
+		Total         int64                `json:"total"`
+		ExpiresAt     time.Time            `json:"expires_at"`
+	}
+)
+
+// Calculate prices every line at the current catalog price in the cart's
+// currency and checks it
+// against available stock. Lines whose price changed since the customer last
+// saw them carry the previous price, and the stored price is refreshed.
+func Calculate(db *gorm.DB, cart types.Cart) (Summary, error) {
+	pricer, err := currency.NewPricer(db, cart.Currency)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary := Summary{
+		Token:     cart.Token,
+		UserID:    cart.UserID,
+		Email:     cart.Email,
+		Status:    cart.Status,
+		Currency:  pricer.To,
+		Lines:     []Line{},
+		Discounts: []promotion.Applied{},
+		ExpiresAt: cart.ExpiresAt,
+	}
+
+	for _, item := range cart.Items {
+		line := Line{
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		}
+
+		variant, product, err := loadVariant(db, item.VariantID)
+		if errors.Is(err, ErrVariantUnavailable) {
+			line.Notice = "no longer available"
+			summary.Lines = append(summary.Lines, line)
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+		line.ProductID = product.ID
+		line.SKU = variant.SKU
+		line.Title = product.Title
+		if variant.Title != "" {
+			line.Title += " - " + variant.Title
+		}
+
+		price := pricer.Price(variant.ID, catalog.VariantPrice(product, variant))
+		if price != item.UnitPrice {
+			line.PreviousUnitPrice = item.UnitPrice
+			line.UnitPrice = price
+			line.Notice = "price changed"
+			if err := db.Model(&item).Update("unit_price", price).Error; err != nil {
+				return summary, err
+			}
+		}
+		line.LineTotal = line.UnitPrice * int64(line.Quantity)
+
+		line.Available, err = inventory.Available(db, item.VariantID)
+		if err != nil {
+			return summary, err
+		}
+		line.Purchasable = line.Available >= line.Quantity
+		if !line.Purchasable {
+			line.Notice = "not enough stock"
+		}
+
+		summary.Lines = append(summary.Lines, line)
+		summary.ItemCount += line.Quantity
+		summary.Subtotal += line.LineTotal
+	}
+	return summary, ApplyPromotions(db, &summary, cart, "")
+}
+
+// ApplyPromotions evaluates the automatic promotions and the codes entered
+// on the cart against the priced lines. The email identifies a guest for
+// per customer limits once it is known, at checkout.
+func ApplyPromotions(db *gorm.DB, summary *Summary, cart types.Cart, email string) error {
+	var codes []string
+	if err := db.Model(&types.CartCoupon{}).Where("cart_id = ?", cart.ID).Pluck("code", &codes).Error; err != nil {
+		return err
+	}
+
+	in := promotion.Input{UserID: cart.UserID, Email: email, Codes: codes, Currency: summary.Currency}
+	var indexes []int
+	for i, line := range summary.Lines {
+		if line.ProductID == 0 {
+			// no longer available, nothing to discount
+			continue
+		}
+		in.Lines = append(in.Lines, promotion.Line{
+			VariantID: line.VariantID,
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			LineTotal: line.LineTotal,
+		})
+		indexes = append(indexes, i)
+	}
+
+	res, err := promotion.Evaluate(db, in)
+	if err != nil {
+		return err
+	}
+	for i, discount := range res.LineDiscounts {
+		summary.Lines[indexes[i]].Discount = discount
+	}
+	summary.Discounts = res.Applied
+	summary.Rejected = res.Rejected
+	summary.DiscountTotal = res.DiscountTotal
+	summary.FreeShipping = res.FreeShipping
+	summary.Total = summary.Subtotal - summary.DiscountTotal
+	return nil
+}
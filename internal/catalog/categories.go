@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryCycle    = errors.New("category cannot be moved into its own subtree")
+	ErrNotSiblings      = errors.New("all categories must share the same parent")
+	ErrCategoryNotEmpty = errors.New("category has subcategories")
+)
+
+// CategoryNode is a category together with its children, as returned by Tree.
+type CategoryNode struct {
+	types.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+func categoryPath(parent *types.Category, id uint) string {
+	if parent == nil {
+		return fmt.Sprintf("/%d/", id)
+	}
+	return fmt.Sprintf("%s%d/", parent.Path, id)
+}
+
+// CreateCategory inserts a category under parentID (nil for a root category)
+// as the last of its siblings and fills in its path.
+func CreateCategory(db *gorm.DB, category *types.Category) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var parent *types.Category
+		if category.ParentID != nil {
+			parent = &types.Category{}
+			if err := tx.First(parent, *category.ParentID).Error; err != nil {
+				return err
+			}
+			category.Depth = parent.Depth + 1
+		}
+
+		var position int
+		if err := siblings(tx, category.ParentID).Model(&types.Category{}).Select("COALESCE(MAX(position), -1) + 1").Scan(&position).Error; err != nil {
+			return err
+		}
+		category.Position = position
+
+		// the path contains the id, so it can only be set after the insert
+		category.Path = "/"
+		if err := tx.Create(category).Error; err != nil {
+			return err
+		}
+		category.Path = categoryPath(parent, category.ID)
+		return tx.Model(category).Update("path", category.Path).Error
+	})
+}
+
+// MoveCategory re-parents a category, carrying its whole subtree along, and
+// places it at position among its new siblings. The siblings it leaves
+// behind close up the gap.
+func MoveCategory(db *gorm.DB, id uint, parentID *uint, position int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var category types.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+
+		var parent *types.Category
+		depth := 0
+		if parentID != nil {
+			parent = &types.Category{}
+			if err := tx.First(parent, *parentID).Error; err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path, category.Path) {
+				return ErrCategoryCycle
+			}
+			depth = parent.Depth + 1
+		}
+
+		oldParentID := category.ParentID
+		oldPath := category.Path
+		newPath := categoryPath(parent, category.ID)
+		if oldPath != newPath {
+			// rewrite the path prefix and depth of the category and all its descendants
+			if err := tx.Model(&types.Category{}).
+				Where("path LIKE ?", oldPath+"%").
+				Updates(map[string]interface{}{
+					"path":  gorm.Expr("? || SUBSTRING(path FROM ?)", newPath, len(oldPath)+1),
+					"depth": gorm.Expr("depth + ?", depth-category.Depth),
+				}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&category).Update("parent_id", parentID).Error; err != nil {
+				return err
+			}
+
+			// renumber the old siblings
+			var left []uint
+			if err := siblings(tx, oldParentID).Model(&types.Category{}).
+				Where("id <> ?", category.ID).
+				Order("position, id").
+				Pluck("id", &left).Error; err != nil {
+				return err
+			}
+			if err := setPositions(tx, left); err != nil {
+				return err
+			}
+		}
+
+		// place the category among its new siblings
+		var ids []uint
+		if err := siblings(tx, parentID).Model(&types.Category{}).
+			Where("id <> ?", category.ID).
+			Order("position, id").
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if position < 0 || position > len(ids) {
+			position = len(ids)
+		}
+		ids = append(ids[:position], append([]uint{category.ID}, ids[position:]...)...)
+		return setPositions(tx, ids)
+	})
+}
+
+// ReorderCategories sets the order of the children of parentID. ids must list
+// categories that share that parent.
+func ReorderCategories(db *gorm.DB, parentID *uint, ids []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := siblings(tx, parentID).Model(&types.Category{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(ids) {
+			return ErrNotSiblings
+		}
+		return setPositions(tx, ids)
+	})
+}
+
+// DeleteCategory removes a leaf category and detaches its products.
+func DeleteCategory(db *gorm.DB, id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.Category{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCategoryNotEmpty
+		}
+		if err := tx.Model(&types.Product{}).Where("category_id = ?", id).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&types.ProductSearchDocument{}).Where("category_id = ?", id).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.Category{}, id).Error
+	})
+}
+
+// Tree loads every category and assembles them into a forest of root nodes.
+func Tree(db *gorm.DB) ([]*CategoryNode, error) {
+	var categories []types.Category
+	if err := db.Order("depth, position, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := &CategoryNode{Category: category, Children: []*CategoryNode{}}
+		nodes[category.ID] = node
+		if category.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return roots, nil
+}
+
+// SubtreeIDs returns the id of the category and all of its descendants.
+func SubtreeIDs(db *gorm.DB, id uint) ([]uint, error) {
+	var category types.Category
+	if err := db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	var ids []uint
+	err := db.Model(&types.Category{}).Where("path LIKE ?", category.Path+"%").Pluck("id", &ids).Error
+	return ids, err
+}
+
+func siblings(db *gorm.DB, parentID *uint) *gorm.DB {
+	if parentID == nil {
+		return db.Where("parent_id IS NULL")
+	}
+	return db.Where("parent_id = ?", *parentID)
+}
+
+func setPositions(db *gorm.DB, ids []uint) error {
+	for position, id := range ids {
+		if err := db.Model(&types.Category{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
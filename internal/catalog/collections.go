@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// FactsFor builds the rule engine view of a product. Tags and variants must
+// be preloaded.
+func FactsFor(product types.Product) ProductFacts {
+	facts := ProductFacts{
+		Title: product.Title,
+		Price: product.Price,
+	}
+	if product.CategoryID != nil {
+		facts.CategoryID = *product.CategoryID
+	}
+	for _, tag := range product.Tags {
+		facts.Tags = append(facts.Tags, tag.Name)
+	}
+	for _, variant := range product.Variants {
+		facts.SKUs = append(facts.SKUs, variant.SKU)
+	}
+	return facts
+}
+
+// SyncProductCollections re-evaluates every smart collection against a single
+// product and adds or removes the product accordingly. It is called whenever
+// a product is created or updated.
+func SyncProductCollections(db *gorm.DB, productID uint) error {
+	var product types.Product
+	if err := db.Preload("Tags").Preload("Variants").First(&product, productID).Error; err != nil {
+		return err
+	}
+	facts := FactsFor(product)
+
+	var collections []types.Collection
+	if err := db.Where("smart = ?", true).Find(&collections).Error; err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		rules, err := ParseRules(collection.Rules)
+		if err != nil {
+			// rules are validated on save, skip anything that slipped through
+			continue
+		}
+
+		if !rules.Match(facts) {
+			if err := db.Where("collection_id = ? AND product_id = ?", collection.ID, productID).Delete(&types.CollectionProduct{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		var count int64
+		if err := db.Model(&types.CollectionProduct{}).Where("collection_id = ? AND product_id = ?", collection.ID, productID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		var position int
+		if err := db.Model(&types.CollectionProduct{}).Where("collection_id = ?", collection.ID).Select("COALESCE(MAX(position), -1) + 1").Scan(&position).Error; err != nil {
+			return err
+		}
+		if err := db.Create(&types.CollectionProduct{
+			CollectionID: collection.ID,
+			ProductID:    productID,
+			Position:     position,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildCollection evaluates a smart collection's rules over the whole
+// catalog and replaces its membership with the result.
+func RebuildCollection(db *gorm.DB, collection types.Collection) error {
+	rules, err := ParseRules(collection.Rules)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&types.CollectionProduct{}).Error; err != nil {
+			return err
+		}
+
+		position := 0
+		var products []types.Product
+		return tx.Preload("Tags").Preload("Variants").Order("id").FindInBatches(&products, 200, func(batch *gorm.DB, _ int) error {
+			var members []types.CollectionProduct
+			for _, product := range products {
+				if !rules.Match(FactsFor(product)) {
+					continue
+				}
+				members = append(members, types.CollectionProduct{
+					CollectionID: collection.ID,
+					ProductID:    product.ID,
+					Position:     position,
+				})
+				position++
+			}
+			if len(members) == 0 {
+				return nil
+			}
+			return tx.Create(&members).Error
+		}).Error
+	})
+}
+
+// RemoveProduct drops a product from every collection.
+func RemoveProduct(db *gorm.DB, productID uint) error {
+	return db.Where("product_id = ?", productID).Delete(&types.CollectionProduct{}).Error
+}
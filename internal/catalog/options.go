@@ -0,0 +1,29 @@
+package catalog
+
+import (
+	"sort"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// SetVariantOptions replaces the option values of a variant, e.g.
+// {"Color": "Red"}, and stores them on variant.Options in name order.
+func SetVariantOptions(db *gorm.DB, variant *types.ProductVariant, options map[string]string) error {
+	if err := db.Where("variant_id = ?", variant.ID).Delete(&types.ProductVariantOption{}).Error; err != nil {
+		return err
+	}
+	variant.Options = nil
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		variant.Options = append(variant.Options, types.ProductVariantOption{VariantID: variant.ID, Name: name, Value: options[name]})
+	}
+	if len(variant.Options) == 0 {
+		return nil
+	}
+	return db.Create(&variant.Options).Error
+}
@@ -0,0 +1,14 @@
+package catalog
+
+import (
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+// VariantPrice returns the selling price of a variant in minor units. A
+// variant without its own price sells at the product price.
+func VariantPrice(product types.Product, variant types.ProductVariant) int64 {
+	if variant.Price > 0 {
+		return variant.Price
+	}
+	return product.Price
+}
@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Rule is a single smart collection condition such as `price < 50`.
+type Rule struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// RuleSet is a disjunction of conjunctions: `a AND b OR c` parses into
+// [[a b] [c]], AND binding tighter than OR.
+type RuleSet [][]Rule
+
+// ProductFacts is what the rule engine knows about a product.
+type ProductFacts struct {
+	Title      string
+	Price      int64
+	Tags       []string
+	SKUs       []string
+	CategoryID uint
+}
+
+// supported fields and the operators each of them accepts
+var ruleOperators = map[string][]string{
+	"tag":      {"=", "!="},
+	"sku":      {"=", "!="},
+	"title":    {"=", "!=", "contains"},
+	"category": {"=", "!="},
+	"price":    {"=", "!=", "<", "<=", ">", ">="},
+}
+
+// ParseRules parses a rule expression such as `tag = summer AND price < 50`.
+// Values containing spaces can be quoted. Prices are written in major units
+// and compared against the minor unit price of the product.
+func ParseRules(expr string) (RuleSet, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("rules are empty")
+	}
+
+	var set RuleSet
+	var group []Rule
+	for i := 0; i < len(tokens); {
+		if i+3 > len(tokens) {
+			return nil, fmt.Errorf("incomplete condition %q", strings.Join(tokens[i:], " "))
+		}
+		rule := Rule{
+			Field:    strings.ToLower(tokens[i]),
+			Operator: strings.ToLower(tokens[i+1]),
+			Value:    tokens[i+2],
+		}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		group = append(group, rule)
+		i += 3
+
+		if i == len(tokens) {
+			break
+		}
+		switch strings.ToUpper(tokens[i]) {
+		case "AND":
+		case "OR":
+			set = append(set, group)
+			group = nil
+		default:
+			return nil, fmt.Errorf("expected AND or OR, got %q", tokens[i])
+		}
+		i++
+		if i == len(tokens) {
+			return nil, fmt.Errorf("rules end with %q", tokens[i-1])
+		}
+	}
+	set = append(set, group)
+
+	return set, nil
+}
+
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		ch := expr[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expr[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+			tokens = append(tokens, expr[i+1:i+1+end])
+			i += end + 2
+		case strings.IndexByte("=!<>", ch) >= 0:
+			j := i + 1
+			if j < len(expr) && expr[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(expr) && strings.IndexByte(" \t\r\n=!<>\"'", expr[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func (r Rule) validate() error {
+	operators, ok := ruleOperators[r.Field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", r.Field)
+	}
+	supported := false
+	for _, op := range operators {
+		if op == r.Operator {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("operator %q is not supported for %s", r.Operator, r.Field)
+	}
+
+	switch r.Field {
+	case "price":
+		if _, err := parsePrice(r.Value); err != nil {
+			return err
+		}
+	case "category":
+		if _, err := strconv.ParseUint(r.Value, 10, 64); err != nil {
+			return fmt.Errorf("category must be an id, got %q", r.Value)
+		}
+	}
+	return nil
+}
+
+// parsePrice converts a major unit price such as "49.99" into minor units.
+func parsePrice(value string) (int64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q", value)
+	}
+	return int64(math.Round(f * 100)), nil
+}
+
+// Match reports whether the product satisfies the rule set.
+func (rs RuleSet) Match(p ProductFacts) bool {
+	for _, group := range rs {
+		matched := true
+		for _, rule := range group {
+			if !rule.Match(p) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the product satisfies the rule.
+func (r Rule) Match(p ProductFacts) bool {
+	switch r.Field {
+	case "tag":
+		return containsFold(p.Tags, r.Value) == (r.Operator == "=")
+	case "sku":
+		return containsFold(p.SKUs, r.Value) == (r.Operator == "=")
+	case "title":
+		switch r.Operator {
+		case "contains":
+			return strings.Contains(strings.ToLower(p.Title), strings.ToLower(r.Value))
+		case "=":
+			return strings.EqualFold(p.Title, r.Value)
+		default:
+			return !strings.EqualFold(p.Title, r.Value)
+		}
+	case "category":
+		id, _ := strconv.ParseUint(r.Value, 10, 64)
+		return (p.CategoryID == uint(id)) == (r.Operator == "=")
+	case "price":
+		price, _ := parsePrice(r.Value)
+		switch r.Operator {
+		case "=":
+			return p.Price == price
+		case "!=":
+			return p.Price != price
+		case "<":
+			return p.Price < price
+		case "<=":
+			return p.Price <= price
+		case ">":
+			return p.Price > price
+		case ">=":
+			return p.Price >= price
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to code that schedules work, so tests can drive the
+// schedule with a Manual clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type (
+	systemClock struct{}
+
+	// Manual is a clock that only moves when told to.
+	Manual struct {
+		mu  sync.Mutex
+		now time.Time
+	}
+)
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewManual returns a clock stopped at now.
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Set moves the clock to now.
+func (m *Manual) Set(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = now
+}
+
+// Advance moves the clock d forward.
+func (m *Manual) Advance(d time.Duration) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	return m.now
+}
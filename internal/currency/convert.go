@@ -0,0 +1,94 @@
+package currency
+
+import (
+	"math/big"
+
+	"github.com/Satishcg12/multicommers/internal/tax"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Converter turns amounts in minor units of From into minor units of To.
+// The zero Converter leaves amounts alone.
+type Converter struct {
+	From      string
+	To        string
+	rate      *big.Rat
+	factor    *big.Rat
+	mode      string
+	increment int64
+}
+
+// For returns the converter between two currencies, an empty code standing
+// for the base currency. Amounts are rounded with the rounding rule of the
+// target currency, or else of the settings.
+func For(db *gorm.DB, from, to string) (Converter, error) {
+	settings, err := Settings(db)
+	if err != nil {
+		return Converter{}, err
+	}
+	if from == "" {
+		from = settings.BaseCurrency
+	}
+	if to == "" {
+		to = settings.BaseCurrency
+	}
+	conv := Converter{From: from, To: to, mode: settings.RoundingMode}
+	if from == to {
+		return conv, nil
+	}
+
+	rate, err := Rate(db, from, to)
+	if err != nil {
+		return conv, err
+	}
+	target := types.Currency{}
+	if err := db.Where("code = ?", to).Limit(1).Find(&target).Error; err != nil {
+		return conv, err
+	}
+	if tax.ValidRoundingMode(target.RoundingMode) {
+		conv.mode = target.RoundingMode
+	}
+	return newConverter(from, to, rate, conv.mode, target.RoundingIncrement), nil
+}
+
+// newConverter returns the converter applying rate between two currencies,
+// rounding with mode to increment minor units of to.
+func newConverter(from, to string, rate *big.Rat, mode string, increment int64) Converter {
+	// minor units of both sides may differ, 1 JPY is 1 but 1 USD is 100
+	factor := new(big.Rat).Mul(rate, new(big.Rat).SetFrac(
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Decimals(to))), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(Decimals(from))), nil),
+	))
+	return Converter{From: from, To: to, rate: rate, factor: factor, mode: mode, increment: increment}
+}
+
+// FromBase returns the converter from the base currency to code.
+func FromBase(db *gorm.DB, code string) (Converter, error) {
+	return For(db, "", code)
+}
+
+// Rate returns the exchange rate applied, one unit of From in units of To.
+func (c Converter) Rate() string {
+	if c.rate == nil {
+		return "1"
+	}
+	return c.rate.FloatString(12)
+}
+
+// Convert converts amount, rounding to a whole minor unit and then to the
+// rounding increment of the target currency.
+func (c Converter) Convert(amount int64) int64 {
+	if c.factor == nil {
+		return amount
+	}
+	if amount < 0 {
+		return -c.Convert(-amount)
+	}
+
+	converted := tax.Round(new(big.Rat).Mul(big.NewRat(amount, 1), c.factor), c.mode)
+	if c.increment > 1 {
+		converted = tax.Round(big.NewRat(converted, c.increment), c.mode) * c.increment
+	}
+	return converted
+}
@@ -0,0 +1,49 @@
+package currency
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/tax"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		name      string
+		from, to  string
+		rate      *big.Rat
+		mode      string
+		increment int64
+		amount    int64
+		want      int64
+	}{
+		{"same minor units", "USD", "EUR", big.NewRat(92, 100), tax.RoundHalfUp, 0, 1000, 920},
+		{"rounded half up", "USD", "EUR", big.NewRat(925, 1000), tax.RoundHalfUp, 0, 1, 1},
+		{"rounded half even", "USD", "EUR", big.NewRat(5, 10), tax.RoundHalfEven, 0, 5, 2},
+		{"rounded down", "USD", "EUR", big.NewRat(99, 100), tax.RoundDown, 0, 199, 197},
+		{"to a currency without minor units", "USD", "JPY", big.NewRat(15025, 100), tax.RoundHalfUp, 0, 1999, 3003},
+		{"from a currency without minor units", "JPY", "USD", big.NewRat(1, 150), tax.RoundHalfUp, 0, 3000, 2000},
+		{"to three decimals", "USD", "KWD", big.NewRat(307, 1000), tax.RoundHalfUp, 0, 1000, 3070},
+		{"to a rounding increment", "EUR", "CHF", big.NewRat(96, 100), tax.RoundHalfUp, 5, 1000, 960},
+		{"up to a rounding increment", "EUR", "CHF", big.NewRat(96, 100), tax.RoundHalfUp, 5, 1003, 965},
+		{"negative amounts mirror positive ones", "USD", "EUR", big.NewRat(925, 1000), tax.RoundHalfUp, 0, -1, -1},
+	}
+	for _, c := range cases {
+		conv := newConverter(c.from, c.to, c.rate, c.mode, c.increment)
+		if got := conv.Convert(c.amount); got != c.want {
+			t.Errorf("%s: %d %s is %d %s, want %d", c.name, c.amount, c.from, got, c.to, c.want)
+		}
+	}
+
+	if got := (Converter{}).Convert(1234); got != 1234 {
+		t.Errorf("the zero converter turned 1234 into %d", got)
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	for code, want := range map[string]int{"USD": 2, "EUR": 2, "JPY": 0, "KRW": 0, "KWD": 3, "XYZ": 2} {
+		if got := Decimals(code); got != want {
+			t.Errorf("%s has %d decimals, want %d", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package currency
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/tax"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/dotenv"
+	"gorm.io/gorm"
+)
+
+// Price list modes.
+const (
+	ModeOverride = "override"
+	ModeConvert  = "convert"
+)
+
+var (
+	ErrInvalidCode        = errors.New("invalid currency code")
+	ErrCurrencyNotEnabled = errors.New("currency is not enabled")
+	ErrNoExchangeRate     = errors.New("no exchange rate between the currencies")
+	ErrInvalidMode        = errors.New("invalid price list mode")
+)
+
+// minorUnits lists the currencies whose minor unit is not a hundredth of
+// the major one.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Decimals returns how many decimal places the minor unit of code has.
+func Decimals(code string) int {
+	if decimals, ok := minorUnits[code]; ok {
+		return decimals
+	}
+	return 2
+}
+
+// NormalizeCode upper-cases a currency code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidCode reports whether code looks like an ISO 4217 code.
+func ValidCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidMode reports whether mode is a known price list mode.
+func ValidMode(mode string) bool {
+	return mode == ModeOverride || mode == ModeConvert
+}
+
+// Settings returns the currency settings of the tenant, or the defaults when
+// none were saved: STORE_CURRENCY as base currency, rounding half up.
+func Settings(db *gorm.DB) (types.CurrencySettings, error) {
+	settings := types.CurrencySettings{}
+	err := db.Order("id").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.CurrencySettings{
+			BaseCurrency: NormalizeCode(dotenv.GetEnvOrDefault("STORE_CURRENCY", "USD")),
+			RoundingMode: tax.RoundHalfUp,
+		}, nil
+	}
+	return settings, err
+}
+
+// Base returns the currency catalog prices are entered in.
+func Base(db *gorm.DB) (string, error) {
+	settings, err := Settings(db)
+	return settings.BaseCurrency, err
+}
+
+// Enabled reports whether customers may shop in code. The base currency is
+// always enabled.
+func Enabled(db *gorm.DB, code string) (bool, error) {
+	base, err := Base(db)
+	if err != nil || code == base {
+		return err == nil, err
+	}
+	var count int64
+	err = db.Model(&types.Currency{}).Where("code = ? AND enabled = ?", code, true).Count(&count).Error
+	return count > 0, err
+}
+
+// Available returns the base currency followed by the enabled presentment
+// currencies.
+func Available(db *gorm.DB) ([]string, error) {
+	base, err := Base(db)
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	if err := db.Model(&types.Currency{}).Where("enabled = ? AND code <> ?", true, base).Pluck("code", &codes).Error; err != nil {
+		return nil, err
+	}
+	sort.Strings(codes)
+	return append([]string{base}, codes...), nil
+}
@@ -0,0 +1,46 @@
+package currency
+
+import (
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Pricer prices variants in a presentment currency through its price list.
+type Pricer struct {
+	Converter
+	overrides map[uint]int64
+}
+
+// NewPricer returns the pricer for code, the base currency when empty.
+// Without an active price list every base price is converted.
+func NewPricer(db *gorm.DB, code string) (Pricer, error) {
+	conv, err := FromBase(db, code)
+	if err != nil {
+		return Pricer{}, err
+	}
+	pricer := Pricer{Converter: conv, overrides: map[uint]int64{}}
+
+	list := types.PriceList{}
+	if err := db.Where("currency = ? AND active = ?", conv.To, true).Limit(1).Find(&list).Error; err != nil {
+		return pricer, err
+	}
+	if list.ID == 0 || list.Mode != ModeOverride {
+		return pricer, nil
+	}
+	var entries []types.PriceListEntry
+	if err := db.Where("price_list_id = ?", list.ID).Find(&entries).Error; err != nil {
+		return pricer, err
+	}
+	for _, entry := range entries {
+		pricer.overrides[entry.VariantID] = entry.Price
+	}
+	return pricer, nil
+}
+
+// Price returns the price of a variant whose base price is base.
+func (p Pricer) Price(variantID uint, base int64) int64 {
+	if price, ok := p.overrides[variantID]; ok {
+		return price
+	}
+	return p.Convert(base)
+}
@@ -0,0 +1,119 @@
+package currency
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidRateFile = errors.New("invalid exchange rate file")
+
+// ImportRates loads exchange rates from a CSV file of from,to,rate records,
+// e.g. "USD,EUR,0.92", with an optional header line. Rates already known for
+// a pair are replaced. The whole file is rejected when a record is invalid.
+func ImportRates(db *gorm.DB, r io.Reader, source string) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	now := time.Now()
+	var rates []types.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidRateFile, err)
+		}
+
+		from, to := NormalizeCode(record[0]), NormalizeCode(record[1])
+		rate, ok := new(big.Rat).SetString(record[2])
+		if !ok && line == 1 {
+			// header
+			continue
+		}
+		if !ok || rate.Sign() <= 0 || !ValidCode(from) || !ValidCode(to) || from == to {
+			return 0, fmt.Errorf("%w: line %d", ErrInvalidRateFile, line)
+		}
+		rates = append(rates, types.ExchangeRate{
+			FromCurrency: from,
+			ToCurrency:   to,
+			Rate:         rate.FloatString(12),
+			Source:       source,
+			ImportedAt:   now,
+		})
+	}
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("%w: no rates", ErrInvalidRateFile)
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "imported_at"}),
+	}).Create(&rates).Error
+	return len(rates), err
+}
+
+// Rate returns how many units of to one unit of from buys. Pairs missing
+// from the table are derived from the opposite pair or through the base
+// currency.
+func Rate(db *gorm.DB, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	rate, err := pairRate(db, from, to)
+	if !errors.Is(err, ErrNoExchangeRate) {
+		return rate, err
+	}
+
+	base, err := Base(db)
+	if err != nil {
+		return nil, err
+	}
+	if from == base || to == base {
+		return nil, ErrNoExchangeRate
+	}
+	toBase, err := pairRate(db, from, base)
+	if err != nil {
+		return nil, err
+	}
+	fromBase, err := pairRate(db, base, to)
+	if err != nil {
+		return nil, err
+	}
+	return toBase.Mul(toBase, fromBase), nil
+}
+
+// pairRate looks a pair up directly or as the inverse of the opposite pair.
+func pairRate(db *gorm.DB, from, to string) (*big.Rat, error) {
+	var rates []types.ExchangeRate
+	if err := db.Where("(from_currency = ? AND to_currency = ?) OR (from_currency = ? AND to_currency = ?)", from, to, to, from).
+		Find(&rates).Error; err != nil {
+		return nil, err
+	}
+
+	var inverse *big.Rat
+	for _, stored := range rates {
+		rate, ok := new(big.Rat).SetString(stored.Rate)
+		if !ok || rate.Sign() <= 0 {
+			continue
+		}
+		if stored.FromCurrency == from {
+			return rate, nil
+		}
+		inverse = rate.Inv(rate)
+	}
+	if inverse == nil {
+		return nil, ErrNoExchangeRate
+	}
+	return inverse, nil
+}
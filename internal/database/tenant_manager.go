@@ -1,11 +1,13 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/Satishcg12/multicommers/internal/types"
 	"github.com/Satishcg12/multicommers/utils/dotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -16,12 +18,22 @@ type tenantInfo struct {
 	db         *gorm.DB
 	lastAccess time.Time
 	closeChan  chan struct{}
+	// ready is closed once the connection is open and migrated, err tells
+	// whether that worked
+	ready chan struct{}
+	err   error
 }
 
+// ErrUnknownTenant is returned for a tenant no vendor owns.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
 type DatabaseManager struct {
 	mu      sync.Mutex
 	tenants map[string]*tenantInfo
 	timeout time.Duration
+	models  []interface{}
+	setup   []func(db *gorm.DB) error
+	main    *gorm.DB
 }
 
 func NewDatabaseManager(timeout time.Duration) *DatabaseManager {
@@ -31,18 +43,113 @@ func NewDatabaseManager(timeout time.Duration) *DatabaseManager {
 	}
 }
 
-func (manager *DatabaseManager) GetDB(tenantID string) (*gorm.DB, error) {
+// RegisterTenantModels sets the models that are migrated when a tenant
+// database is created and at startup.
+func (manager *DatabaseManager) RegisterTenantModels(models ...interface{}) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.models = append(manager.models, models...)
+}
+
+// RegisterTenantSetup adds a function that runs against a tenant database
+// right after its models are migrated, for schema objects AutoMigrate cannot
+// express such as extensions and custom indexes.
+func (manager *DatabaseManager) RegisterTenantSetup(fn func(db *gorm.DB) error) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
+	manager.setup = append(manager.setup, fn)
+}
+
+// runSetup calls every registered setup function against db.
+func (manager *DatabaseManager) runSetup(db *gorm.DB) error {
+	manager.mu.Lock()
+	setup := append([]func(db *gorm.DB) error{}, manager.setup...)
+	manager.mu.Unlock()
+
+	for _, fn := range setup {
+		if err := fn(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate brings the tenant schema up to date with the registered models
+// and extra, then runs the setup functions.
+func (manager *DatabaseManager) migrate(db *gorm.DB, extra ...interface{}) error {
+	manager.mu.Lock()
+	models := append(append([]interface{}{}, manager.models...), extra...)
+	manager.mu.Unlock()
+
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			return err
+		}
+	}
+	return manager.runSetup(db)
+}
 
-	// If the connection already exists, return it and update the last access time
-	if tenant, exists := manager.tenants[tenantID]; exists {
-		// Reset the timeout
-		tenant.closeChan <- struct{}{}
+// GetDB returns the connection to a tenant database, opening it on first
+// use. Only tenants owned by a vendor are opened, ErrUnknownTenant is
+// returned for any other name. The schema is not migrated here: that happens
+// when the tenant is added and at startup. Opening happens outside the
+// manager lock so requests for other tenants are not held up by a slow
+// connection; concurrent requests for the same tenant wait for the one
+// opening it.
+func (manager *DatabaseManager) GetDB(tenantID string) (*gorm.DB, error) {
+	manager.mu.Lock()
+	tenant, exists := manager.tenants[tenantID]
+	if !exists {
+		tenant = &tenantInfo{
+			closeChan: make(chan struct{}, 1),
+			ready:     make(chan struct{}),
+		}
+		manager.tenants[tenantID] = tenant
+	}
+	manager.mu.Unlock()
+
+	// If the connection already exists, return it and reset the timeout
+	if exists {
+		<-tenant.ready
+		if tenant.err != nil {
+			return nil, tenant.err
+		}
+		select {
+		case tenant.closeChan <- struct{}{}:
+		default:
+			// a reset is already pending
+		}
 		return tenant.db, nil
 	}
 
 	// Create a new database connection for the tenant
+	db, err := manager.open(tenantID)
+
+	manager.mu.Lock()
+	tenant.db, tenant.err, tenant.lastAccess = db, err, time.Now()
+	if err != nil {
+		// forget the failed attempt so the next request tries again
+		delete(manager.tenants, tenantID)
+	}
+	manager.mu.Unlock()
+	close(tenant.ready)
+
+	if err != nil {
+		return nil, err
+	}
+	go manager.startTimeout(tenantID, tenant)
+	return db, nil
+}
+
+// open connects to the database of a known tenant.
+func (manager *DatabaseManager) open(tenantID string) (*gorm.DB, error) {
+	known, err := manager.TenantExists(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		return nil, ErrUnknownTenant
+	}
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
 		dotenv.GetEnvOrDefault("DB_HOST", "localhost"),
 		dotenv.GetEnvOrDefault("DB_PORT", "5432"),
@@ -50,22 +157,74 @@ func (manager *DatabaseManager) GetDB(tenantID string) (*gorm.DB, error) {
 		dotenv.GetEnvOrDefault("DB_PASSWORD", ""),
 		tenantID,
 	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
+
+// Tenants lists the tenants of the platform, one per vendor with a store.
+func (manager *DatabaseManager) Tenants() ([]string, error) {
+	main := manager.MainDB()
+	if main == nil {
+		return nil, errors.New("main database is not initialized")
 	}
+	var tenantIDs []string
+	err := main.Model(&types.Vendor{}).Where("tenant_id <> ''").
+		Distinct().Order("tenant_id").Pluck("tenant_id", &tenantIDs).Error
+	return tenantIDs, err
+}
 
-	// Create a close channel and start the timeout goroutine
-	closeChan := make(chan struct{})
-	tenant := &tenantInfo{
-		db:         db,
-		lastAccess: time.Now(),
-		closeChan:  closeChan,
+// TenantExists reports whether a vendor owns tenantID.
+func (manager *DatabaseManager) TenantExists(tenantID string) (bool, error) {
+	main := manager.MainDB()
+	if main == nil {
+		return false, errors.New("main database is not initialized")
 	}
-	manager.tenants[tenantID] = tenant
-	go manager.startTimeout(tenantID, tenant)
+	if tenantID == "" {
+		return false, nil
+	}
+	var count int64
+	err := main.Model(&types.Vendor{}).Where("tenant_id = ?", tenantID).Count(&count).Error
+	return count > 0, err
+}
 
-	return db, nil
+// MigrateTenants brings the database of every known tenant up to date with
+// the registered models and setup functions. It runs once at startup; a
+// tenant that fails is logged and the others are still migrated.
+func (manager *DatabaseManager) MigrateTenants() error {
+	tenantIDs, err := manager.Tenants()
+	if err != nil {
+		return err
+	}
+	for _, tenantID := range tenantIDs {
+		db, err := manager.GetDB(tenantID)
+		if err == nil {
+			err = manager.migrate(db)
+		}
+		if err != nil {
+			log.Printf("Error migrating tenant %s: %s", tenantID, err)
+		}
+	}
+	return nil
+}
+
+// ForEachTenant calls fn for every tenant of the platform, opening the
+// connections that were closed for being idle, so background jobs keep
+// running for quiet stores. A tenant that cannot be opened is logged and
+// skipped. The tenants are listed up front so fn may call back into the
+// manager.
+func (manager *DatabaseManager) ForEachTenant(fn func(tenantID string, db *gorm.DB)) {
+	tenantIDs, err := manager.Tenants()
+	if err != nil {
+		log.Printf("Error listing tenants: %s", err)
+		return
+	}
+	for _, tenantID := range tenantIDs {
+		db, err := manager.GetDB(tenantID)
+		if err != nil {
+			log.Printf("Error opening tenant %s: %s", tenantID, err)
+			continue
+		}
+		fn(tenantID, db)
+	}
 }
 
 func (manager *DatabaseManager) startTimeout(tenantID string, tenant *tenantInfo) {
@@ -91,10 +250,10 @@ func (manager *DatabaseManager) startTimeout(tenantID string, tenant *tenantInfo
 	}
 }
 
+// AddTenant creates and migrates the database of a new tenant. The manager
+// lock is only taken to store the connection, so other tenants are served
+// while the new one is set up.
 func (manager *DatabaseManager) AddTenant(tenantID string, models ...interface{}) error {
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-
 	// Create the tenant's database
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Asia/Shanghai",
 		dotenv.GetEnvOrDefault("DB_HOST", "localhost"),
@@ -133,18 +292,29 @@ func (manager *DatabaseManager) AddTenant(tenantID string, models ...interface{}
 	}
 
 	// AutoMigrate the tables
-	if err := tenantDB.AutoMigrate(models...); err != nil {
+	if err := manager.migrate(tenantDB, models...); err != nil {
 		return err
 	}
 
 	// Store the connection and last access time in the map
-	closeChan := make(chan struct{})
 	tenant := &tenantInfo{
 		db:         tenantDB,
 		lastAccess: time.Now(),
-		closeChan:  closeChan,
+		closeChan:  make(chan struct{}, 1),
+		ready:      make(chan struct{}),
+	}
+	close(tenant.ready)
+	manager.mu.Lock()
+	if _, exists := manager.tenants[tenantID]; exists {
+		manager.mu.Unlock()
+		// a request opened the tenant meanwhile, keep its connection
+		if sqlDB, err := tenantDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return nil
 	}
 	manager.tenants[tenantID] = tenant
+	manager.mu.Unlock()
 	go manager.startTimeout(tenantID, tenant)
 
 	return nil
@@ -155,7 +325,7 @@ func (manager *DatabaseManager) DeleteTenant(tenantID string) error {
 	defer manager.mu.Unlock()
 
 	// Close the tenant's database connection
-	if tenant, exists := manager.tenants[tenantID]; exists {
+	if tenant, exists := manager.tenants[tenantID]; exists && tenant.db != nil {
 		sqlDB, err := tenant.db.DB()
 		if err != nil {
 			return err
@@ -239,5 +409,16 @@ func (manager *DatabaseManager) InitMainDB(models ...interface{}) error {
 	}
 	log.Println("Main database initialized")
 
+	manager.mu.Lock()
+	manager.main = mainDB
+	manager.mu.Unlock()
+
 	return nil
 }
+
+// MainDB returns the connection to the main database, nil before InitMainDB.
+func (manager *DatabaseManager) MainDB() *gorm.DB {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager.main
+}
@@ -0,0 +1,107 @@
+package digital
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits used for assets created without their own.
+const (
+	DefaultMaxDownloads = 5
+	DefaultExpiryHours  = 7 * 24
+)
+
+var (
+	ErrNotPrivate     = errors.New("digital assets must be private files")
+	ErrExpired        = errors.New("download link has expired")
+	ErrLimitReached   = errors.New("download limit reached")
+	ErrRevoked        = errors.New("download link has been revoked")
+	ErrBadSignature   = errors.New("invalid download link")
+	ErrNotSatisfiable = errors.New("requested range not satisfiable")
+)
+
+// Issue grants the lines of a paid order their downloads and license keys.
+// Lines that already have them are skipped, so issuing twice is harmless.
+func Issue(tx *gorm.DB, o types.Order, now time.Time) error {
+	var lines []types.OrderLine
+	if err := tx.Where("order_id = ?", o.ID).Find(&lines).Error; err != nil {
+		return err
+	}
+	variantIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		variantIDs = append(variantIDs, line.VariantID)
+	}
+	var assets []types.DigitalAsset
+	if err := tx.Where("variant_id IN ?", variantIDs).Order("id").Find(&assets).Error; err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+
+	for _, line := range lines {
+		licensed := false
+		for _, asset := range assets {
+			if asset.VariantID != line.VariantID {
+				continue
+			}
+			grant := types.DownloadGrant{
+				OrderID:      o.ID,
+				OrderLineID:  line.ID,
+				AssetID:      asset.ID,
+				Secret:       randomString.GenerateSecureToken(32),
+				MaxDownloads: asset.MaxDownloads,
+				ExpiresAt:    now.Add(time.Duration(asset.ExpiryHours) * time.Hour),
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+				return err
+			}
+			licensed = licensed || asset.IssueLicense
+		}
+		if licensed {
+			if err := issueLicense(tx, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Revoke withdraws the downloads and license keys of a cancelled or refunded
+// order.
+func Revoke(tx *gorm.DB, orderID uint, now time.Time) error {
+	if err := tx.Model(&types.DownloadGrant{}).
+		Where("order_id = ? AND revoked = false", orderID).
+		Update("revoked", true).Error; err != nil {
+		return err
+	}
+	return tx.Model(&types.LicenseKey{}).
+		Where("order_id = ? AND status = ?", orderID, LicenseActive).
+		Updates(map[string]interface{}{"status": LicenseRevoked, "revoked_at": now}).Error
+}
+
+// Reissue gives a grant a fresh download allowance valid from now, e.g. when
+// a customer ran out of downloads for a good reason. The secret is rotated,
+// so links handed out before stop working. Revoked grants stay revoked.
+func Reissue(db *gorm.DB, grant *types.DownloadGrant, asset types.DigitalAsset, now time.Time) error {
+	if grant.Revoked {
+		return ErrRevoked
+	}
+	grant.Secret = randomString.GenerateSecureToken(32)
+	grant.MaxDownloads = asset.MaxDownloads
+	grant.Downloads = 0
+	grant.BytesServed = 0
+	grant.ExpiresAt = now.Add(time.Duration(asset.ExpiryHours) * time.Hour)
+	return db.Model(grant).Updates(map[string]interface{}{
+		"secret":        grant.Secret,
+		"max_downloads": grant.MaxDownloads,
+		"downloads":     0,
+		"bytes_served":  0,
+		"expires_at":    grant.ExpiresAt,
+	}).Error
+}
@@ -0,0 +1,57 @@
+package digital
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// License key statuses.
+const (
+	LicenseActive  = "active"
+	LicenseRevoked = "revoked"
+)
+
+var ErrLicenseNotFound = errors.New("license key not found")
+
+// NormalizeKey uppercases key and drops surrounding space.
+func NormalizeKey(key string) string {
+	return strings.ToUpper(strings.TrimSpace(key))
+}
+
+// newKey returns a random key of five groups of five characters.
+func newKey() string {
+	raw := strings.ToUpper(randomString.GenerateSecureToken(13))[:25]
+	groups := make([]string, 0, 5)
+	for i := 0; i < len(raw); i += 5 {
+		groups = append(groups, raw[i:i+5])
+	}
+	return strings.Join(groups, "-")
+}
+
+func issueLicense(tx *gorm.DB, line types.OrderLine) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&types.LicenseKey{
+		Key:         newKey(),
+		OrderID:     line.OrderID,
+		OrderLineID: line.ID,
+		ProductID:   line.ProductID,
+		VariantID:   line.VariantID,
+		SKU:         line.SKU,
+		Seats:       line.Quantity,
+		Status:      LicenseActive,
+	}).Error
+}
+
+// FindLicense returns the license of key.
+func FindLicense(db *gorm.DB, key string) (types.LicenseKey, error) {
+	license := types.LicenseKey{}
+	err := db.Where("key = ?", NormalizeKey(key)).First(&license).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return license, ErrLicenseNotFound
+	}
+	return license, err
+}
@@ -0,0 +1,145 @@
+package digital
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// LinkQuery returns the query string that authorizes a download of grant.
+// Links carry the grant's expiry and an HMAC of it, so they cannot be
+// extended or pointed at another grant.
+func LinkQuery(grant types.DownloadGrant) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(grant.ExpiresAt.Unix(), 10))
+	query.Set("signature", signature(grant, grant.ExpiresAt.Unix()))
+	return query.Encode()
+}
+
+// Verify checks a link of grant against its signature and the grant's
+// state at now. The download limit is left to Claim.
+func Verify(grant types.DownloadGrant, expires, sig string, now time.Time) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || unix != grant.ExpiresAt.Unix() || !hmac.Equal([]byte(sig), []byte(signature(grant, unix))) {
+		return ErrBadSignature
+	}
+	return Active(grant, now)
+}
+
+// Active reports why grant cannot be downloaded at now, if it cannot.
+func Active(grant types.DownloadGrant, now time.Time) error {
+	if grant.Revoked {
+		return ErrRevoked
+	}
+	if !now.Before(grant.ExpiresAt) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Claim counts a request for length bytes of a file of size bytes, starting
+// at offset, against grant. A grant allows max_downloads times the file in
+// bytes, so resuming from a later offset cannot be used to get around the
+// limit; requests starting at the first byte also count as a download. The
+// counts are raised with a conditional update so concurrent downloads cannot
+// exceed the limit.
+func Claim(db *gorm.DB, grant *types.DownloadGrant, size, offset, length int64, now time.Time) error {
+	query := db.Model(&types.DownloadGrant{}).
+		Where("id = ? AND revoked = false AND expires_at > ?", grant.ID, now).
+		Where("bytes_served + ? <= max_downloads * ?", length, size)
+	updates := map[string]interface{}{
+		"bytes_served":     gorm.Expr("bytes_served + ?", length),
+		"last_download_at": now,
+	}
+	if offset == 0 {
+		query = query.Where("downloads < max_downloads")
+		updates["downloads"] = gorm.Expr("downloads + 1")
+	}
+	res := query.Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := db.First(grant, grant.ID).Error; err != nil {
+			return err
+		}
+		if err := Active(*grant, now); err != nil {
+			return err
+		}
+		return ErrLimitReached
+	}
+	if offset == 0 {
+		grant.Downloads++
+	}
+	grant.BytesServed += length
+	grant.LastDownloadAt = &now
+	return nil
+}
+
+// Release gives back the bytes of a claim that were never sent, so an
+// interrupted download can be resumed without using up the allowance.
+func Release(db *gorm.DB, grant *types.DownloadGrant, unsent int64) error {
+	if unsent <= 0 {
+		return nil
+	}
+	grant.BytesServed = max(grant.BytesServed-unsent, 0)
+	return db.Model(&types.DownloadGrant{}).Where("id = ?", grant.ID).
+		Update("bytes_served", gorm.Expr("GREATEST(bytes_served - ?, 0)", unsent)).Error
+}
+
+func signature(grant types.DownloadGrant, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(grant.Secret))
+	mac.Write([]byte(strconv.FormatUint(uint64(grant.ID), 10) + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseRange reads a Range header for a file of size bytes. Only a single
+// byte range is supported; ok is false when the whole file should be sent,
+// which is also how other range forms are answered. ErrNotSatisfiable is
+// returned for a range outside the file.
+func ParseRange(header string, size int64) (offset, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+
+	if first == "" {
+		// a suffix range, the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, ErrNotSatisfiable
+		}
+		n = min(n, size)
+		return size - n, n, true, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	if start >= size {
+		return 0, 0, false, ErrNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false, nil
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true, nil
+}
@@ -0,0 +1,84 @@
+package digital
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header         string
+		offset, length int64
+		ok             bool
+		err            error
+	}{
+		{"", 0, 0, false, nil},
+		{"bytes=0-", 0, 100, true, nil},
+		{"bytes=10-19", 10, 10, true, nil},
+		{"bytes=90-200", 90, 10, true, nil},
+		{"bytes=-30", 70, 30, true, nil},
+		{"bytes=-300", 0, 100, true, nil},
+		{"bytes=100-", 0, 0, false, ErrNotSatisfiable},
+		{"bytes=-0", 0, 0, false, ErrNotSatisfiable},
+		{"bytes=0-1,5-6", 0, 0, false, nil},
+		{"bytes=5-1", 0, 0, false, nil},
+		{"items=0-1", 0, 0, false, nil},
+	}
+	for _, c := range cases {
+		offset, length, ok, err := ParseRange(c.header, 100)
+		if offset != c.offset || length != c.length || ok != c.ok || !errors.Is(err, c.err) {
+			t.Errorf("%q: %d+%d %v %v, want %d+%d %v %v", c.header, offset, length, ok, err, c.offset, c.length, c.ok, c.err)
+		}
+	}
+}
+
+func TestClaimCountsResumedRanges(t *testing.T) {
+	db := testdb.Open(t)
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	media := types.MediaFile{Key: "tenant/digital/book.pdf", ContentType: "application/pdf", Size: 100, Private: true}
+	if err := db.Create(&media).Error; err != nil {
+		t.Fatal(err)
+	}
+	asset := types.DigitalAsset{VariantID: 1, MediaID: media.ID, Name: "Book", MaxDownloads: 2, ExpiryHours: 24}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatal(err)
+	}
+	grant := types.DownloadGrant{OrderID: 1, OrderLineID: 1, AssetID: asset.ID, Secret: "secret", MaxDownloads: 2, ExpiresAt: now.Add(time.Hour)}
+	if err := db.Create(&grant).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// a download interrupted after 40 bytes and resumed
+	if err := Claim(db, &grant, 100, 0, 100, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release(db, &grant, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := Claim(db, &grant, 100, 40, 60, now); err != nil {
+		t.Fatalf("resuming: %s", err)
+	}
+
+	// the second and last download
+	if err := Claim(db, &grant, 100, 0, 100, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&grant, grant.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if grant.Downloads != 2 || grant.BytesServed != 200 {
+		t.Errorf("%d downloads and %d bytes served, want 2 and 200", grant.Downloads, grant.BytesServed)
+	}
+
+	// the limit holds however the rest of the file is asked for
+	for _, r := range [][2]int64{{0, 100}, {1, 99}, {50, 50}, {99, 1}} {
+		if err := Claim(db, &grant, 100, r[0], r[1], now); !errors.Is(err, ErrLimitReached) {
+			t.Errorf("claiming %d+%d past the limit returned %v", r[0], r[1], err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package fulfillment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Shipment statuses, in the order a parcel goes through them.
+const (
+	StatusPending      = "pending"
+	StatusLabelCreated = "label_created"
+	StatusInTransit    = "in_transit"
+	StatusDelivered    = "delivered"
+)
+
+var statusRank = map[string]int{
+	StatusPending:      0,
+	StatusLabelCreated: 1,
+	StatusInTransit:    2,
+	StatusDelivered:    3,
+}
+
+var (
+	ErrNotFulfillable   = errors.New("order is not ready to be fulfilled")
+	ErrInvalidLine      = errors.New("line does not belong to the order")
+	ErrInvalidQuantity  = errors.New("quantity exceeds what is left to ship")
+	ErrInvalidStatus    = errors.New("invalid shipment status")
+	ErrUnknownLocation  = errors.New("unknown stock location")
+	ErrShipmentStarted  = errors.New("shipment is already on its way")
+	ErrNothingToShip    = errors.New("no lines to ship")
+	fulfillableStatuses = map[string]bool{order.StatusPaid: true, order.StatusFulfilling: true}
+)
+
+type (
+	// LineInput is a quantity of an order line to pack.
+	LineInput struct {
+		OrderLineID uint
+		Quantity    int
+	}
+	// ShipmentInput describes a new shipment.
+	ShipmentInput struct {
+		LocationID     uint
+		Carrier        string
+		TrackingNumber string
+		Lines          []LineInput
+	}
+	// EventInput is a tracking update of a shipment.
+	EventInput struct {
+		Status      string
+		Description string
+		Location    string
+		OccurredAt  time.Time
+	}
+)
+
+// ValidStatus reports whether status is a status a tracking event can report.
+func ValidStatus(status string) bool {
+	return status == StatusLabelCreated || status == StatusInTransit || status == StatusDelivered
+}
+
+// CreateShipment packs lines of a paid order into a shipment from a stock
+// location. No line can be shipped more often than it was ordered. The
+// first shipment moves the order to fulfilling.
+func CreateShipment(db *gorm.DB, o *types.Order, in ShipmentInput) (types.Shipment, error) {
+	shipment := types.Shipment{
+		OrderID:        o.ID,
+		LocationID:     in.LocationID,
+		Status:         StatusPending,
+		Carrier:        in.Carrier,
+		TrackingNumber: in.TrackingNumber,
+	}
+	if len(in.Lines) == 0 {
+		return shipment, ErrNothingToShip
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// serialise shipments of the same order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(o, o.ID).Error; err != nil {
+			return err
+		}
+		if !fulfillableStatuses[o.Status] {
+			return ErrNotFulfillable
+		}
+		if err := tx.First(&types.StockLocation{}, in.LocationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownLocation
+			}
+			return err
+		}
+
+		ordered, err := orderedQuantities(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		shipped, err := shippedQuantities(tx, o.ID, "")
+		if err != nil {
+			return err
+		}
+		for _, line := range in.Lines {
+			if _, ok := ordered[line.OrderLineID]; !ok {
+				return ErrInvalidLine
+			}
+			shipped[line.OrderLineID] += line.Quantity
+			if line.Quantity <= 0 || shipped[line.OrderLineID] > ordered[line.OrderLineID] {
+				return ErrInvalidQuantity
+			}
+			shipment.Lines = append(shipment.Lines, types.ShipmentLine{
+				OrderLineID: line.OrderLineID,
+				Quantity:    line.Quantity,
+			})
+		}
+
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		if o.Status == order.StatusPaid {
+			return order.Transition(tx, o, order.StatusFulfilling, "shipment created")
+		}
+		return nil
+	})
+	return shipment, err
+}
+
+// DeleteShipment unpacks a shipment that has not been handed over yet.
+func DeleteShipment(db *gorm.DB, shipment *types.Shipment) error {
+	res := db.Where("id = ? AND status IN ?", shipment.ID, []string{StatusPending, StatusLabelCreated}).Delete(&types.Shipment{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrShipmentStarted
+	}
+	return nil
+}
+
+// Record adds a tracking event to a shipment and reports whether it moved
+// the shipment forward. Events reporting a status the shipment is already
+// past are kept in the history only, so carriers may report late or twice.
+// The order follows its shipments: it is shipped once every line is on its
+// way and delivered once every line arrived.
+func Record(db *gorm.DB, o *types.Order, shipment *types.Shipment, in EventInput) (bool, error) {
+	if !ValidStatus(in.Status) {
+		return false, ErrInvalidStatus
+	}
+	if in.OccurredAt.IsZero() {
+		in.OccurredAt = time.Now()
+	}
+
+	advanced := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&types.ShipmentEvent{
+			ShipmentID:  shipment.ID,
+			Status:      in.Status,
+			Description: in.Description,
+			Location:    in.Location,
+			OccurredAt:  in.OccurredAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		if statusRank[in.Status] <= statusRank[shipment.Status] {
+			return nil
+		}
+		updates := map[string]interface{}{"status": in.Status}
+		if in.Status == StatusInTransit || (in.Status == StatusDelivered && shipment.ShippedAt == nil) {
+			updates["shipped_at"] = in.OccurredAt
+			shipment.ShippedAt = &in.OccurredAt
+		}
+		if in.Status == StatusDelivered {
+			updates["delivered_at"] = in.OccurredAt
+			shipment.DeliveredAt = &in.OccurredAt
+		}
+		res := tx.Model(&types.Shipment{}).Where("id = ? AND status = ?", shipment.ID, shipment.Status).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// another update won, it is its job to move the order
+			return nil
+		}
+		shipment.Status = in.Status
+		advanced = true
+
+		return syncOrder(tx, o)
+	})
+	return advanced, err
+}
+
+// syncOrder moves the order as far as its shipments allow.
+func syncOrder(tx *gorm.DB, o *types.Order) error {
+	if err := tx.First(o, o.ID).Error; err != nil {
+		return err
+	}
+	ordered, err := orderedQuantities(tx, o.ID)
+	if err != nil {
+		return err
+	}
+	inTransit, err := shippedQuantities(tx, o.ID, StatusInTransit)
+	if err != nil {
+		return err
+	}
+	delivered, err := shippedQuantities(tx, o.ID, StatusDelivered)
+	if err != nil {
+		return err
+	}
+
+	allShipped, allDelivered := true, true
+	for id, quantity := range ordered {
+		if inTransit[id]+delivered[id] < quantity {
+			allShipped = false
+		}
+		if delivered[id] < quantity {
+			allDelivered = false
+		}
+	}
+
+	var path []string
+	switch {
+	case allDelivered:
+		path = []string{order.StatusFulfilling, order.StatusShipped, order.StatusDelivered}
+	case allShipped:
+		path = []string{order.StatusFulfilling, order.StatusShipped}
+	default:
+		path = []string{order.StatusFulfilling}
+	}
+	for _, status := range path {
+		if !order.CanTransition(o.Status, status) {
+			continue
+		}
+		if err := order.Transition(tx, o, status, "shipment update"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func orderedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var lines []types.OrderLine
+	if err := tx.Where("order_id = ?", orderID).Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	ordered := map[uint]int{}
+	for _, line := range lines {
+		ordered[line.ID] = line.Quantity
+	}
+	return ordered, nil
+}
+
+// shippedQuantities sums the quantities of each order line packed in the
+// shipments of the order, or only in those with the given status.
+func shippedQuantities(tx *gorm.DB, orderID uint, status string) (map[uint]int, error) {
+	var rows []struct {
+		OrderLineID uint
+		Quantity    int
+	}
+	query := tx.Table("shipment_lines").
+		Select("shipment_lines.order_line_id, SUM(shipment_lines.quantity) AS quantity").
+		Joins("JOIN shipments ON shipments.id = shipment_lines.shipment_id").
+		Where("shipments.order_id = ? AND shipments.deleted_at IS NULL", orderID)
+	if status != "" {
+		query = query.Where("shipments.status = ?", status)
+	}
+	if err := query.Group("shipment_lines.order_line_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	quantities := map[uint]int{}
+	for _, row := range rows {
+		quantities[row.OrderLineID] = row.Quantity
+	}
+	return quantities, nil
+}
@@ -0,0 +1,255 @@
+package giftcard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Transaction kinds. Redeem takes value off for an order, release gives it
+// back when that order is cancelled unpaid and refund when it is refunded.
+const (
+	KindIssue      = "issue"
+	KindRedeem     = "redeem"
+	KindRelease    = "release"
+	KindRefund     = "refund"
+	KindAdjustment = "adjustment"
+)
+
+var (
+	ErrNotFound         = errors.New("gift card not found")
+	ErrExpired          = errors.New("gift card has expired")
+	ErrDisabled         = errors.New("gift card is disabled")
+	ErrEmpty            = errors.New("gift card has no balance left")
+	ErrCurrencyMismatch = errors.New("gift card is in another currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+// IssueInput describes a new gift card.
+type IssueInput struct {
+	Currency       string
+	Value          int64
+	ExpiresAt      *time.Time
+	RecipientEmail string
+	Note           string
+}
+
+// NormalizeCode uppercases code and drops the separators customers type.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newCode returns a random code of four groups of four characters.
+func newCode() string {
+	raw := strings.ToUpper(randomString.GenerateSecureToken(8))
+	groups := []string{raw[0:4], raw[4:8], raw[8:12], raw[12:16]}
+	return strings.Join(groups, "-")
+}
+
+// Issue creates a gift card loaded with in.Value and returns it with its
+// code. The code is only known at this point, it cannot be read back later.
+func Issue(db *gorm.DB, in IssueInput) (types.GiftCard, string, error) {
+	if in.Value <= 0 {
+		return types.GiftCard{}, "", ErrInvalidAmount
+	}
+	code := newCode()
+	card := types.GiftCard{
+		CodeHash:       hashCode(code),
+		Last4:          code[len(code)-4:],
+		Currency:       in.Currency,
+		InitialValue:   in.Value,
+		ExpiresAt:      in.ExpiresAt,
+		RecipientEmail: in.RecipientEmail,
+		Note:           in.Note,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&card).Error; err != nil {
+			return err
+		}
+		return tx.Create(&types.GiftCardTransaction{
+			GiftCardID: card.ID,
+			Kind:       KindIssue,
+			Amount:     in.Value,
+			Note:       in.Note,
+		}).Error
+	})
+	return card, code, err
+}
+
+// Find returns the card of code.
+func Find(db *gorm.DB, code string) (types.GiftCard, error) {
+	card := types.GiftCard{}
+	err := db.Where("code_hash = ?", hashCode(code)).First(&card).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return card, ErrNotFound
+	}
+	return card, err
+}
+
+// Balance is the value left on the card.
+func Balance(db *gorm.DB, cardID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&types.GiftCardTransaction{}).
+		Where("gift_card_id = ?", cardID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// Usable reports why card cannot be spent at now, if it cannot.
+func Usable(card types.GiftCard, now time.Time) error {
+	if card.Disabled {
+		return ErrDisabled
+	}
+	if card.ExpiresAt != nil && !card.ExpiresAt.After(now) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Redeem takes up to amount off the card of code for the order and returns
+// what it took, the balance when it is lower than amount.
+func Redeem(tx *gorm.DB, code, currency string, amount int64, orderID uint, now time.Time) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	card, err := Find(tx, code)
+	if err != nil {
+		return 0, err
+	}
+	if card.Currency != currency {
+		return 0, ErrCurrencyMismatch
+	}
+	if err := Usable(card, now); err != nil {
+		return 0, err
+	}
+
+	// lock the card so concurrent redemptions see each other
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&card, card.ID).Error; err != nil {
+		return 0, err
+	}
+	balance, err := Balance(tx, card.ID)
+	if err != nil {
+		return 0, err
+	}
+	if balance <= 0 {
+		return 0, ErrEmpty
+	}
+	taken := min(amount, balance)
+	err = tx.Create(&types.GiftCardTransaction{
+		GiftCardID: card.ID,
+		Kind:       KindRedeem,
+		Amount:     -taken,
+		OrderID:    &orderID,
+	}).Error
+	return taken, err
+}
+
+// Adjust adds amount, or takes it off when negative, by hand. The balance
+// cannot go below zero.
+func Adjust(db *gorm.DB, card types.GiftCard, amount int64, note string) error {
+	if amount == 0 {
+		return ErrInvalidAmount
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&card, card.ID).Error; err != nil {
+			return err
+		}
+		balance, err := Balance(tx, card.ID)
+		if err != nil {
+			return err
+		}
+		if balance+amount < 0 {
+			return ErrInvalidAmount
+		}
+		return tx.Create(&types.GiftCardTransaction{
+			GiftCardID: card.ID,
+			Kind:       KindAdjustment,
+			Amount:     amount,
+			Note:       note,
+		}).Error
+	})
+}
+
+// outstanding is what the order still holds of each card it was paid with.
+func outstanding(tx *gorm.DB, orderID uint) ([]held, error) {
+	var rows []held
+	err := tx.Model(&types.GiftCardTransaction{}).
+		Select("gift_card_id, -SUM(amount) AS amount").
+		Where("order_id = ? AND kind IN ?", orderID, []string{KindRedeem, KindRelease, KindRefund}).
+		Group("gift_card_id").
+		Having("-SUM(amount) > 0").
+		Order("gift_card_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+type held struct {
+	GiftCardID uint
+	Amount     int64
+}
+
+// Outstanding is the gift card value the order holds and could give back.
+func Outstanding(db *gorm.DB, orderID uint) (int64, error) {
+	rows, err := outstanding(db, orderID)
+	var total int64
+	for _, row := range rows {
+		total += row.Amount
+	}
+	return total, err
+}
+
+// Release puts back everything the order took off its gift cards, for an
+// order cancelled before it was paid.
+func Release(tx *gorm.DB, orderID uint) error {
+	_, err := giveBack(tx, orderID, -1, KindRelease, "order cancelled")
+	return err
+}
+
+// Refund puts up to amount back on the cards the order was paid with and
+// returns what it put back.
+func Refund(tx *gorm.DB, orderID uint, amount int64, note string) (int64, error) {
+	return giveBack(tx, orderID, amount, KindRefund, note)
+}
+
+// giveBack returns up to amount, everything when negative, to the cards of
+// the order.
+func giveBack(tx *gorm.DB, orderID uint, amount int64, kind, note string) (int64, error) {
+	rows, err := outstanding(tx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	var given int64
+	for _, row := range rows {
+		part := row.Amount
+		if amount >= 0 {
+			part = min(part, amount-given)
+		}
+		if part <= 0 {
+			break
+		}
+		if err := tx.Create(&types.GiftCardTransaction{
+			GiftCardID: row.GiftCardID,
+			Kind:       kind,
+			Amount:     part,
+			OrderID:    &orderID,
+			Note:       note,
+		}).Error; err != nil {
+			return given, err
+		}
+		given += part
+	}
+	return given, nil
+}
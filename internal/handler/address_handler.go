@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/address"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	AddressHandler struct {
+	}
+	AddressHandlerInterface interface {
+		List(c echo.Context) error
+		Create(c echo.Context) error
+		Get(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+		Validate(c echo.Context) error
+		CustomerAddresses(c echo.Context) error
+	}
+	addressRequest struct {
+		FullName        string `json:"full_name" validate:"max=255"`
+		Phone           string `json:"phone" validate:"max=30"`
+		StreetNumber    string `json:"street_number" validate:"max=20"`
+		Directional     string `json:"directional" validate:"max=10"`
+		Street          string `json:"street" validate:"required,max=255"`
+		Suffix          string `json:"suffix" validate:"max=50"`
+		UnitType        string `json:"unit_type" validate:"max=50"`
+		UnitNumber      string `json:"unit_number" validate:"max=20"`
+		City            string `json:"city" validate:"max=100"`
+		Region          string `json:"region" validate:"max=100"`
+		ZipCode         string `json:"zip_code" validate:"max=20"`
+		CountryCode     string `json:"country_code" validate:"required,len=2"`
+		IsBilling       *bool  `json:"is_billing"`
+		IsShipping      *bool  `json:"is_shipping"`
+		PrimaryBilling  bool   `json:"primary_billing"`
+		PrimaryShipping bool   `json:"primary_shipping"`
+	}
+)
+
+var errAddressNotFound = errors.New("address not found")
+
+func NewAddressHandler() AddressHandlerInterface {
+	return &AddressHandler{}
+}
+
+// List returns the address book of the signed in customer, primary
+// addresses first.
+func (h *AddressHandler) List(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	return h.list(c, db, userID)
+}
+
+func (h *AddressHandler) Create(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+	var req addressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	entry := types.UserPhysicalAddress{UserID: userID}
+	req.apply(&entry)
+	if err := address.Save(db, &entry); err != nil {
+		return addressError(c, err)
+	}
+	return c.JSON(http.StatusCreated, entry)
+}
+
+func (h *AddressHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	entry, err := myAddress(c, db)
+	if err != nil {
+		return addressError(c, err)
+	}
+	return c.JSON(http.StatusOK, entry)
+}
+
+// Update replaces an address. Kinds left out of the request keep their
+// current value.
+func (h *AddressHandler) Update(c echo.Context) error {
+	var req addressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	entry, err := myAddress(c, db)
+	if err != nil {
+		return addressError(c, err)
+	}
+	req.apply(&entry)
+	if err := address.Save(db, &entry); err != nil {
+		return addressError(c, err)
+	}
+	return c.JSON(http.StatusOK, entry)
+}
+
+func (h *AddressHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	entry, err := myAddress(c, db)
+	if err != nil {
+		return addressError(c, err)
+	}
+	if err := address.Remove(db, entry); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting address"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// Validate normalizes an address without saving it, so the storefront can
+// show the customer the canonical form or what is wrong with it.
+func (h *AddressHandler) Validate(c echo.Context) error {
+	var req addressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	entry := types.UserPhysicalAddress{}
+	req.apply(&entry)
+	if err := address.Normalize(&entry); err != nil {
+		return addressError(c, err)
+	}
+	return c.JSON(http.StatusOK, entry)
+}
+
+// CustomerAddresses returns the address book of a customer for the admin.
+func (h *AddressHandler) CustomerAddresses(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	user := types.User{}
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+	}
+	return h.list(c, db, user.ID)
+}
+
+func (h *AddressHandler) list(c echo.Context, db *gorm.DB, userID uint) error {
+	var entries []types.UserPhysicalAddress
+	if err := db.Where("user_id = ? AND active = ?", userID, true).
+		Order("primary_shipping DESC, primary_billing DESC, id").
+		Find(&entries).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching addresses"})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// apply copies the request onto entry. An address is used for both kinds
+// unless the request says otherwise.
+func (r *addressRequest) apply(entry *types.UserPhysicalAddress) {
+	entry.FullName = r.FullName
+	entry.Phone = r.Phone
+	entry.StreetNumber = r.StreetNumber
+	entry.Directional = r.Directional
+	entry.Street = r.Street
+	entry.Suffix = r.Suffix
+	entry.UnitType = r.UnitType
+	entry.UnitNumber = r.UnitNumber
+	entry.City = r.City
+	entry.Region = r.Region
+	entry.ZipCode = r.ZipCode
+	entry.CountryCode = r.CountryCode
+	if entry.ID == 0 {
+		entry.IsBilling, entry.IsShipping = true, true
+	}
+	if r.IsBilling != nil {
+		entry.IsBilling = *r.IsBilling
+	}
+	if r.IsShipping != nil {
+		entry.IsShipping = *r.IsShipping
+	}
+	entry.PrimaryBilling = r.PrimaryBilling
+	entry.PrimaryShipping = r.PrimaryShipping
+}
+
+// myAddress finds the address named in the path in the signed in customer's
+// address book.
+func myAddress(c echo.Context, db *gorm.DB) (types.UserPhysicalAddress, error) {
+	entry := types.UserPhysicalAddress{}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return entry, errLoginRequired
+	}
+	if err := db.Where("user_id = ? AND active = ?", userID, true).First(&entry, c.Param("id")).Error; err != nil {
+		return entry, errAddressNotFound
+	}
+	return entry, nil
+}
+
+// addressError maps address book errors to responses.
+func addressError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errLoginRequired):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, errAddressNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, address.ErrInvalidAddress), errors.Is(err, address.ErrInvalidCountry):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving address"})
+}
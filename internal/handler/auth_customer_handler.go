@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/Satishcg12/multicommers/internal/session"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/password"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	AuthCustomerHandler struct {
+	}
+	AuthCustomerHandlerInterface interface {
+		Register(c echo.Context) error
+		Login(c echo.Context) error
+		Logout(c echo.Context) error
+		Me(c echo.Context) error
+	}
+	customerRegisterRequest struct {
+		FullName    string `json:"full_name" validate:"required,max=255"`
+		Email       string `json:"email" validate:"required,email"`
+		Password    string `json:"password" validate:"required,password,min=8,max=50"`
+		ConfirmPass string `json:"confirm_password" validate:"required,eqfield=Password"`
+	}
+	// customerSessionResponse carries the bearer token that signs the
+	// customer in on later requests
+	customerSessionResponse struct {
+		Token     string     `json:"token"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		User      types.User `json:"user"`
+	}
+)
+
+var errInvalidCredentials = errors.New("invalid email or password")
+
+func NewAuthCustomerHandler() AuthCustomerHandlerInterface {
+	return &AuthCustomerHandler{}
+}
+
+// Register creates a customer account and signs it in.
+func (h *AuthCustomerHandler) Register(c echo.Context) error {
+	var req customerRegisterRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := db.Where("email = ?", email).First(&types.User{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "email already exists"})
+	}
+
+	hashedPassword, err := password.HashPassword(req.Password)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error hashing password"})
+	}
+
+	user := types.User{FullName: req.FullName, Email: email}
+	var token string
+	var current types.UserSession
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		secret := types.UserPassword{UserID: user.ID, HashedPassword: hashedPassword, Active: true}
+		if err := tx.Create(&secret).Error; err != nil {
+			return err
+		}
+		user.PasswordID = secret.ID
+		if err := tx.Model(&user).Update("password_id", secret.ID).Error; err != nil {
+			return err
+		}
+		token, current, err = session.Create(tx, user.ID, time.Now())
+		return err
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating account"})
+	}
+	return c.JSON(http.StatusCreated, customerSessionResponse{Token: token, ExpiresAt: current.ExpiresAt, User: user})
+}
+
+// Login signs a customer in with their email and password.
+func (h *AuthCustomerHandler) Login(c echo.Context) error {
+	var req loginRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	user := types.User{}
+	if err := db.Where("email = ?", strings.ToLower(strings.TrimSpace(req.Email))).First(&user).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+	secret := types.UserPassword{}
+	if err := db.Where("user_id = ? AND active = ?", user.ID, true).First(&secret).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+	if err := password.ComparePasswords(secret.HashedPassword, req.Password); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+
+	token, current, err := session.Create(db, user.ID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error signing in"})
+	}
+	return c.JSON(http.StatusOK, customerSessionResponse{Token: token, ExpiresAt: current.ExpiresAt, User: user})
+}
+
+// Logout ends the session the request was made with.
+func (h *AuthCustomerHandler) Logout(c echo.Context) error {
+	token, ok := middleware.BearerToken(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errLoginRequired.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	if err := session.Revoke(db, token, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error signing out"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// Me returns the signed in customer.
+func (h *AuthCustomerHandler) Me(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errLoginRequired.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	user := types.User{}
+	if err := db.First(&user, userID).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errLoginRequired.Error()})
+	}
+	return c.JSON(http.StatusOK, user)
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/Satishcg12/multicommers/internal/session"
 	"github.com/Satishcg12/multicommers/internal/types"
 	"github.com/Satishcg12/multicommers/utils/password"
 	randomString "github.com/Satishcg12/multicommers/utils/string"
@@ -43,6 +46,13 @@ type (
 		Email    string `json:"email" validate:"required,email"`
 		Password string `json:"password" validate:"required"`
 	}
+	// vendorSessionResponse carries the token that signs the vendor in to
+	// the admin on later requests, sent in the X-Vendor-Token header
+	vendorSessionResponse struct {
+		Token     string       `json:"token"`
+		ExpiresAt time.Time    `json:"expires_at"`
+		Vendor    types.Vendor `json:"vendor"`
+	}
 	RequestResetPasswordRequest struct {
 		Email string `json:"email" validate:"required,email"`
 	}
@@ -212,8 +222,38 @@ func (h *AuthVendorHandler) ResendOTP(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
 }
 
+// Login signs a vendor with a verified email in to the admin of their store.
 func (h *AuthVendorHandler) Login(c echo.Context) error {
-	return nil
+	var req loginRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor := types.Vendor{}
+	if err := main.Where("email = ?", strings.TrimSpace(req.Email)).First(&vendor).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+	secret := types.VendorPassword{}
+	if err := main.Where("vendor_id = ? AND active = ?", vendor.ID, true).First(&secret).Error; err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+	if err := password.ComparePasswords(secret.HashedPassword, req.Password); err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": errInvalidCredentials.Error()})
+	}
+	if !vendor.EmailVerified {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "email not verified"})
+	}
+
+	token, current, err := session.CreateVendor(main, vendor.ID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error signing in"})
+	}
+	return c.JSON(http.StatusOK, vendorSessionResponse{Token: token, ExpiresAt: current.ExpiresAt, Vendor: vendor})
 }
 
 func (h *AuthVendorHandler) RequestResetPassword(c echo.Context) error {
@@ -224,6 +264,17 @@ func (h *AuthVendorHandler) ResetPassword(c echo.Context) error {
 	return nil
 }
 
+// Logout ends the vendor session the request was made with.
 func (h *AuthVendorHandler) Logout(c echo.Context) error {
-	return nil
+	token := c.Request().Header.Get(middleware.VendorTokenHeader)
+	if token == "" {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "vendor authentication required"})
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	if err := session.RevokeVendor(main, token, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error signing out"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
 }
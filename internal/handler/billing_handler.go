@@ -0,0 +1,402 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	BillingHandler struct {
+	}
+	BillingHandlerInterface interface {
+		ListPlans(c echo.Context) error
+		CreatePlan(c echo.Context) error
+		UpdatePlan(c echo.Context) error
+		DeletePlan(c echo.Context) error
+		AvailablePlans(c echo.Context) error
+		GetSubscription(c echo.Context) error
+		Subscribe(c echo.Context) error
+		ChangePlan(c echo.Context) error
+		Cancel(c echo.Context) error
+		Resume(c echo.Context) error
+		UpdatePaymentMethod(c echo.Context) error
+		MyInvoices(c echo.Context) error
+		Usage(c echo.Context) error
+		ListInvoices(c echo.Context) error
+		RetryInvoice(c echo.Context) error
+	}
+	planRequest struct {
+		Code              string `json:"code" validate:"required,max=50"`
+		Name              string `json:"name" validate:"required,max=100"`
+		Price             int64  `json:"price" validate:"min=0"`
+		Currency          string `json:"currency" validate:"required,len=3"`
+		Interval          string `json:"interval" validate:"required,oneof=month year"`
+		TrialDays         int    `json:"trial_days" validate:"min=0"`
+		MaxProducts       int64  `json:"max_products" validate:"min=0"`
+		MaxStaff          int64  `json:"max_staff" validate:"min=0"`
+		MaxOrdersPerMonth int64  `json:"max_orders_per_month" validate:"min=0"`
+		MaxStorageMB      int64  `json:"max_storage_mb" validate:"min=0"`
+		Active            *bool  `json:"active"`
+		Position          int    `json:"position"`
+	}
+	subscribeRequest struct {
+		PlanID          uint   `json:"plan_id" validate:"required"`
+		PaymentProvider string `json:"payment_provider"`
+		PaymentMethod   string `json:"payment_method"`
+	}
+	changePlanRequest struct {
+		PlanID uint `json:"plan_id" validate:"required"`
+	}
+	paymentMethodRequest struct {
+		PaymentProvider string `json:"payment_provider" validate:"required"`
+		PaymentMethod   string `json:"payment_method" validate:"required"`
+	}
+)
+
+func NewBillingHandler() BillingHandlerInterface {
+	return &BillingHandler{}
+}
+
+func (h *BillingHandler) ListPlans(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	plans := []types.Plan{}
+	if err := main.Order("position, id").Find(&plans).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching plans"})
+	}
+	return c.JSON(http.StatusOK, plans)
+}
+
+func (h *BillingHandler) CreatePlan(c echo.Context) error {
+	return h.savePlan(c, types.Plan{}, http.StatusCreated)
+}
+
+func (h *BillingHandler) UpdatePlan(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	plan := types.Plan{}
+	if err := main.First(&plan, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "plan not found"})
+	}
+	return h.savePlan(c, plan, http.StatusOK)
+}
+
+// savePlan writes a plan. Price changes apply from the next renewal.
+func (h *BillingHandler) savePlan(c echo.Context, plan types.Plan, status int) error {
+	var req planRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	// check if code already exists
+	if err := main.Where("code = ? AND id <> ?", req.Code, plan.ID).First(&types.Plan{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	plan.Code = req.Code
+	plan.Name = req.Name
+	plan.Price = req.Price
+	plan.Currency = currency.NormalizeCode(req.Currency)
+	plan.Interval = req.Interval
+	plan.TrialDays = req.TrialDays
+	plan.MaxProducts = req.MaxProducts
+	plan.MaxStaff = req.MaxStaff
+	plan.MaxOrdersPerMonth = req.MaxOrdersPerMonth
+	plan.MaxStorageMB = req.MaxStorageMB
+	plan.Active = req.Active == nil || *req.Active
+	plan.Position = req.Position
+	if err := main.Save(&plan).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving plan"})
+	}
+
+	return c.JSON(status, plan)
+}
+
+// DeletePlan removes a plan nobody is subscribed to, plans in use can only be
+// deactivated.
+func (h *BillingHandler) DeletePlan(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	var count int64
+	if err := main.Model(&types.VendorSubscription{}).Where("plan_id = ?", c.Param("id")).Count(&count).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing plan"})
+	}
+	if count > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "plan has subscriptions, deactivate it instead"})
+	}
+	if err := main.Delete(&types.Plan{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing plan"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// AvailablePlans lists the plans a vendor can subscribe to.
+func (h *BillingHandler) AvailablePlans(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	plans := []types.Plan{}
+	if err := main.Where("active = ?", true).Order("position, id").Find(&plans).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching plans"})
+	}
+	return c.JSON(http.StatusOK, plans)
+}
+
+func (h *BillingHandler) GetSubscription(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	sub, err := h.currentSubscription(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *BillingHandler) Subscribe(c echo.Context) error {
+	var req subscribeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+	plan := types.Plan{}
+	if err := main.First(&plan, req.PlanID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "plan not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	sub, err := billing.Subscribe(ctx, main, vendor.ID, plan, billing.PaymentDetails{
+		Provider: req.PaymentProvider,
+		Method:   req.PaymentMethod,
+	}, time.Now())
+	if err != nil {
+		return billingError(c, err, "error creating subscription")
+	}
+	return c.JSON(http.StatusCreated, sub)
+}
+
+// ChangePlan upgrades or downgrades the subscription, prorating the current
+// period. The invoice for the difference is returned when one was due.
+func (h *BillingHandler) ChangePlan(c echo.Context) error {
+	var req changePlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	sub, err := h.currentSubscription(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	plan := types.Plan{}
+	if err := main.First(&plan, req.PlanID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "plan not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	invoice, err := billing.ChangePlan(ctx, main, &sub, plan, time.Now())
+	if err != nil {
+		return billingError(c, err, "error changing plan")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"subscription": sub,
+		"invoice":      invoice,
+	})
+}
+
+// Cancel stops the subscription at the end of the period, or right away with
+// ?immediately=true.
+func (h *BillingHandler) Cancel(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	sub, err := h.currentSubscription(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	if err := billing.Cancel(main, &sub, c.QueryParam("immediately") == "true", time.Now()); err != nil {
+		return billingError(c, err, "error cancelling subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *BillingHandler) Resume(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	sub, err := h.currentSubscription(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	if err := billing.Resume(main, &sub); err != nil {
+		return billingError(c, err, "error resuming subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// UpdatePaymentMethod replaces how the subscription is charged and charges
+// the open invoices again right away.
+func (h *BillingHandler) UpdatePaymentMethod(c echo.Context) error {
+	var req paymentMethodRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	sub, err := h.currentSubscription(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	if err := main.Model(&sub).Omit("Plan").Updates(map[string]interface{}{
+		"payment_provider": req.PaymentProvider,
+		"payment_method":   req.PaymentMethod,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating subscription"})
+	}
+
+	var open []types.VendorInvoice
+	if err := main.Where("subscription_id = ? AND status = ?", sub.ID, billing.InvoiceOpen).Find(&open).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching invoices"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	for i := range open {
+		if err := billing.Charge(ctx, main, &open[i], time.Now()); err != nil {
+			return billingError(c, err, "error charging invoice")
+		}
+	}
+	if err := main.Preload("Plan").First(&sub, sub.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscription"})
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *BillingHandler) MyInvoices(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+
+	var invoices []types.VendorInvoice
+	res, err := findPage(c, main.Model(&types.VendorInvoice{}).Where("vendor_id = ?", vendor.ID).Order("id DESC"), &invoices, "Lines")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching invoices"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Usage reports what the store uses against its plan limits.
+func (h *BillingHandler) Usage(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+	db := c.Get("db").(*gorm.DB)
+	tenantID, _ := c.Get("tenant").(string)
+
+	usages, err := billing.Usages(main, db, tenantID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching usage"})
+	}
+	return c.JSON(http.StatusOK, usages)
+}
+
+func (h *BillingHandler) ListInvoices(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	query := main.Model(&types.VendorInvoice{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if vendorID := c.QueryParam("vendor_id"); vendorID != "" {
+		query = query.Where("vendor_id = ?", vendorID)
+	}
+
+	var invoices []types.VendorInvoice
+	res, err := findPage(c, query, &invoices, "Lines")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching invoices"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// RetryInvoice charges an open invoice now instead of waiting for the next
+// scheduled attempt.
+func (h *BillingHandler) RetryInvoice(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	invoice := types.VendorInvoice{}
+	if err := main.First(&invoice, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "invoice not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	if err := billing.Charge(ctx, main, &invoice, time.Now()); err != nil {
+		return billingError(c, err, "error charging invoice")
+	}
+	return c.JSON(http.StatusOK, invoice)
+}
+
+// currentSubscription returns the subscription of the vendor owning the
+// store of the request.
+func (h *BillingHandler) currentSubscription(c echo.Context, main *gorm.DB) (types.VendorSubscription, error) {
+	sub := types.VendorSubscription{}
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return sub, err
+	}
+	err = main.Preload("Plan").Where("vendor_id = ?", vendor.ID).First(&sub).Error
+	return sub, err
+}
+
+// billingError maps billing errors onto responses, fallback is the message
+// for unexpected errors.
+func billingError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, billing.ErrPaymentFailed):
+		return c.JSON(http.StatusPaymentRequired, map[string]string{"error": err.Error()})
+	case errors.Is(err, billing.ErrPlanInactive),
+		errors.Is(err, billing.ErrSamePlan),
+		errors.Is(err, billing.ErrCurrencyMismatch),
+		errors.Is(err, billing.ErrPaymentRequired):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, billing.ErrAlreadySubscribed),
+		errors.Is(err, billing.ErrInvalidSubState),
+		errors.Is(err, billing.ErrInvalidInvoiceState):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": fallback})
+}
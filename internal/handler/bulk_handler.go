@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/bulk"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxImportFileSize bounds an uploaded product import file.
+const maxImportFileSize = 20 << 20
+
+type (
+	BulkHandler struct {
+	}
+	BulkHandlerInterface interface {
+		Import(c echo.Context) error
+		Export(c echo.Context) error
+		ListImports(c echo.Context) error
+		GetImport(c echo.Context) error
+		ImportRows(c echo.Context) error
+		ImportErrors(c echo.Context) error
+	}
+)
+
+func NewBulkHandler() BulkHandlerInterface {
+	return &BulkHandler{}
+}
+
+// Import queues a CSV or JSON product file for import. The format comes from
+// the format form field or else the file extension.
+func (h *BulkHandler) Import(c echo.Context) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+	if header.Size > maxImportFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+	}
+	format := strings.ToLower(c.FormValue("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading file"})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading file"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	job, err := bulk.Enqueue(db, format, header.Filename, data)
+	if errors.Is(err, bulk.ErrInvalidFormat) || errors.Is(err, bulk.ErrInvalidFile) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error queueing import"})
+	}
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// Export streams the whole catalog as CSV (the default) or JSON.
+func (h *BulkHandler) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = bulk.FormatCSV
+	}
+	if format != bulk.FormatCSV && format != bulk.FormatJSON {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": bulk.ErrInvalidFormat.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	contentType := "text/csv"
+	if format == bulk.FormatJSON {
+		contentType = echo.MIMEApplicationJSON
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "products."+format))
+	res.WriteHeader(http.StatusOK)
+	// the status is already sent, a failure can only cut the stream short
+	return bulk.Export(db, format, res)
+}
+
+func (h *BulkHandler) ListImports(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.ImportJob{}).Omit("data").Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var jobs []types.ImportJob
+	res, err := findPage(c, query, &jobs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching imports"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *BulkHandler) GetImport(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	job := types.ImportJob{}
+	if err := db.Omit("data").First(&job, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+// ImportRows lists the per row results of an import, optionally only those
+// with the given status.
+func (h *BulkHandler) ImportRows(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	job := types.ImportJob{}
+	if err := db.Omit("data").First(&job, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	query := db.Model(&types.ImportRow{}).Where("job_id = ?", job.ID).Order("line, id")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var rows []types.ImportRow
+	res, err := findPage(c, query, &rows)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching import rows"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ImportErrors downloads the failed rows of an import in the format of the
+// uploaded file, ready to be fixed and imported again.
+func (h *BulkHandler) ImportErrors(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	job := types.ImportJob{}
+	if err := db.First(&job, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	if job.Status == bulk.JobQueued || job.Status == bulk.JobRunning {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "import is not finished"})
+	}
+
+	contentType := "text/csv"
+	if job.Format == bulk.FormatJSON {
+		contentType = echo.MIMEApplicationJSON
+	}
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("import-%d-errors.%s", job.ID, job.Format)))
+	res.WriteHeader(http.StatusOK)
+	return bulk.ErrorFile(db, job, res)
+}
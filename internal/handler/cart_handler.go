@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// cartTokenHeader carries the token of a guest cart.
+const cartTokenHeader = "X-Cart-Token"
+
+type (
+	CartHandler struct {
+	}
+	CartHandlerInterface interface {
+		Create(c echo.Context) error
+		Get(c echo.Context) error
+		AddItem(c echo.Context) error
+		UpdateItem(c echo.Context) error
+		RemoveItem(c echo.Context) error
+		Merge(c echo.Context) error
+		ApplyCode(c echo.Context) error
+		RemoveCode(c echo.Context) error
+		SetCurrency(c echo.Context) error
+		SetEmail(c echo.Context) error
+	}
+	applyCodeRequest struct {
+		Code string `json:"code" validate:"required,max=50"`
+	}
+	addCartItemRequest struct {
+		VariantID uint `json:"variant_id" validate:"required"`
+		Quantity  int  `json:"quantity" validate:"required,gt=0"`
+	}
+	updateCartItemRequest struct {
+		Quantity int `json:"quantity" validate:"gte=0"`
+	}
+	mergeCartRequest struct {
+		Token string `json:"token" validate:"required"`
+	}
+	cartCurrencyRequest struct {
+		Currency string `json:"currency" validate:"required,len=3"`
+	}
+	cartEmailRequest struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+)
+
+func NewCartHandler() CartHandlerInterface {
+	return &CartHandler{}
+}
+
+// Create starts a guest cart, or returns the open cart of a signed in
+// customer.
+func (h *CartHandler) Create(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var current types.Cart
+	var err error
+	if userID, ok := currentUserID(c); ok {
+		current, err = cart.ForUser(db, userID)
+	} else {
+		current, err = cart.New(db, nil)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating cart"})
+	}
+
+	return h.respond(c, db, current, http.StatusCreated)
+}
+
+func (h *CartHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	return h.respond(c, db, current, http.StatusOK)
+}
+
+func (h *CartHandler) AddItem(c echo.Context) error {
+	var req addCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.AddItem(db, &current, req.VariantID, req.Quantity); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+func (h *CartHandler) UpdateItem(c echo.Context) error {
+	var req updateCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid variant id"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.SetQuantity(db, &current, uint(variantID), req.Quantity); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+func (h *CartHandler) RemoveItem(c echo.Context) error {
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid variant id"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.SetQuantity(db, &current, uint(variantID), 0); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+// Merge folds a guest cart into the cart of the signed in customer. It is
+// called by the storefront right after login.
+func (h *CartHandler) Merge(c echo.Context) error {
+	var req mergeCartRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	guest, err := cart.ByToken(db, req.Token)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if guest.UserID != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "only guest carts can be merged"})
+	}
+	customer, err := cart.ForUser(db, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching cart"})
+	}
+	if err := cart.Merge(db, &guest, &customer); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error merging carts"})
+	}
+
+	return h.reload(c, db, customer)
+}
+
+// ApplyCode enters a discount code on the cart. The cart response explains
+// whether it applies.
+func (h *CartHandler) ApplyCode(c echo.Context) error {
+	var req applyCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.ApplyCode(db, &current, req.Code); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+func (h *CartHandler) RemoveCode(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.RemoveCode(db, &current, c.Param("code")); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+// SetCurrency switches the cart to another enabled currency.
+func (h *CartHandler) SetCurrency(c echo.Context) error {
+	var req cartCurrencyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.SetCurrency(db, &current, req.Currency); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+// SetEmail records the email of a guest before checkout, so the cart can be
+// followed up if it is abandoned.
+func (h *CartHandler) SetEmail(c echo.Context) error {
+	var req cartEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	if err := cart.SetEmail(db, &current, req.Email); err != nil {
+		return cartError(c, err)
+	}
+
+	return h.reload(c, db, current)
+}
+
+// reload reads the cart back after a change and responds with it.
+func (h *CartHandler) reload(c echo.Context, db *gorm.DB, current types.Cart) error {
+	if err := db.Preload("Items").First(&current, current.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching cart"})
+	}
+	return h.respond(c, db, current, http.StatusOK)
+}
+
+func (h *CartHandler) respond(c echo.Context, db *gorm.DB, current types.Cart, status int) error {
+	summary, err := cart.Calculate(db, current)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error calculating cart"})
+	}
+	return c.JSON(status, summary)
+}
+
+// resolveCart finds the cart of the request: the signed in customer's cart,
+// or the guest cart named by the cart token header.
+func resolveCart(c echo.Context, db *gorm.DB) (types.Cart, error) {
+	if userID, ok := currentUserID(c); ok {
+		return cart.ForUser(db, userID)
+	}
+	return cart.ByToken(db, c.Request().Header.Get(cartTokenHeader))
+}
+
+func cartError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, cart.ErrCartNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, cart.ErrVariantUnavailable), errors.Is(err, inventory.ErrInsufficientStock),
+		errors.Is(err, cart.ErrUnknownCode), errors.Is(err, currency.ErrCurrencyNotEnabled),
+		errors.Is(err, currency.ErrNoExchangeRate):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating cart"})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	CategoryHandler struct {
+	}
+	CategoryHandlerInterface interface {
+		Tree(c echo.Context) error
+		Create(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+		Move(c echo.Context) error
+		Reorder(c echo.Context) error
+	}
+	createCategoryRequest struct {
+		ParentID *uint  `json:"parent_id"`
+		Name     string `json:"name" validate:"required,min=1,max=255"`
+		Handle   string `json:"handle" validate:"required,min=1,max=255"`
+	}
+	updateCategoryRequest struct {
+		Name   string `json:"name" validate:"required,min=1,max=255"`
+		Handle string `json:"handle" validate:"required,min=1,max=255"`
+	}
+	moveCategoryRequest struct {
+		ParentID *uint `json:"parent_id"`
+		Position int   `json:"position"`
+	}
+	reorderCategoriesRequest struct {
+		ParentID *uint  `json:"parent_id"`
+		IDs      []uint `json:"ids" validate:"required,min=1"`
+	}
+)
+
+func NewCategoryHandler() CategoryHandlerInterface {
+	return &CategoryHandler{}
+}
+
+func (h *CategoryHandler) Tree(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	tree, err := catalog.Tree(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching categories"})
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) Create(c echo.Context) error {
+	var req createCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if handle already exists
+	if err := db.Where("handle = ?", req.Handle).First(&types.Category{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+
+	category := types.Category{
+		ParentID: req.ParentID,
+		Name:     req.Name,
+		Handle:   req.Handle,
+	}
+	if err := catalog.CreateCategory(db, &category); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "parent category does not exist"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating category"})
+	}
+
+	return c.JSON(http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) Update(c echo.Context) error {
+	var req updateCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	category := types.Category{}
+	if err := db.First(&category, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	}
+	// check if handle is taken by another category
+	if err := db.Where("handle = ? AND id <> ?", req.Handle, category.ID).First(&types.Category{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+
+	if err := db.Model(&category).Updates(types.Category{Name: req.Name, Handle: req.Handle}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating category"})
+	}
+
+	return c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid category id"})
+	}
+
+	if err := catalog.DeleteCategory(db, uint(id)); err != nil {
+		if errors.Is(err, catalog.ErrCategoryNotEmpty) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting category"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CategoryHandler) Move(c echo.Context) error {
+	var req moveCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid category id"})
+	}
+
+	if err := catalog.MoveCategory(db, uint(id), req.ParentID, req.Position); err != nil {
+		switch {
+		case errors.Is(err, catalog.ErrCategoryCycle):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error moving category"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CategoryHandler) Reorder(c echo.Context) error {
+	var req reorderCategoriesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	if err := catalog.ReorderCategories(db, req.ParentID, req.IDs); err != nil {
+		if errors.Is(err, catalog.ErrNotSiblings) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error reordering categories"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
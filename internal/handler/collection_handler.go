@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	CollectionHandler struct {
+	}
+	CollectionHandlerInterface interface {
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Create(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+		AddProduct(c echo.Context) error
+		RemoveProduct(c echo.Context) error
+		Refresh(c echo.Context) error
+	}
+	collectionRequest struct {
+		Title       string `json:"title" validate:"required,min=1,max=255"`
+		Handle      string `json:"handle" validate:"required,min=1,max=255"`
+		Description string `json:"description"`
+		Smart       bool   `json:"smart"`
+		Rules       string `json:"rules" validate:"required_if=Smart true"`
+	}
+	collectionProductRequest struct {
+		ProductID uint `json:"product_id" validate:"required"`
+		Position  *int `json:"position"`
+	}
+)
+
+func NewCollectionHandler() CollectionHandlerInterface {
+	return &CollectionHandler{}
+}
+
+func (h *CollectionHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var collections []types.Collection
+	res, err := findPage(c, db.Model(&types.Collection{}).Order("id DESC"), &collections)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching collections"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *CollectionHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var collection types.Collection
+	if err := db.Preload("Products", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+	return c.JSON(http.StatusOK, collection)
+}
+
+func (h *CollectionHandler) Create(c echo.Context) error {
+	var req collectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if req.Smart {
+		if _, err := catalog.ParseRules(req.Rules); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rules: " + err.Error()})
+		}
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if handle already exists
+	if err := db.Where("handle = ?", req.Handle).First(&types.Collection{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+
+	collection := types.Collection{
+		Title:       req.Title,
+		Handle:      req.Handle,
+		Description: req.Description,
+		Smart:       req.Smart,
+		Rules:       req.Rules,
+	}
+	if err := db.Create(&collection).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating collection"})
+	}
+	if collection.Smart {
+		if err := catalog.RebuildCollection(db, collection); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error evaluating collection rules"})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, collection)
+}
+
+func (h *CollectionHandler) Update(c echo.Context) error {
+	var req collectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if req.Smart {
+		if _, err := catalog.ParseRules(req.Rules); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rules: " + err.Error()})
+		}
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+	// check if handle is taken by another collection
+	if err := db.Where("handle = ? AND id <> ?", req.Handle, collection.ID).First(&types.Collection{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+
+	rulesChanged := collection.Smart != req.Smart || collection.Rules != req.Rules
+	collection.Title = req.Title
+	collection.Handle = req.Handle
+	collection.Description = req.Description
+	collection.Smart = req.Smart
+	collection.Rules = req.Rules
+	if err := db.Save(&collection).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating collection"})
+	}
+	if collection.Smart && rulesChanged {
+		if err := catalog.RebuildCollection(db, collection); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error evaluating collection rules"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, collection)
+}
+
+func (h *CollectionHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&types.CollectionProduct{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&collection).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting collection"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CollectionHandler) AddProduct(c echo.Context) error {
+	var req collectionProductRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+	if collection.Smart {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "products of a smart collection are managed by its rules"})
+	}
+	if err := db.First(&types.Product{}, req.ProductID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "product does not exist"})
+	}
+
+	member := types.CollectionProduct{CollectionID: collection.ID, ProductID: req.ProductID}
+	if req.Position != nil {
+		member.Position = *req.Position
+	} else {
+		db.Model(&types.CollectionProduct{}).Where("collection_id = ?", collection.ID).Select("COALESCE(MAX(position), -1) + 1").Scan(&member.Position)
+	}
+	if err := db.Save(&member).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error adding product"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CollectionHandler) RemoveProduct(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+	if collection.Smart {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "products of a smart collection are managed by its rules"})
+	}
+
+	if err := db.Where("collection_id = ? AND product_id = ?", collection.ID, c.Param("product_id")).Delete(&types.CollectionProduct{}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing product"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CollectionHandler) Refresh(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.First(&collection, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+	if !collection.Smart {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "only smart collections can be refreshed"})
+	}
+
+	if err := catalog.RebuildCollection(db, collection); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error evaluating collection rules"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
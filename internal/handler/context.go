@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// currentUserID returns the id of the signed in customer, if any. Customer
+// authentication (CustomerAuthMiddleware) stores it on the context under
+// "user_id" from the bearer token of a session.
+func currentUserID(c echo.Context) (uint, bool) {
+	id, ok := c.Get("user_id").(uint)
+	return id, ok && id != 0
+}
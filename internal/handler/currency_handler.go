@@ -0,0 +1,307 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/tax"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxRateFileSize bounds an uploaded exchange rate file.
+const maxRateFileSize = 1 << 20
+
+type (
+	CurrencyHandler struct {
+	}
+	CurrencyHandlerInterface interface {
+		Available(c echo.Context) error
+		GetSettings(c echo.Context) error
+		UpdateSettings(c echo.Context) error
+		ListCurrencies(c echo.Context) error
+		SaveCurrency(c echo.Context) error
+		DeleteCurrency(c echo.Context) error
+		ListRates(c echo.Context) error
+		ImportRates(c echo.Context) error
+		ListPriceLists(c echo.Context) error
+		GetPriceList(c echo.Context) error
+		CreatePriceList(c echo.Context) error
+		UpdatePriceList(c echo.Context) error
+		DeletePriceList(c echo.Context) error
+	}
+	currencySettingsRequest struct {
+		BaseCurrency string `json:"base_currency" validate:"required,len=3"`
+		RoundingMode string `json:"rounding_mode" validate:"required"`
+	}
+	currencyRequest struct {
+		Enabled           *bool  `json:"enabled"`
+		RoundingMode      string `json:"rounding_mode"`
+		RoundingIncrement int64  `json:"rounding_increment" validate:"min=0"`
+	}
+	priceListEntryRequest struct {
+		VariantID uint  `json:"variant_id" validate:"required"`
+		Price     int64 `json:"price" validate:"min=0"`
+	}
+	priceListRequest struct {
+		Name     string                  `json:"name" validate:"required,max=100"`
+		Currency string                  `json:"currency" validate:"required,len=3"`
+		Mode     string                  `json:"mode" validate:"required"`
+		Active   *bool                   `json:"active"`
+		Entries  []priceListEntryRequest `json:"entries" validate:"dive"`
+	}
+	availableCurrenciesResponse struct {
+		Base       string   `json:"base"`
+		Currencies []string `json:"currencies"`
+	}
+)
+
+func NewCurrencyHandler() CurrencyHandlerInterface {
+	return &CurrencyHandler{}
+}
+
+// Available lists the currencies shoppers may pick.
+func (h *CurrencyHandler) Available(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	codes, err := currency.Available(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching currencies"})
+	}
+	return c.JSON(http.StatusOK, availableCurrenciesResponse{Base: codes[0], Currencies: codes})
+}
+
+func (h *CurrencyHandler) GetSettings(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := currency.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching currency settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+func (h *CurrencyHandler) UpdateSettings(c echo.Context) error {
+	var req currencySettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	code := currency.NormalizeCode(req.BaseCurrency)
+	if !currency.ValidCode(code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": currency.ErrInvalidCode.Error()})
+	}
+	if !tax.ValidRoundingMode(req.RoundingMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": tax.ErrInvalidRoundingMode.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := currency.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching currency settings"})
+	}
+	settings.BaseCurrency = code
+	settings.RoundingMode = req.RoundingMode
+	if err := db.Save(&settings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving currency settings"})
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+func (h *CurrencyHandler) ListCurrencies(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	currencies := []types.Currency{}
+	if err := db.Order("code").Find(&currencies).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching currencies"})
+	}
+	return c.JSON(http.StatusOK, currencies)
+}
+
+// SaveCurrency adds or changes the presentment currency named by the path.
+func (h *CurrencyHandler) SaveCurrency(c echo.Context) error {
+	var req currencyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	code := currency.NormalizeCode(c.Param("code"))
+	if !currency.ValidCode(code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": currency.ErrInvalidCode.Error()})
+	}
+	if req.RoundingMode != "" && !tax.ValidRoundingMode(req.RoundingMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": tax.ErrInvalidRoundingMode.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	record := types.Currency{}
+	db.Where("code = ?", code).First(&record)
+	record.Code = code
+	record.Enabled = req.Enabled == nil || *req.Enabled
+	record.RoundingMode = req.RoundingMode
+	record.RoundingIncrement = req.RoundingIncrement
+	if err := db.Save(&record).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving currency"})
+	}
+
+	return c.JSON(http.StatusOK, record)
+}
+
+func (h *CurrencyHandler) DeleteCurrency(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Where("code = ?", currency.NormalizeCode(c.Param("code"))).Delete(&types.Currency{}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing currency"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *CurrencyHandler) ListRates(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	rates := []types.ExchangeRate{}
+	if err := db.Order("from_currency, to_currency").Find(&rates).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching exchange rates"})
+	}
+	return c.JSON(http.StatusOK, rates)
+}
+
+// ImportRates loads the exchange rate CSV uploaded as the "file" form field.
+func (h *CurrencyHandler) ImportRates(c echo.Context) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+	if header.Size > maxRateFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading file"})
+	}
+	defer file.Close()
+
+	db := c.Get("db").(*gorm.DB)
+
+	imported, err := currency.ImportRates(db, file, header.Filename)
+	if err != nil {
+		if errors.Is(err, currency.ErrInvalidRateFile) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error importing exchange rates"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"imported": imported})
+}
+
+func (h *CurrencyHandler) ListPriceLists(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	lists := []types.PriceList{}
+	if err := db.Order("currency").Find(&lists).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching price lists"})
+	}
+	return c.JSON(http.StatusOK, lists)
+}
+
+func (h *CurrencyHandler) GetPriceList(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list := types.PriceList{}
+	if err := db.Preload("Entries").First(&list, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "price list not found"})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *CurrencyHandler) CreatePriceList(c echo.Context) error {
+	return h.savePriceList(c, types.PriceList{}, http.StatusCreated)
+}
+
+func (h *CurrencyHandler) UpdatePriceList(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list := types.PriceList{}
+	if err := db.First(&list, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "price list not found"})
+	}
+	return h.savePriceList(c, list, http.StatusOK)
+}
+
+// savePriceList writes a price list and replaces its entries.
+func (h *CurrencyHandler) savePriceList(c echo.Context, list types.PriceList, status int) error {
+	var req priceListRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	code := currency.NormalizeCode(req.Currency)
+	if !currency.ValidCode(code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": currency.ErrInvalidCode.Error()})
+	}
+	if !currency.ValidMode(req.Mode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": currency.ErrInvalidMode.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if the currency already has a price list
+	if err := db.Where("currency = ? AND id <> ?", code, list.ID).First(&types.PriceList{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "a price list for this currency already exists"})
+	}
+
+	list.Name = req.Name
+	list.Currency = code
+	list.Mode = req.Mode
+	list.Active = req.Active == nil || *req.Active
+	err := db.Transaction(func(tx *gorm.DB) error {
+		list.Entries = nil
+		if err := tx.Save(&list).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("price_list_id = ?", list.ID).Delete(&types.PriceListEntry{}).Error; err != nil {
+			return err
+		}
+		seen := map[uint]bool{}
+		for _, entry := range req.Entries {
+			if seen[entry.VariantID] {
+				continue
+			}
+			seen[entry.VariantID] = true
+			list.Entries = append(list.Entries, types.PriceListEntry{
+				PriceListID: list.ID,
+				VariantID:   entry.VariantID,
+				Price:       entry.Price,
+			})
+		}
+		if len(list.Entries) == 0 {
+			return nil
+		}
+		return tx.Create(&list.Entries).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving price list"})
+	}
+
+	return c.JSON(status, list)
+}
+
+func (h *CurrencyHandler) DeletePriceList(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Delete(&types.PriceList{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing price list"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
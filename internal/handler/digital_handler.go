@@ -0,0 +1,430 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/digital"
+	"github.com/Satishcg12/multicommers/internal/storage"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// downloadPath is where the download links of grants point to.
+const downloadPath = "/api/store/downloads/"
+
+var (
+	errVariantNotFound = errors.New("variant not found")
+	errMediaNotFound   = errors.New("media not found")
+)
+
+type (
+	DigitalHandler struct {
+	}
+	DigitalHandlerInterface interface {
+		ListAssets(c echo.Context) error
+		CreateAsset(c echo.Context) error
+		UpdateAsset(c echo.Context) error
+		DeleteAsset(c echo.Context) error
+		OrderDownloads(c echo.Context) error
+		ReissueDownload(c echo.Context) error
+		ListLicenses(c echo.Context) error
+		RevokeLicense(c echo.Context) error
+		MyDownloads(c echo.Context) error
+		Download(c echo.Context) error
+		VerifyLicense(c echo.Context) error
+	}
+	digitalAssetRequest struct {
+		VariantID    uint   `json:"variant_id" validate:"required"`
+		MediaID      uint   `json:"media_id" validate:"required"`
+		Name         string `json:"name" validate:"required,max=255"`
+		MaxDownloads int    `json:"max_downloads" validate:"gte=0,lte=1000"`
+		ExpiryHours  int    `json:"expiry_hours" validate:"gte=0,lte=8760"`
+		IssueLicense bool   `json:"issue_license"`
+	}
+	verifyLicenseRequest struct {
+		Key string `json:"key" validate:"required"`
+		SKU string `json:"sku"`
+	}
+	downloadResponse struct {
+		ID           uint       `json:"id"`
+		OrderLineID  uint       `json:"order_line_id"`
+		Name         string     `json:"name"`
+		Filename     string     `json:"filename"`
+		ContentType  string     `json:"content_type"`
+		Size         int64      `json:"size"`
+		Downloads    int        `json:"downloads"`
+		MaxDownloads int        `json:"max_downloads"`
+		ExpiresAt    time.Time  `json:"expires_at"`
+		Revoked      bool       `json:"revoked"`
+		LastDownload *time.Time `json:"last_download_at"`
+		URL          string     `json:"url,omitempty"`
+	}
+	orderDownloadsResponse struct {
+		Downloads []downloadResponse `json:"downloads"`
+		Licenses  []types.LicenseKey `json:"licenses"`
+	}
+	licenseStatusResponse struct {
+		Valid     bool      `json:"valid"`
+		Status    string    `json:"status"`
+		ProductID uint      `json:"product_id"`
+		SKU       string    `json:"sku"`
+		Seats     int       `json:"seats"`
+		IssuedAt  time.Time `json:"issued_at"`
+	}
+)
+
+func NewDigitalHandler() DigitalHandlerInterface {
+	return &DigitalHandler{}
+}
+
+func (h *DigitalHandler) ListAssets(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.DigitalAsset{}).Order("id DESC")
+	if variantID := c.QueryParam("variant_id"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	var assets []types.DigitalAsset
+	res, err := findPage(c, query, &assets, "Media")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching digital assets"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// CreateAsset attaches an uploaded private file to a variant. Orders paid
+// from then on get download links to it.
+func (h *DigitalHandler) CreateAsset(c echo.Context) error {
+	var req digitalAssetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	asset := types.DigitalAsset{}
+	if err := h.apply(db, &asset, req); err != nil {
+		return digitalError(c, err)
+	}
+	if err := db.Create(&asset).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating digital asset"})
+	}
+	if err := db.Preload("Media").First(&asset, asset.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching digital asset"})
+	}
+	return c.JSON(http.StatusCreated, asset)
+}
+
+// UpdateAsset changes an asset. A new file reaches every buyer, limits only
+// apply to orders paid afterwards.
+func (h *DigitalHandler) UpdateAsset(c echo.Context) error {
+	var req digitalAssetRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	asset := types.DigitalAsset{}
+	if err := db.First(&asset, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "digital asset not found"})
+	}
+	if err := h.apply(db, &asset, req); err != nil {
+		return digitalError(c, err)
+	}
+	if err := db.Save(&asset).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating digital asset"})
+	}
+	if err := db.Preload("Media").First(&asset, asset.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching digital asset"})
+	}
+	return c.JSON(http.StatusOK, asset)
+}
+
+// DeleteAsset removes an asset nobody has bought yet.
+func (h *DigitalHandler) DeleteAsset(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	asset := types.DigitalAsset{}
+	if err := db.First(&asset, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "digital asset not found"})
+	}
+	var grants int64
+	if err := db.Model(&types.DownloadGrant{}).Where("asset_id = ?", asset.ID).Count(&grants).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting digital asset"})
+	}
+	if grants > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "digital asset has been sold and cannot be deleted"})
+	}
+	if err := db.Delete(&asset).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting digital asset"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *DigitalHandler) OrderDownloads(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+	res, err := orderDownloads(db, o, false, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching downloads"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ReissueDownload resets the download count and expiry of a grant. Links
+// handed out before stop working, the customer fetches new ones.
+func (h *DigitalHandler) ReissueDownload(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	grant := types.DownloadGrant{}
+	if err := db.Preload("Asset").First(&grant, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "download not found"})
+	}
+	if err := digital.Reissue(db, &grant, grant.Asset, time.Now()); err != nil {
+		if errors.Is(err, digital.ErrRevoked) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error reissuing download"})
+	}
+	return c.JSON(http.StatusOK, grant)
+}
+
+func (h *DigitalHandler) ListLicenses(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.LicenseKey{}).Order("id DESC")
+	if orderID := c.QueryParam("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if sku := c.QueryParam("sku"); sku != "" {
+		query = query.Where("sku = ?", sku)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var licenses []types.LicenseKey
+	res, err := findPage(c, query, &licenses)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching licenses"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *DigitalHandler) RevokeLicense(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	license := types.LicenseKey{}
+	if err := db.First(&license, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "license not found"})
+	}
+	if err := db.Model(&license).Updates(map[string]interface{}{
+		"status":     digital.LicenseRevoked,
+		"revoked_at": time.Now(),
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error revoking license"})
+	}
+	if err := db.First(&license, license.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching license"})
+	}
+	return c.JSON(http.StatusOK, license)
+}
+
+// MyDownloads lists the download links and license keys of a customer's
+// order.
+func (h *DigitalHandler) MyDownloads(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	o, err := customerOrder(c, db, c.QueryParam("email"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+	res, err := orderDownloads(db, o, true, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching downloads"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Download streams the file of a grant to the holder of a signed link.
+// Single byte ranges are supported so interrupted downloads can resume.
+// Every request counts the bytes it asks for against the grant's allowance
+// and gives back what could not be sent; only requests starting at the first
+// byte count as a download.
+func (h *DigitalHandler) Download(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+	store := c.Get("storage").(storage.Storage)
+	now := time.Now()
+
+	grant := types.DownloadGrant{}
+	if err := db.Preload("Asset.Media").First(&grant, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "download not found"})
+	}
+	if err := digital.Verify(grant, c.QueryParam("expires"), c.QueryParam("signature"), now); err != nil {
+		return digitalError(c, err)
+	}
+
+	file := grant.Asset.Media
+	offset, length, partial, err := digital.ParseRange(c.Request().Header.Get("Range"), file.Size)
+	if err != nil {
+		c.Response().Header().Set("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+		return c.JSON(http.StatusRequestedRangeNotSatisfiable, map[string]string{"error": err.Error()})
+	}
+	if !partial {
+		offset, length = 0, file.Size
+	}
+	if offset > 0 && grant.Downloads == 0 {
+		return c.JSON(http.StatusRequestedRangeNotSatisfiable, map[string]string{"error": "download has not been started"})
+	}
+	if err := digital.Claim(db, &grant, file.Size, offset, length, now); err != nil {
+		return digitalError(c, err)
+	}
+
+	body, err := store.GetRange(c.Request().Context(), file.Key, offset, length)
+	if err != nil {
+		digital.Release(db, &grant, length)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error reading file"})
+	}
+	defer body.Close()
+	defer func() {
+		digital.Release(db, &grant, length-c.Response().Size)
+	}()
+
+	filename := file.Filename
+	if filename == "" {
+		filename = grant.Asset.Name
+	}
+	header := c.Response().Header()
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	status := http.StatusOK
+	if partial {
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, file.Size))
+		status = http.StatusPartialContent
+	}
+	return c.Stream(status, file.ContentType, body)
+}
+
+// VerifyLicense tells whether a license key is valid, optionally for a
+// given SKU. It is meant to be called by the licensed software.
+func (h *DigitalHandler) VerifyLicense(c echo.Context) error {
+	var req verifyLicenseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	license, err := digital.FindLicense(db, req.Key)
+	if errors.Is(err, digital.ErrLicenseNotFound) || (err == nil && req.SKU != "" && req.SKU != license.SKU) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": digital.ErrLicenseNotFound.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error verifying license"})
+	}
+	return c.JSON(http.StatusOK, licenseStatusResponse{
+		Valid:     license.Status == digital.LicenseActive,
+		Status:    license.Status,
+		ProductID: license.ProductID,
+		SKU:       license.SKU,
+		Seats:     license.Seats,
+		IssuedAt:  license.CreatedAt,
+	})
+}
+
+// apply copies req onto asset after checking the variant and the file.
+func (h *DigitalHandler) apply(db *gorm.DB, asset *types.DigitalAsset, req digitalAssetRequest) error {
+	if err := db.First(&types.ProductVariant{}, req.VariantID).Error; err != nil {
+		return errVariantNotFound
+	}
+	file := types.MediaFile{}
+	if err := db.First(&file, req.MediaID).Error; err != nil {
+		return errMediaNotFound
+	}
+	if !file.Private {
+		return digital.ErrNotPrivate
+	}
+	if req.MaxDownloads == 0 {
+		req.MaxDownloads = digital.DefaultMaxDownloads
+	}
+	if req.ExpiryHours == 0 {
+		req.ExpiryHours = digital.DefaultExpiryHours
+	}
+	asset.VariantID = req.VariantID
+	asset.MediaID = req.MediaID
+	asset.Name = req.Name
+	asset.MaxDownloads = req.MaxDownloads
+	asset.ExpiryHours = req.ExpiryHours
+	asset.IssueLicense = req.IssueLicense
+	return nil
+}
+
+// orderDownloads collects the grants and licenses of an order. Links are
+// only included for customers and only while the grant can still be used.
+func orderDownloads(db *gorm.DB, o types.Order, links bool, now time.Time) (orderDownloadsResponse, error) {
+	res := orderDownloadsResponse{Downloads: []downloadResponse{}, Licenses: []types.LicenseKey{}}
+
+	var grants []types.DownloadGrant
+	if err := db.Preload("Asset.Media").Where("order_id = ?", o.ID).Order("id").Find(&grants).Error; err != nil {
+		return res, err
+	}
+	for _, grant := range grants {
+		item := downloadResponse{
+			ID:           grant.ID,
+			OrderLineID:  grant.OrderLineID,
+			Name:         grant.Asset.Name,
+			Filename:     grant.Asset.Media.Filename,
+			ContentType:  grant.Asset.Media.ContentType,
+			Size:         grant.Asset.Media.Size,
+			Downloads:    grant.Downloads,
+			MaxDownloads: grant.MaxDownloads,
+			ExpiresAt:    grant.ExpiresAt,
+			Revoked:      grant.Revoked,
+			LastDownload: grant.LastDownloadAt,
+		}
+		if links && digital.Active(grant, now) == nil && grant.Downloads < grant.MaxDownloads {
+			item.URL = downloadPath + strconv.FormatUint(uint64(grant.ID), 10) + "?" + digital.LinkQuery(grant)
+		}
+		res.Downloads = append(res.Downloads, item)
+	}
+
+	if err := db.Where("order_id = ?", o.ID).Order("id").Find(&res.Licenses).Error; err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// digitalError maps digital delivery errors to responses.
+func digitalError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errVariantNotFound), errors.Is(err, errMediaNotFound), errors.Is(err, digital.ErrNotPrivate):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, digital.ErrBadSignature):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, digital.ErrExpired), errors.Is(err, digital.ErrRevoked), errors.Is(err, digital.ErrLimitReached):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing download"})
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/fulfillment"
+	"github.com/Satishcg12/multicommers/internal/notification"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	FulfillmentHandler struct {
+	}
+	FulfillmentHandlerInterface interface {
+		OrderShipments(c echo.Context) error
+		CreateShipment(c echo.Context) error
+		DeleteShipment(c echo.Context) error
+		AddEvent(c echo.Context) error
+		Track(c echo.Context) error
+	}
+	shipmentLineRequest struct {
+		OrderLineID uint `json:"order_line_id" validate:"required"`
+		Quantity    int  `json:"quantity" validate:"required,min=1"`
+	}
+	createShipmentRequest struct {
+		LocationID     uint                  `json:"location_id" validate:"required"`
+		Carrier        string                `json:"carrier" validate:"required,max=50"`
+		TrackingNumber string                `json:"tracking_number" validate:"max=100"`
+		Lines          []shipmentLineRequest `json:"lines" validate:"required,min=1,dive"`
+	}
+	shipmentEventRequest struct {
+		Status      string     `json:"status" validate:"required"`
+		Description string     `json:"description"`
+		Location    string     `json:"location" validate:"max=255"`
+		OccurredAt  *time.Time `json:"occurred_at"`
+	}
+	trackingResponse struct {
+		Number    string           `json:"number"`
+		Status    string           `json:"status"`
+		Shipments []types.Shipment `json:"shipments"`
+	}
+)
+
+func NewFulfillmentHandler() FulfillmentHandlerInterface {
+	return &FulfillmentHandler{}
+}
+
+func (h *FulfillmentHandler) OrderShipments(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	shipments, err := findShipments(db, c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching shipments"})
+	}
+	return c.JSON(http.StatusOK, shipments)
+}
+
+// CreateShipment packs lines of a paid order into a shipment.
+func (h *FulfillmentHandler) CreateShipment(c echo.Context) error {
+	var req createShipmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	in := fulfillment.ShipmentInput{
+		LocationID:     req.LocationID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	}
+	for _, line := range req.Lines {
+		in.Lines = append(in.Lines, fulfillment.LineInput{OrderLineID: line.OrderLineID, Quantity: line.Quantity})
+	}
+
+	shipment, err := fulfillment.CreateShipment(db, &o, in)
+	if err != nil {
+		return fulfillmentError(c, err)
+	}
+	return c.JSON(http.StatusCreated, shipment)
+}
+
+// DeleteShipment removes a shipment the carrier has not picked up yet.
+func (h *FulfillmentHandler) DeleteShipment(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	shipment := types.Shipment{}
+	if err := db.First(&shipment, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipment not found"})
+	}
+	if err := fulfillment.DeleteShipment(db, &shipment); err != nil {
+		return fulfillmentError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// AddEvent records a tracking update. When it moves the shipment forward the
+// customer is told by email.
+func (h *FulfillmentHandler) AddEvent(c echo.Context) error {
+	var req shipmentEventRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	shipment := types.Shipment{}
+	if err := db.First(&shipment, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipment not found"})
+	}
+	o := types.Order{}
+	if err := db.First(&o, shipment.OrderID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching order"})
+	}
+
+	in := fulfillment.EventInput{
+		Status:      req.Status,
+		Description: req.Description,
+		Location:    req.Location,
+	}
+	if req.OccurredAt != nil {
+		in.OccurredAt = *req.OccurredAt
+	}
+	advanced, err := fulfillment.Record(db, &o, &shipment, in)
+	if err != nil {
+		return fulfillmentError(c, err)
+	}
+
+	// only tell the customer about news
+	if advanced && o.Email != "" {
+		mailer := c.Get("mailer").(email.EmailDaemonInterface)
+		mailer.Send(notification.ShipmentUpdate(o, shipment))
+	}
+
+	return c.JSON(http.StatusOK, shipment)
+}
+
+// Track shows the shipments of an order to anyone knowing its number and the
+// email it was placed with.
+func (h *FulfillmentHandler) Track(c echo.Context) error {
+	number, address := c.QueryParam("number"), c.QueryParam("email")
+	if number == "" || address == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "number and email are required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.Where("number = ? AND LOWER(email) = LOWER(?)", number, address).First(&o).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	shipments, err := findShipments(db, o.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching shipments"})
+	}
+	return c.JSON(http.StatusOK, trackingResponse{Number: o.Number, Status: o.Status, Shipments: shipments})
+}
+
+func findShipments(db *gorm.DB, orderID interface{}) ([]types.Shipment, error) {
+	shipments := []types.Shipment{}
+	err := db.Preload("Lines").Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at, id")
+	}).Where("order_id = ?", orderID).Order("id").Find(&shipments).Error
+	return shipments, err
+}
+
+func fulfillmentError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, fulfillment.ErrInvalidLine),
+		errors.Is(err, fulfillment.ErrInvalidQuantity),
+		errors.Is(err, fulfillment.ErrInvalidStatus),
+		errors.Is(err, fulfillment.ErrUnknownLocation),
+		errors.Is(err, fulfillment.ErrNothingToShip):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, fulfillment.ErrNotFulfillable),
+		errors.Is(err, fulfillment.ErrShipmentStarted):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating shipment"})
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/giftcard"
+	"github.com/Satishcg12/multicommers/internal/notification"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	GiftCardHandler struct {
+	}
+	GiftCardHandlerInterface interface {
+		List(c echo.Context) error
+		Issue(c echo.Context) error
+		Get(c echo.Context) error
+		Update(c echo.Context) error
+		Adjust(c echo.Context) error
+		CheckBalance(c echo.Context) error
+	}
+	issueGiftCardRequest struct {
+		Currency       string     `json:"currency" validate:"required,len=3"`
+		Value          int64      `json:"value" validate:"required,gt=0"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+		RecipientEmail string     `json:"recipient_email" validate:"omitempty,email"`
+		Note           string     `json:"note"`
+	}
+	updateGiftCardRequest struct {
+		ExpiresAt *time.Time `json:"expires_at"`
+		Disabled  bool       `json:"disabled"`
+		Note      string     `json:"note"`
+	}
+	adjustBalanceRequest struct {
+		Amount int64  `json:"amount" validate:"required"`
+		Note   string `json:"note" validate:"required"`
+	}
+	giftCardBalanceRequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+	giftCardResponse struct {
+		types.GiftCard
+		Balance int64  `json:"balance"`
+		Code    string `json:"code,omitempty"`
+	}
+)
+
+func NewGiftCardHandler() GiftCardHandlerInterface {
+	return &GiftCardHandler{}
+}
+
+func (h *GiftCardHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.GiftCard{}).Order("id DESC")
+	if last4 := c.QueryParam("last4"); last4 != "" {
+		query = query.Where("last4 = ?", giftcard.NormalizeCode(last4))
+	}
+	if recipient := c.QueryParam("recipient_email"); recipient != "" {
+		query = query.Where("LOWER(recipient_email) = LOWER(?)", recipient)
+	}
+
+	var cards []types.GiftCard
+	res, err := findPage(c, query, &cards)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift cards"})
+	}
+	items := make([]giftCardResponse, 0, len(cards))
+	for _, card := range cards {
+		balance, err := giftcard.Balance(db, card.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift cards"})
+		}
+		items = append(items, giftCardResponse{GiftCard: card, Balance: balance})
+	}
+	res.Items = items
+	return c.JSON(http.StatusOK, res)
+}
+
+// Issue creates a gift card. Its code is only shown in this response and
+// emailed to the recipient when there is one.
+func (h *GiftCardHandler) Issue(c echo.Context) error {
+	var req issueGiftCardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	card, code, err := giftcard.Issue(db, giftcard.IssueInput{
+		Currency:       currency.NormalizeCode(req.Currency),
+		Value:          req.Value,
+		ExpiresAt:      req.ExpiresAt,
+		RecipientEmail: req.RecipientEmail,
+		Note:           req.Note,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error issuing gift card"})
+	}
+
+	if card.RecipientEmail != "" {
+		tenant, _ := c.Get("tenant").(string)
+		mailer := c.Get("mailer").(email.EmailDaemonInterface)
+		mailer.Send(notification.GiftCardIssued(card, code, tenant))
+	}
+
+	return c.JSON(http.StatusCreated, giftCardResponse{GiftCard: card, Balance: card.InitialValue, Code: code})
+}
+
+func (h *GiftCardHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	card := types.GiftCard{}
+	if err := db.Preload("Transactions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&card, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "gift card not found"})
+	}
+	balance, err := giftcard.Balance(db, card.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift card"})
+	}
+	return c.JSON(http.StatusOK, giftCardResponse{GiftCard: card, Balance: balance})
+}
+
+// Update changes the expiry of a card or disables it.
+func (h *GiftCardHandler) Update(c echo.Context) error {
+	var req updateGiftCardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	card := types.GiftCard{}
+	if err := db.First(&card, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "gift card not found"})
+	}
+	if err := db.Model(&card).Updates(map[string]interface{}{
+		"expires_at": req.ExpiresAt,
+		"disabled":   req.Disabled,
+		"note":       req.Note,
+	}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating gift card"})
+	}
+	if err := db.First(&card, card.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift card"})
+	}
+	return c.JSON(http.StatusOK, card)
+}
+
+// Adjust corrects the balance of a card by hand, negative amounts take
+// value off.
+func (h *GiftCardHandler) Adjust(c echo.Context) error {
+	var req adjustBalanceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	card := types.GiftCard{}
+	if err := db.First(&card, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "gift card not found"})
+	}
+	if err := giftcard.Adjust(db, card, req.Amount, req.Note); err != nil {
+		if errors.Is(err, giftcard.ErrInvalidAmount) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "adjustment would make the balance negative"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error adjusting gift card"})
+	}
+	balance, err := giftcard.Balance(db, card.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift card"})
+	}
+	return c.JSON(http.StatusOK, giftCardResponse{GiftCard: card, Balance: balance})
+}
+
+// CheckBalance tells anyone holding a code what is left on the card.
+func (h *GiftCardHandler) CheckBalance(c echo.Context) error {
+	var req giftCardBalanceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	card, err := giftcard.Find(db, req.Code)
+	if err != nil {
+		if errors.Is(err, giftcard.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift card"})
+	}
+	balance, err := giftcard.Balance(db, card.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching gift card"})
+	}
+
+	status := "active"
+	if err := giftcard.Usable(card, time.Now()); err != nil {
+		status = err.Error()
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"last4":      card.Last4,
+		"currency":   card.Currency,
+		"balance":    balance,
+		"expires_at": card.ExpiresAt,
+		"status":     status,
+	})
+}
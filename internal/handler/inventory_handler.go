@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	InventoryHandler struct {
+	}
+	InventoryHandlerInterface interface {
+		ListWarehouses(c echo.Context) error
+		CreateWarehouse(c echo.Context) error
+		UpdateWarehouse(c echo.Context) error
+		CreateLocation(c echo.Context) error
+		UpdateLocation(c echo.Context) error
+		ListLevels(c echo.Context) error
+		Adjust(c echo.Context) error
+		Transfer(c echo.Context) error
+		ListMovements(c echo.Context) error
+		ListReservations(c echo.Context) error
+	}
+	warehouseRequest struct {
+		Name   string `json:"name" validate:"required,min=1,max=255"`
+		Code   string `json:"code" validate:"required,min=1,max=50"`
+		Active bool   `json:"active"`
+	}
+	locationRequest struct {
+		Name     string `json:"name" validate:"required,min=1,max=255"`
+		Code     string `json:"code" validate:"required,min=1,max=50"`
+		Priority int    `json:"priority"`
+		Active   bool   `json:"active"`
+	}
+	adjustStockRequest struct {
+		VariantID  uint   `json:"variant_id" validate:"required"`
+		LocationID uint   `json:"location_id" validate:"required"`
+		Quantity   int    `json:"quantity" validate:"required"`
+		Reason     string `json:"reason" validate:"required"`
+		Reference  string `json:"reference" validate:"max=255"`
+		Note       string `json:"note"`
+	}
+	transferStockRequest struct {
+		VariantID      uint   `json:"variant_id" validate:"required"`
+		FromLocationID uint   `json:"from_location_id" validate:"required"`
+		ToLocationID   uint   `json:"to_location_id" validate:"required"`
+		Quantity       int    `json:"quantity" validate:"required,gt=0"`
+		Reference      string `json:"reference" validate:"max=255"`
+	}
+	inventoryLevelResponse struct {
+		types.InventoryLevel
+		Available int `json:"available"`
+	}
+)
+
+func NewInventoryHandler() InventoryHandlerInterface {
+	return &InventoryHandler{}
+}
+
+func (h *InventoryHandler) ListWarehouses(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var warehouses []types.Warehouse
+	if err := db.Preload("Locations").Order("id").Find(&warehouses).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching warehouses"})
+	}
+	return c.JSON(http.StatusOK, warehouses)
+}
+
+func (h *InventoryHandler) CreateWarehouse(c echo.Context) error {
+	var req warehouseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if code already exists
+	if err := db.Where("code = ?", req.Code).First(&types.Warehouse{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	warehouse := types.Warehouse{Name: req.Name, Code: req.Code, Active: req.Active}
+	if err := db.Create(&warehouse).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating warehouse"})
+	}
+	return c.JSON(http.StatusCreated, warehouse)
+}
+
+func (h *InventoryHandler) UpdateWarehouse(c echo.Context) error {
+	var req warehouseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	warehouse := types.Warehouse{}
+	if err := db.First(&warehouse, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "warehouse not found"})
+	}
+	// check if code is taken by another warehouse
+	if err := db.Where("code = ? AND id <> ?", req.Code, warehouse.ID).First(&types.Warehouse{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	warehouse.Name = req.Name
+	warehouse.Code = req.Code
+	warehouse.Active = req.Active
+	if err := db.Save(&warehouse).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating warehouse"})
+	}
+	return c.JSON(http.StatusOK, warehouse)
+}
+
+func (h *InventoryHandler) CreateLocation(c echo.Context) error {
+	var req locationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	warehouse := types.Warehouse{}
+	if err := db.First(&warehouse, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "warehouse not found"})
+	}
+	// check if code already exists
+	if err := db.Where("code = ?", req.Code).First(&types.StockLocation{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	location := types.StockLocation{
+		WarehouseID: warehouse.ID,
+		Name:        req.Name,
+		Code:        req.Code,
+		Priority:    req.Priority,
+		Active:      req.Active,
+	}
+	if err := db.Create(&location).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating location"})
+	}
+	return c.JSON(http.StatusCreated, location)
+}
+
+func (h *InventoryHandler) UpdateLocation(c echo.Context) error {
+	var req locationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	location := types.StockLocation{}
+	if err := db.First(&location, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "location not found"})
+	}
+	// check if code is taken by another location
+	if err := db.Where("code = ? AND id <> ?", req.Code, location.ID).First(&types.StockLocation{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	location.Name = req.Name
+	location.Code = req.Code
+	location.Priority = req.Priority
+	location.Active = req.Active
+	if err := db.Save(&location).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating location"})
+	}
+	return c.JSON(http.StatusOK, location)
+}
+
+func (h *InventoryHandler) ListLevels(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.InventoryLevel{}).Order("variant_id, location_id")
+	if variantID := c.QueryParam("variant_id"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if locationID := c.QueryParam("location_id"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+
+	var levels []types.InventoryLevel
+	res, err := findPage(c, query, &levels)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching inventory levels"})
+	}
+	items := make([]inventoryLevelResponse, 0, len(levels))
+	for _, level := range levels {
+		items = append(items, inventoryLevelResponse{InventoryLevel: level, Available: level.Available()})
+	}
+	res.Items = items
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *InventoryHandler) Adjust(c echo.Context) error {
+	var req adjustStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.First(&types.ProductVariant{}, req.VariantID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "variant does not exist"})
+	}
+	if err := db.First(&types.StockLocation{}, req.LocationID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "location does not exist"})
+	}
+
+	err := inventory.Adjust(db, types.StockMovement{
+		VariantID:  req.VariantID,
+		LocationID: req.LocationID,
+		Quantity:   req.Quantity,
+		Reason:     req.Reason,
+		Reference:  req.Reference,
+		Note:       req.Note,
+	})
+	if err != nil {
+		if errors.Is(err, inventory.ErrInsufficientStock) || errors.Is(err, inventory.ErrInvalidReason) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error adjusting stock"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *InventoryHandler) Transfer(c echo.Context) error {
+	var req transferStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.First(&types.StockLocation{}, req.ToLocationID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "destination location does not exist"})
+	}
+
+	err := inventory.Transfer(db, req.VariantID, req.FromLocationID, req.ToLocationID, req.Quantity, req.Reference)
+	if err != nil {
+		if errors.Is(err, inventory.ErrInsufficientStock) || errors.Is(err, inventory.ErrSameLocation) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error transferring stock"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *InventoryHandler) ListMovements(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.StockMovement{}).Order("id DESC")
+	if variantID := c.QueryParam("variant_id"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if locationID := c.QueryParam("location_id"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if reason := c.QueryParam("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	var movements []types.StockMovement
+	res, err := findPage(c, query, &movements)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching stock movements"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *InventoryHandler) ListReservations(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.StockReservation{}).Order("id DESC")
+	if variantID := c.QueryParam("variant_id"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var reservations []types.StockReservation
+	res, err := findPage(c, query, &reservations)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching reservations"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,370 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/ledger"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	LedgerHandler struct {
+	}
+	LedgerHandlerInterface interface {
+		MyStatement(c echo.Context) error
+		MyPayouts(c echo.Context) error
+		ListPlans(c echo.Context) error
+		CreatePlan(c echo.Context) error
+		UpdatePlan(c echo.Context) error
+		DeletePlan(c echo.Context) error
+		AssignPlan(c echo.Context) error
+		VendorStatement(c echo.Context) error
+		RecordChargeback(c echo.Context) error
+		Reconciliation(c echo.Context) error
+		ListBatches(c echo.Context) error
+		GetBatch(c echo.Context) error
+		GenerateBatches(c echo.Context) error
+		MarkBatchPaid(c echo.Context) error
+		FailPayout(c echo.Context) error
+	}
+	commissionPlanRequest struct {
+		Name     string `json:"name" validate:"required,max=100"`
+		Rate     int64  `json:"rate" validate:"min=0,max=10000"`
+		FixedFee int64  `json:"fixed_fee" validate:"min=0"`
+		Default  bool   `json:"default"`
+	}
+	assignPlanRequest struct {
+		CommissionPlanID *uint `json:"commission_plan_id"`
+	}
+	chargebackRequest struct {
+		VendorID    uint   `json:"vendor_id" validate:"required"`
+		OrderNumber string `json:"order_number" validate:"required"`
+		Amount      int64  `json:"amount" validate:"required,gt=0"`
+		Currency    string `json:"currency" validate:"required,len=3"`
+		Reason      string `json:"reason" validate:"required"`
+	}
+	generateBatchesRequest struct {
+		Threshold *int64 `json:"threshold" validate:"omitempty,min=0"`
+	}
+	failPayoutRequest struct {
+		Note string `json:"note" validate:"required"`
+	}
+)
+
+func NewLedgerHandler() LedgerHandlerInterface {
+	return &LedgerHandler{}
+}
+
+// MyStatement is the payout statement of the vendor owning the store.
+func (h *LedgerHandler) MyStatement(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+	return h.statement(c, main, vendor.ID)
+}
+
+func (h *LedgerHandler) MyPayouts(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+
+	var payouts []types.Payout
+	res, err := findPage(c, main.Model(&types.Payout{}).Where("vendor_id = ?", vendor.ID).Order("id DESC"), &payouts)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching payouts"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *LedgerHandler) ListPlans(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	plans := []types.CommissionPlan{}
+	if err := main.Order("name").Find(&plans).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching commission plans"})
+	}
+	return c.JSON(http.StatusOK, plans)
+}
+
+func (h *LedgerHandler) CreatePlan(c echo.Context) error {
+	return h.savePlan(c, types.CommissionPlan{}, http.StatusCreated)
+}
+
+func (h *LedgerHandler) UpdatePlan(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	plan := types.CommissionPlan{}
+	if err := main.First(&plan, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "commission plan not found"})
+	}
+	return h.savePlan(c, plan, http.StatusOK)
+}
+
+// savePlan writes a commission plan. Only one plan can be the default.
+func (h *LedgerHandler) savePlan(c echo.Context, plan types.CommissionPlan, status int) error {
+	var req commissionPlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	// check if name already exists
+	if err := main.Where("name = ? AND id <> ?", req.Name, plan.ID).First(&types.CommissionPlan{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name already exists"})
+	}
+
+	plan.Name = req.Name
+	plan.Rate = req.Rate
+	plan.FixedFee = req.FixedFee
+	plan.Default = req.Default
+	err := main.Transaction(func(tx *gorm.DB) error {
+		if plan.Default {
+			if err := tx.Model(&types.CommissionPlan{}).Where("\"default\" = ? AND id <> ?", true, plan.ID).
+				Update("default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&plan).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving commission plan"})
+	}
+
+	return c.JSON(status, plan)
+}
+
+// DeletePlan removes a commission plan, its vendors fall back to the default.
+func (h *LedgerHandler) DeletePlan(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	err := main.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&types.Vendor{}).Where("commission_plan_id = ?", c.Param("id")).
+			Update("commission_plan_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.CommissionPlan{}, c.Param("id")).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing commission plan"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *LedgerHandler) AssignPlan(c echo.Context) error {
+	var req assignPlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor := types.Vendor{}
+	if err := main.First(&vendor, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+	if req.CommissionPlanID != nil {
+		if err := main.First(&types.CommissionPlan{}, *req.CommissionPlanID).Error; err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "commission plan not found"})
+		}
+	}
+	if err := main.Model(&vendor).Update("commission_plan_id", req.CommissionPlanID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating vendor"})
+	}
+
+	return c.JSON(http.StatusOK, vendor)
+}
+
+func (h *LedgerHandler) VendorStatement(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid vendor id"})
+	}
+	return h.statement(c, main, uint(id))
+}
+
+// RecordChargeback books money a customer's bank pulled back from a vendor.
+func (h *LedgerHandler) RecordChargeback(c echo.Context) error {
+	var req chargebackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor := types.Vendor{}
+	if err := main.First(&vendor, req.VendorID).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+
+	reference := ledger.OrderReference(vendor.TenantID, types.Order{Number: req.OrderNumber})
+	transaction, err := ledger.BookChargeback(main, vendor.ID, reference, currency.NormalizeCode(req.Currency), req.Amount, req.Reason)
+	if err != nil {
+		if errors.Is(err, ledger.ErrInvalidAmount) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error recording chargeback"})
+	}
+
+	return c.JSON(http.StatusCreated, transaction)
+}
+
+func (h *LedgerHandler) Reconciliation(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	report, err := ledger.Reconcile(main)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building reconciliation"})
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *LedgerHandler) ListBatches(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	query := main.Model(&types.PayoutBatch{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var batches []types.PayoutBatch
+	res, err := findPage(c, query, &batches)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching payout batches"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *LedgerHandler) GetBatch(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	batch := types.PayoutBatch{}
+	if err := main.Preload("Payouts").First(&batch, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "payout batch not found"})
+	}
+	return c.JSON(http.StatusOK, batch)
+}
+
+// GenerateBatches runs the payout schedule now, optionally with another
+// minimum than the configured one.
+func (h *LedgerHandler) GenerateBatches(c echo.Context) error {
+	var req generateBatchesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	threshold := ledger.PayoutThreshold()
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	batches, err := ledger.GeneratePayouts(main, threshold, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error generating payouts"})
+	}
+	return c.JSON(http.StatusCreated, batches)
+}
+
+func (h *LedgerHandler) MarkBatchPaid(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	batch := types.PayoutBatch{}
+	if err := main.First(&batch, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "payout batch not found"})
+	}
+	if err := ledger.MarkBatchPaid(main, &batch, time.Now()); err != nil {
+		if errors.Is(err, ledger.ErrInvalidPayoutState) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating payout batch"})
+	}
+	return c.JSON(http.StatusOK, batch)
+}
+
+// FailPayout puts a payout that bounced back on the vendor's balance.
+func (h *LedgerHandler) FailPayout(c echo.Context) error {
+	var req failPayoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	main := c.Get("main_db").(*gorm.DB)
+
+	payout := types.Payout{}
+	if err := main.First(&payout, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "payout not found"})
+	}
+	if err := ledger.FailPayout(main, &payout, req.Note); err != nil {
+		if errors.Is(err, ledger.ErrInvalidPayoutState) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating payout"})
+	}
+	return c.JSON(http.StatusOK, payout)
+}
+
+// statement responds with the statement of a vendor for the currency, from
+// and to query parameters (YYYY-MM-DD, to inclusive).
+func (h *LedgerHandler) statement(c echo.Context, main *gorm.DB, vendorID uint) error {
+	code := currency.NormalizeCode(c.QueryParam("currency"))
+	if !currency.ValidCode(code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency is required"})
+	}
+	var from, to *time.Time
+	if value := c.QueryParam("from"); value != "" {
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+		}
+		from = &t
+	}
+	if value := c.QueryParam("to"); value != "" {
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+
+	statement, err := ledger.VendorStatement(main, vendorID, code, from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building statement"})
+	}
+	return c.JSON(http.StatusOK, statement)
+}
+
+// currentVendor returns the vendor owning the store of the request.
+func currentVendor(c echo.Context, main *gorm.DB) (types.Vendor, error) {
+	vendor := types.Vendor{}
+	tenantID, _ := c.Get("tenant").(string)
+	err := main.Where("tenant_id = ?", tenantID).First(&vendor).Error
+	return vendor, err
+}
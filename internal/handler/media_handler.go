@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/media"
+	"github.com/Satishcg12/multicommers/internal/storage"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// defaultURLExpiry is how long a signed URL stays valid when no ttl is given.
+const defaultURLExpiry = time.Hour
+
+type (
+	MediaHandler struct {
+	}
+	MediaHandlerInterface interface {
+		Upload(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		SignedURL(c echo.Context) error
+		Delete(c echo.Context) error
+		Serve(c echo.Context) error
+		UploadLogo(c echo.Context) error
+		AddProductImage(c echo.Context) error
+		DeleteProductImage(c echo.Context) error
+	}
+	signedURLResponse struct {
+		URLs      map[string]string `json:"urls"`
+		ExpiresAt time.Time         `json:"expires_at"`
+	}
+)
+
+func NewMediaHandler() MediaHandlerInterface {
+	return &MediaHandler{}
+}
+
+// Upload stores a file for the tenant. The private form field keeps it out
+// of public reach, to be read through signed URLs only.
+func (h *MediaHandler) Upload(c echo.Context) error {
+	return h.upload(c, c.FormValue("private") == "true", false, nil)
+}
+
+func (h *MediaHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.MediaFile{}).Order("id DESC")
+	if contentType := c.QueryParam("content_type"); contentType != "" {
+		query = query.Where("content_type LIKE ?", strings.TrimSuffix(contentType, "*")+"%")
+	}
+	var files []types.MediaFile
+	res, err := findPage(c, query, &files, "Variants")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching media"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *MediaHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	file := types.MediaFile{}
+	if err := db.Preload("Variants").First(&file, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+	}
+	return c.JSON(http.StatusOK, file)
+}
+
+// SignedURL returns time-limited URLs to a file and its renditions. The ttl
+// query parameter is in seconds.
+func (h *MediaHandler) SignedURL(c echo.Context) error {
+	ttl := defaultURLExpiry
+	if value := c.QueryParam("ttl"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > media.MaxURLExpiry {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "ttl must be between 1 second and 7 days"})
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	db := c.Get("db").(*gorm.DB)
+	store := c.Get("storage").(storage.Storage)
+
+	file := types.MediaFile{}
+	if err := db.Preload("Variants").First(&file, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+	}
+	expires := time.Now().Add(ttl)
+	urls, err := media.SignedURLs(store, file, expires)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error signing url"})
+	}
+	return c.JSON(http.StatusOK, signedURLResponse{URLs: urls, ExpiresAt: expires})
+}
+
+func (h *MediaHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+	store := c.Get("storage").(storage.Storage)
+
+	file := types.MediaFile{}
+	if err := db.First(&file, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+	}
+	if err := media.Delete(c.Request().Context(), db, store, file); err != nil {
+		if errors.Is(err, media.ErrInUse) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting media"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// Serve streams files kept on the local disk. Only the tenant's own files
+// are served, and private ones need a valid signature.
+func (h *MediaHandler) Serve(c echo.Context) error {
+	local, ok := c.Get("storage").(*storage.Local)
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "file not found"})
+	}
+	tenantID, _ := c.Get("tenant").(string)
+	key, err := mediaKey(c.Param("*"))
+	if err != nil || !strings.HasPrefix(key, tenantID+"/") {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "file not found"})
+	}
+
+	private := strings.HasPrefix(key, tenantID+"/private/")
+	if private && !local.Verify(key, c.QueryParam("expires"), c.QueryParam("signature"), time.Now()) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "invalid or expired signature"})
+	}
+
+	file, err := local.Get(c.Request().Context(), key)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "file not found"})
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+	if private {
+		c.Response().Header().Set("Cache-Control", "private, no-store")
+	} else {
+		// keys are random and never reused, so public files never change
+		c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, contentType, file)
+}
+
+// UploadLogo replaces the logo of the vendor owning the tenant. The medium
+// rendition is used when the image is large enough to have one.
+func (h *MediaHandler) UploadLogo(c echo.Context) error {
+	main := c.Get("main_db").(*gorm.DB)
+
+	vendor, err := currentVendor(c, main)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "vendor not found"})
+	}
+	return h.upload(c, false, true, func(tx *gorm.DB, file *types.MediaFile) error {
+		logo := file.URL
+		for _, variant := range file.Variants {
+			if variant.Name == "medium" {
+				logo = variant.URL
+			}
+		}
+		return main.Model(&vendor).Update("logo", logo).Error
+	})
+}
+
+// AddProductImage uploads an image and appends it to the product's images.
+func (h *MediaHandler) AddProductImage(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.First(&product, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	alt := c.FormValue("alt")
+	if len(alt) > 255 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "alt must be at most 255 characters"})
+	}
+	return h.upload(c, false, true, func(tx *gorm.DB, file *types.MediaFile) error {
+		var count int64
+		if err := tx.Model(&types.ProductImage{}).Where("product_id = ?", product.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		return tx.Create(&types.ProductImage{ProductID: product.ID, MediaID: file.ID, Alt: alt, Position: int(count)}).Error
+	})
+}
+
+// DeleteProductImage removes an image from a product and deletes the file.
+func (h *MediaHandler) DeleteProductImage(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+	store := c.Get("storage").(storage.Storage)
+
+	image := types.ProductImage{}
+	if err := db.Preload("Media").Where("product_id = ?", c.Param("id")).First(&image, c.Param("image_id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "image not found"})
+	}
+	if err := media.Delete(c.Request().Context(), db, store, image.Media); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting image"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// upload reads the "file" form field, stores it and calls attach with the
+// new record, if given. When attach fails the file is removed again.
+func (h *MediaHandler) upload(c echo.Context, private, imageOnly bool, attach func(tx *gorm.DB, file *types.MediaFile) error) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+	if header.Size > media.MaxFileSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": media.ErrTooLarge.Error()})
+	}
+	src, err := header.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading file"})
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, media.MaxFileSize+1))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading file"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+	store := c.Get("storage").(storage.Storage)
+	tenantID, _ := c.Get("tenant").(string)
+	ctx := c.Request().Context()
+
+	file, err := media.Upload(ctx, db, store, media.UploadInput{
+		TenantID:  tenantID,
+		Filename:  header.Filename,
+		Data:      data,
+		Private:   private,
+		ImageOnly: imageOnly,
+	})
+	if err != nil {
+		return mediaError(c, err)
+	}
+	if attach == nil {
+		return c.JSON(http.StatusCreated, file)
+	}
+	if err := attach(db, file); err != nil {
+		media.Delete(ctx, db, store, *file)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving file"})
+	}
+	return c.JSON(http.StatusCreated, file)
+}
+
+// mediaKey cleans the key of a media URL and refuses keys leaving the
+// storage root.
+func mediaKey(raw string) (string, error) {
+	key := path.Clean("/" + raw)[1:]
+	if key == "" || key != raw {
+		return "", storage.ErrInvalidKey
+	}
+	return key, nil
+}
+
+// mediaError maps upload errors to responses.
+func mediaError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+	case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrNotImage):
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+	case errors.Is(err, media.ErrImageTooLarge):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error storing file"})
+}
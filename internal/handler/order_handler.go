@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/shipping"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	OrderHandler struct {
+	}
+	OrderHandlerInterface interface {
+		Checkout(c echo.Context) error
+		ShippingOptions(c echo.Context) error
+		MyOrders(c echo.Context) error
+		MyOrder(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Transition(c echo.Context) error
+	}
+	orderAddressRequest struct {
+		FullName     string `json:"full_name" validate:"max=255"`
+		Phone        string `json:"phone" validate:"max=30"`
+		StreetNumber string `json:"street_number" validate:"max=20"`
+		Directional  string `json:"directional" validate:"max=10"`
+		Street       string `json:"street" validate:"required,max=255"`
+		Suffix       string `json:"suffix" validate:"max=50"`
+		UnitType     string `json:"unit_type" validate:"max=50"`
+		UnitNumber   string `json:"unit_number" validate:"max=20"`
+		City         string `json:"city" validate:"max=100"`
+		Region       string `json:"region" validate:"max=100"`
+		ZipCode      string `json:"zip_code" validate:"required,max=20"`
+		CountryCode  string `json:"country_code" validate:"required,max=5"`
+	}
+	checkoutRequest struct {
+		Email            string               `json:"email" validate:"omitempty,email"`
+		BillingAddress   *orderAddressRequest `json:"billing_address"`
+		ShippingAddress  *orderAddressRequest `json:"shipping_address"`
+		ShippingMethodID *uint                `json:"shipping_method_id"`
+	}
+	transitionOrderRequest struct {
+		Status string `json:"status" validate:"required"`
+		Note   string `json:"note"`
+	}
+)
+
+func NewOrderHandler() OrderHandlerInterface {
+	return &OrderHandler{}
+}
+
+func (h *OrderHandler) Checkout(c echo.Context) error {
+	var req checkoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	o, err := order.Checkout(ctx, db, order.CheckoutInput{
+		Cart:             current,
+		Email:            req.Email,
+		BillingAddress:   req.BillingAddress.toOrderAddress(),
+		ShippingAddress:  req.ShippingAddress.toOrderAddress(),
+		ShippingMethodID: req.ShippingMethodID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrEmptyCart),
+			errors.Is(err, order.ErrCartNotPurchasable),
+			errors.Is(err, order.ErrMissingAddress),
+			errors.Is(err, order.ErrMissingEmail),
+			errors.Is(err, order.ErrCodeNotApplicable),
+			errors.Is(err, promotion.ErrUsageLimitReached),
+			errors.Is(err, order.ErrMissingShippingMethod),
+			errors.Is(err, shipping.ErrMethodUnavailable),
+			errors.Is(err, shipping.ErrNoShippingToRegion),
+			errors.Is(err, currency.ErrCurrencyNotEnabled),
+			errors.Is(err, currency.ErrNoExchangeRate),
+			errors.Is(err, inventory.ErrInsufficientStock):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, cart.ErrCartNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error placing order"})
+	}
+
+	return c.JSON(http.StatusCreated, o)
+}
+
+// ShippingOptions lists the shipping methods offered for the cart and the
+// destination given by the country_code and zip_code query parameters.
+func (h *OrderHandler) ShippingOptions(c echo.Context) error {
+	country := c.QueryParam("country_code")
+	if country == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "country_code is required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	current, err := resolveCart(c, db)
+	if err != nil {
+		return cartError(c, err)
+	}
+	summary, err := cart.Calculate(db, current)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error calculating cart"})
+	}
+
+	address := types.OrderAddress{CountryCode: country, ZipCode: c.QueryParam("zip_code")}
+	options, err := shipping.Options(c.Request().Context(), db, order.ShippingRequest(summary, address, summary.Currency))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching shipping methods"})
+	}
+	return c.JSON(http.StatusOK, options)
+}
+
+func (h *OrderHandler) MyOrders(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	var orders []types.Order
+	res, err := findPage(c, db.Model(&types.Order{}).Where("user_id = ?", userID).Order("id DESC"), &orders, "Lines")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching orders"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// MyOrder returns an order of the signed in customer, or a guest order when
+// the email it was placed with is given.
+func (h *OrderHandler) MyOrder(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Preload("Lines").Preload("Addresses").Preload("Events").Preload("Discounts").Where("number = ?", c.Param("number"))
+	if userID, ok := currentUserID(c); ok {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		email := c.QueryParam("email")
+		if email == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+		}
+		query = query.Where("user_id IS NULL AND LOWER(email) = LOWER(?)", email)
+	}
+
+	o := types.Order{}
+	if err := query.First(&o).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+	return c.JSON(http.StatusOK, o)
+}
+
+func (h *OrderHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.Order{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if email := c.QueryParam("email"); email != "" {
+		query = query.Where("LOWER(email) = LOWER(?)", email)
+	}
+
+	var orders []types.Order
+	res, err := findPage(c, query, &orders)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching orders"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *OrderHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.Preload("Lines").Preload("Addresses").Preload("Discounts").Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+	return c.JSON(http.StatusOK, o)
+}
+
+func (h *OrderHandler) Transition(c echo.Context) error {
+	var req transitionOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if !order.ValidStatus(req.Status) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid status"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	if err := order.ManualTransition(db, &o, req.Status, req.Note); err != nil {
+		var invalid *order.InvalidTransitionError
+		switch {
+		case errors.As(err, &invalid):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, order.ErrStaleOrder), errors.Is(err, order.ErrPaymentInProgress):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating order"})
+	}
+
+	return c.JSON(http.StatusOK, o)
+}
+
+func (r *orderAddressRequest) toOrderAddress() *types.OrderAddress {
+	if r == nil {
+		return nil
+	}
+	return &types.OrderAddress{
+		FullName:     r.FullName,
+		Phone:        r.Phone,
+		StreetNumber: r.StreetNumber,
+		Directional:  r.Directional,
+		Street:       r.Street,
+		Suffix:       r.Suffix,
+		UnitType:     r.UnitType,
+		UnitNumber:   r.UnitNumber,
+		City:         r.City,
+		Region:       r.Region,
+		ZipCode:      r.ZipCode,
+		CountryCode:  r.CountryCode,
+	}
+}
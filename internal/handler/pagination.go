@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type paginatedResponse struct {
+	Items   interface{} `json:"items"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int64       `json:"total"`
+}
+
+// paginate reads the page and per_page query parameters, falling back to
+// sane defaults for missing or out of range values.
+func paginate(c echo.Context) (page, perPage int) {
+	page, err := strconv.Atoi(c.QueryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(c.QueryParam("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
+// findPage counts the rows matched by query and loads the requested page of
+// them into dest together with the given associations.
+func findPage(c echo.Context, query *gorm.DB, dest interface{}, preloads ...string) (paginatedResponse, error) {
+	page, perPage := paginate(c)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return paginatedResponse{}, err
+	}
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	if err := query.Offset((page - 1) * perPage).Limit(perPage).Find(dest).Error; err != nil {
+		return paginatedResponse{}, err
+	}
+
+	return paginatedResponse{
+		Items:   dest,
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}, nil
+}
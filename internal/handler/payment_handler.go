@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/giftcard"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/storecredit"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// providerTimeout bounds a single call to a payment provider.
+const providerTimeout = 30 * time.Second
+
+type (
+	PaymentHandler struct {
+	}
+	PaymentHandlerInterface interface {
+		ListProviders(c echo.Context) error
+		ConfigureProvider(c echo.Context) error
+		RemoveProvider(c echo.Context) error
+		Pay(c echo.Context) error
+		OrderPayments(c echo.Context) error
+		Capture(c echo.Context) error
+		Void(c echo.Context) error
+	}
+	configureProviderRequest struct {
+		Enabled     bool              `json:"enabled"`
+		Credentials map[string]string `json:"credentials" validate:"required"`
+	}
+	payOrderRequest struct {
+		Provider       string   `json:"provider" validate:"required_with=PaymentMethod"`
+		PaymentMethod  string   `json:"payment_method" validate:"required_with=Provider"`
+		GiftCardCodes  []string `json:"gift_card_codes" validate:"omitempty,dive,required"`
+		UseStoreCredit bool     `json:"use_store_credit"`
+		Email          string   `json:"email" validate:"omitempty,email"`
+	}
+	providerListResponse struct {
+		Available  []string                      `json:"available"`
+		Configured []types.PaymentProviderConfig `json:"configured"`
+	}
+)
+
+func NewPaymentHandler() PaymentHandlerInterface {
+	return &PaymentHandler{}
+}
+
+func (h *PaymentHandler) ListProviders(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	configs := []types.PaymentProviderConfig{}
+	if err := db.Order("provider").Find(&configs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching payment providers"})
+	}
+	return c.JSON(http.StatusOK, providerListResponse{Available: payment.Providers(), Configured: configs})
+}
+
+// ConfigureProvider stores the tenant's credentials for a provider.
+func (h *PaymentHandler) ConfigureProvider(c echo.Context) error {
+	var req configureProviderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	name := c.Param("provider")
+	if _, err := payment.New(name, req.Credentials); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	credentials, err := json.Marshal(req.Credentials)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid credentials"})
+	}
+	config := types.PaymentProviderConfig{}
+	db.Where("provider = ?", name).First(&config)
+	config.Provider = name
+	config.Credentials = string(credentials)
+	config.Enabled = req.Enabled
+	if err := db.Save(&config).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving payment provider"})
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func (h *PaymentHandler) RemoveProvider(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Where("provider = ?", c.Param("provider")).Delete(&types.PaymentProviderConfig{}).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing payment provider"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// Pay charges an order awaiting payment. Gift cards and store credit are
+// used first, the provider is charged for the rest and can be left out when
+// they cover the whole order. Guests identify the order with the email it
+// was placed with.
+func (h *PaymentHandler) Pay(c echo.Context) error {
+	var req payOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Where("number = ?", c.Param("number"))
+	if userID, ok := currentUserID(c); ok {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		query = query.Where("user_id IS NULL AND LOWER(email) = LOWER(?)", req.Email)
+	}
+	o := types.Order{}
+	if err := query.First(&o).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	if len(req.GiftCardCodes) > 0 || req.UseStoreCredit {
+		if err := order.ApplyTenders(db, &o, order.Tenders{
+			GiftCardCodes:  req.GiftCardCodes,
+			UseStoreCredit: req.UseStoreCredit,
+		}, time.Now()); err != nil {
+			return tenderError(c, err)
+		}
+		if o.Status == order.StatusPaid {
+			return c.JSON(http.StatusOK, o)
+		}
+	}
+	if req.Provider == "" {
+		return c.JSON(http.StatusPaymentRequired, map[string]interface{}{
+			"error":      "a payment provider is required for the amount due",
+			"amount_due": order.AmountDue(o),
+		})
+	}
+
+	provider, err := payment.ForTenant(db, req.Provider)
+	if err != nil {
+		if errors.Is(err, payment.ErrProviderNotConfigured) || errors.Is(err, payment.ErrUnknownProvider) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error loading payment provider"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	intent, err := order.Pay(ctx, db, &o, provider, req.PaymentMethod)
+	if err != nil {
+		switch {
+		case errors.Is(err, order.ErrNotPayable):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, order.ErrPaymentInProgress):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, payment.ErrDeclined):
+			return c.JSON(http.StatusPaymentRequired, map[string]interface{}{"error": err.Error(), "payment": intent})
+		case errors.Is(err, payment.ErrTimeout):
+			return c.JSON(http.StatusAccepted, map[string]interface{}{"message": "payment is being processed", "payment": intent})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing payment"})
+	}
+
+	return c.JSON(http.StatusOK, intent)
+}
+
+func (h *PaymentHandler) OrderPayments(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	intents := []types.PaymentIntent{}
+	if err := db.Preload("Transactions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("order_id = ?", c.Param("id")).Order("id").Find(&intents).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching payments"})
+	}
+	return c.JSON(http.StatusOK, intents)
+}
+
+// Capture collects an authorized payment, e.g. one whose capture timed out,
+// and marks its order paid.
+func (h *PaymentHandler) Capture(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	intent, provider, err := loadIntent(db, c.Param("id"))
+	if err != nil {
+		return paymentError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	if err := payment.Capture(ctx, db, provider, &intent, intent.Amount); err != nil {
+		return paymentError(c, err)
+	}
+
+	o := types.Order{}
+	if err := db.First(&o, intent.OrderID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching order"})
+	}
+	if err := order.MarkPaid(db, &o, "payment captured by "+provider.Name()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating order"})
+	}
+
+	return c.JSON(http.StatusOK, intent)
+}
+
+func (h *PaymentHandler) Void(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	intent, provider, err := loadIntent(db, c.Param("id"))
+	if err != nil {
+		return paymentError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	if err := payment.Void(ctx, db, provider, &intent); err != nil {
+		return paymentError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, intent)
+}
+
+// loadIntent fetches a payment intent together with its provider.
+func loadIntent(db *gorm.DB, id string) (types.PaymentIntent, payment.Provider, error) {
+	intent := types.PaymentIntent{}
+	if err := db.First(&intent, id).Error; err != nil {
+		return intent, nil, err
+	}
+	provider, err := payment.ForTenant(db, intent.Provider)
+	return intent, provider, err
+}
+
+func paymentError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "payment not found"})
+	case errors.Is(err, payment.ErrProviderNotConfigured), errors.Is(err, payment.ErrUnknownProvider):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, payment.ErrInvalidIntentState), errors.Is(err, payment.ErrInvalidAmount):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, payment.ErrDeclined):
+		return c.JSON(http.StatusPaymentRequired, map[string]string{"error": err.Error()})
+	case errors.Is(err, payment.ErrTimeout):
+		return c.JSON(http.StatusGatewayTimeout, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing payment"})
+}
+
+// tenderError maps gift card and store credit errors onto responses.
+func tenderError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, giftcard.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, order.ErrPaymentInProgress):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, order.ErrNotPayable),
+		errors.Is(err, order.ErrGuestStoreCredit),
+		errors.Is(err, giftcard.ErrExpired),
+		errors.Is(err, giftcard.ErrDisabled),
+		errors.Is(err, giftcard.ErrEmpty),
+		errors.Is(err, giftcard.ErrCurrencyMismatch),
+		errors.Is(err, storecredit.ErrNoCredit):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error applying gift card or store credit"})
+}
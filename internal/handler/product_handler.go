@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/search"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	ProductHandler struct {
+	}
+	ProductHandlerInterface interface {
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Create(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+	}
+	productVariantRequest struct {
+		SKU    string `json:"sku" validate:"required,max=100"`
+		Title  string `json:"title" validate:"max=255"`
+		Price  int64  `json:"price" validate:"gte=0"`
+		Weight int    `json:"weight" validate:"gte=0"`
+		// LowStockThreshold keeps the variant's current threshold when left out
+		LowStockThreshold *int `json:"low_stock_threshold" validate:"omitempty,gte=0"`
+		// Options maps option names to values, e.g. {"Color": "Red"}
+		Options map[string]string `json:"options" validate:"dive,keys,required,max=100,endkeys,required,max=100"`
+	}
+	productRequest struct {
+		Title       string                  `json:"title" validate:"required,min=1,max=255"`
+		Handle      string                  `json:"handle" validate:"required,min=1,max=255"`
+		Description string                  `json:"description"`
+		Price       int64                   `json:"price" validate:"gte=0"`
+		Published   bool                    `json:"published"`
+		CategoryID  *uint                   `json:"category_id"`
+		TaxClassID  *uint                   `json:"tax_class_id"`
+		Tags        []string                `json:"tags" validate:"dive,required,max=100"`
+		Variants    []productVariantRequest `json:"variants" validate:"dive"`
+	}
+)
+
+func NewProductHandler() ProductHandlerInterface {
+	return &ProductHandler{}
+}
+
+func (h *ProductHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var products []types.Product
+	res, err := findPage(c, db.Model(&types.Product{}).Order("id DESC"), &products, "Tags", "Variants.Options")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching products"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *ProductHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var product types.Product
+	if err := db.Preload("Tags").Preload("Variants.Options").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Images.Media.Variants").Preload("Rating").First(&product, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	return c.JSON(http.StatusOK, product)
+}
+
+func (h *ProductHandler) Create(c echo.Context) error {
+	var req productRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if handle already exists
+	if err := db.Where("handle = ?", req.Handle).First(&types.Product{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+	// check if any sku is used by another product
+	if skuTaken(db, 0, req.Variants) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "sku already exists"})
+	}
+
+	product := types.Product{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := saveProduct(tx, &product, req); err != nil {
+			return err
+		}
+		return hook.PublishProduct(tx, hook.ProductCreated, product.ID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating product"})
+	}
+
+	return c.JSON(http.StatusCreated, product)
+}
+
+func (h *ProductHandler) Update(c echo.Context) error {
+	var req productRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.First(&product, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	// check if handle is taken by another product
+	if err := db.Where("handle = ? AND id <> ?", req.Handle, product.ID).First(&types.Product{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "handle already exists"})
+	}
+	// check if any sku is used by another product
+	if skuTaken(db, product.ID, req.Variants) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "sku already exists"})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := saveProduct(tx, &product, req); err != nil {
+			return err
+		}
+		return hook.PublishProduct(tx, hook.ProductUpdated, product.ID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating product"})
+	}
+
+	return c.JSON(http.StatusOK, product)
+}
+
+func (h *ProductHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid product id"})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := catalog.RemoveProduct(tx, uint(id)); err != nil {
+			return err
+		}
+		if err := search.Remove(tx, uint(id)); err != nil {
+			return err
+		}
+		if err := tx.Delete(&types.Product{}, id).Error; err != nil {
+			return err
+		}
+		return hook.PublishProduct(tx, hook.ProductDeleted, uint(id))
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting product"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// skuTaken reports whether any of the variant skus belongs to a product other
+// than productID.
+func skuTaken(db *gorm.DB, productID uint, variants []productVariantRequest) bool {
+	if len(variants) == 0 {
+		return false
+	}
+	skus := make([]string, 0, len(variants))
+	for _, v := range variants {
+		skus = append(skus, v.SKU)
+	}
+	var count int64
+	db.Model(&types.ProductVariant{}).Where("sku IN ? AND product_id <> ?", skus, productID).Count(&count)
+	return count > 0
+}
+
+// saveProduct writes the product, replaces its tags and variants and
+// refreshes its smart collection membership and search document.
+func saveProduct(tx *gorm.DB, product *types.Product, req productRequest) error {
+	product.Title = req.Title
+	product.Handle = req.Handle
+	product.Description = req.Description
+	product.Price = req.Price
+	product.Published = req.Published
+	product.CategoryID = req.CategoryID
+	product.TaxClassID = req.TaxClassID
+	product.Tags = nil
+	product.Variants = nil
+	if err := tx.Save(product).Error; err != nil {
+		return err
+	}
+
+	// replace tags
+	if err := tx.Where("product_id = ?", product.ID).Delete(&types.ProductTag{}).Error; err != nil {
+		return err
+	}
+	for _, name := range req.Tags {
+		product.Tags = append(product.Tags, types.ProductTag{ProductID: product.ID, Name: name})
+	}
+	if len(product.Tags) > 0 {
+		if err := tx.Create(&product.Tags).Error; err != nil {
+			return err
+		}
+	}
+
+	// upsert variants by sku and drop the ones no longer listed
+	skus := []string{}
+	for i, v := range req.Variants {
+		variant := types.ProductVariant{}
+		if err := tx.Unscoped().Where("sku = ?", v.SKU).First(&variant).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		variant.DeletedAt = gorm.DeletedAt{}
+		variant.ProductID = product.ID
+		variant.SKU = v.SKU
+		variant.Title = v.Title
+		variant.Price = v.Price
+		variant.Weight = v.Weight
+		variant.Position = i
+		if v.LowStockThreshold != nil {
+			variant.LowStockThreshold = *v.LowStockThreshold
+		}
+		if err := tx.Unscoped().Save(&variant).Error; err != nil {
+			return err
+		}
+		if err := catalog.SetVariantOptions(tx, &variant, v.Options); err != nil {
+			return err
+		}
+		product.Variants = append(product.Variants, variant)
+		skus = append(skus, v.SKU)
+	}
+	drop := tx.Where("product_id = ?", product.ID)
+	if len(skus) > 0 {
+		drop = drop.Where("sku NOT IN ?", skus)
+	}
+	if err := drop.Delete(&types.ProductVariant{}).Error; err != nil {
+		return err
+	}
+
+	if err := catalog.SyncProductCollections(tx, product.ID); err != nil {
+		return err
+	}
+	return search.Index(tx, product.ID)
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	PromotionHandler struct {
+	}
+	PromotionHandlerInterface interface {
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Create(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+		Redemptions(c echo.Context) error
+	}
+	promotionRequest struct {
+		Name        string           `json:"name" validate:"required,max=255"`
+		Code        string           `json:"code" validate:"max=50"`
+		Kind        string           `json:"kind" validate:"required"`
+		Value       int64            `json:"value" validate:"min=0"`
+		BuyQuantity int              `json:"buy_quantity" validate:"min=0"`
+		GetQuantity int              `json:"get_quantity" validate:"min=0"`
+		Tiers       []promotion.Tier `json:"tiers"`
+		Conditions  string           `json:"conditions"`
+		MinSubtotal int64            `json:"min_subtotal" validate:"min=0"`
+		StartsAt    *time.Time       `json:"starts_at"`
+		EndsAt      *time.Time       `json:"ends_at"`
+		UsageLimit  int              `json:"usage_limit" validate:"min=0"`
+		PerCustomer int              `json:"per_customer" validate:"min=0"`
+		Stackable   bool             `json:"stackable"`
+		Priority    int              `json:"priority"`
+		Active      *bool            `json:"active"`
+	}
+)
+
+func NewPromotionHandler() PromotionHandlerInterface {
+	return &PromotionHandler{}
+}
+
+func (h *PromotionHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.Promotion{}).Order("id DESC")
+	switch c.QueryParam("type") {
+	case "coupon":
+		query = query.Where("code IS NOT NULL")
+	case "automatic":
+		query = query.Where("code IS NULL")
+	}
+
+	var promotions []types.Promotion
+	res, err := findPage(c, query, &promotions)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching promotions"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *PromotionHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	p := types.Promotion{}
+	if err := db.First(&p, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "promotion not found"})
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+func (h *PromotionHandler) Create(c echo.Context) error {
+	var req promotionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	p := types.Promotion{}
+	if err := req.apply(&p); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// check if code already exists
+	if p.Code != nil {
+		if err := db.Where("code = ?", *p.Code).First(&types.Promotion{}).Error; err == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+		}
+	}
+
+	if err := db.Create(&p).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating promotion"})
+	}
+
+	return c.JSON(http.StatusCreated, p)
+}
+
+func (h *PromotionHandler) Update(c echo.Context) error {
+	var req promotionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	p := types.Promotion{}
+	if err := db.First(&p, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "promotion not found"})
+	}
+	if err := req.apply(&p); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// check if code is taken by another promotion
+	if p.Code != nil {
+		if err := db.Where("code = ? AND id <> ?", *p.Code, p.ID).First(&types.Promotion{}).Error; err == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+		}
+	}
+
+	// usage_count is left out so concurrent redemptions are not overwritten
+	if err := db.Omit("usage_count").Save(&p).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating promotion"})
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+func (h *PromotionHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Delete(&types.Promotion{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting promotion"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *PromotionHandler) Redemptions(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var redemptions []types.PromotionRedemption
+	res, err := findPage(c, db.Model(&types.PromotionRedemption{}).Where("promotion_id = ?", c.Param("id")).Order("id DESC"), &redemptions)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching redemptions"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// apply copies the request onto p and validates the result.
+func (r *promotionRequest) apply(p *types.Promotion) error {
+	p.Name = r.Name
+	p.Code = nil
+	if code := promotion.NormalizeCode(r.Code); code != "" {
+		p.Code = &code
+	}
+	p.Kind = r.Kind
+	p.Value = r.Value
+	p.BuyQuantity = r.BuyQuantity
+	p.GetQuantity = r.GetQuantity
+	p.Tiers = ""
+	if len(r.Tiers) > 0 {
+		tiers, err := json.Marshal(r.Tiers)
+		if err != nil {
+			return err
+		}
+		p.Tiers = string(tiers)
+	}
+	p.Conditions = r.Conditions
+	p.MinSubtotal = r.MinSubtotal
+	p.StartsAt = r.StartsAt
+	p.EndsAt = r.EndsAt
+	p.UsageLimit = r.UsageLimit
+	p.PerCustomer = r.PerCustomer
+	p.Stackable = r.Stackable
+	p.Priority = r.Priority
+	p.Active = r.Active == nil || *r.Active
+	return promotion.Validate(*p)
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/recovery"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	RecoveryHandler struct {
+	}
+	RecoveryHandlerInterface interface {
+		Open(c echo.Context) error
+		Click(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Report(c echo.Context) error
+		GetSettings(c echo.Context) error
+		UpdateSettings(c echo.Context) error
+	}
+	recoverySettingsRequest struct {
+		Enabled        bool            `json:"enabled"`
+		Steps          []recovery.Step `json:"steps"`
+		CodeValidHours int             `json:"code_valid_hours" validate:"required,gt=0"`
+		CartURL        string          `json:"cart_url" validate:"omitempty,url,max=255"`
+	}
+	recoveredCartResponse struct {
+		CartToken string `json:"cart_token"`
+		Code      string `json:"code,omitempty"`
+	}
+)
+
+// trackingPixel is a transparent 1x1 GIF.
+var trackingPixel = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+func NewRecoveryHandler() RecoveryHandlerInterface {
+	return &RecoveryHandler{}
+}
+
+// Open serves the tracking pixel of a reminder and counts the open. The
+// pixel is served whatever happens, a broken image only hurts the email.
+func (h *RecoveryHandler) Open(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	recovery.Open(db, c.Param("token"), time.Now())
+	c.Response().Header().Set("Cache-Control", "private, no-store")
+	return c.Blob(http.StatusOK, "image/gif", trackingPixel)
+}
+
+// Click follows the cart link of a reminder. The cart is restored with the
+// reminder's discount code and the customer sent to the storefront's cart
+// page with cart_token and code query parameters, or, without a cart page
+// configured, the token and code are returned.
+func (h *RecoveryHandler) Click(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := recovery.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching recovery settings"})
+	}
+	message, restored, err := recovery.Click(db, c.Param("token"), time.Now())
+	switch {
+	case errors.Is(err, recovery.ErrEmailNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, cart.ErrCartNotFound):
+		// the cart was checked out or expired, the storefront is the best
+		// place left to land
+		if settings.CartURL != "" {
+			return c.Redirect(http.StatusFound, settings.CartURL)
+		}
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error restoring cart"})
+	}
+
+	res := recoveredCartResponse{CartToken: restored.Token, Code: message.DiscountCode}
+	if settings.CartURL == "" {
+		return c.JSON(http.StatusOK, res)
+	}
+	target, err := url.Parse(settings.CartURL)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "invalid cart url"})
+	}
+	query := target.Query()
+	query.Set("cart_token", res.CartToken)
+	if res.Code != "" {
+		query.Set("code", res.Code)
+	}
+	target.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, target.String())
+}
+
+// List lists the reminder sequences with their emails. It filters on status
+// and email.
+func (h *RecoveryHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.CartRecovery{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if address := c.QueryParam("email"); address != "" {
+		query = query.Where("email = ?", address)
+	}
+	var recoveries []types.CartRecovery
+	res, err := findPage(c, query, &recoveries, "Emails")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching cart recoveries"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *RecoveryHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.CartRecovery{}
+	if err := db.Preload("Emails", func(db *gorm.DB) *gorm.DB { return db.Order("step") }).First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "cart recovery not found"})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// Report sums up the campaign for the sequences started between the from
+// and to dates (YYYY-MM-DD, to inclusive), both optional.
+func (h *RecoveryHandler) Report(c echo.Context) error {
+	var from, to time.Time
+	if value := c.QueryParam("from"); value != "" {
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+		}
+		from = t
+	}
+	if value := c.QueryParam("to"); value != "" {
+		t, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	report, err := recovery.Summarize(db, from, to)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building report"})
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h *RecoveryHandler) GetSettings(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := recovery.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching recovery settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings changes the reminder sequence. Sequences already running
+// continue with the new steps; no steps restores the default sequence.
+func (h *RecoveryHandler) UpdateSettings(c echo.Context) error {
+	var req recoverySettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	steps := ""
+	if len(req.Steps) > 0 {
+		encoded, err := json.Marshal(req.Steps)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		steps = string(encoded)
+	}
+	if _, err := recovery.ParseSteps(steps); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := recovery.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching recovery settings"})
+	}
+	settings.Enabled = req.Enabled
+	settings.Steps = steps
+	settings.CodeValidHours = req.CodeValidHours
+	settings.CartURL = req.CartURL
+	if err := db.Save(&settings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving recovery settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
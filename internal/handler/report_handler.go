@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	ReportHandler struct {
+	}
+	ReportHandlerInterface interface {
+		Sales(c echo.Context) error
+	}
+	// salesRow sums the orders placed in one currency. Amounts are in minor
+	// units of Currency, BaseTotal in the base currency.
+	salesRow struct {
+		Currency      string `json:"currency"`
+		Orders        int64  `json:"orders"`
+		Subtotal      int64  `json:"subtotal"`
+		DiscountTotal int64  `json:"discount_total"`
+		ShippingTotal int64  `json:"shipping_total"`
+		TaxTotal      int64  `json:"tax_total"`
+		Total         int64  `json:"total"`
+		Refunded      int64  `json:"refunded"`
+		BaseTotal     int64  `json:"base_total"`
+	}
+	salesReportResponse struct {
+		From         *time.Time `json:"from,omitempty"`
+		To           *time.Time `json:"to,omitempty"`
+		BaseCurrency string     `json:"base_currency"`
+		BaseTotal    int64      `json:"base_total"`
+		Currencies   []salesRow `json:"currencies"`
+	}
+)
+
+func NewReportHandler() ReportHandlerInterface {
+	return &ReportHandler{}
+}
+
+// Sales sums the paid orders per currency, optionally between the from and
+// to dates (YYYY-MM-DD, to inclusive).
+func (h *ReportHandler) Sales(c echo.Context) error {
+	res := salesReportResponse{Currencies: []salesRow{}}
+	if from := c.QueryParam("from"); from != "" {
+		t, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid from date"})
+		}
+		res.From = &t
+	}
+	if to := c.QueryParam("to"); to != "" {
+		t, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid to date"})
+		}
+		res.To = &t
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	base, err := currency.Base(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching currency settings"})
+	}
+	res.BaseCurrency = base
+
+	statuses := []string{order.StatusPaid, order.StatusFulfilling, order.StatusShipped, order.StatusDelivered, order.StatusRefunded}
+	orders := db.Model(&types.Order{}).Where("orders.status IN ?", statuses)
+	if res.From != nil {
+		orders = orders.Where("orders.created_at >= ?", *res.From)
+	}
+	if res.To != nil {
+		orders = orders.Where("orders.created_at < ?", res.To.AddDate(0, 0, 1))
+	}
+
+	if err := orders.Session(&gorm.Session{}).
+		Select(`orders.currency, COUNT(*) AS orders, SUM(orders.subtotal) AS subtotal,
+			SUM(orders.discount_total) AS discount_total, SUM(orders.shipping_total) AS shipping_total,
+			SUM(orders.tax_total) AS tax_total, SUM(orders.total) AS total, SUM(orders.base_total) AS base_total`).
+		Group("orders.currency").Order("orders.currency").
+		Scan(&res.Currencies).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building report"})
+	}
+
+	var refunds []struct {
+		Currency string
+		Refunded int64
+	}
+	if err := orders.Session(&gorm.Session{}).
+		Select("refunds.currency, SUM(refunds.amount) AS refunded").
+		Joins("JOIN refunds ON refunds.order_id = orders.id").
+		Where("refunds.status IN ?", []string{order.RefundSucceeded, order.RefundPartial}).
+		Group("refunds.currency").
+		Scan(&refunds).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building report"})
+	}
+	for i := range res.Currencies {
+		for _, refund := range refunds {
+			if refund.Currency == res.Currencies[i].Currency {
+				res.Currencies[i].Refunded = refund.Refunded
+			}
+		}
+		res.BaseTotal += res.Currencies[i].BaseTotal
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/returns"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	ReturnHandler struct {
+	}
+	ReturnHandlerInterface interface {
+		Create(c echo.Context) error
+		MyReturns(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Approve(c echo.Context) error
+		Reject(c echo.Context) error
+		Receive(c echo.Context) error
+		Inspect(c echo.Context) error
+		RefundReturn(c echo.Context) error
+		RefundOrder(c echo.Context) error
+		OrderRefunds(c echo.Context) error
+	}
+	returnLineRequest struct {
+		OrderLineID uint   `json:"order_line_id" validate:"required"`
+		Quantity    int    `json:"quantity" validate:"required,min=1"`
+		Reason      string `json:"reason" validate:"required"`
+		Note        string `json:"note"`
+	}
+	createReturnRequest struct {
+		Email string              `json:"email" validate:"omitempty,email"`
+		Note  string              `json:"note"`
+		Lines []returnLineRequest `json:"lines" validate:"required,min=1,dive"`
+	}
+	reviewReturnRequest struct {
+		Note string `json:"note"`
+	}
+	restockLineRequest struct {
+		ReturnLineID uint `json:"return_line_id" validate:"required"`
+		Quantity     int  `json:"quantity" validate:"required,min=1"`
+		LocationID   uint `json:"location_id"`
+	}
+	inspectReturnRequest struct {
+		Note    string               `json:"note"`
+		Restock []restockLineRequest `json:"restock" validate:"dive"`
+	}
+	refundRequest struct {
+		Amount      int64  `json:"amount" validate:"min=0"`
+		Reason      string `json:"reason"`
+		Destination string `json:"destination" validate:"omitempty,oneof=original store_credit"`
+	}
+)
+
+func NewReturnHandler() ReturnHandlerInterface {
+	return &ReturnHandler{}
+}
+
+// Create opens a return for an order of the signed in customer, or a guest
+// order when the email it was placed with is given.
+func (h *ReturnHandler) Create(c echo.Context) error {
+	var req createReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	o, err := customerOrder(c, db, req.Email)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	lines := make([]returns.LineInput, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		lines = append(lines, returns.LineInput{
+			OrderLineID: line.OrderLineID,
+			Quantity:    line.Quantity,
+			Reason:      line.Reason,
+			Note:        line.Note,
+		})
+	}
+
+	r, err := returns.Create(db, o, lines, req.Note)
+	if err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, r)
+}
+
+func (h *ReturnHandler) MyReturns(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	o, err := customerOrder(c, db, c.QueryParam("email"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	requests := []types.ReturnRequest{}
+	if err := db.Preload("Lines").Where("order_id = ?", o.ID).Order("id").Find(&requests).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching returns"})
+	}
+	return c.JSON(http.StatusOK, requests)
+}
+
+func (h *ReturnHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.ReturnRequest{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if orderID := c.QueryParam("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var requests []types.ReturnRequest
+	res, err := findPage(c, query, &requests, "Lines")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching returns"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *ReturnHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.ReturnRequest{}
+	if err := db.Preload("Lines").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "return not found"})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *ReturnHandler) Approve(c echo.Context) error {
+	return h.review(c, returns.Approve)
+}
+
+func (h *ReturnHandler) Reject(c echo.Context) error {
+	return h.review(c, returns.Reject)
+}
+
+func (h *ReturnHandler) review(c echo.Context, action func(*gorm.DB, *types.ReturnRequest, string) error) error {
+	var req reviewReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.ReturnRequest{}
+	if err := db.Preload("Lines").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "return not found"})
+	}
+
+	if err := action(db, &r, req.Note); err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *ReturnHandler) Receive(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.ReturnRequest{}
+	if err := db.Preload("Lines").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "return not found"})
+	}
+
+	if err := returns.Receive(db, &r); err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+// Inspect completes the inspection of a received return, restocking the
+// units listed in the request.
+func (h *ReturnHandler) Inspect(c echo.Context) error {
+	var req inspectReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.ReturnRequest{}
+	if err := db.Preload("Lines").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "return not found"})
+	}
+
+	restock := make([]returns.RestockInput, 0, len(req.Restock))
+	for _, line := range req.Restock {
+		restock = append(restock, returns.RestockInput{
+			ReturnLineID: line.ReturnLineID,
+			Quantity:     line.Quantity,
+			LocationID:   line.LocationID,
+		})
+	}
+
+	if err := returns.Inspect(db, &r, restock, req.Note); err != nil {
+		return returnError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+// RefundReturn refunds a return. Without an amount the value of the
+// returned units not yet refunded is given back.
+func (h *ReturnHandler) RefundReturn(c echo.Context) error {
+	var req refundRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.ReturnRequest{}
+	if err := db.Preload("Lines").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "return not found"})
+	}
+	if !returns.Refundable(r) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": returns.ErrInvalidStatus.Error()})
+	}
+
+	o := types.Order{}
+	if err := db.First(&o, r.OrderID).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		due, err := returns.RefundDue(db, r)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error calculating refund"})
+		}
+		amount = due
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "return " + r.Number
+	}
+
+	return issueRefund(c, db, &o, amount, reason, &r.ID, req.Destination)
+}
+
+func (h *ReturnHandler) RefundOrder(c echo.Context) error {
+	var req refundRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		// refund everything that is left
+		refundable, err := order.Refundable(db, o.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error calculating refund"})
+		}
+		amount = refundable
+	}
+
+	return issueRefund(c, db, &o, amount, req.Reason, nil, req.Destination)
+}
+
+func (h *ReturnHandler) OrderRefunds(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	o := types.Order{}
+	if err := db.First(&o, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order not found"})
+	}
+
+	refunds := []types.Refund{}
+	if err := db.Where("order_id = ?", o.ID).Order("id").Find(&refunds).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching refunds"})
+	}
+	refundable, err := order.Refundable(db, o.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching refunds"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"refunds": refunds, "refundable": refundable})
+}
+
+// issueRefund refunds amount on the order to destination, the way it was
+// paid by default, and reports the outcome.
+func issueRefund(c echo.Context, db *gorm.DB, o *types.Order, amount int64, reason string, returnID *uint, destination string) error {
+	if destination == order.RefundToStoreCredit {
+		refund, err := order.RefundToCredit(db, o, amount, reason, returnID)
+		if err != nil {
+			switch {
+			case errors.Is(err, order.ErrRefundExceedsBalance),
+				errors.Is(err, order.ErrGuestOrder),
+				errors.Is(err, payment.ErrInvalidAmount):
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error issuing refund"})
+		}
+		return c.JSON(http.StatusOK, refund)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	refund, err := order.IssueRefund(ctx, db, o, amount, reason, returnID)
+	if err != nil {
+		if refund.ID != 0 && refund.Amount > 0 {
+			return c.JSON(http.StatusMultiStatus, map[string]interface{}{"error": err.Error(), "refund": refund})
+		}
+		switch {
+		case errors.Is(err, order.ErrRefundExceedsBalance), errors.Is(err, payment.ErrInvalidAmount):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return paymentError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, refund)
+}
+
+// customerOrder finds the order named in the path that belongs to the
+// signed in customer, or the guest order placed with email.
+func customerOrder(c echo.Context, db *gorm.DB, email string) (types.Order, error) {
+	query := db.Where("number = ?", c.Param("number"))
+	if userID, ok := currentUserID(c); ok {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		if email == "" {
+			return types.Order{}, gorm.ErrRecordNotFound
+		}
+		query = query.Where("user_id IS NULL AND LOWER(email) = LOWER(?)", email)
+	}
+	o := types.Order{}
+	err := query.First(&o).Error
+	return o, err
+}
+
+func returnError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, returns.ErrNotReturnable),
+		errors.Is(err, returns.ErrInvalidLine),
+		errors.Is(err, returns.ErrInvalidQuantity),
+		errors.Is(err, returns.ErrInvalidReason),
+		errors.Is(err, returns.ErrNoLocation),
+		errors.Is(err, returns.ErrNothingToReturn):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, returns.ErrInvalidStatus):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing return"})
+}
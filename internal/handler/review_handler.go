@@ -0,0 +1,438 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/media"
+	"github.com/Satishcg12/multicommers/internal/review"
+	"github.com/Satishcg12/multicommers/internal/storage"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	ReviewHandler struct {
+	}
+	ReviewHandlerInterface interface {
+		ProductReviews(c echo.Context) error
+		Create(c echo.Context) error
+		MyReviews(c echo.Context) error
+		Update(c echo.Context) error
+		DeleteMine(c echo.Context) error
+		AddImage(c echo.Context) error
+		Vote(c echo.Context) error
+		Unvote(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		Approve(c echo.Context) error
+		Reject(c echo.Context) error
+		Reply(c echo.Context) error
+		Delete(c echo.Context) error
+		GetSettings(c echo.Context) error
+		UpdateSettings(c echo.Context) error
+	}
+	reviewRequest struct {
+		AuthorName string `json:"author_name" validate:"required,max=100"`
+		Rating     int    `json:"rating" validate:"required,min=1,max=5"`
+		Title      string `json:"title" validate:"required,max=255"`
+		Body       string `json:"body" validate:"required,max=5000"`
+	}
+	moderationRequest struct {
+		Note string `json:"note"`
+	}
+	reviewReplyRequest struct {
+		Body string `json:"body" validate:"max=5000"`
+	}
+	reviewVoteRequest struct {
+		Helpful *bool `json:"helpful" validate:"required"`
+	}
+	reviewSettingsRequest struct {
+		AutoApprove  bool   `json:"auto_approve"`
+		AllowLinks   bool   `json:"allow_links"`
+		BlockedWords string `json:"blocked_words"`
+	}
+	productReviewsResponse struct {
+		paginatedResponse
+		Rating types.ProductRating `json:"rating"`
+	}
+)
+
+var errReviewNotFound = errors.New("review not found")
+
+// reviewSorts maps the sort query parameter of product reviews to an order.
+var reviewSorts = map[string]string{
+	"newest":  "created_at DESC, id DESC",
+	"helpful": "helpful_count DESC, created_at DESC, id DESC",
+	"highest": "rating DESC, created_at DESC, id DESC",
+	"lowest":  "rating, created_at DESC, id DESC",
+}
+
+func NewReviewHandler() ReviewHandlerInterface {
+	return &ReviewHandler{}
+}
+
+// ProductReviews lists the published reviews of a product with its rating
+// summary. Supported query parameters are rating, verified, with_images and
+// sort (newest, helpful, highest or lowest).
+func (h *ReviewHandler) ProductReviews(c echo.Context) error {
+	order := reviewSorts["newest"]
+	if sort := c.QueryParam("sort"); sort != "" {
+		var ok bool
+		if order, ok = reviewSorts[sort]; !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "sort must be newest, helpful, highest or lowest"})
+		}
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.Preload("Rating").Where("handle = ? AND published = ?", c.Param("handle"), true).First(&product).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+
+	query := db.Model(&types.Review{}).Where("product_id = ? AND status = ?", product.ID, review.StatusApproved).Order(order)
+	if rating := c.QueryParam("rating"); rating != "" {
+		query = query.Where("rating = ?", rating)
+	}
+	if c.QueryParam("verified") == "true" {
+		query = query.Where("verified = ?", true)
+	}
+	if c.QueryParam("with_images") == "true" {
+		query = query.Where("EXISTS (SELECT 1 FROM review_images WHERE review_images.review_id = reviews.id)")
+	}
+	var reviews []types.Review
+	page, err := findPage(c, query, &reviews, "Images.Media.Variants")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching reviews"})
+	}
+
+	res := productReviewsResponse{paginatedResponse: page, Rating: types.ProductRating{ProductID: product.ID}}
+	if product.Rating != nil {
+		res.Rating = *product.Rating
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Create adds the signed in customer's review of a product.
+func (h *ReviewHandler) Create(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+	var req reviewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.Where("handle = ? AND published = ?", c.Param("handle"), true).First(&product).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	r, err := review.Submit(db, product.ID, userID, reviewInput(req))
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(http.StatusCreated, r)
+}
+
+func (h *ReviewHandler) MyReviews(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	var reviews []types.Review
+	res, err := findPage(c, db.Model(&types.Review{}).Where("user_id = ?", userID).Order("id DESC"), &reviews, "Images.Media")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching reviews"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Update changes the customer's own review, which is moderated again.
+func (h *ReviewHandler) Update(c echo.Context) error {
+	var req reviewRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r, err := myReview(c, db)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	if err := review.Edit(db, &r, reviewInput(req)); err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *ReviewHandler) DeleteMine(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r, err := myReview(c, db)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	return h.remove(c, db, r)
+}
+
+// AddImage uploads a photo to the customer's own review.
+func (h *ReviewHandler) AddImage(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r, err := myReview(c, db)
+	if err != nil {
+		return reviewError(c, err)
+	}
+	var count int64
+	if err := db.Model(&types.ReviewImage{}).Where("review_id = ?", r.ID).Count(&count).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching review"})
+	}
+	if count >= review.MaxImages {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": review.ErrTooManyImages.Error()})
+	}
+	return (&MediaHandler{}).upload(c, false, true, func(tx *gorm.DB, file *types.MediaFile) error {
+		return review.AddImage(tx, &r, file.ID)
+	})
+}
+
+// Vote records whether the signed in customer found a review helpful.
+func (h *ReviewHandler) Vote(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+	var req reviewVoteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	if err := review.Vote(db, r, userID, *req.Helpful); err != nil {
+		return reviewError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *ReviewHandler) Unvote(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	if err := review.Unvote(db, r.ID, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing vote"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// List is the moderation queue. It filters on status, product_id, rating
+// and flagged.
+func (h *ReviewHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.Review{}).Order("id")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if productID := c.QueryParam("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if rating := c.QueryParam("rating"); rating != "" {
+		query = query.Where("rating = ?", rating)
+	}
+	if c.QueryParam("flagged") == "true" {
+		query = query.Where("flags <> ''")
+	}
+	var reviews []types.Review
+	res, err := findPage(c, query, &reviews, "Images.Media")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching reviews"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *ReviewHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.Preload("Images.Media.Variants").First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *ReviewHandler) Approve(c echo.Context) error {
+	return h.moderate(c, review.Approve)
+}
+
+func (h *ReviewHandler) Reject(c echo.Context) error {
+	return h.moderate(c, review.Reject)
+}
+
+// Reply sets the vendor's public answer to a review. An empty body removes
+// it.
+func (h *ReviewHandler) Reply(c echo.Context) error {
+	var req reviewReplyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	if err := review.Reply(db, &r, req.Body, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving reply"})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+func (h *ReviewHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	return h.remove(c, db, r)
+}
+
+func (h *ReviewHandler) GetSettings(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := review.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching review settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings changes how reviews are moderated. Reviews already
+// submitted keep their status.
+func (h *ReviewHandler) UpdateSettings(c echo.Context) error {
+	var req reviewSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := review.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching review settings"})
+	}
+	settings.AutoApprove = req.AutoApprove
+	settings.AllowLinks = req.AllowLinks
+	settings.BlockedWords = strings.Join(review.ParseWords(req.BlockedWords), "\n")
+	if err := db.Save(&settings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving review settings"})
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// moderate applies a moderation decision to the review named in the path.
+func (h *ReviewHandler) moderate(c echo.Context, decide func(db *gorm.DB, r *types.Review, note string) error) error {
+	var req moderationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	r := types.Review{}
+	if err := db.First(&r, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "review not found"})
+	}
+	if err := decide(db, &r, req.Note); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error moderating review"})
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// remove deletes a review and then the files of its images.
+func (h *ReviewHandler) remove(c echo.Context, db *gorm.DB, r types.Review) error {
+	store := c.Get("storage").(storage.Storage)
+
+	var images []types.ReviewImage
+	if err := db.Preload("Media").Where("review_id = ?", r.ID).Find(&images).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting review"})
+	}
+	if err := review.Remove(db, &r); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting review"})
+	}
+	for _, image := range images {
+		// the review is gone already, a file left behind is only wasted space
+		media.Delete(c.Request().Context(), db, store, image.Media)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// myReview finds the review named in the path written by the signed in
+// customer.
+func myReview(c echo.Context, db *gorm.DB) (types.Review, error) {
+	r := types.Review{}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return r, errLoginRequired
+	}
+	if err := db.Where("user_id = ?", userID).First(&r, c.Param("id")).Error; err != nil {
+		return r, errReviewNotFound
+	}
+	return r, nil
+}
+
+func reviewInput(req reviewRequest) review.Input {
+	return review.Input{AuthorName: req.AuthorName, Rating: req.Rating, Title: req.Title, Body: req.Body}
+}
+
+// reviewError maps review errors to responses.
+func reviewError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errLoginRequired):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, errReviewNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, review.ErrAlreadyReviewed):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, review.ErrInvalidRating), errors.Is(err, review.ErrOwnReview),
+		errors.Is(err, review.ErrNotApproved), errors.Is(err, review.ErrTooManyImages):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving review"})
+}
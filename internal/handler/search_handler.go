@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/search"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	SearchHandler struct {
+	}
+	SearchHandlerInterface interface {
+		Search(c echo.Context) error
+		Reindex(c echo.Context) error
+	}
+	searchResponse struct {
+		paginatedResponse
+		Facets search.Facets `json:"facets"`
+	}
+	reindexResponse struct {
+		Indexed int64 `json:"indexed"`
+	}
+)
+
+func NewSearchHandler() SearchHandlerInterface {
+	return &SearchHandler{}
+}
+
+// Search runs a storefront product search. Supported query parameters are q,
+// category (id, subcategories included), min_price, max_price, option
+// (repeatable, "Name:Value"), in_stock and sort (relevance, price_asc,
+// price_desc or newest).
+func (h *SearchHandler) Search(c echo.Context) error {
+	q := search.Query{
+		Text:    c.QueryParam("q"),
+		Sort:    c.QueryParam("sort"),
+		InStock: c.QueryParam("in_stock") == "true",
+		Options: map[string][]string{},
+	}
+	if category := c.QueryParam("category"); category != "" {
+		id, err := strconv.ParseUint(category, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid category"})
+		}
+		categoryID := uint(id)
+		q.CategoryID = &categoryID
+	}
+	if minPrice := c.QueryParam("min_price"); minPrice != "" {
+		price, err := strconv.ParseInt(minPrice, 10, 64)
+		if err != nil || price < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid min_price"})
+		}
+		q.MinPrice = &price
+	}
+	if maxPrice := c.QueryParam("max_price"); maxPrice != "" {
+		price, err := strconv.ParseInt(maxPrice, 10, 64)
+		if err != nil || price < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid max_price"})
+		}
+		q.MaxPrice = &price
+	}
+	for _, option := range c.QueryParams()["option"] {
+		name, value, ok := strings.Cut(option, ":")
+		if !ok || name == "" || value == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid option, expected Name:Value"})
+		}
+		q.Options[name] = append(q.Options[name], value)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	page, perPage := paginate(c)
+	res, err := search.Search(db, q, (page-1)*perPage, perPage)
+	if errors.Is(err, search.ErrInvalidSort) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error searching products"})
+	}
+
+	// load the page of products and keep the search order
+	var products []types.Product
+	if len(res.ProductIDs) > 0 {
+		if err := db.Preload("Tags").Preload("Variants.Options").Where("id IN ?", res.ProductIDs).Find(&products).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error searching products"})
+		}
+	}
+	byID := make(map[uint]types.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	items := make([]types.Product, 0, len(res.ProductIDs))
+	for _, id := range res.ProductIDs {
+		if product, ok := byID[id]; ok {
+			items = append(items, product)
+		}
+	}
+
+	return c.JSON(http.StatusOK, searchResponse{
+		paginatedResponse: paginatedResponse{Items: items, Page: page, PerPage: perPage, Total: res.Total},
+		Facets:            res.Facets,
+	})
+}
+
+// Reindex rebuilds the search documents of every product.
+func (h *SearchHandler) Reindex(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	indexed, err := search.Reindex(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error rebuilding search index"})
+	}
+	return c.JSON(http.StatusOK, reindexResponse{Indexed: indexed})
+}
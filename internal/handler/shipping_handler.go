@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/shipping"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	ShippingHandler struct {
+	}
+	ShippingHandlerInterface interface {
+		Carriers(c echo.Context) error
+		ListZones(c echo.Context) error
+		GetZone(c echo.Context) error
+		CreateZone(c echo.Context) error
+		UpdateZone(c echo.Context) error
+		DeleteZone(c echo.Context) error
+		CreateMethod(c echo.Context) error
+		UpdateMethod(c echo.Context) error
+		DeleteMethod(c echo.Context) error
+	}
+	shippingZoneRequest struct {
+		Name           string `json:"name" validate:"required,max=100"`
+		CountryCode    string `json:"country_code" validate:"required,max=5"`
+		PostalPatterns string `json:"postal_patterns"`
+	}
+	shippingTierRequest struct {
+		MinValue int64 `json:"min_value" validate:"min=0"`
+		Rate     int64 `json:"rate" validate:"min=0"`
+	}
+	shippingMethodRequest struct {
+		Name      string                `json:"name" validate:"required,max=100"`
+		Kind      string                `json:"kind" validate:"required"`
+		FlatRate  int64                 `json:"flat_rate" validate:"min=0"`
+		Carrier   string                `json:"carrier" validate:"required_if=Kind carrier,max=50"`
+		Service   string                `json:"service" validate:"max=50"`
+		FreeAbove int64                 `json:"free_above" validate:"min=0"`
+		Active    *bool                 `json:"active"`
+		Position  int                   `json:"position"`
+		Tiers     []shippingTierRequest `json:"tiers" validate:"dive"`
+	}
+)
+
+func NewShippingHandler() ShippingHandlerInterface {
+	return &ShippingHandler{}
+}
+
+func (h *ShippingHandler) Carriers(c echo.Context) error {
+	return c.JSON(http.StatusOK, shipping.Carriers())
+}
+
+func (h *ShippingHandler) ListZones(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zones := []types.ShippingZone{}
+	if err := db.Preload("Methods.Tiers").Order("country_code, id").Find(&zones).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching shipping zones"})
+	}
+	return c.JSON(http.StatusOK, zones)
+}
+
+func (h *ShippingHandler) GetZone(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.ShippingZone{}
+	if err := db.Preload("Methods.Tiers").First(&zone, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipping zone not found"})
+	}
+	return c.JSON(http.StatusOK, zone)
+}
+
+func (h *ShippingHandler) CreateZone(c echo.Context) error {
+	var req shippingZoneRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.ShippingZone{}
+	req.apply(&zone)
+	if err := db.Create(&zone).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating shipping zone"})
+	}
+
+	return c.JSON(http.StatusCreated, zone)
+}
+
+func (h *ShippingHandler) UpdateZone(c echo.Context) error {
+	var req shippingZoneRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.ShippingZone{}
+	if err := db.First(&zone, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipping zone not found"})
+	}
+	req.apply(&zone)
+	if err := db.Save(&zone).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating shipping zone"})
+	}
+
+	return c.JSON(http.StatusOK, zone)
+}
+
+func (h *ShippingHandler) DeleteZone(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Delete(&types.ShippingZone{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting shipping zone"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *ShippingHandler) CreateMethod(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.ShippingZone{}
+	if err := db.First(&zone, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipping zone not found"})
+	}
+	return h.saveMethod(c, types.ShippingMethod{ShippingZoneID: zone.ID}, http.StatusCreated)
+}
+
+func (h *ShippingHandler) UpdateMethod(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	method := types.ShippingMethod{}
+	if err := db.Where("shipping_zone_id = ?", c.Param("id")).First(&method, c.Param("method_id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "shipping method not found"})
+	}
+	return h.saveMethod(c, method, http.StatusOK)
+}
+
+func (h *ShippingHandler) DeleteMethod(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Where("shipping_zone_id = ?", c.Param("id")).Delete(&types.ShippingMethod{}, c.Param("method_id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting shipping method"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// saveMethod writes a method and replaces its rate tiers.
+func (h *ShippingHandler) saveMethod(c echo.Context, method types.ShippingMethod, status int) error {
+	var req shippingMethodRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if !shipping.ValidKind(req.Kind) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": shipping.ErrInvalidKind.Error()})
+	}
+	if (req.Kind == shipping.KindWeight || req.Kind == shipping.KindPrice) && len(req.Tiers) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tiers are required for table rates"})
+	}
+	if req.Kind == shipping.KindCarrier {
+		if _, err := shipping.CarrierByName(req.Carrier); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	method.Name = req.Name
+	method.Kind = req.Kind
+	method.FlatRate = req.FlatRate
+	method.Carrier = req.Carrier
+	method.Service = req.Service
+	method.FreeAbove = req.FreeAbove
+	method.Active = req.Active == nil || *req.Active
+	method.Position = req.Position
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		method.Tiers = nil
+		if err := tx.Save(&method).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shipping_method_id = ?", method.ID).Delete(&types.ShippingRateTier{}).Error; err != nil {
+			return err
+		}
+		for _, tier := range req.Tiers {
+			method.Tiers = append(method.Tiers, types.ShippingRateTier{
+				ShippingMethodID: method.ID,
+				MinValue:         tier.MinValue,
+				Rate:             tier.Rate,
+			})
+		}
+		if len(method.Tiers) == 0 {
+			return nil
+		}
+		return tx.Create(&method.Tiers).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving shipping method"})
+	}
+
+	return c.JSON(status, method)
+}
+
+func (r *shippingZoneRequest) apply(zone *types.ShippingZone) {
+	zone.Name = r.Name
+	zone.CountryCode = strings.ToUpper(strings.TrimSpace(r.CountryCode))
+	patterns := []string{}
+	for _, pattern := range strings.Split(r.PostalPatterns, ",") {
+		if pattern = shipping.NormalizePostalCode(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	zone.PostalPatterns = strings.Join(patterns, ",")
+}
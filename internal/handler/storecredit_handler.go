@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/storecredit"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	StoreCreditHandler struct {
+	}
+	StoreCreditHandlerInterface interface {
+		CustomerCredit(c echo.Context) error
+		Adjust(c echo.Context) error
+		MyCredit(c echo.Context) error
+	}
+	adjustCreditRequest struct {
+		Currency string `json:"currency" validate:"required,len=3"`
+		Amount   int64  `json:"amount" validate:"required"`
+		Note     string `json:"note" validate:"required"`
+	}
+	storeCreditResponse struct {
+		types.StoreCreditAccount
+		Balance int64 `json:"balance"`
+	}
+)
+
+func NewStoreCreditHandler() StoreCreditHandlerInterface {
+	return &StoreCreditHandler{}
+}
+
+func (h *StoreCreditHandler) CustomerCredit(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	user := types.User{}
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+	}
+	return h.accounts(c, db, user.ID)
+}
+
+// Adjust credits a customer by hand, negative amounts take credit off.
+func (h *StoreCreditHandler) Adjust(c echo.Context) error {
+	var req adjustCreditRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	user := types.User{}
+	if err := db.First(&user, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+	}
+	transaction, err := storecredit.Record(db, storecredit.Entry{
+		UserID:   user.ID,
+		Currency: currency.NormalizeCode(req.Currency),
+		Kind:     storecredit.KindAdjustment,
+		Amount:   req.Amount,
+		Note:     req.Note,
+	})
+	if err != nil {
+		if errors.Is(err, storecredit.ErrInvalidAmount) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "adjustment would make the balance negative"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error adjusting store credit"})
+	}
+	return c.JSON(http.StatusCreated, transaction)
+}
+
+func (h *StoreCreditHandler) MyCredit(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+	return h.accounts(c, db, userID)
+}
+
+// accounts responds with the customer's credit in every currency and its
+// history.
+func (h *StoreCreditHandler) accounts(c echo.Context, db *gorm.DB, userID uint) error {
+	accounts := []types.StoreCreditAccount{}
+	if err := db.Preload("Transactions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id DESC")
+	}).Where("user_id = ?", userID).Order("currency").Find(&accounts).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching store credit"})
+	}
+
+	res := make([]storeCreditResponse, 0, len(accounts))
+	for _, account := range accounts {
+		balance, err := storecredit.Balance(db, account.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching store credit"})
+		}
+		res = append(res, storeCreditResponse{StoreCreditAccount: account, Balance: balance})
+	}
+	return c.JSON(http.StatusOK, res)
+}
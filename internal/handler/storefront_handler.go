@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	StorefrontHandler struct {
+	}
+	StorefrontHandlerInterface interface {
+		Categories(c echo.Context) error
+		CategoryProducts(c echo.Context) error
+		CollectionProducts(c echo.Context) error
+		Product(c echo.Context) error
+	}
+)
+
+func NewStorefrontHandler() StorefrontHandlerInterface {
+	return &StorefrontHandler{}
+}
+
+func (h *StorefrontHandler) Categories(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	tree, err := catalog.Tree(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching categories"})
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+// CategoryProducts lists the published products of a category and all of its
+// subcategories.
+func (h *StorefrontHandler) CategoryProducts(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	category := types.Category{}
+	if err := db.Where("handle = ?", c.Param("handle")).First(&category).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	}
+	ids, err := catalog.SubtreeIDs(db, category.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching products"})
+	}
+
+	var products []types.Product
+	query := db.Model(&types.Product{}).
+		Where("published = ? AND category_id IN ?", true, ids).
+		Order("id DESC")
+	res, err := findPage(c, query, &products, "Tags", "Variants", "Rating")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching products"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// CollectionProducts lists the published products of a collection in
+// collection order.
+func (h *StorefrontHandler) CollectionProducts(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	collection := types.Collection{}
+	if err := db.Where("handle = ?", c.Param("handle")).First(&collection).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "collection not found"})
+	}
+
+	var products []types.Product
+	query := db.Model(&types.Product{}).
+		Joins("JOIN collection_products ON collection_products.product_id = products.id").
+		Where("collection_products.collection_id = ? AND products.published = ?", collection.ID, true).
+		Order("collection_products.position, products.id")
+	res, err := findPage(c, query, &products, "Tags", "Variants", "Rating")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching products"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *StorefrontHandler) Product(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.Preload("Tags").Preload("Variants.Options").Preload("Images", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).Preload("Images.Media.Variants").Preload("Rating").Where("handle = ? AND published = ?", c.Param("handle"), true).First(&product).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	return c.JSON(http.StatusOK, product)
+}
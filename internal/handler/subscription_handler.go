@@ -0,0 +1,432 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/clock"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/subscription"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	SubscriptionHandler struct {
+	}
+	SubscriptionHandlerInterface interface {
+		ProductPlans(c echo.Context) error
+		ActiveProductPlans(c echo.Context) error
+		CreatePlan(c echo.Context) error
+		UpdatePlan(c echo.Context) error
+		DeletePlan(c echo.Context) error
+		List(c echo.Context) error
+		Get(c echo.Context) error
+		AdminCancel(c echo.Context) error
+		RunRenewals(c echo.Context) error
+		MySubscriptions(c echo.Context) error
+		MySubscription(c echo.Context) error
+		Subscribe(c echo.Context) error
+		Update(c echo.Context) error
+		Pause(c echo.Context) error
+		Resume(c echo.Context) error
+		Skip(c echo.Context) error
+		Cancel(c echo.Context) error
+	}
+	subscriptionPlanRequest struct {
+		Name          string `json:"name" validate:"required,max=100"`
+		Interval      string `json:"interval" validate:"required,oneof=day week month year"`
+		IntervalCount int    `json:"interval_count" validate:"omitempty,min=1"`
+		DiscountRate  int64  `json:"discount_rate" validate:"min=0,max=10000"`
+		Active        *bool  `json:"active"`
+	}
+	subscribeProductRequest struct {
+		PlanID           uint   `json:"plan_id" validate:"required"`
+		VariantID        uint   `json:"variant_id" validate:"required"`
+		Quantity         int    `json:"quantity" validate:"required,min=1"`
+		Currency         string `json:"currency" validate:"omitempty,len=3"`
+		ShippingMethodID *uint  `json:"shipping_method_id"`
+		PaymentProvider  string `json:"payment_provider" validate:"required"`
+		PaymentMethod    string `json:"payment_method" validate:"required"`
+	}
+	updateSubscriptionRequest struct {
+		Quantity         *int   `json:"quantity" validate:"omitempty,min=1"`
+		ShippingMethodID *uint  `json:"shipping_method_id"`
+		PaymentProvider  string `json:"payment_provider"`
+		PaymentMethod    string `json:"payment_method"`
+	}
+	pauseSubscriptionRequest struct {
+		Until *time.Time `json:"until"`
+	}
+)
+
+func NewSubscriptionHandler() SubscriptionHandlerInterface {
+	return &SubscriptionHandler{}
+}
+
+func (h *SubscriptionHandler) ProductPlans(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	plans := []types.SubscriptionPlan{}
+	if err := db.Where("product_id = ?", c.Param("id")).Order("id").Find(&plans).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscription plans"})
+	}
+	return c.JSON(http.StatusOK, plans)
+}
+
+// ActiveProductPlans lists the ways a product can be subscribed to.
+func (h *SubscriptionHandler) ActiveProductPlans(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	plans := []types.SubscriptionPlan{}
+	if err := db.Where("product_id = ? AND active = ?", c.Param("id"), true).Order("id").Find(&plans).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscription plans"})
+	}
+	return c.JSON(http.StatusOK, plans)
+}
+
+func (h *SubscriptionHandler) CreatePlan(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	product := types.Product{}
+	if err := db.First(&product, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "product not found"})
+	}
+	return h.savePlan(c, types.SubscriptionPlan{ProductID: product.ID}, http.StatusCreated)
+}
+
+func (h *SubscriptionHandler) UpdatePlan(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	plan := types.SubscriptionPlan{}
+	if err := db.First(&plan, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription plan not found"})
+	}
+	return h.savePlan(c, plan, http.StatusOK)
+}
+
+// savePlan writes a subscription plan. Changes apply from the next renewal
+// of existing subscriptions.
+func (h *SubscriptionHandler) savePlan(c echo.Context, plan types.SubscriptionPlan, status int) error {
+	var req subscriptionPlanRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	plan.Name = req.Name
+	plan.Interval = req.Interval
+	plan.IntervalCount = req.IntervalCount
+	if plan.IntervalCount == 0 {
+		plan.IntervalCount = 1
+	}
+	plan.DiscountRate = req.DiscountRate
+	plan.Active = req.Active == nil || *req.Active
+	if err := db.Save(&plan).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving subscription plan"})
+	}
+
+	return c.JSON(status, plan)
+}
+
+// DeletePlan removes a plan without subscriptions, plans in use can only be
+// deactivated.
+func (h *SubscriptionHandler) DeletePlan(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	var count int64
+	if err := db.Model(&types.CustomerSubscription{}).Where("plan_id = ?", c.Param("id")).Count(&count).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing subscription plan"})
+	}
+	if count > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "plan has subscriptions, deactivate it instead"})
+	}
+	if err := db.Delete(&types.SubscriptionPlan{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing subscription plan"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *SubscriptionHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.CustomerSubscription{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := c.QueryParam("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var subs []types.CustomerSubscription
+	res, err := findPage(c, query, &subs, "Plan")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscriptions"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *SubscriptionHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.CustomerSubscription{}
+	if err := db.Preload("Plan").First(&sub, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *SubscriptionHandler) AdminCancel(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.CustomerSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	}
+	if err := subscription.Cancel(db, &sub, clockNow(c)); err != nil {
+		return subscriptionError(c, err, "error cancelling subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// RunRenewals bills the store's due subscriptions now instead of waiting for
+// the scheduler, at the time of the clock.
+func (h *SubscriptionHandler) RunRenewals(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	outcomes, err := subscription.Renew(ctx, db, clockNow(c))
+	subscription.Notify(c.Get("mailer").(email.EmailDaemonInterface), outcomes...)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error renewing subscriptions"})
+	}
+	return c.JSON(http.StatusOK, outcomes)
+}
+
+func (h *SubscriptionHandler) MySubscriptions(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	subs := []types.CustomerSubscription{}
+	if err := db.Preload("Plan").Where("user_id = ?", userID).Order("id DESC").Find(&subs).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscriptions"})
+	}
+	return c.JSON(http.StatusOK, subs)
+}
+
+func (h *SubscriptionHandler) MySubscription(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// Subscribe starts a subscription and places its first order, which has to be
+// paid for the subscription to start.
+func (h *SubscriptionHandler) Subscribe(c echo.Context) error {
+	var req subscribeProductRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	plan := types.SubscriptionPlan{}
+	if err := db.First(&plan, req.PlanID).Error; err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "subscription plan not found"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), providerTimeout)
+	defer cancel()
+
+	sub, outcome, err := subscription.Subscribe(ctx, db, subscription.SubscribeInput{
+		UserID:           userID,
+		PlanID:           plan.ID,
+		VariantID:        req.VariantID,
+		Quantity:         req.Quantity,
+		Currency:         currency.NormalizeCode(req.Currency),
+		ShippingMethodID: req.ShippingMethodID,
+		PaymentProvider:  req.PaymentProvider,
+		PaymentMethod:    req.PaymentMethod,
+	}, clockNow(c))
+	if err != nil {
+		if errors.Is(err, subscription.ErrFirstPaymentFailed) {
+			return c.JSON(http.StatusPaymentRequired, map[string]string{"error": err.Error()})
+		}
+		return subscriptionError(c, err, "error creating subscription")
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"subscription": sub,
+		"order":        outcome.Order,
+	})
+}
+
+// Update changes the quantity, the shipping method or the payment method used
+// from the next renewal on.
+func (h *SubscriptionHandler) Update(c echo.Context) error {
+	var req updateSubscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if (req.PaymentProvider == "") != (req.PaymentMethod == "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "payment_provider and payment_method go together"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	if sub.Status == subscription.StatusCancelled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": subscription.ErrInvalidState.Error()})
+	}
+
+	updates := map[string]interface{}{}
+	if req.Quantity != nil {
+		updates["quantity"] = *req.Quantity
+	}
+	if req.ShippingMethodID != nil {
+		updates["shipping_method_id"] = *req.ShippingMethodID
+	}
+	if req.PaymentProvider != "" {
+		updates["payment_provider"] = req.PaymentProvider
+		updates["payment_method"] = req.PaymentMethod
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&sub).Omit("Plan").Updates(updates).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating subscription"})
+		}
+	}
+	if err := db.Preload("Plan").First(&sub, sub.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching subscription"})
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// Pause stops renewals until the optional until date.
+func (h *SubscriptionHandler) Pause(c echo.Context) error {
+	var req pauseSubscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	if err := subscription.Pause(db, &sub, req.Until, clockNow(c)); err != nil {
+		return subscriptionError(c, err, "error pausing subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *SubscriptionHandler) Resume(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	if err := subscription.Resume(db, &sub, clockNow(c)); err != nil {
+		return subscriptionError(c, err, "error resuming subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// Skip skips the next delivery.
+func (h *SubscriptionHandler) Skip(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	if err := subscription.Skip(db, &sub); err != nil {
+		return subscriptionError(c, err, "error skipping delivery")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+func (h *SubscriptionHandler) Cancel(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub, err := h.mine(c, db)
+	if err != nil {
+		return subscriptionError(c, err, "error fetching subscription")
+	}
+	if err := subscription.Cancel(db, &sub, clockNow(c)); err != nil {
+		return subscriptionError(c, err, "error cancelling subscription")
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// mine returns the subscription of the :id parameter when it belongs to the
+// signed in customer.
+func (h *SubscriptionHandler) mine(c echo.Context, db *gorm.DB) (types.CustomerSubscription, error) {
+	sub := types.CustomerSubscription{}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return sub, errLoginRequired
+	}
+	err := db.Preload("Plan").Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&sub).Error
+	return sub, err
+}
+
+// clockNow is the time of the clock on the context, the wall clock without one.
+func clockNow(c echo.Context) time.Time {
+	if clk, ok := c.Get("clock").(clock.Clock); ok {
+		return clk.Now()
+	}
+	return time.Now()
+}
+
+var errLoginRequired = errors.New("login required")
+
+// subscriptionError maps subscription errors onto responses, fallback is the
+// message for unexpected errors.
+func subscriptionError(c echo.Context, err error, fallback string) error {
+	switch {
+	case errors.Is(err, errLoginRequired):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription not found"})
+	case errors.Is(err, subscription.ErrPlanInactive),
+		errors.Is(err, subscription.ErrVariantMismatch),
+		errors.Is(err, subscription.ErrInvalidPauseDate):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, subscription.ErrInvalidState):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": fallback})
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/tax"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	TaxHandler struct {
+	}
+	TaxHandlerInterface interface {
+		GetSettings(c echo.Context) error
+		UpdateSettings(c echo.Context) error
+		ListClasses(c echo.Context) error
+		CreateClass(c echo.Context) error
+		UpdateClass(c echo.Context) error
+		DeleteClass(c echo.Context) error
+		ListZones(c echo.Context) error
+		GetZone(c echo.Context) error
+		CreateZone(c echo.Context) error
+		UpdateZone(c echo.Context) error
+		DeleteZone(c echo.Context) error
+	}
+	taxSettingsRequest struct {
+		Provider         string `json:"provider" validate:"required"`
+		PricesIncludeTax bool   `json:"prices_include_tax"`
+		RoundingMode     string `json:"rounding_mode" validate:"required"`
+	}
+	taxClassRequest struct {
+		Name string `json:"name" validate:"required,max=100"`
+		Code string `json:"code" validate:"required,max=50"`
+	}
+	taxRateRequest struct {
+		TaxClassID *uint  `json:"tax_class_id"`
+		Name       string `json:"name" validate:"required,max=100"`
+		Rate       int64  `json:"rate" validate:"min=0,max=10000"`
+		Compound   bool   `json:"compound"`
+		Priority   int    `json:"priority"`
+	}
+	taxZoneRequest struct {
+		Name        string           `json:"name" validate:"required,max=100"`
+		CountryCode string           `json:"country_code" validate:"required,max=5"`
+		ZipPrefix   string           `json:"zip_prefix" validate:"max=20"`
+		Rates       []taxRateRequest `json:"rates" validate:"dive"`
+	}
+	taxSettingsResponse struct {
+		types.TaxSettings
+		Providers []string `json:"providers"`
+	}
+)
+
+func NewTaxHandler() TaxHandlerInterface {
+	return &TaxHandler{}
+}
+
+func (h *TaxHandler) GetSettings(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	settings, err := tax.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching tax settings"})
+	}
+	return c.JSON(http.StatusOK, taxSettingsResponse{TaxSettings: settings, Providers: tax.Providers()})
+}
+
+func (h *TaxHandler) UpdateSettings(c echo.Context) error {
+	var req taxSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+	if !tax.ValidRoundingMode(req.RoundingMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": tax.ErrInvalidRoundingMode.Error()})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	if _, err := tax.New(req.Provider, db); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	settings, err := tax.Settings(db)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching tax settings"})
+	}
+	settings.Provider = req.Provider
+	settings.PricesIncludeTax = req.PricesIncludeTax
+	settings.RoundingMode = req.RoundingMode
+	if err := db.Save(&settings).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving tax settings"})
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+func (h *TaxHandler) ListClasses(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	classes := []types.TaxClass{}
+	if err := db.Order("name").Find(&classes).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching tax classes"})
+	}
+	return c.JSON(http.StatusOK, classes)
+}
+
+func (h *TaxHandler) CreateClass(c echo.Context) error {
+	var req taxClassRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	// check if code already exists
+	if err := db.Where("code = ?", req.Code).First(&types.TaxClass{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	class := types.TaxClass{Name: req.Name, Code: req.Code}
+	if err := db.Create(&class).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating tax class"})
+	}
+
+	return c.JSON(http.StatusCreated, class)
+}
+
+func (h *TaxHandler) UpdateClass(c echo.Context) error {
+	var req taxClassRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	class := types.TaxClass{}
+	if err := db.First(&class, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tax class not found"})
+	}
+	// check if code is taken by another class
+	if err := db.Where("code = ? AND id <> ?", req.Code, class.ID).First(&types.TaxClass{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "code already exists"})
+	}
+
+	class.Name = req.Name
+	class.Code = req.Code
+	if err := db.Save(&class).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating tax class"})
+	}
+
+	return c.JSON(http.StatusOK, class)
+}
+
+// DeleteClass removes a tax class. Products and rates of the class fall
+// back to the rates for every class.
+func (h *TaxHandler) DeleteClass(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&types.Product{}).Where("tax_class_id = ?", c.Param("id")).Update("tax_class_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tax_class_id = ?", c.Param("id")).Delete(&types.TaxRate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.TaxClass{}, c.Param("id")).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting tax class"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *TaxHandler) ListZones(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zones := []types.TaxZone{}
+	if err := db.Preload("Rates").Order("country_code, zip_prefix").Find(&zones).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching tax zones"})
+	}
+	return c.JSON(http.StatusOK, zones)
+}
+
+func (h *TaxHandler) GetZone(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.TaxZone{}
+	if err := db.Preload("Rates").First(&zone, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tax zone not found"})
+	}
+	return c.JSON(http.StatusOK, zone)
+}
+
+func (h *TaxHandler) CreateZone(c echo.Context) error {
+	return h.saveZone(c, types.TaxZone{}, http.StatusCreated)
+}
+
+func (h *TaxHandler) UpdateZone(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	zone := types.TaxZone{}
+	if err := db.First(&zone, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tax zone not found"})
+	}
+	return h.saveZone(c, zone, http.StatusOK)
+}
+
+// saveZone writes a zone and replaces its rates.
+func (h *TaxHandler) saveZone(c echo.Context, zone types.TaxZone, status int) error {
+	var req taxZoneRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	zone.Name = req.Name
+	zone.CountryCode = strings.ToUpper(strings.TrimSpace(req.CountryCode))
+	zone.ZipPrefix = tax.NormalizeZip(req.ZipPrefix)
+	// check if the area is covered by another zone
+	if err := db.Where("country_code = ? AND zip_prefix = ? AND id <> ?", zone.CountryCode, zone.ZipPrefix, zone.ID).First(&types.TaxZone{}).Error; err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "a zone for this area already exists"})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		zone.Rates = nil
+		if err := tx.Save(&zone).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tax_zone_id = ?", zone.ID).Delete(&types.TaxRate{}).Error; err != nil {
+			return err
+		}
+		for _, rate := range req.Rates {
+			zone.Rates = append(zone.Rates, types.TaxRate{
+				TaxZoneID:  zone.ID,
+				TaxClassID: rate.TaxClassID,
+				Name:       rate.Name,
+				Rate:       rate.Rate,
+				Compound:   rate.Compound,
+				Priority:   rate.Priority,
+			})
+		}
+		if len(zone.Rates) == 0 {
+			return nil
+		}
+		return tx.Create(&zone.Rates).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving tax zone"})
+	}
+
+	return c.JSON(status, zone)
+}
+
+func (h *TaxHandler) DeleteZone(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := db.Delete(&types.TaxZone{}, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting tax zone"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxWebhookBody bounds the size of an incoming webhook payload.
+const maxWebhookBody = 1 << 20
+
+type (
+	WebhookHandler struct {
+	}
+	WebhookHandlerInterface interface {
+		PaymentWebhook(c echo.Context) error
+		ListPaymentEvents(c echo.Context) error
+		ReplayPaymentEvent(c echo.Context) error
+	}
+)
+
+func NewWebhookHandler() WebhookHandlerInterface {
+	return &WebhookHandler{}
+}
+
+// PaymentWebhook receives an event from a payment provider. The tenant is
+// taken from the path, so the tenant middleware has already picked its
+// database. A non 2xx answer makes the provider deliver the event again.
+func (h *WebhookHandler) PaymentWebhook(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBody))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "error reading payload"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	provider, err := payment.ForTenant(db, c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, payment.ErrProviderNotConfigured) || errors.Is(err, payment.ErrUnknownProvider) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error loading payment provider"})
+	}
+
+	// check the signature before anything is stored
+	event, err := provider.VerifyWebhook(c.Request().Header, body)
+	if err != nil {
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if event.ID == "" || event.Type == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "event id and type are required"})
+	}
+
+	stored, duplicate, err := payment.StoreEvent(db, provider.Name(), event, body)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error storing event"})
+	}
+	if duplicate {
+		return c.JSON(http.StatusOK, map[string]string{"message": "event already processed"})
+	}
+
+	if err := order.ApplyPaymentEvent(db, &stored); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error processing event"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+func (h *WebhookHandler) ListPaymentEvents(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.PaymentWebhookEvent{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if reference := c.QueryParam("provider_reference"); reference != "" {
+		query = query.Where("provider_reference = ?", reference)
+	}
+
+	var events []types.PaymentWebhookEvent
+	res, err := findPage(c, query, &events)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching events"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ReplayPaymentEvent applies a stored event again, e.g. one that failed or
+// arrived before its payment was known. The signature was checked when the
+// event was received, so it is not checked again.
+func (h *WebhookHandler) ReplayPaymentEvent(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	stored := types.PaymentWebhookEvent{}
+	if err := db.First(&stored, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "event not found"})
+	}
+
+	if err := order.ApplyPaymentEvent(db, &stored); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": "error processing event", "event": stored})
+	}
+
+	return c.JSON(http.StatusOK, stored)
+}
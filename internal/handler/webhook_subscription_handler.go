@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	WebhookSubscriptionHandler struct {
+	}
+	WebhookSubscriptionHandlerInterface interface {
+		Events(c echo.Context) error
+		List(c echo.Context) error
+		Create(c echo.Context) error
+		Get(c echo.Context) error
+		Update(c echo.Context) error
+		Delete(c echo.Context) error
+		RotateSecret(c echo.Context) error
+		Test(c echo.Context) error
+		Deliveries(c echo.Context) error
+		GetDelivery(c echo.Context) error
+		Redeliver(c echo.Context) error
+	}
+	webhookSubscriptionRequest struct {
+		URL         string   `json:"url" validate:"required,url,max=2048"`
+		Description string   `json:"description" validate:"max=255"`
+		Events      []string `json:"events" validate:"required,min=1,dive,required,max=50"`
+		// Active re-enables a disabled subscription, or pauses one
+		Active *bool `json:"active"`
+	}
+	// webhookSubscriptionResponse reveals the signing secret, only when the
+	// subscription is created or its secret rotated
+	webhookSubscriptionResponse struct {
+		types.WebhookSubscription
+		Secret string `json:"secret"`
+	}
+)
+
+func NewWebhookSubscriptionHandler() WebhookSubscriptionHandlerInterface {
+	return &WebhookSubscriptionHandler{}
+}
+
+// Events lists the event types a subscription may name.
+func (h *WebhookSubscriptionHandler) Events(c echo.Context) error {
+	return c.JSON(http.StatusOK, hook.Events)
+}
+
+func (h *WebhookSubscriptionHandler) List(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.WebhookSubscription{}).Order("id DESC")
+	if active := c.QueryParam("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	var subs []types.WebhookSubscription
+	res, err := findPage(c, query, &subs)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching webhook subscriptions"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Create subscribes an endpoint to events. The response carries the secret
+// that signs its deliveries, which is not shown again.
+func (h *WebhookSubscriptionHandler) Create(c echo.Context) error {
+	var req webhookSubscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{Secret: hook.NewSecret(), Active: true}
+	if err := req.apply(&sub); err != nil {
+		return webhookSubscriptionError(c, err)
+	}
+	if err := db.Create(&sub).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating webhook subscription"})
+	}
+	return c.JSON(http.StatusCreated, webhookSubscriptionResponse{WebhookSubscription: sub, Secret: sub.Secret})
+}
+
+func (h *WebhookSubscriptionHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// Update changes a subscription. Turning a disabled subscription back on
+// clears its failures, and its pending deliveries are sent again on their
+// schedule.
+func (h *WebhookSubscriptionHandler) Update(c echo.Context) error {
+	var req webhookSubscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+	if err := req.apply(&sub); err != nil {
+		return webhookSubscriptionError(c, err)
+	}
+	if err := db.Save(&sub).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error updating webhook subscription"})
+	}
+	return c.JSON(http.StatusOK, sub)
+}
+
+// Delete removes a subscription together with its deliveries.
+func (h *WebhookSubscriptionHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", sub.ID).Delete(&types.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&sub).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting webhook subscription"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// RotateSecret replaces the signing secret of a subscription. Deliveries
+// are signed with the new secret from then on, retries included.
+func (h *WebhookSubscriptionHandler) RotateSecret(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+	sub.Secret = hook.NewSecret()
+	if err := db.Model(&sub).Update("secret", sub.Secret).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error rotating secret"})
+	}
+	return c.JSON(http.StatusOK, webhookSubscriptionResponse{WebhookSubscription: sub, Secret: sub.Secret})
+}
+
+// Test sends a webhook.test event to the subscription right away and
+// responds with the delivery and its attempt, whether or not the endpoint
+// accepted it.
+func (h *WebhookSubscriptionHandler) Test(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+	delivery, err := hook.SendTest(c.Request().Context(), db, sub, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error sending test event"})
+	}
+	return c.JSON(http.StatusOK, delivery)
+}
+
+// Deliveries lists the deliveries of a subscription, newest first,
+// optionally filtered by status and event.
+func (h *WebhookSubscriptionHandler) Deliveries(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrSubscriptionNotFound)
+	}
+
+	query := db.Model(&types.WebhookDelivery{}).Where("subscription_id = ?", sub.ID).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.QueryParam("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var deliveries []types.WebhookDelivery
+	res, err := findPage(c, query, &deliveries)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching deliveries"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (h *WebhookSubscriptionHandler) GetDelivery(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	delivery := types.WebhookDelivery{}
+	if err := db.Preload("History", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&delivery, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrDeliveryNotFound)
+	}
+	return c.JSON(http.StatusOK, delivery)
+}
+
+// Redeliver sends a delivery again right away and responds with the
+// attempt.
+func (h *WebhookSubscriptionHandler) Redeliver(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	delivery := types.WebhookDelivery{}
+	if err := db.First(&delivery, c.Param("id")).Error; err != nil {
+		return webhookSubscriptionError(c, hook.ErrDeliveryNotFound)
+	}
+	attempt, err := hook.Redeliver(c.Request().Context(), db, delivery.ID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error redelivering event"})
+	}
+	return c.JSON(http.StatusOK, attempt)
+}
+
+// apply copies the request onto sub.
+func (r *webhookSubscriptionRequest) apply(sub *types.WebhookSubscription) error {
+	if err := hook.ValidURL(r.URL); err != nil {
+		return err
+	}
+	events, err := hook.ParseEvents(r.Events)
+	if err != nil {
+		return err
+	}
+	sub.URL = r.URL
+	sub.Description = r.Description
+	sub.Events = events
+	if r.Active != nil {
+		if *r.Active && !sub.Active {
+			sub.FailedDeliveries = 0
+			sub.DisabledAt = nil
+			sub.DisabledReason = ""
+		}
+		sub.Active = *r.Active
+	}
+	return nil
+}
+
+// webhookSubscriptionError maps webhook subscription errors to responses.
+func webhookSubscriptionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, hook.ErrSubscriptionNotFound), errors.Is(err, hook.ErrDeliveryNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, hook.ErrUnknownEvent), errors.Is(err, hook.ErrNoEvents), errors.Is(err, hook.ErrInvalidURL),
+		errors.Is(err, hook.ErrPrivateAddress), errors.Is(err, hook.ErrUnresolvableHost):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving webhook subscription"})
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/internal/wishlist"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type (
+	WishlistHandler struct {
+	}
+	WishlistHandlerInterface interface {
+		MyWishlists(c echo.Context) error
+		Create(c echo.Context) error
+		Get(c echo.Context) error
+		Rename(c echo.Context) error
+		Delete(c echo.Context) error
+		AddItem(c echo.Context) error
+		RemoveItem(c echo.Context) error
+		Share(c echo.Context) error
+		Unshare(c echo.Context) error
+		Shared(c echo.Context) error
+		Subscribe(c echo.Context) error
+		Unsubscribe(c echo.Context) error
+		ListAlerts(c echo.Context) error
+		MostWanted(c echo.Context) error
+	}
+	wishlistRequest struct {
+		Name string `json:"name" validate:"required,max=100"`
+	}
+	wishlistItemRequest struct {
+		ProductID uint   `json:"product_id" validate:"required"`
+		VariantID *uint  `json:"variant_id"`
+		Note      string `json:"note" validate:"max=255"`
+	}
+	stockAlertRequest struct {
+		Email string `json:"email" validate:"omitempty,email"`
+	}
+	stockAlertResponse struct {
+		types.StockAlert
+		Token string `json:"token"`
+	}
+)
+
+var errWishlistNotFound = errors.New("wishlist not found")
+
+func NewWishlistHandler() WishlistHandlerInterface {
+	return &WishlistHandler{}
+}
+
+func (h *WishlistHandler) MyWishlists(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	var lists []types.Wishlist
+	if err := db.Preload("Items").Where("user_id = ?", userID).Order("id").Find(&lists).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching wishlists"})
+	}
+	return c.JSON(http.StatusOK, lists)
+}
+
+func (h *WishlistHandler) Create(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+	}
+	var req wishlistRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	list := types.Wishlist{UserID: userID, Name: strings.TrimSpace(req.Name)}
+	if err := db.Create(&list).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error creating wishlist"})
+	}
+	return c.JSON(http.StatusCreated, list)
+}
+
+func (h *WishlistHandler) Get(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	if err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product.Images").Preload("Items.Variant").First(&list, list.ID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching wishlist"})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *WishlistHandler) Rename(c echo.Context) error {
+	var req wishlistRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	list.Name = strings.TrimSpace(req.Name)
+	if err := db.Model(&list).Update("name", list.Name).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving wishlist"})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *WishlistHandler) Delete(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("wishlist_id = ?", list.ID).Delete(&types.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&list).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error deleting wishlist"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// AddItem saves a product, or one of its variants, to a list. The id
+// "default" names the customer's first list, which is created if needed.
+func (h *WishlistHandler) AddItem(c echo.Context) error {
+	var req wishlistItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	var list types.Wishlist
+	var err error
+	if c.Param("id") == "default" {
+		userID, ok := currentUserID(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "login required"})
+		}
+		list, err = wishlist.Default(db, userID)
+	} else {
+		list, err = myWishlist(c, db)
+	}
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	item, err := wishlist.AddItem(db, list, req.ProductID, req.VariantID, req.Note)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(http.StatusCreated, item)
+}
+
+func (h *WishlistHandler) RemoveItem(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	res := db.Where("wishlist_id = ?", list.ID).Delete(&types.WishlistItem{}, c.Param("item_id"))
+	if res.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error removing item"})
+	}
+	if res.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "item not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// Share makes a list readable by anyone with its share token.
+func (h *WishlistHandler) Share(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	if err := wishlist.Share(db, &list); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error sharing wishlist"})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (h *WishlistHandler) Unshare(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list, err := myWishlist(c, db)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	if err := wishlist.Unshare(db, &list); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error sharing wishlist"})
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+// Shared shows a shared list to anyone with its token. Items of products no
+// longer published are left out.
+func (h *WishlistHandler) Shared(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	list := types.Wishlist{}
+	published := db.Model(&types.Product{}).Select("id").Where("published = ?", true)
+	err := db.Preload("Items", func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("product_id IN (?)", published).Order("id")
+	}).Preload("Items.Product.Images").Preload("Items.Variant").
+		Where("share_token = ?", c.Param("token")).First(&list).Error
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "wishlist not found"})
+	}
+	list.UserID = 0
+	return c.JSON(http.StatusOK, list)
+}
+
+// Subscribe asks for an email when an out of stock variant is available
+// again. Guests give an email, signed in customers default to their own.
+// The returned token unsubscribes.
+func (h *WishlistHandler) Subscribe(c echo.Context) error {
+	var req stockAlertRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.(*echo.HTTPError).Message)
+	}
+
+	db := c.Get("db").(*gorm.DB)
+
+	var userID *uint
+	if id, ok := currentUserID(c); ok {
+		userID = &id
+		if req.Email == "" {
+			user := types.User{}
+			if err := db.First(&user, id).Error; err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching customer"})
+			}
+			req.Email = user.Email
+		}
+	}
+	if req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "email is required"})
+	}
+
+	variant := types.ProductVariant{}
+	if err := db.First(&variant, c.Param("id")).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "variant not found"})
+	}
+	alert, err := wishlist.Subscribe(db, variant.ID, req.Email, userID)
+	if err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(http.StatusCreated, stockAlertResponse{StockAlert: alert, Token: alert.Token})
+}
+
+func (h *WishlistHandler) Unsubscribe(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	if err := wishlist.Unsubscribe(db, c.Param("token")); err != nil {
+		return wishlistError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "success"})
+}
+
+// ListAlerts lists back-in-stock subscriptions. It filters on status and
+// variant_id.
+func (h *WishlistHandler) ListAlerts(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	query := db.Model(&types.StockAlert{}).Order("id DESC")
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if variantID := c.QueryParam("variant_id"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	var alerts []types.StockAlert
+	res, err := findPage(c, query, &alerts, "Variant")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error fetching stock alerts"})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// MostWanted reports the out of stock variants customers wait for or keep
+// on their wishlists, most wanted first. per_page bounds the rows.
+func (h *WishlistHandler) MostWanted(c echo.Context) error {
+	db := c.Get("db").(*gorm.DB)
+
+	_, limit := paginate(c)
+	items, err := wishlist.MostWanted(db, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error building report"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// myWishlist finds the list named in the path owned by the signed in
+// customer.
+func myWishlist(c echo.Context, db *gorm.DB) (types.Wishlist, error) {
+	list := types.Wishlist{}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return list, errLoginRequired
+	}
+	if err := db.Where("user_id = ?", userID).First(&list, c.Param("id")).Error; err != nil {
+		return list, errWishlistNotFound
+	}
+	return list, nil
+}
+
+// wishlistError maps wishlist and stock alert errors to responses.
+func wishlistError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errLoginRequired):
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	case errors.Is(err, errWishlistNotFound), errors.Is(err, wishlist.ErrProductNotFound),
+		errors.Is(err, wishlist.ErrVariantNotFound), errors.Is(err, wishlist.ErrAlertNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, wishlist.ErrVariantMismatch):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, wishlist.ErrInStock):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error saving wishlist"})
+}
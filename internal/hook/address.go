@@ -0,0 +1,102 @@
+package hook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// blockedNets are ranges outside the standard library's address classes
+// that still reach the host or its network: "this network", which Linux
+// routes to the host itself, and the carrier-grade NAT range.
+var blockedNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+}
+
+// lookupTimeout bounds resolving the host of a subscription URL.
+const lookupTimeout = 5 * time.Second
+
+// publicIP reports whether ip may receive deliveries. Loopback, private,
+// link-local, unspecified and multicast addresses are refused so a
+// subscription cannot make the server call itself or its internal network,
+// whose answers would come back in the response excerpt.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePublic checks that every address host resolves to is public.
+func resolvePublic(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrUnresolvableHost
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// refusePrivate is the dialer's control hook. It sees the address actually
+// connected to, after resolution, so a host that resolved to a public
+// address when the subscription was saved and to an internal one later is
+// still refused.
+func refusePrivate(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// newTransport returns the transport of the delivery client. It uses no
+// proxy, so the dialer checks the endpoint itself rather than the proxy.
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   Timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   refusePrivate,
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package hook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"127.1.2.3":        false,
+		"::1":              false,
+		"10.0.0.5":         false,
+		"172.16.3.4":       false,
+		"192.168.1.1":      false,
+		"fd00::1":          false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"::":               false,
+		"100.64.0.1":       false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.1.1.1":  false,
+	}
+	for raw, want := range cases {
+		if got := publicIP(net.ParseIP(raw)); got != want {
+			t.Errorf("%s public %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	cases := map[string]error{
+		"https://93.184.216.34/hooks":         nil,
+		"http://[2606:4700::1111]:8080/hooks": nil,
+		"ftp://93.184.216.34/hooks":           ErrInvalidURL,
+		"/hooks":                              ErrInvalidURL,
+		"http://:80/hooks":                    ErrInvalidURL,
+		"http://127.0.0.1:8080/hooks":         ErrPrivateAddress,
+		"http://[::1]/hooks":                  ErrPrivateAddress,
+		"http://169.254.169.254/latest":       ErrPrivateAddress,
+		"http://10.0.0.1/hooks":               ErrPrivateAddress,
+		"http://localhost/hooks":              ErrPrivateAddress,
+	}
+	for raw, want := range cases {
+		if err := ValidURL(raw); !errors.Is(err, want) {
+			t.Errorf("%s: %v, want %v", raw, err, want)
+		}
+	}
+}
+
+// TestClientRefusesPrivateAddresses checks the delivery client itself, which
+// must hold even for a host that resolved to a public address when the
+// subscription was saved.
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	res, err := client.Post(server.URL, "application/json", nil)
+	if err == nil {
+		res.Body.Close()
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("posting to %s returned %v, want ErrPrivateAddress", server.URL, err)
+	}
+	if called {
+		t.Error("the request reached the loopback server")
+	}
+}
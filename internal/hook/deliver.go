@@ -0,0 +1,301 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Delivery statuses. A pending delivery is retried on its schedule until it
+// succeeds or runs out of attempts and fails.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every delivery. The signature has the form
+// "t=<unix seconds>,v1=<hex hmac-sha256>", the hmac taken with the
+// subscription secret over the timestamp, a dot and the body.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	IDHeader        = "X-Webhook-ID"
+)
+
+// DisableAfter is the number of deliveries in a row given up on after which
+// a subscription is disabled.
+const DisableAfter = 5
+
+// Timeout bounds one delivery request.
+const Timeout = 10 * time.Second
+
+// batchSize bounds the deliveries a tenant sends per run.
+const batchSize = 100
+
+// maxExcerpt bounds the part of a response body kept with an attempt.
+const maxExcerpt = 1024
+
+// backoff is the wait before each retry; a delivery is given up on when it
+// fails once more after the last one, about two days after the event.
+var backoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// MaxAttempts is the number of attempts after which a delivery is given up on.
+var MaxAttempts = len(backoff) + 1
+
+// client does not follow redirects: a subscription must name the endpoint
+// itself, and a redirect counts as a failure. It only connects to public
+// addresses.
+var client = &http.Client{
+	Timeout:   Timeout,
+	Transport: newTransport(),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Sign returns the signature header value of body sent at time t.
+func Sign(secret string, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliverDue sends the pending deliveries of active subscriptions that are
+// due at now and returns how many succeeded. Each delivery is claimed with
+// a conditional update first so concurrent runs do not send it twice.
+func DeliverDue(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	active := db.Model(&types.WebhookSubscription{}).Select("id").Where("active = ?", true)
+
+	var due []types.WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ? AND subscription_id IN (?)", StatusPending, now, active).
+		Order("next_attempt_at, id").Limit(batchSize).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range due {
+		// hold the delivery for the length of the attempt
+		lease := now.Add(2 * Timeout)
+		res := db.Model(&types.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at <= ?", d.ID, StatusPending, now).
+			Update("next_attempt_at", lease)
+		if res.Error != nil {
+			return delivered, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		sub := types.WebhookSubscription{}
+		if err := db.First(&sub, d.SubscriptionID).Error; err != nil {
+			return delivered, err
+		}
+		attempt, err := send(ctx, db, sub, &d, now, false)
+		if err != nil {
+			return delivered, err
+		}
+		if succeeded(attempt) {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Redeliver sends a delivery again right away, whatever its status or the
+// state of its subscription, and returns the attempt. A pending delivery
+// keeps its retry schedule unless the attempt succeeds; one without a
+// scheduled retry is failed when the attempt does. Manual failures do not
+// count against the subscription.
+func Redeliver(ctx context.Context, db *gorm.DB, deliveryID uint, now time.Time) (types.WebhookAttempt, error) {
+	d := types.WebhookDelivery{}
+	if err := db.First(&d, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.WebhookAttempt{}, ErrDeliveryNotFound
+		}
+		return types.WebhookAttempt{}, err
+	}
+	sub := types.WebhookSubscription{}
+	if err := db.First(&sub, d.SubscriptionID).Error; err != nil {
+		return types.WebhookAttempt{}, err
+	}
+	return send(ctx, db, sub, &d, now, true)
+}
+
+// SendTest sends a test event to a subscription, whether or not it is
+// active, and returns the delivery with its attempt. Test deliveries are not
+// retried.
+func SendTest(ctx context.Context, db *gorm.DB, sub types.WebhookSubscription, now time.Time) (types.WebhookDelivery, error) {
+	eventID, payload, err := envelope(Test, map[string]interface{}{
+		"subscription_id": sub.ID,
+		"message":         "This is a test event.",
+	})
+	if err != nil {
+		return types.WebhookDelivery{}, err
+	}
+	d := types.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        eventID,
+		Event:          Test,
+		Payload:        payload,
+		Status:         StatusPending,
+	}
+	if err := db.Create(&d).Error; err != nil {
+		return d, err
+	}
+	attempt, err := send(ctx, db, sub, &d, now, true)
+	if err != nil {
+		return d, err
+	}
+	d.History = []types.WebhookAttempt{attempt}
+	return d, nil
+}
+
+// send makes one attempt of d and records it. A scheduled attempt that
+// fails moves the delivery to its next retry, or fails it when none is
+// left; giving up on a delivery counts against the subscription, which is
+// disabled once DisableAfter deliveries in a row were given up on. Any
+// successful attempt clears the count.
+func send(ctx context.Context, db *gorm.DB, sub types.WebhookSubscription, d *types.WebhookDelivery, now time.Time, manual bool) (types.WebhookAttempt, error) {
+	started := time.Now()
+	code, excerpt, err := post(ctx, sub, *d, now)
+	attempt := types.WebhookAttempt{
+		DeliveryID:   d.ID,
+		StatusCode:   code,
+		ResponseBody: excerpt,
+		DurationMs:   time.Since(started).Milliseconds(),
+		Manual:       manual,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	} else if !succeeded(attempt) {
+		attempt.Error = fmt.Sprintf("endpoint answered %d", code)
+	}
+
+	d.Attempts++
+	d.LastStatusCode = code
+	gaveUp := false
+	switch {
+	case succeeded(attempt):
+		d.Status = StatusSucceeded
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+	case manual && d.Status == StatusPending && d.NextAttemptAt != nil:
+		// the retry schedule carries on
+	case manual:
+		d.Status = StatusFailed
+		d.NextAttemptAt = nil
+	case d.Attempts >= MaxAttempts:
+		d.Status = StatusFailed
+		d.NextAttemptAt = nil
+		gaveUp = true
+	default:
+		next := now.Add(Backoff(d.Attempts))
+		d.NextAttemptAt = &next
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(d).Select("status", "attempts", "last_status_code", "next_attempt_at", "delivered_at").
+			Updates(d).Error; err != nil {
+			return err
+		}
+		switch {
+		case succeeded(attempt):
+			return tx.Model(&types.WebhookSubscription{}).Where("id = ?", sub.ID).
+				Updates(map[string]interface{}{"failed_deliveries": 0, "last_success_at": now}).Error
+		case gaveUp:
+			return giveUp(tx, sub.ID, now)
+		}
+		return nil
+	})
+	return attempt, err
+}
+
+// giveUp counts a delivery given up on against the subscription and
+// disables it when too many were in a row.
+func giveUp(tx *gorm.DB, subscriptionID uint, now time.Time) error {
+	if err := tx.Model(&types.WebhookSubscription{}).Where("id = ?", subscriptionID).
+		Update("failed_deliveries", gorm.Expr("failed_deliveries + 1")).Error; err != nil {
+		return err
+	}
+	return tx.Model(&types.WebhookSubscription{}).
+		Where("id = ? AND active = ? AND failed_deliveries >= ?", subscriptionID, true, DisableAfter).
+		Updates(map[string]interface{}{
+			"active":          false,
+			"disabled_at":     now,
+			"disabled_reason": fmt.Sprintf("%d deliveries in a row failed", DisableAfter),
+		}).Error
+}
+
+// Backoff returns the wait before the retry that follows attempt number
+// attempts.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	if attempts > len(backoff) {
+		return backoff[len(backoff)-1]
+	}
+	return backoff[attempts-1]
+}
+
+// post sends the payload of d to the subscription and returns the status
+// code and the start of the response body.
+func post(ctx context.Context, sub types.WebhookSubscription, d types.WebhookDelivery, now time.Time) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "multicommers-webhooks/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(IDHeader, d.EventID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, body, now))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	excerpt, _ := io.ReadAll(io.LimitReader(res.Body, maxExcerpt))
+	// the excerpt is stored as text, which takes neither broken utf-8 nor
+	// NUL bytes
+	return res.StatusCode, strings.ReplaceAll(strings.ToValidUTF8(string(excerpt), ""), "\x00", ""), nil
+}
+
+func succeeded(attempt types.WebhookAttempt) bool {
+	return attempt.Error == "" && attempt.StatusCode >= 200 && attempt.StatusCode < 300
+}
@@ -0,0 +1,195 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// Event types. Order events other than order.created are named after the
+// status the order moved to.
+const (
+	OrderCreated    = "order.created"
+	OrderPaid       = "order.paid"
+	OrderFulfilling = "order.fulfilling"
+	OrderShipped    = "order.shipped"
+	OrderDelivered  = "order.delivered"
+	OrderCancelled  = "order.cancelled"
+	OrderRefunded   = "order.refunded"
+	ProductCreated  = "product.created"
+	ProductUpdated  = "product.updated"
+	ProductDeleted  = "product.deleted"
+	InventoryLow    = "inventory.low"
+
+	// Test is only sent by SendTest and cannot be subscribed to.
+	Test = "webhook.test"
+)
+
+// AllEvents subscribes to every event, including ones added later.
+const AllEvents = "*"
+
+// Events lists the event types a subscription may name.
+var Events = []string{
+	OrderCreated, OrderPaid, OrderFulfilling, OrderShipped, OrderDelivered, OrderCancelled, OrderRefunded,
+	ProductCreated, ProductUpdated, ProductDeleted,
+	InventoryLow,
+}
+
+var (
+	ErrUnknownEvent         = errors.New("unknown event")
+	ErrNoEvents             = errors.New("at least one event is required")
+	ErrInvalidURL           = errors.New("url must be an absolute http or https url")
+	ErrPrivateAddress       = errors.New("url must not point at a private, loopback or link-local address")
+	ErrUnresolvableHost     = errors.New("url host could not be resolved")
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+)
+
+// Envelope is the body of every delivery. Data is the object the event is
+// about, as the admin API returns it.
+type Envelope struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewSecret returns a fresh signing secret.
+func NewSecret() string {
+	return "whsec_" + randomString.GenerateSecureToken(24)
+}
+
+// ParseEvents checks a list of event types and returns it in the form
+// stored on a subscription.
+func ParseEvents(events []string) (string, error) {
+	if len(events) == 0 {
+		return "", ErrNoEvents
+	}
+	seen := map[string]bool{}
+	list := []string{}
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if event == AllEvents {
+			return AllEvents, nil
+		}
+		if !known(event) {
+			return "", fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			list = append(list, event)
+		}
+	}
+	return strings.Join(list, ","), nil
+}
+
+// ValidURL checks the endpoint of a subscription. Its host must resolve to
+// public addresses only; deliveries check the address again when they
+// connect.
+func ValidURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	return resolvePublic(u.Hostname())
+}
+
+// Subscribes reports whether sub wants event.
+func Subscribes(sub types.WebhookSubscription, event string) bool {
+	for _, name := range strings.Split(sub.Events, ",") {
+		if name == AllEvents || name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether any active subscription wants event, so callers can
+// skip building a payload nobody receives.
+func Wants(db *gorm.DB, event string) (bool, error) {
+	subs, err := subscribers(db, event)
+	return len(subs) > 0, err
+}
+
+// Publish queues event with data for every active subscription that wants
+// it. Call it inside the transaction of the change the event reports: the
+// deliveries are then stored only if the change is, and sent by the
+// delivery job once it commits.
+func Publish(db *gorm.DB, event string, data interface{}) error {
+	subs, err := subscribers(db, event)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	eventID, payload, err := envelope(event, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]types.WebhookDelivery, 0, len(subs))
+	for _, sub := range subs {
+		deliveries = append(deliveries, types.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			Event:          event,
+			Payload:        payload,
+			Status:         StatusPending,
+			NextAttemptAt:  &now,
+		})
+	}
+	return db.Create(&deliveries).Error
+}
+
+// PublishProduct publishes event with the product, its tags and variants.
+// Deleted products are published as they were when deleted.
+func PublishProduct(db *gorm.DB, event string, productID uint) error {
+	wanted, err := Wants(db, event)
+	if err != nil || !wanted {
+		return err
+	}
+	product := types.Product{}
+	if err := db.Unscoped().Preload("Tags").Preload("Variants.Options").First(&product, productID).Error; err != nil {
+		return err
+	}
+	return Publish(db, event, product)
+}
+
+func subscribers(db *gorm.DB, event string) ([]types.WebhookSubscription, error) {
+	var active []types.WebhookSubscription
+	if err := db.Where("active = ?", true).Find(&active).Error; err != nil {
+		return nil, err
+	}
+	subs := []types.WebhookSubscription{}
+	for _, sub := range active {
+		if Subscribes(sub, event) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// envelope wraps data for event and returns the event id and the body.
+func envelope(event string, data interface{}) (string, string, error) {
+	eventID := "evt_" + randomString.GenerateSecureToken(12)
+	body, err := json.Marshal(Envelope{ID: eventID, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return "", "", err
+	}
+	return eventID, string(body), nil
+}
+
+func known(event string) bool {
+	for _, name := range Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
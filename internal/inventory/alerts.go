@@ -0,0 +1,77 @@
+package inventory
+
+import (
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Stock alert statuses. Waiting alerts are queued once their variant has
+// stock available again and become notified when their email is sent.
+const (
+	AlertWaiting  = "waiting"
+	AlertQueued   = "queued"
+	AlertNotified = "notified"
+)
+
+// queueAlerts queues the waiting back-in-stock alerts of a variant if it
+// has stock available. Sending is left to a background job so a large
+// delivery does not hold up the stock movement.
+func queueAlerts(tx *gorm.DB, variantID uint) error {
+	var waiting int64
+	if err := tx.Model(&types.StockAlert{}).Where("variant_id = ? AND status = ?", variantID, AlertWaiting).Count(&waiting).Error; err != nil {
+		return err
+	}
+	if waiting == 0 {
+		return nil
+	}
+	available, err := Available(tx, variantID)
+	if err != nil || available <= 0 {
+		return err
+	}
+	return tx.Model(&types.StockAlert{}).
+		Where("variant_id = ? AND status = ?", variantID, AlertWaiting).
+		Updates(map[string]interface{}{"status": AlertQueued, "queued_at": time.Now()}).Error
+}
+
+// LowStock is the data of the inventory.low webhook event.
+type LowStock struct {
+	VariantID uint   `json:"variant_id"`
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Available int    `json:"available"`
+	Threshold int    `json:"threshold"`
+}
+
+// reportLow publishes inventory.low when taking quantity units of a variant
+// brought its available stock from above its low stock threshold to or
+// below it, so the event fires once per drop rather than on every sale.
+func reportLow(tx *gorm.DB, variantID uint, quantity int) error {
+	wanted, err := hook.Wants(tx, hook.InventoryLow)
+	if err != nil || !wanted {
+		return err
+	}
+	variant := types.ProductVariant{}
+	if err := tx.First(&variant, variantID).Error; err != nil {
+		return err
+	}
+	if variant.LowStockThreshold <= 0 {
+		return nil
+	}
+	available, err := Available(tx, variantID)
+	if err != nil {
+		return err
+	}
+	if available > variant.LowStockThreshold || available+quantity <= variant.LowStockThreshold {
+		return nil
+	}
+	return hook.Publish(tx, hook.InventoryLow, LowStock{
+		VariantID: variant.ID,
+		ProductID: variant.ProductID,
+		SKU:       variant.SKU,
+		Available: available,
+		Threshold: variant.LowStockThreshold,
+	})
+}
@@ -0,0 +1,129 @@
+package inventory
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reason codes recorded on stock movements.
+const (
+	ReasonReceived    = "received"
+	ReasonSold        = "sold"
+	ReasonReturned    = "returned"
+	ReasonAdjustment  = "adjustment"
+	ReasonDamaged     = "damaged"
+	ReasonCancelled   = "cancelled"
+	ReasonTransferIn  = "transfer_in"
+	ReasonTransferOut = "transfer_out"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidReason     = errors.New("invalid reason code")
+	ErrSameLocation      = errors.New("source and destination locations are the same")
+)
+
+// ValidReason reports whether reason is a known movement reason code.
+func ValidReason(reason string) bool {
+	switch reason {
+	case ReasonReceived, ReasonSold, ReasonReturned, ReasonAdjustment,
+		ReasonDamaged, ReasonCancelled, ReasonTransferIn, ReasonTransferOut:
+		return true
+	}
+	return false
+}
+
+// Adjust applies a stock movement: it changes the on-hand quantity of the
+// variant at the location by movement.Quantity and appends the movement to
+// the ledger. Decrements fail with ErrInsufficientStock rather than eat into
+// reserved stock. Increments that make the variant available queue its
+// back-in-stock alerts, decrements that take it down to its low stock
+// threshold report it low.
+func Adjust(db *gorm.DB, movement types.StockMovement) error {
+	if !ValidReason(movement.Reason) {
+		return ErrInvalidReason
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := applyOnHand(tx, movement.VariantID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return err
+		}
+		if movement.Quantity > 0 {
+			return queueAlerts(tx, movement.VariantID)
+		}
+		// stock moving out only passes through when transferred
+		if movement.Reason != ReasonTransferOut {
+			return reportLow(tx, movement.VariantID, -movement.Quantity)
+		}
+		return nil
+	})
+}
+
+// Transfer moves quantity units of a variant between two locations.
+func Transfer(db *gorm.DB, variantID, fromLocationID, toLocationID uint, quantity int, reference string) error {
+	if fromLocationID == toLocationID {
+		return ErrSameLocation
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := Adjust(tx, types.StockMovement{
+			VariantID:  variantID,
+			LocationID: fromLocationID,
+			Quantity:   -quantity,
+			Reason:     ReasonTransferOut,
+			Reference:  reference,
+		}); err != nil {
+			return err
+		}
+		return Adjust(tx, types.StockMovement{
+			VariantID:  variantID,
+			LocationID: toLocationID,
+			Quantity:   quantity,
+			Reason:     ReasonTransferIn,
+			Reference:  reference,
+		})
+	})
+}
+
+// Available returns the total available quantity of a variant over all
+// active locations.
+func Available(db *gorm.DB, variantID uint) (int, error) {
+	var available int
+	err := db.Model(&types.InventoryLevel{}).
+		Joins("JOIN stock_locations ON stock_locations.id = inventory_levels.location_id AND stock_locations.active AND stock_locations.deleted_at IS NULL").
+		Where("inventory_levels.variant_id = ?", variantID).
+		Select("COALESCE(SUM(inventory_levels.on_hand - inventory_levels.reserved), 0)").
+		Scan(&available).Error
+	return available, err
+}
+
+// applyOnHand changes the on-hand quantity with a single conditional
+// statement so concurrent decrements can never take it below what is
+// reserved.
+func applyOnHand(tx *gorm.DB, variantID, locationID uint, delta int) error {
+	if delta >= 0 {
+		level := types.InventoryLevel{VariantID: variantID, LocationID: locationID, OnHand: delta}
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "variant_id"}, {Name: "location_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"on_hand":    gorm.Expr("inventory_levels.on_hand + ?", delta),
+				"updated_at": gorm.Expr("NOW()"),
+			}),
+		}).Create(&level).Error
+	}
+
+	res := tx.Model(&types.InventoryLevel{}).
+		Where("variant_id = ? AND location_id = ? AND on_hand - reserved >= ?", variantID, locationID, -delta).
+		Update("on_hand", gorm.Expr("on_hand + ?", delta))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
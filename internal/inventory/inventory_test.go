@@ -0,0 +1,201 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// seedStock creates a variant with perLocation units on hand at each of
+// locations active locations.
+func seedStock(t *testing.T, db *gorm.DB, locations, perLocation int) (uint, []uint) {
+	t.Helper()
+	product := types.Product{Title: "Mug", Handle: "mug"}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	variant := types.ProductVariant{ProductID: product.ID, SKU: "MUG-1"}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatal(err)
+	}
+	warehouse := types.Warehouse{Name: "Main", Code: "MAIN", Active: true}
+	if err := db.Create(&warehouse).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []uint{}
+	for i := 0; i < locations; i++ {
+		location := types.StockLocation{WarehouseID: warehouse.ID, Name: fmt.Sprintf("Bin %d", i), Code: fmt.Sprintf("BIN-%d", i), Active: true}
+		if err := db.Create(&location).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := Adjust(db, types.StockMovement{VariantID: variant.ID, LocationID: location.ID, Quantity: perLocation, Reason: ReasonReceived}); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, location.ID)
+	}
+	return variant.ID, ids
+}
+
+// run starts n goroutines at once and waits for all of them.
+func run(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+// levels returns the total on hand and reserved of a variant and fails the
+// test if any location has more reserved than on hand.
+func levels(t *testing.T, db *gorm.DB, variantID uint) (onHand, reserved int) {
+	t.Helper()
+	var rows []types.InventoryLevel
+	if err := db.Where("variant_id = ?", variantID).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range rows {
+		if level.Reserved < 0 || level.Available() < 0 {
+			t.Errorf("location %d oversold: on hand %d, reserved %d", level.LocationID, level.OnHand, level.Reserved)
+		}
+		onHand += level.OnHand
+		reserved += level.Reserved
+	}
+	return onHand, reserved
+}
+
+func TestReserveNeverOversells(t *testing.T) {
+	db := testdb.Open(t)
+	variantID, _ := seedStock(t, db, 2, 5)
+
+	var reservedCount int64
+	run(40, func(i int) {
+		_, err := Reserve(db, variantID, 1, fmt.Sprintf("cart:%d", i), time.Minute)
+		switch {
+		case err == nil:
+			atomic.AddInt64(&reservedCount, 1)
+		case !errors.Is(err, ErrInsufficientStock):
+			t.Errorf("reserve %d: %s", i, err)
+		}
+	})
+
+	if reservedCount != 10 {
+		t.Errorf("reserved %d units of 10 in stock", reservedCount)
+	}
+	onHand, reserved := levels(t, db, variantID)
+	if onHand != 10 || reserved != 10 {
+		t.Errorf("on hand %d, reserved %d, want 10 and 10", onHand, reserved)
+	}
+}
+
+func TestAdjustNeverEatsIntoReserved(t *testing.T) {
+	db := testdb.Open(t)
+	variantID, locations := seedStock(t, db, 1, 10)
+	if _, err := Reserve(db, variantID, 4, "order:HELD", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var removed int64
+	run(20, func(i int) {
+		err := Adjust(db, types.StockMovement{VariantID: variantID, LocationID: locations[0], Quantity: -1, Reason: ReasonDamaged})
+		switch {
+		case err == nil:
+			atomic.AddInt64(&removed, 1)
+		case !errors.Is(err, ErrInsufficientStock):
+			t.Errorf("adjust %d: %s", i, err)
+		}
+	})
+
+	if removed != 6 {
+		t.Errorf("removed %d units, only 6 were unreserved", removed)
+	}
+	onHand, reserved := levels(t, db, variantID)
+	if onHand != 4 || reserved != 4 {
+		t.Errorf("on hand %d, reserved %d, want 4 and 4", onHand, reserved)
+	}
+}
+
+func TestReserveAndAdjustTogether(t *testing.T) {
+	db := testdb.Open(t)
+	variantID, locations := seedStock(t, db, 1, 10)
+
+	var reservedCount, removed int64
+	run(30, func(i int) {
+		var err error
+		if i%2 == 0 {
+			if _, err = Reserve(db, variantID, 1, fmt.Sprintf("cart:%d", i), time.Minute); err == nil {
+				atomic.AddInt64(&reservedCount, 1)
+			}
+		} else {
+			err = Adjust(db, types.StockMovement{VariantID: variantID, LocationID: locations[0], Quantity: -1, Reason: ReasonDamaged})
+			if err == nil {
+				atomic.AddInt64(&removed, 1)
+			}
+		}
+		if err != nil && !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("worker %d: %s", i, err)
+		}
+	})
+
+	if reservedCount+removed != 10 {
+		t.Errorf("reserved %d and removed %d units of 10 in stock", reservedCount, removed)
+	}
+	onHand, reserved := levels(t, db, variantID)
+	if int64(reserved) != reservedCount || int64(onHand) != 10-removed {
+		t.Errorf("on hand %d, reserved %d after reserving %d and removing %d", onHand, reserved, reservedCount, removed)
+	}
+}
+
+func TestReleasedReservationsQueueAlerts(t *testing.T) {
+	db := testdb.Open(t)
+	variantID, _ := seedStock(t, db, 1, 2)
+	if _, err := Reserve(db, variantID, 1, "order:HELD", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reserve(db, variantID, 1, "cart:1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	alert := types.StockAlert{VariantID: variantID, Email: "ada@example.com", Token: "token", Status: AlertWaiting}
+	if err := db.Create(&alert).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	status := func() string {
+		t.Helper()
+		if err := db.First(&alert, alert.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return alert.Status
+	}
+
+	if err := Release(db, "order:HELD"); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != AlertQueued {
+		t.Errorf("alert is %s after a release, want queued", got)
+	}
+
+	if err := db.Model(&alert).Update("status", AlertWaiting).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExpireReservations(db, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != AlertQueued {
+		t.Errorf("alert is %s after an expiry, want queued", got)
+	}
+}
@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reservation statuses.
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+var ErrNoReservation = errors.New("no active reservation")
+
+// Reserve holds quantity units of a variant for reference (a cart or order)
+// until ttl elapses. Stock is taken from active locations by priority and may
+// be split over several of them. Either the full quantity is reserved or
+// ErrInsufficientStock is returned and nothing is held. A reservation that
+// takes the variant down to its low stock threshold reports it low.
+func Reserve(db *gorm.DB, variantID uint, quantity int, reference string, ttl time.Duration) ([]types.StockReservation, error) {
+	var reservations []types.StockReservation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// give back anything that already timed out for this variant
+		if _, err := releaseWhere(tx, ReservationExpired, "variant_id = ? AND status = ? AND expires_at <= ?", variantID, ReservationActive, time.Now()); err != nil {
+			return err
+		}
+
+		var levels []types.InventoryLevel
+		if err := tx.Model(&types.InventoryLevel{}).
+			Joins("JOIN stock_locations ON stock_locations.id = inventory_levels.location_id AND stock_locations.active AND stock_locations.deleted_at IS NULL").
+			Where("inventory_levels.variant_id = ? AND inventory_levels.on_hand > inventory_levels.reserved", variantID).
+			Order("stock_locations.priority DESC, inventory_levels.on_hand - inventory_levels.reserved DESC").
+			Find(&levels).Error; err != nil {
+			return err
+		}
+
+		remaining := quantity
+		expiresAt := time.Now().Add(ttl)
+		for _, level := range levels {
+			for remaining > 0 {
+				// re-read the level, another checkout may have taken stock since
+				var fresh types.InventoryLevel
+				if err := tx.First(&fresh, level.ID).Error; err != nil {
+					return err
+				}
+				take := fresh.Available()
+				if take > remaining {
+					take = remaining
+				}
+				if take <= 0 {
+					break
+				}
+
+				res := tx.Model(&types.InventoryLevel{}).
+					Where("id = ? AND on_hand - reserved >= ?", level.ID, take).
+					Update("reserved", gorm.Expr("reserved + ?", take))
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					continue
+				}
+
+				reservation := types.StockReservation{
+					VariantID:  variantID,
+					LocationID: level.LocationID,
+					Quantity:   take,
+					Reference:  reference,
+					Status:     ReservationActive,
+					ExpiresAt:  expiresAt,
+				}
+				if err := tx.Create(&reservation).Error; err != nil {
+					return err
+				}
+				reservations = append(reservations, reservation)
+				remaining -= take
+			}
+			if remaining == 0 {
+				break
+			}
+		}
+		if remaining > 0 {
+			return ErrInsufficientStock
+		}
+		return reportLow(tx, variantID, quantity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// Extend pushes back the expiry of every active reservation of reference.
+func Extend(db *gorm.DB, reference string, expiresAt time.Time) error {
+	return db.Model(&types.StockReservation{}).
+		Where("reference = ? AND status = ?", reference, ReservationActive).
+		Update("expires_at", expiresAt).Error
+}
+
+// Release gives back every active reservation of reference.
+func Release(db *gorm.DB, reference string) error {
+	_, err := releaseWhere(db, ReservationReleased, "reference = ? AND status = ?", reference, ReservationActive)
+	return err
+}
+
+// Commit turns the active reservations of reference into sales: the reserved
+// units leave on-hand stock and a sold movement is written for each of them.
+func Commit(db *gorm.DB, reference string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var reservations []types.StockReservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reference = ? AND status = ?", reference, ReservationActive).
+			Find(&reservations).Error; err != nil {
+			return err
+		}
+		if len(reservations) == 0 {
+			return ErrNoReservation
+		}
+
+		for _, r := range reservations {
+			if err := tx.Model(&types.InventoryLevel{}).
+				Where("variant_id = ? AND location_id = ?", r.VariantID, r.LocationID).
+				Updates(map[string]interface{}{
+					"on_hand":  gorm.Expr("on_hand - ?", r.Quantity),
+					"reserved": gorm.Expr("reserved - ?", r.Quantity),
+				}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&types.StockMovement{
+				VariantID:  r.VariantID,
+				LocationID: r.LocationID,
+				Quantity:   -r.Quantity,
+				Reason:     ReasonSold,
+				Reference:  reference,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&r).Update("status", ReservationCommitted).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExpireReservations releases every active reservation whose expiry has
+// passed and returns how many were released.
+func ExpireReservations(db *gorm.DB, now time.Time) (int, error) {
+	return releaseWhere(db, ReservationExpired, "status = ? AND expires_at <= ?", ReservationActive, now)
+}
+
+// releaseWhere moves the matching reservations to status and returns their
+// units to available stock. Each reservation is flipped with a conditional
+// update so concurrent releases can never return the same units twice.
+// Back-in-stock alerts of the variants that became available are queued.
+func releaseWhere(db *gorm.DB, status string, query string, args ...interface{}) (int, error) {
+	released := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reservations []types.StockReservation
+		if err := tx.Where(query, args...).Find(&reservations).Error; err != nil {
+			return err
+		}
+
+		variants := map[uint]bool{}
+		for _, r := range reservations {
+			res := tx.Model(&types.StockReservation{}).
+				Where("id = ? AND status = ?", r.ID, ReservationActive).
+				Update("status", status)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				continue
+			}
+			if err := tx.Model(&types.InventoryLevel{}).
+				Where("variant_id = ? AND location_id = ?", r.VariantID, r.LocationID).
+				Update("reserved", gorm.Expr("reserved - ?", r.Quantity)).Error; err != nil {
+				return err
+			}
+			variants[r.VariantID] = true
+			released++
+		}
+
+		for variantID := range variants {
+			if err := queueAlerts(tx, variantID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return released, err
+}
@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/bulk"
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/clock"
+	"github.com/Satishcg12/multicommers/internal/database"
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/jobs"
+	"github.com/Satishcg12/multicommers/internal/ledger"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/recovery"
+	"github.com/Satishcg12/multicommers/internal/subscription"
+	"github.com/Satishcg12/multicommers/internal/wishlist"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"gorm.io/gorm"
+)
+
+// registerJobs sets up the recurring background jobs. Tenant jobs run against
+// every tenant of the platform.
+func registerJobs(scheduler jobs.SchedulerInterface, tenantManager *database.DatabaseManager, clk clock.Clock, mailer email.EmailDaemonInterface) {
+	scheduler.Every("expire-stock-reservations", time.Minute, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := inventory.ExpireReservations(db, time.Now()); err != nil {
+				log.Printf("Error expiring stock reservations for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("expire-abandoned-carts", time.Hour, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := cart.ExpireAbandoned(db, time.Now()); err != nil {
+				log.Printf("Error expiring carts for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("cancel-unpaid-orders", 5*time.Minute, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := order.CancelUnpaid(db, time.Now()); err != nil {
+				log.Printf("Error cancelling unpaid orders for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("sync-vendor-ledgers", 5*time.Minute, func() error {
+		main := tenantManager.MainDB()
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if err := ledger.Sync(main, tenantID, db); err != nil {
+				log.Printf("Error syncing the ledger of %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("generate-payouts", 24*time.Hour, func() error {
+		_, err := ledger.GeneratePayouts(tenantManager.MainDB(), ledger.PayoutThreshold(), time.Now())
+		return err
+	})
+
+	scheduler.Every("renew-subscriptions", time.Hour, func() error {
+		_, err := billing.Renew(context.Background(), tenantManager.MainDB(), clk.Now())
+		return err
+	})
+
+	scheduler.Every("run-product-imports", 15*time.Second, func() error {
+		main := tenantManager.MainDB()
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := bulk.RunPending(main, db, tenantID, time.Now()); err != nil {
+				log.Printf("Error running product imports for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("renew-customer-subscriptions", 15*time.Minute, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			outcomes, err := subscription.Renew(context.Background(), db, clk.Now())
+			subscription.Notify(mailer, outcomes...)
+			if err != nil {
+				log.Printf("Error renewing subscriptions for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("send-back-in-stock-alerts", time.Minute, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := wishlist.SendQueued(db, mailer, tenantID, clk.Now()); err != nil {
+				log.Printf("Error sending back-in-stock alerts for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("send-cart-reminders", 5*time.Minute, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := recovery.Run(db, mailer, tenantID, clk.Now()); err != nil {
+				log.Printf("Error sending cart reminders for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+
+	scheduler.Every("deliver-webhooks", 15*time.Second, func() error {
+		tenantManager.ForEachTenant(func(tenantID string, db *gorm.DB) {
+			if _, err := hook.DeliverDue(context.Background(), db, time.Now()); err != nil {
+				log.Printf("Error delivering webhooks for %s: %s", tenantID, err)
+			}
+		})
+		return nil
+	})
+}
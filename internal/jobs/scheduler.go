@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type (
+	job struct {
+		name     string
+		interval time.Duration
+		run      func() error
+	}
+	Scheduler struct {
+		mu   sync.Mutex
+		jobs []job
+		stop chan struct{}
+	}
+	SchedulerInterface interface {
+		Every(name string, interval time.Duration, run func() error)
+		Start()
+		Stop()
+	}
+)
+
+func NewScheduler() SchedulerInterface {
+	return &Scheduler{
+		stop: make(chan struct{}),
+	}
+}
+
+// Every registers a job that runs once per interval after Start is called.
+func (s *Scheduler) Every(name string, interval time.Duration, run func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		go s.loop(j)
+	}
+}
+
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) loop(j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.runOnce(j)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// runOnce runs a job and keeps a failing or panicking job from taking the
+// scheduler down with it.
+func (s *Scheduler) runOnce(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job %s panicked: %v", j.name, r)
+		}
+	}()
+	if err := j.run(); err != nil {
+		log.Printf("job %s failed: %s", j.name, err)
+	}
+}
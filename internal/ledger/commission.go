@@ -0,0 +1,155 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// PlanFor returns the commission plan of the vendor, the default plan when
+// the vendor has none, or an empty plan taking nothing.
+func PlanFor(db *gorm.DB, vendor types.Vendor) (types.CommissionPlan, error) {
+	plan := types.CommissionPlan{}
+	query := db.Where("\"default\" = ?", true)
+	if vendor.CommissionPlanID != nil {
+		query = db.Where("id = ?", *vendor.CommissionPlanID)
+	}
+	err := query.Order("id").First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.CommissionPlan{}, nil
+	}
+	return plan, err
+}
+
+// Commission returns the platform's cut of an order: Rate of the goods value
+// after discounts, rounded half up, plus the fixed fee. It never exceeds the
+// order total.
+func Commission(plan types.CommissionPlan, o types.Order) int64 {
+	goods := o.Subtotal - o.DiscountTotal
+	commission := (goods*plan.Rate+5000)/10000 + plan.FixedFee
+	return max(min(commission, o.Total), 0)
+}
+
+// OrderReference identifies an order of a tenant in the ledger.
+func OrderReference(tenantID string, o types.Order) string {
+	return fmt.Sprintf("%s/order/%s", tenantID, o.Number)
+}
+
+// BookOrder records the revenue of a paid order for the vendor and the
+// commission the platform takes from it.
+func BookOrder(db *gorm.DB, vendorID uint, plan types.CommissionPlan, tenantID string, o types.Order, at time.Time) error {
+	if o.Total <= 0 {
+		return nil
+	}
+	reference := OrderReference(tenantID, o)
+	if _, _, err := Post(db, Posting{
+		VendorID:    vendorID,
+		Kind:        KindOrderRevenue,
+		Reference:   reference,
+		Currency:    o.Currency,
+		Description: "order " + o.Number,
+		OccurredAt:  at,
+		Lines: []Line{
+			{VendorID: platform, Account: AccountCash, Amount: o.Total},
+			{VendorID: vendorID, Account: AccountVendorPayable, Amount: -o.Total},
+		},
+	}); err != nil {
+		return err
+	}
+
+	commission := Commission(plan, o)
+	if commission == 0 {
+		return nil
+	}
+	_, _, err := Post(db, Posting{
+		VendorID:    vendorID,
+		Kind:        KindCommission,
+		Reference:   reference,
+		Currency:    o.Currency,
+		Description: fmt.Sprintf("commission on order %s (%s)", o.Number, plan.Name),
+		OccurredAt:  at,
+		Lines: []Line{
+			{VendorID: vendorID, Account: AccountVendorPayable, Amount: commission},
+			{VendorID: platform, Account: AccountCommission, Amount: -commission},
+		},
+	})
+	return err
+}
+
+// BookRefund records a refund given on an order of the vendor. The platform
+// hands back the same share of its commission.
+func BookRefund(db *gorm.DB, vendorID uint, tenantID string, o types.Order, refund types.Refund) error {
+	if refund.Amount <= 0 {
+		return nil
+	}
+	reference := fmt.Sprintf("%s/refund/%d", tenantID, refund.ID)
+	if _, _, err := Post(db, Posting{
+		VendorID:    vendorID,
+		Kind:        KindRefund,
+		Reference:   reference,
+		Currency:    refund.Currency,
+		Description: "refund on order " + o.Number,
+		OccurredAt:  refund.CreatedAt,
+		Lines: []Line{
+			{VendorID: vendorID, Account: AccountVendorPayable, Amount: refund.Amount},
+			{VendorID: platform, Account: AccountCash, Amount: -refund.Amount},
+		},
+	}); err != nil {
+		return err
+	}
+
+	commission := types.LedgerTransaction{}
+	err := db.Preload("Entries").Where("kind = ? AND reference = ?", KindCommission, OrderReference(tenantID, o)).First(&commission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || o.Total == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var taken int64
+	for _, entry := range commission.Entries {
+		if entry.Amount > 0 {
+			taken += entry.Amount
+		}
+	}
+	reversal := taken * min(refund.Amount, o.Total) / o.Total
+	if reversal == 0 {
+		return nil
+	}
+	_, _, err = Post(db, Posting{
+		VendorID:    vendorID,
+		Kind:        KindCommissionReversal,
+		Reference:   reference,
+		Currency:    refund.Currency,
+		Description: "commission returned on refund of order " + o.Number,
+		OccurredAt:  refund.CreatedAt,
+		Lines: []Line{
+			{VendorID: platform, Account: AccountCommission, Amount: reversal},
+			{VendorID: vendorID, Account: AccountVendorPayable, Amount: -reversal},
+		},
+	})
+	return err
+}
+
+// BookChargeback records money a customer's bank pulled back on an order of
+// the vendor. Unlike a refund the platform keeps its commission.
+func BookChargeback(db *gorm.DB, vendorID uint, reference, currency string, amount int64, reason string) (types.LedgerTransaction, error) {
+	if amount <= 0 {
+		return types.LedgerTransaction{}, ErrInvalidAmount
+	}
+	transaction, _, err := Post(db, Posting{
+		VendorID:    vendorID,
+		Kind:        KindChargeback,
+		Reference:   reference,
+		Currency:    currency,
+		Description: reason,
+		Lines: []Line{
+			{VendorID: vendorID, Account: AccountVendorPayable, Amount: amount},
+			{VendorID: platform, Account: AccountCash, Amount: -amount},
+		},
+	})
+	return transaction, err
+}
@@ -0,0 +1,147 @@
+package ledger
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Account kinds. Cash is the money the platform collected and holds,
+// commission revenue the platform's own income and vendor payable what the
+// platform owes a vendor.
+const (
+	AccountCash          = "cash"
+	AccountCommission    = "commission_revenue"
+	AccountVendorPayable = "vendor_payable"
+)
+
+// Transaction kinds.
+const (
+	KindOrderRevenue       = "order_revenue"
+	KindCommission         = "commission"
+	KindRefund             = "refund"
+	KindCommissionReversal = "commission_reversal"
+	KindChargeback         = "chargeback"
+	KindPayout             = "payout"
+	KindPayoutReversal     = "payout_reversal"
+)
+
+// platform is the vendor id of the platform's own accounts.
+const platform uint = 0
+
+var (
+	ErrUnbalanced    = errors.New("ledger transaction does not balance")
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+type (
+	// Line moves Amount into an account, debits positive, credits negative.
+	Line struct {
+		VendorID uint
+		Account  string
+		Amount   int64
+	}
+	// Posting is a transaction to book.
+	Posting struct {
+		VendorID    uint
+		Kind        string
+		Reference   string
+		Currency    string
+		Description string
+		OccurredAt  time.Time
+		Lines       []Line
+	}
+)
+
+// Post books a balanced transaction and reports whether it was new. A
+// posting whose kind and reference were booked before is left alone, so
+// callers may retry freely.
+func Post(db *gorm.DB, p Posting) (types.LedgerTransaction, bool, error) {
+	var sum int64
+	var lines []Line
+	for _, line := range p.Lines {
+		if line.Amount != 0 {
+			lines = append(lines, line)
+			sum += line.Amount
+		}
+	}
+	if len(lines) == 0 {
+		return types.LedgerTransaction{}, false, ErrInvalidAmount
+	}
+	if sum != 0 || len(lines) < 2 {
+		return types.LedgerTransaction{}, false, ErrUnbalanced
+	}
+	if p.OccurredAt.IsZero() {
+		p.OccurredAt = time.Now()
+	}
+
+	transaction := types.LedgerTransaction{
+		Kind:        p.Kind,
+		Reference:   p.Reference,
+		Currency:    p.Currency,
+		Description: p.Description,
+		OccurredAt:  p.OccurredAt,
+	}
+	if p.VendorID != platform {
+		transaction.VendorID = &p.VendorID
+	}
+
+	booked := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&transaction)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return tx.Preload("Entries").Where("kind = ? AND reference = ?", p.Kind, p.Reference).First(&transaction).Error
+		}
+
+		for _, line := range lines {
+			account, err := accountFor(tx, line.VendorID, line.Account, p.Currency)
+			if err != nil {
+				return err
+			}
+			transaction.Entries = append(transaction.Entries, types.LedgerEntry{
+				TransactionID: transaction.ID,
+				AccountID:     account.ID,
+				Amount:        line.Amount,
+			})
+		}
+		booked = true
+		return tx.Create(&transaction.Entries).Error
+	})
+	return transaction, booked, err
+}
+
+// Balance returns the sum of the entries of an account, debits positive.
+func Balance(db *gorm.DB, vendorID uint, kind, currency string) (int64, error) {
+	var balance int64
+	err := db.Model(&types.LedgerEntry{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Where("ledger_accounts.vendor_id = ? AND ledger_accounts.kind = ? AND ledger_accounts.currency = ?", vendorID, kind, currency).
+		Select("COALESCE(SUM(ledger_entries.amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// VendorBalance returns what the platform owes the vendor in currency.
+func VendorBalance(db *gorm.DB, vendorID uint, currency string) (int64, error) {
+	balance, err := Balance(db, vendorID, AccountVendorPayable, currency)
+	return -balance, err
+}
+
+// accountFor returns the account, opening it on first use.
+func accountFor(tx *gorm.DB, vendorID uint, kind, currency string) (types.LedgerAccount, error) {
+	account := types.LedgerAccount{VendorID: vendorID, Kind: kind, Currency: currency}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return account, err
+	}
+	if account.ID != 0 {
+		return account, nil
+	}
+	err := tx.Where("vendor_id = ? AND kind = ? AND currency = ?", vendorID, kind, currency).First(&account).Error
+	return account, err
+}
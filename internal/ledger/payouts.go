@@ -0,0 +1,162 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/dotenv"
+	"gorm.io/gorm"
+)
+
+// Payout and batch statuses.
+const (
+	PayoutPending = "pending"
+	PayoutPaid    = "paid"
+	PayoutFailed  = "failed"
+)
+
+// payoutLock serialises payout generation across server instances.
+const payoutLock = 7303
+
+var ErrInvalidPayoutState = errors.New("payout is not in a state that allows this operation")
+
+// PayoutThreshold is the smallest balance paid out, PAYOUT_MINIMUM minor
+// units, 5000 by default.
+func PayoutThreshold() int64 {
+	threshold, err := strconv.ParseInt(dotenv.GetEnvOrDefault("PAYOUT_MINIMUM", "5000"), 10, 64)
+	if err != nil || threshold < 0 {
+		return 5000
+	}
+	return threshold
+}
+
+// GeneratePayouts pays out every vendor balance of at least threshold, one
+// batch per currency. The amounts leave the vendors' balances right away so
+// the next run cannot pay them twice.
+func GeneratePayouts(db *gorm.DB, threshold int64, now time.Time) ([]types.PayoutBatch, error) {
+	batches := []types.PayoutBatch{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", payoutLock).Error; err != nil {
+			return err
+		}
+
+		var balances []struct {
+			VendorID uint
+			Currency string
+			Balance  int64
+		}
+		if err := tx.Model(&types.LedgerEntry{}).
+			Select("ledger_accounts.vendor_id, ledger_accounts.currency, -SUM(ledger_entries.amount) AS balance").
+			Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+			Where("ledger_accounts.kind = ?", AccountVendorPayable).
+			Group("ledger_accounts.vendor_id, ledger_accounts.currency").
+			Having("-SUM(ledger_entries.amount) >= ? AND -SUM(ledger_entries.amount) > 0", threshold).
+			Order("ledger_accounts.currency, ledger_accounts.vendor_id").
+			Scan(&balances).Error; err != nil {
+			return err
+		}
+
+		var batch *types.PayoutBatch
+		for _, balance := range balances {
+			if batch == nil || batch.Currency != balance.Currency {
+				batches = append(batches, types.PayoutBatch{Currency: balance.Currency, Status: PayoutPending, Threshold: threshold})
+				batch = &batches[len(batches)-1]
+				if err := tx.Create(batch).Error; err != nil {
+					return err
+				}
+			}
+
+			payout := types.Payout{
+				BatchID:  batch.ID,
+				VendorID: balance.VendorID,
+				Amount:   balance.Balance,
+				Currency: balance.Currency,
+				Status:   PayoutPending,
+			}
+			if err := tx.Create(&payout).Error; err != nil {
+				return err
+			}
+			if _, _, err := Post(tx, Posting{
+				VendorID:    balance.VendorID,
+				Kind:        KindPayout,
+				Reference:   payoutReference(payout),
+				Currency:    payout.Currency,
+				Description: fmt.Sprintf("payout in batch %d", batch.ID),
+				OccurredAt:  now,
+				Lines: []Line{
+					{VendorID: balance.VendorID, Account: AccountVendorPayable, Amount: payout.Amount},
+					{VendorID: platform, Account: AccountCash, Amount: -payout.Amount},
+				},
+			}); err != nil {
+				return err
+			}
+			batch.Total += payout.Amount
+			batch.Payouts = append(batch.Payouts, payout)
+		}
+
+		for i := range batches {
+			if err := tx.Model(&batches[i]).Update("total", batches[i].Total).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return batches, err
+}
+
+// MarkBatchPaid records that the pending payouts of a batch were sent.
+func MarkBatchPaid(db *gorm.DB, batch *types.PayoutBatch, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&types.PayoutBatch{}).Where("id = ? AND status = ?", batch.ID, PayoutPending).
+			Updates(map[string]interface{}{"status": PayoutPaid, "paid_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidPayoutState
+		}
+		batch.Status, batch.PaidAt = PayoutPaid, &now
+		return tx.Model(&types.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, PayoutPending).
+			Update("status", PayoutPaid).Error
+	})
+}
+
+// FailPayout records that a payout could not be sent and puts the amount
+// back on the vendor's balance for the next batch.
+func FailPayout(db *gorm.DB, payout *types.Payout, note string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&types.Payout{}).Where("id = ? AND status <> ?", payout.ID, PayoutFailed).
+			Updates(map[string]interface{}{"status": PayoutFailed, "note": note})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidPayoutState
+		}
+		payout.Status, payout.Note = PayoutFailed, note
+
+		if err := tx.Model(&types.PayoutBatch{}).Where("id = ?", payout.BatchID).
+			Update("total", gorm.Expr("total - ?", payout.Amount)).Error; err != nil {
+			return err
+		}
+		_, _, err := Post(tx, Posting{
+			VendorID:    payout.VendorID,
+			Kind:        KindPayoutReversal,
+			Reference:   payoutReference(*payout),
+			Currency:    payout.Currency,
+			Description: "payout failed: " + note,
+			Lines: []Line{
+				{VendorID: platform, Account: AccountCash, Amount: payout.Amount},
+				{VendorID: payout.VendorID, Account: AccountVendorPayable, Amount: -payout.Amount},
+			},
+		})
+		return err
+	})
+}
+
+func payoutReference(payout types.Payout) string {
+	return fmt.Sprintf("payout/%d", payout.ID)
+}
@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+type (
+	// StatementLine is a movement on a vendor's balance. Amount is positive
+	// when the platform owes the vendor more.
+	StatementLine struct {
+		TransactionID uint      `json:"transaction_id"`
+		Kind          string    `json:"kind"`
+		Reference     string    `json:"reference"`
+		Description   string    `json:"description"`
+		OccurredAt    time.Time `json:"occurred_at"`
+		Amount        int64     `json:"amount"`
+		Balance       int64     `json:"balance"`
+	}
+	// Statement is the history of a vendor's balance in one currency over a
+	// period, From inclusive and To exclusive.
+	Statement struct {
+		VendorID uint            `json:"vendor_id"`
+		Currency string          `json:"currency"`
+		From     *time.Time      `json:"from,omitempty"`
+		To       *time.Time      `json:"to,omitempty"`
+		Opening  int64           `json:"opening_balance"`
+		Closing  int64           `json:"closing_balance"`
+		Lines    []StatementLine `json:"lines"`
+	}
+	// CurrencyTotals sums the platform's books in one currency. Cash must
+	// equal commission revenue plus what is owed to vendors.
+	CurrencyTotals struct {
+		Currency          string `json:"currency"`
+		Cash              int64  `json:"cash"`
+		CommissionRevenue int64  `json:"commission_revenue"`
+		VendorPayable     int64  `json:"vendor_payable"`
+		PendingPayouts    int64  `json:"pending_payouts"`
+		PaidPayouts       int64  `json:"paid_payouts"`
+		Balanced          bool   `json:"balanced"`
+	}
+	// VendorTotals is what the platform owes a vendor in one currency.
+	VendorTotals struct {
+		VendorID uint   `json:"vendor_id"`
+		Currency string `json:"currency"`
+		Balance  int64  `json:"balance"`
+	}
+	// Reconciliation checks the whole ledger. Unbalanced lists the
+	// transactions whose entries do not add up to zero.
+	Reconciliation struct {
+		Currencies []CurrencyTotals `json:"currencies"`
+		Vendors    []VendorTotals   `json:"vendors"`
+		Unbalanced []uint           `json:"unbalanced_transactions"`
+	}
+)
+
+// VendorStatement lists the movements of a vendor's balance in currency
+// between from and to, either of which may be nil.
+func VendorStatement(db *gorm.DB, vendorID uint, currency string, from, to *time.Time) (Statement, error) {
+	statement := Statement{VendorID: vendorID, Currency: currency, From: from, To: to, Lines: []StatementLine{}}
+
+	payable := db.Model(&types.LedgerEntry{}).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+		Where("ledger_accounts.vendor_id = ? AND ledger_accounts.kind = ? AND ledger_accounts.currency = ?", vendorID, AccountVendorPayable, currency)
+
+	if from != nil {
+		if err := payable.Session(&gorm.Session{}).
+			Where("ledger_transactions.occurred_at < ?", *from).
+			Select("COALESCE(-SUM(ledger_entries.amount), 0)").
+			Scan(&statement.Opening).Error; err != nil {
+			return statement, err
+		}
+	}
+
+	query := payable.Session(&gorm.Session{})
+	if from != nil {
+		query = query.Where("ledger_transactions.occurred_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("ledger_transactions.occurred_at < ?", *to)
+	}
+	if err := query.Select(`ledger_transactions.id AS transaction_id, ledger_transactions.kind, ledger_transactions.reference,
+			ledger_transactions.description, ledger_transactions.occurred_at, -ledger_entries.amount AS amount`).
+		Order("ledger_transactions.occurred_at, ledger_transactions.id").
+		Scan(&statement.Lines).Error; err != nil {
+		return statement, err
+	}
+
+	balance := statement.Opening
+	for i := range statement.Lines {
+		balance += statement.Lines[i].Amount
+		statement.Lines[i].Balance = balance
+	}
+	statement.Closing = balance
+	return statement, nil
+}
+
+// Reconcile sums the ledger per currency and per vendor and looks for
+// transactions that do not balance.
+func Reconcile(db *gorm.DB) (Reconciliation, error) {
+	res := Reconciliation{Currencies: []CurrencyTotals{}, Vendors: []VendorTotals{}, Unbalanced: []uint{}}
+
+	var sums []struct {
+		VendorID uint
+		Kind     string
+		Currency string
+		Total    int64
+	}
+	if err := db.Model(&types.LedgerEntry{}).
+		Select("ledger_accounts.vendor_id, ledger_accounts.kind, ledger_accounts.currency, SUM(ledger_entries.amount) AS total").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Group("ledger_accounts.vendor_id, ledger_accounts.kind, ledger_accounts.currency").
+		Order("ledger_accounts.currency, ledger_accounts.vendor_id").
+		Scan(&sums).Error; err != nil {
+		return res, err
+	}
+
+	totals := map[string]*CurrencyTotals{}
+	totalsFor := func(currency string) *CurrencyTotals {
+		if totals[currency] == nil {
+			res.Currencies = append(res.Currencies, CurrencyTotals{Currency: currency})
+			totals[currency] = &CurrencyTotals{}
+		}
+		return totals[currency]
+	}
+	for _, sum := range sums {
+		t := totalsFor(sum.Currency)
+		switch sum.Kind {
+		case AccountCash:
+			t.Cash += sum.Total
+		case AccountCommission:
+			t.CommissionRevenue -= sum.Total
+		case AccountVendorPayable:
+			t.VendorPayable -= sum.Total
+			res.Vendors = append(res.Vendors, VendorTotals{VendorID: sum.VendorID, Currency: sum.Currency, Balance: -sum.Total})
+		}
+	}
+
+	var payouts []struct {
+		Currency string
+		Status   string
+		Total    int64
+	}
+	if err := db.Model(&types.Payout{}).
+		Select("currency, status, SUM(amount) AS total").
+		Where("status IN ?", []string{PayoutPending, PayoutPaid}).
+		Group("currency, status").
+		Scan(&payouts).Error; err != nil {
+		return res, err
+	}
+	for _, payout := range payouts {
+		t := totalsFor(payout.Currency)
+		if payout.Status == PayoutPaid {
+			t.PaidPayouts += payout.Total
+		} else {
+			t.PendingPayouts += payout.Total
+		}
+	}
+
+	for i := range res.Currencies {
+		t := totals[res.Currencies[i].Currency]
+		t.Currency = res.Currencies[i].Currency
+		t.Balanced = t.Cash == t.CommissionRevenue+t.VendorPayable
+		res.Currencies[i] = *t
+	}
+
+	err := db.Model(&types.LedgerEntry{}).
+		Group("transaction_id").
+		Having("SUM(amount) <> 0").
+		Order("transaction_id").
+		Pluck("transaction_id", &res.Unbalanced).Error
+	return res, err
+}
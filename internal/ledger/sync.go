@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// syncBatchSize bounds how many events are booked per run.
+const syncBatchSize = 500
+
+// Sync books the orders paid and the refunds given in a tenant's store since
+// the last run into the main ledger. Tenants without a vendor are skipped.
+// Progress is saved after every booking, and bookings are idempotent, so an
+// interrupted run simply continues.
+func Sync(main *gorm.DB, tenantID string, tenant *gorm.DB) error {
+	vendor := types.Vendor{}
+	if err := main.Where("tenant_id = ?", tenantID).First(&vendor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	plan, err := PlanFor(main, vendor)
+	if err != nil {
+		return err
+	}
+
+	cursor := types.LedgerCursor{VendorID: vendor.ID}
+	if err := main.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error; err != nil {
+		return err
+	}
+	if err := main.Where("vendor_id = ?", vendor.ID).First(&cursor).Error; err != nil {
+		return err
+	}
+
+	var events []types.OrderEvent
+	if err := tenant.Where("id > ? AND to_status = ?", cursor.LastOrderEventID, order.StatusPaid).
+		Order("id").Limit(syncBatchSize).Find(&events).Error; err != nil {
+		return err
+	}
+	for _, event := range events {
+		o := types.Order{}
+		if err := tenant.Unscoped().First(&o, event.OrderID).Error; err != nil {
+			return err
+		}
+		if err := BookOrder(main, vendor.ID, plan, tenantID, o, event.CreatedAt); err != nil {
+			return err
+		}
+		if err := main.Model(&cursor).Update("last_order_event_id", event.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	var refunds []types.Refund
+	if err := tenant.Where("id > ? AND status IN ?", cursor.LastRefundID, []string{order.RefundSucceeded, order.RefundPartial}).
+		Order("id").Limit(syncBatchSize).Find(&refunds).Error; err != nil {
+		return err
+	}
+	for _, refund := range refunds {
+		o := types.Order{}
+		if err := tenant.Unscoped().First(&o, refund.OrderID).Error; err != nil {
+			return err
+		}
+		// the order itself must be booked first to return its commission
+		var booked int64
+		if err := main.Model(&types.LedgerTransaction{}).
+			Where("kind = ? AND reference = ?", KindOrderRevenue, OrderReference(tenantID, o)).
+			Count(&booked).Error; err != nil {
+			return err
+		}
+		if booked == 0 && o.Total > 0 {
+			break
+		}
+		if err := BookRefund(main, vendor.ID, tenantID, o, refund); err != nil {
+			return err
+		}
+		if err := main.Model(&cursor).Update("last_refund_id", refund.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
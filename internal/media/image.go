@@ -0,0 +1,224 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Size is a rendition generated for every uploaded image. Crop fills the
+// exact box and cuts off the excess; otherwise the image is scaled down to
+// Width keeping its ratio, and sizes wider than the image are skipped.
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+	Crop   bool
+}
+
+// Sizes are the renditions of every image, a square thumbnail and the
+// responsive widths.
+var Sizes = []Size{
+	{Name: "thumbnail", Width: 150, Height: 150, Crop: true},
+	{Name: "small", Width: 320},
+	{Name: "medium", Width: 640},
+	{Name: "large", Width: 1280},
+}
+
+const (
+	// MaxDimension bounds the stored original, larger images are scaled down.
+	MaxDimension = 2560
+	// maxPixels refuses images that would take too much memory to decode.
+	maxPixels = 50_000_000
+	// jpegQuality is used for every JPEG written.
+	jpegQuality = 85
+)
+
+// rendition is one encoded image ready to be stored.
+type rendition struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// processImage decodes an image, turns it upright according to its EXIF
+// orientation and re-encodes it together with every Size. Re-encoding drops
+// all metadata, EXIF location data included. PNG and GIF images become PNG
+// to keep their transparency, everything else becomes JPEG.
+func processImage(data []byte) (rendition, []rendition, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return rendition{}, nil, ErrNotImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return rendition{}, nil, ErrImageTooLarge
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return rendition{}, nil, ErrNotImage
+	}
+
+	img := toRGBA(decoded)
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	asPNG := format == "png" || format == "gif"
+
+	original, err := encode("original", fit(img, MaxDimension, MaxDimension), asPNG)
+	if err != nil {
+		return rendition{}, nil, err
+	}
+	sizes := []rendition{}
+	for _, size := range Sizes {
+		var scaled *image.RGBA
+		switch {
+		case size.Crop:
+			scaled = cover(img, size.Width, size.Height)
+		case img.Rect.Dx() > size.Width:
+			scaled = fit(img, size.Width, 0)
+		default:
+			continue
+		}
+		r, err := encode(size.Name, scaled, asPNG)
+		if err != nil {
+			return rendition{}, nil, err
+		}
+		sizes = append(sizes, r)
+	}
+	return original, sizes, nil
+}
+
+func encode(name string, img *image.RGBA, asPNG bool) (rendition, error) {
+	r := rendition{Name: name, Width: img.Rect.Dx(), Height: img.Rect.Dy()}
+	var buf bytes.Buffer
+	if asPNG {
+		r.ContentType, r.Extension = "image/png", ".png"
+		if err := png.Encode(&buf, img); err != nil {
+			return rendition{}, err
+		}
+	} else {
+		r.ContentType, r.Extension = "image/jpeg", ".jpg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return rendition{}, err
+		}
+	}
+	r.Data = buf.Bytes()
+	return r, nil
+}
+
+// toRGBA copies img into an RGBA image anchored at the origin.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
+
+// fit scales src down to fit within maxWidth by maxHeight, a zero bound
+// being unlimited. Images already small enough are returned as is.
+func fit(src *image.RGBA, maxWidth, maxHeight int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if maxWidth > 0 && w > maxWidth {
+		h = h * maxWidth / w
+		w = maxWidth
+	}
+	if maxHeight > 0 && h > maxHeight {
+		w = w * maxHeight / h
+		h = maxHeight
+	}
+	if w == src.Rect.Dx() && h == src.Rect.Dy() {
+		return src
+	}
+	return resize(src, max(w, 1), max(h, 1))
+}
+
+// cover crops the centre of src to the ratio of width by height and scales
+// the result to exactly that size.
+func cover(src *image.RGBA, width, height int) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	cropW, cropH := w, w*height/width
+	if cropH > h {
+		cropW, cropH = h*width/height, h
+	}
+	x0 := src.Rect.Min.X + (w-cropW)/2
+	y0 := src.Rect.Min.Y + (h-cropH)/2
+	cropped := src.SubImage(image.Rect(x0, y0, x0+cropW, y0+cropH)).(*image.RGBA)
+	return resize(cropped, width, height)
+}
+
+// resize scales src to w by h, averaging the source pixels that fall into
+// each destination pixel so downscaled photos stay free of aliasing.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	origin := src.Rect.Min
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					p := src.Pix[src.PixOffset(origin.X+sx, origin.Y+sy):]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[dst.PixOffset(x, y):]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (2 to 8) so the image displays upright
+// once its metadata is gone.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := sw, sh
+	if orientation >= 5 {
+		dw, dh = sh, sw
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = sw-1-x, y
+			case 3: // upside down
+				sx, sy = sw-1-x, sh-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, sh-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // needs a clockwise quarter turn
+				sx, sy = y, sh-1-x
+			case 7: // transversed
+				sx, sy = sw-1-y, sh-1-x
+			case 8: // needs a counter-clockwise quarter turn
+				sx, sy = sw-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy):])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,227 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/storage"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxImageSize bounds an uploaded image.
+	MaxImageSize = 10 << 20
+	// MaxFileSize bounds any other uploaded file.
+	MaxFileSize = 100 << 20
+	// MaxURLExpiry bounds how long a signed URL stays valid.
+	MaxURLExpiry = 7 * 24 * time.Hour
+)
+
+var (
+	ErrTooLarge        = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("file type is not allowed")
+	ErrNotImage        = errors.New("file is not a supported image")
+	ErrImageTooLarge   = errors.New("image dimensions are too large")
+	ErrInUse           = errors.New("file is delivered as a digital asset")
+)
+
+// ImageTypes are the image formats that are decoded and resized.
+var ImageTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// FileTypes are the other formats that may be uploaded, stored as is.
+var FileTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"application/epub+zip",
+	"text/plain",
+	"text/csv",
+	"audio/mpeg",
+	"video/mp4",
+}
+
+// UploadInput describes an uploaded file. The content type is always
+// sniffed from Data, never taken from the client.
+type UploadInput struct {
+	TenantID  string
+	Filename  string
+	Data      []byte
+	Private   bool
+	ImageOnly bool
+}
+
+// Upload checks, processes and stores a file and records it. Images are
+// stored re-encoded without metadata together with their Sizes. Keys are
+// random and start with the tenant id and the visibility.
+func Upload(ctx context.Context, db *gorm.DB, store storage.Storage, in UploadInput) (*types.MediaFile, error) {
+	detected := mimetype.Detect(in.Data)
+	contentType, _, _ := strings.Cut(detected.String(), ";")
+	isImage := mimetype.EqualsAny(contentType, ImageTypes...)
+	switch {
+	case in.ImageOnly && !isImage:
+		return nil, ErrNotImage
+	case !isImage && !mimetype.EqualsAny(contentType, FileTypes...):
+		return nil, ErrUnsupportedType
+	case isImage && len(in.Data) > MaxImageSize, len(in.Data) > MaxFileSize:
+		return nil, ErrTooLarge
+	}
+
+	visibility := "public"
+	if in.Private {
+		visibility = "private"
+	}
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%s/%s/%s", in.TenantID, visibility, hex.EncodeToString(name))
+
+	file := &types.MediaFile{
+		Key:         base + detected.Extension(),
+		Filename:    in.Filename,
+		ContentType: contentType,
+		Size:        int64(len(in.Data)),
+		Private:     in.Private,
+	}
+	type object struct {
+		key         string
+		data        []byte
+		contentType string
+	}
+	objects := []object{}
+	if isImage {
+		original, sizes, err := processImage(in.Data)
+		if err != nil {
+			return nil, err
+		}
+		file.Key = base + original.Extension
+		file.ContentType = original.ContentType
+		file.Size = int64(len(original.Data))
+		file.Width, file.Height = original.Width, original.Height
+		objects = append(objects, object{file.Key, original.Data, original.ContentType})
+		for _, size := range sizes {
+			variant := types.MediaVariant{
+				Name:        size.Name,
+				Key:         base + "-" + size.Name + size.Extension,
+				ContentType: size.ContentType,
+				Size:        int64(len(size.Data)),
+				Width:       size.Width,
+				Height:      size.Height,
+			}
+			objects = append(objects, object{variant.Key, size.Data, size.ContentType})
+			file.Variants = append(file.Variants, variant)
+		}
+	} else {
+		objects = append(objects, object{file.Key, in.Data, contentType})
+	}
+
+	if !in.Private {
+		file.URL = store.PublicURL(file.Key)
+		for i := range file.Variants {
+			file.Variants[i].URL = store.PublicURL(file.Variants[i].Key)
+		}
+	}
+
+	// store the objects, cleaning up what was written when anything fails
+	stored := []string{}
+	cleanup := func() {
+		for _, key := range stored {
+			store.Delete(context.Background(), key)
+		}
+	}
+	for _, o := range objects {
+		if err := store.Put(ctx, o.key, bytes.NewReader(o.data), int64(len(o.data)), o.contentType); err != nil {
+			cleanup()
+			return nil, err
+		}
+		stored = append(stored, o.key)
+	}
+	if err := db.Create(file).Error; err != nil {
+		cleanup()
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete removes a file, its renditions and the product and review images
+// showing it.
+// Files sold as digital assets are kept until the asset is removed, so
+// customers who bought them can still download them. The records go first: an object left behind by a failing store is only
+// wasted space, while a record without its object would be a broken link.
+func Delete(ctx context.Context, db *gorm.DB, store storage.Storage, file types.MediaFile) error {
+	var assets int64
+	if err := db.Model(&types.DigitalAsset{}).Where("media_id = ?", file.ID).Count(&assets).Error; err != nil {
+		return err
+	}
+	if assets > 0 {
+		return ErrInUse
+	}
+
+	var variants []types.MediaVariant
+	if err := db.Where("media_id = ?", file.ID).Find(&variants).Error; err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", file.ID).Delete(&types.ProductImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("media_id = ?", file.ID).Delete(&types.ReviewImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("media_id = ?", file.ID).Delete(&types.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.MediaFile{}, file.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := store.Delete(ctx, file.Key); err != nil {
+		return err
+	}
+	for _, variant := range variants {
+		if err := store.Delete(ctx, variant.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SignedURLs returns URLs to a file and each of its renditions, keyed by
+// rendition name with the file itself under "original", valid until
+// expires.
+func SignedURLs(store storage.Storage, file types.MediaFile, expires time.Time) (map[string]string, error) {
+	urls := map[string]string{}
+	url, err := store.SignedURL(file.Key, expires)
+	if err != nil {
+		return nil, err
+	}
+	urls["original"] = url
+	for _, variant := range file.Variants {
+		if urls[variant.Name], err = store.SignedURL(variant.Key, expires); err != nil {
+			return nil, err
+		}
+	}
+	return urls, nil
+}
+
+// UsedBytes is the storage taken by the files of a tenant, renditions
+// included.
+func UsedBytes(db *gorm.DB) (int64, error) {
+	var files, variants int64
+	if err := db.Model(&types.MediaFile{}).Select("COALESCE(SUM(size), 0)").Scan(&files).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&types.MediaVariant{}).Select("COALESCE(SUM(size), 0)").Scan(&variants).Error; err != nil {
+		return 0, err
+	}
+	return files + variants, nil
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/Satishcg12/multicommers/internal/clock"
+	"github.com/labstack/echo/v4"
+)
+
+// ClockMiddleware makes the clock scheduling work available to handlers
+// under "clock".
+func ClockMiddleware(clk clock.Clock) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("clock", clk)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/session"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CustomerAuthMiddleware signs the customer of a request in from the bearer
+// token in the Authorization header and stores their id under "user_id".
+// Requests without a token carry on as guests; a token that is not a live
+// session of the tenant is refused with 401. It runs after
+// TenantDBMiddleware.
+func CustomerAuthMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := BearerToken(c)
+			if !ok {
+				return next(c)
+			}
+
+			db := c.Get("db").(*gorm.DB)
+
+			current, err := session.Lookup(db, token, time.Now())
+			if errors.Is(err, session.ErrInvalidSession) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error checking session"})
+			}
+
+			c.Set("user_id", current.UserID)
+			return next(c)
+		}
+	}
+}
+
+// BearerToken returns the token of an "Authorization: Bearer <token>"
+// header.
+func BearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
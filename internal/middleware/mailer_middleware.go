@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/Satishcg12/multicommers/utils/email"
+	"github.com/labstack/echo/v4"
+)
+
+// MailerMiddleware makes the mail daemon available to handlers under
+// "mailer".
+func MailerMiddleware(mailer email.EmailDaemonInterface) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("mailer", mailer)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// QuotaMiddleware refuses with 402 Payment Required the requests creating one
+// more of resource than the tenant's plan allows. It runs after
+// TenantDBMiddleware.
+func QuotaMiddleware(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			main := c.Get("main_db").(*gorm.DB)
+			db := c.Get("db").(*gorm.DB)
+			tenantID, _ := c.Get("tenant").(string)
+
+			err := billing.Check(main, db, tenantID, resource, time.Now())
+			var limitErr *billing.LimitError
+			if errors.As(err, &limitErr) {
+				return c.JSON(http.StatusPaymentRequired, map[string]interface{}{
+					"error":    "plan limit reached",
+					"resource": limitErr.Resource,
+					"limit":    limitErr.Limit,
+					"plan":     limitErr.Plan,
+				})
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error checking plan limits"})
+			}
+			return next(c)
+		}
+	}
+}
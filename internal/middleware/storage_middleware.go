@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/Satishcg12/multicommers/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// StorageMiddleware makes the file storage available to handlers under
+// "storage".
+func StorageMiddleware(store storage.Storage) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("storage", store)
+			return next(c)
+		}
+	}
+}
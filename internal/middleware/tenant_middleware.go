@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -9,11 +10,18 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// TenantDBMiddleware picks the database of a request. The tenant is named by
+// the :tenant path parameter, the X-Tenant-ID header or the subdomain; the
+// platform's own name, DB_NAME, gets the main database. Any other name must
+// be the tenant of a vendor, unknown tenants are answered with 404.
 func TenantDBMiddleware(dbManager *database.DatabaseManager) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// Extract the tenant ID (e.g., from subdomain or header)
-			subdomain := c.Request().Header.Get("X-Tenant-ID")
+			// Extract the tenant ID (e.g., from path, header or subdomain)
+			subdomain := c.Param("tenant")
+			if subdomain == "" {
+				subdomain = c.Request().Header.Get("X-Tenant-ID")
+			}
 
 			if subdomain == "" {
 				subdomain = strings.Split(c.Request().Host, ".")[0]
@@ -23,13 +31,22 @@ func TenantDBMiddleware(dbManager *database.DatabaseManager) echo.MiddlewareFunc
 			}
 
 			// Get the tenant database
-			db, err := dbManager.GetDB(subdomain)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, err.Error())
+			db := dbManager.MainDB()
+			if subdomain != dotenv.GetEnvOrDefault("DB_NAME", "multicommers") {
+				var err error
+				db, err = dbManager.GetDB(subdomain)
+				if errors.Is(err, database.ErrUnknownTenant) {
+					return c.JSON(http.StatusNotFound, map[string]string{"error": "store not found"})
+				}
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error opening store database"})
+				}
 			}
 
 			// Set the database connection on the context
 			c.Set("db", db)
+			c.Set("tenant", subdomain)
+			c.Set("main_db", dbManager.MainDB())
 
 			// Continue processing the request
 			return next(c)
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/session"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// VendorTokenHeader carries the session token of a vendor signed in to the
+// admin. It is kept apart from the Authorization header, which signs
+// customers in.
+const VendorTokenHeader = "X-Vendor-Token"
+
+// VendorAuthMiddleware lets only the vendor owning the tenant of the request
+// through and stores their id under "vendor_id". A missing or expired
+// session is refused with 401, a session of another vendor with 403. It runs
+// after TenantDBMiddleware.
+func VendorAuthMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			main := c.Get("main_db").(*gorm.DB)
+			tenantID, _ := c.Get("tenant").(string)
+
+			current, err := session.LookupVendor(main, c.Request().Header.Get(VendorTokenHeader), time.Now())
+			if errors.Is(err, session.ErrInvalidSession) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "vendor authentication required"})
+			}
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "error checking session"})
+			}
+			if current.Vendor.TenantID == "" || current.Vendor.TenantID != tenantID {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "not allowed to manage this store"})
+			}
+
+			c.Set("vendor_id", current.VendorID)
+			return next(c)
+		}
+	}
+}
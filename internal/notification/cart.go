@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/utils/email"
+)
+
+// CartReminderLinks are the tracked links of a cart reminder. Code is the
+// discount code it offers, if any.
+type CartReminderLinks struct {
+	Cart  string
+	Pixel string
+	Code  string
+}
+
+// CartReminder reminds a customer of the cart they left, with a link that
+// restores it.
+func CartReminder(to string, summary cart.Summary, links CartReminderLinks, storeName string) email.EmailMessage {
+	subject := "You left something in your cart"
+	if storeName != "" {
+		subject += " at " + storeName
+	}
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	body.WriteString("<p>Your cart is still waiting for you:</p><ul>")
+	for _, line := range summary.Lines {
+		fmt.Fprintf(&body, "<li>%d x %s</li>", line.Quantity, html.EscapeString(line.Title))
+	}
+	body.WriteString("</ul>")
+	fmt.Fprintf(&body, "<p>Total: %s</p>", html.EscapeString(formatAmount(summary.Total, summary.Currency)))
+	if links.Code != "" {
+		fmt.Fprintf(&body, "<p>Use the code <strong>%s</strong> for a discount on this order.</p>", html.EscapeString(links.Code))
+	}
+	fmt.Fprintf(&body, `<p><a href="%s">Return to your cart</a></p>`, html.EscapeString(links.Cart))
+	fmt.Fprintf(&body, `<img src="%s" width="1" height="1" alt="">`, html.EscapeString(links.Pixel))
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      to,
+		Subject: subject,
+		Body:    body.String(),
+	}
+}
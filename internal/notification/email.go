@@ -0,0 +1,90 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/dotenv"
+	"github.com/Satishcg12/multicommers/utils/email"
+)
+
+// From is the sender address of customer emails, MAIL_FROM or else the SMTP
+// account.
+func From() string {
+	return dotenv.GetEnvOrDefault("MAIL_FROM", dotenv.GetEnvOrDefault("SMTP_USERNAME", ""))
+}
+
+var shipmentSubjects = map[string]string{
+	"label_created": "Your order %s is being prepared",
+	"in_transit":    "Your order %s is on its way",
+	"delivered":     "Your order %s was delivered",
+}
+
+// ShipmentUpdate tells the customer of o where shipment is.
+func ShipmentUpdate(o types.Order, shipment types.Shipment) email.EmailMessage {
+	subject, ok := shipmentSubjects[shipment.Status]
+	if !ok {
+		subject = "Update on your order %s"
+	}
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	fmt.Fprintf(&body, "<p>%s.</p>", html.EscapeString(fmt.Sprintf(subject, o.Number)))
+	if shipment.Carrier != "" {
+		fmt.Fprintf(&body, "<p>Carrier: %s</p>", html.EscapeString(shipment.Carrier))
+	}
+	if shipment.TrackingNumber != "" {
+		fmt.Fprintf(&body, "<p>Tracking number: %s</p>", html.EscapeString(shipment.TrackingNumber))
+	}
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      o.Email,
+		Subject: fmt.Sprintf(subject, o.Number),
+		Body:    body.String(),
+	}
+}
+
+// GiftCardIssued sends the code of a new gift card to its recipient.
+func GiftCardIssued(card types.GiftCard, code, storeName string) email.EmailMessage {
+	subject := "You received a gift card"
+	if storeName != "" {
+		subject += " for " + storeName
+	}
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	fmt.Fprintf(&body, "<p>%s.</p>", html.EscapeString(subject))
+	fmt.Fprintf(&body, "<p>Code: <strong>%s</strong></p>", html.EscapeString(code))
+	fmt.Fprintf(&body, "<p>Value: %s</p>", html.EscapeString(formatAmount(card.InitialValue, card.Currency)))
+	if card.ExpiresAt != nil {
+		fmt.Fprintf(&body, "<p>Valid until %s.</p>", card.ExpiresAt.Format("January 2, 2006"))
+	}
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      card.RecipientEmail,
+		Subject: subject,
+		Body:    body.String(),
+	}
+}
+
+// formatAmount writes amount minor units of code as a decimal, e.g. 12.50 USD.
+func formatAmount(amount int64, code string) string {
+	decimals := currency.Decimals(code)
+	if decimals == 0 {
+		return fmt.Sprintf("%d %s", amount, code)
+	}
+	scale := int64(1)
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/scale, decimals, amount%scale, code)
+}
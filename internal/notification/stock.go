@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+)
+
+// BackInStockItem is a variant a customer asked to hear about, with its
+// product.
+type BackInStockItem struct {
+	Product types.Product
+	Variant types.ProductVariant
+}
+
+// BackInStock tells a customer the variants they subscribed to are
+// available again.
+func BackInStock(to string, items []BackInStockItem, storeName string) email.EmailMessage {
+	subject := "Back in stock"
+	if len(items) == 1 {
+		subject = items[0].Product.Title + " is back in stock"
+	}
+	if storeName != "" {
+		subject += " at " + storeName
+	}
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	body.WriteString("<p>Good news, items you asked about are available again:</p><ul>")
+	for _, item := range items {
+		name := item.Product.Title
+		if item.Variant.Title != "" {
+			name += " - " + item.Variant.Title
+		}
+		fmt.Fprintf(&body, "<li>%s</li>", html.EscapeString(name))
+	}
+	body.WriteString("</ul><p>Stock is limited, so order soon.</p>")
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      to,
+		Subject: subject,
+		Body:    body.String(),
+	}
+}
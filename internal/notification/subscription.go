@@ -0,0 +1,49 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+)
+
+// SubscriptionPaymentFailed tells the customer their renewal failed and
+// when it is tried again.
+func SubscriptionPaymentFailed(sub types.CustomerSubscription, reason string) email.EmailMessage {
+	subject := fmt.Sprintf("We could not renew your %s subscription", sub.Plan.Name)
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	fmt.Fprintf(&body, "<p>%s: %s.</p>", html.EscapeString(subject), html.EscapeString(reason))
+	if sub.NextRetryAt != nil {
+		fmt.Fprintf(&body, "<p>We will try again on %s. Please check your payment method before then.</p>",
+			sub.NextRetryAt.Format("January 2, 2006"))
+	}
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      sub.Email,
+		Subject: subject,
+		Body:    body.String(),
+	}
+}
+
+// SubscriptionCancelled tells the customer their subscription stopped after
+// the last failed renewal.
+func SubscriptionCancelled(sub types.CustomerSubscription, reason string) email.EmailMessage {
+	subject := fmt.Sprintf("Your %s subscription was cancelled", sub.Plan.Name)
+
+	var body strings.Builder
+	body.WriteString("<p>Hello,</p>")
+	fmt.Fprintf(&body, "<p>We could not renew your subscription after several attempts: %s.</p>", html.EscapeString(reason))
+	body.WriteString("<p>No further orders will be placed. You can subscribe again at any time.</p>")
+
+	return email.EmailMessage{
+		From:    From(),
+		To:      sub.Email,
+		Subject: subject,
+		Body:    body.String(),
+	}
+}
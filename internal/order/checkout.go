@@ -0,0 +1,337 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/address"
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationTTL is how long stock is held for an order awaiting payment.
+// Unpaid orders are cancelled once it has passed.
+const ReservationTTL = 30 * time.Minute
+
+// Address kinds.
+const (
+	AddressBilling  = "billing"
+	AddressShipping = "shipping"
+)
+
+var (
+	ErrEmptyCart          = errors.New("cart is empty")
+	ErrCartNotPurchasable = errors.New("cart has items that cannot be purchased")
+	ErrMissingAddress     = errors.New("billing and shipping addresses are required")
+	ErrMissingEmail       = errors.New("email is required")
+	ErrCodeNotApplicable  = errors.New("discount code cannot be applied")
+)
+
+// CheckoutInput is what checkout needs besides the cart. Addresses left nil
+// are taken from the customer's address book. The shipping method must be
+// one offered for the shipping address. DiscountRate basis points are taken
+// off every line on top of promotions and recorded as DiscountDescription,
+// for subscription renewals.
+type CheckoutInput struct {
+	Cart                types.Cart
+	Email               string
+	BillingAddress      *types.OrderAddress
+	ShippingAddress     *types.OrderAddress
+	ShippingMethodID    *uint
+	DiscountRate        int64
+	DiscountDescription string
+}
+
+// Checkout turns a cart into an order awaiting payment. Lines, prices and
+// addresses are copied onto the order, tax is calculated for the shipping
+// address, stock is reserved for every line and the cart is closed, all in a
+// single transaction.
+func Checkout(ctx context.Context, db *gorm.DB, in CheckoutInput) (types.Order, error) {
+	o := types.Order{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// lock the cart so it cannot be checked out twice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", in.Cart.ID, cart.StatusOpen).
+			First(&types.Cart{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return cart.ErrCartNotFound
+			}
+			return err
+		}
+
+		summary, err := cart.Calculate(tx, in.Cart)
+		if err != nil {
+			return err
+		}
+		if len(summary.Lines) == 0 {
+			return ErrEmptyCart
+		}
+		for _, line := range summary.Lines {
+			if !line.Purchasable {
+				return ErrCartNotPurchasable
+			}
+		}
+
+		email := in.Email
+		billing, shipping := in.BillingAddress, in.ShippingAddress
+		if in.Cart.UserID != nil {
+			user := types.User{}
+			if err := tx.First(&user, *in.Cart.UserID).Error; err != nil {
+				return err
+			}
+			if email == "" {
+				email = user.Email
+			}
+			if billing == nil {
+				billing, err = addressBookEntry(tx, user, address.KindBilling)
+				if err != nil {
+					return err
+				}
+			}
+			if shipping == nil {
+				shipping, err = addressBookEntry(tx, user, address.KindShipping)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if email == "" {
+			return ErrMissingEmail
+		}
+		if billing == nil || shipping == nil {
+			return ErrMissingAddress
+		}
+
+		// the currency may have been switched off since the cart was filled
+		enabled, err := currency.Enabled(tx, summary.Currency)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return currency.ErrCurrencyNotEnabled
+		}
+
+		// price promotions again now that the customer is known, a code
+		// dropping out must not silently raise the price
+		if err := cart.ApplyPromotions(tx, &summary, in.Cart, email); err != nil {
+			return err
+		}
+		if len(summary.Rejected) > 0 {
+			return fmt.Errorf("%w: %s %s", ErrCodeNotApplicable, summary.Rejected[0].Code, summary.Rejected[0].Reason)
+		}
+		rateDiscount := applyDiscountRate(&summary, in.DiscountRate)
+
+		o = types.Order{
+			Number:        strings.ToUpper(randomString.GenerateSecureToken(5)),
+			UserID:        in.Cart.UserID,
+			CartID:        &in.Cart.ID,
+			Email:         email,
+			Status:        StatusPendingPayment,
+			Currency:      summary.Currency,
+			Subtotal:      summary.Subtotal,
+			DiscountTotal: summary.DiscountTotal,
+		}
+		if err := applyShipping(ctx, tx, &o, summary, *shipping, in.ShippingMethodID); err != nil {
+			return err
+		}
+		taxes, err := calculateTax(ctx, tx, &o, summary.Lines, *shipping)
+		if err != nil {
+			return err
+		}
+		o.Total = o.Subtotal - o.DiscountTotal + o.ShippingTotal
+		if !o.TaxIncluded {
+			o.Total += o.TaxTotal
+		}
+		if err := applyBaseTotal(tx, &o); err != nil {
+			return err
+		}
+		if err := tx.Create(&o).Error; err != nil {
+			return err
+		}
+
+		for i, line := range summary.Lines {
+			o.Lines = append(o.Lines, types.OrderLine{
+				OrderID:   o.ID,
+				ProductID: line.ProductID,
+				VariantID: line.VariantID,
+				SKU:       line.SKU,
+				Title:     line.Title,
+				Quantity:  line.Quantity,
+				UnitPrice: line.UnitPrice,
+				Discount:  line.Discount,
+				TaxAmount: taxes[i],
+				LineTotal: line.LineTotal,
+			})
+		}
+		if err := tx.Create(&o.Lines).Error; err != nil {
+			return err
+		}
+
+		if len(summary.Discounts) > 0 {
+			for _, applied := range summary.Discounts {
+				o.Discounts = append(o.Discounts, types.OrderDiscount{
+					OrderID:     o.ID,
+					PromotionID: applied.PromotionID,
+					Code:        applied.Code,
+					Description: applied.Description,
+					Amount:      applied.Amount,
+				})
+			}
+			if err := tx.Create(&o.Discounts).Error; err != nil {
+				return err
+			}
+			if err := promotion.Redeem(tx, summary.Discounts, o); err != nil {
+				return err
+			}
+		}
+		if rateDiscount > 0 {
+			discount := types.OrderDiscount{OrderID: o.ID, Description: in.DiscountDescription, Amount: rateDiscount}
+			if err := tx.Create(&discount).Error; err != nil {
+				return err
+			}
+			o.Discounts = append(o.Discounts, discount)
+		}
+
+		billing.ID, billing.OrderID, billing.Kind = 0, o.ID, AddressBilling
+		shipping.ID, shipping.OrderID, shipping.Kind = 0, o.ID, AddressShipping
+		o.Addresses = []types.OrderAddress{*billing, *shipping}
+		if err := tx.Create(&o.Addresses).Error; err != nil {
+			return err
+		}
+
+		for _, line := range o.Lines {
+			if _, err := inventory.Reserve(tx, line.VariantID, line.Quantity, Reference(o), ReservationTTL); err != nil {
+				return err
+			}
+		}
+
+		event := types.OrderEvent{OrderID: o.ID, ToStatus: StatusPendingPayment, Note: "order placed"}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		o.Events = []types.OrderEvent{event}
+
+		if err := publish(tx, hook.OrderCreated, o.ID); err != nil {
+			return err
+		}
+		return tx.Model(&in.Cart).Update("status", cart.StatusConverted).Error
+	})
+	if err != nil {
+		return types.Order{}, err
+	}
+	return o, nil
+}
+
+// applyDiscountRate takes rate basis points off what is left to pay of
+// every line and returns the amount taken off.
+func applyDiscountRate(summary *cart.Summary, rate int64) int64 {
+	if rate <= 0 {
+		return 0
+	}
+	var total int64
+	for i, line := range summary.Lines {
+		discount := (line.LineTotal - line.Discount) * rate / 10000
+		summary.Lines[i].Discount += discount
+		total += discount
+	}
+	summary.DiscountTotal += total
+	summary.Total -= total
+	return total
+}
+
+// applyBaseTotal records the total of the order in the base currency and
+// the exchange rate it was converted at.
+func applyBaseTotal(tx *gorm.DB, o *types.Order) error {
+	conv, err := currency.For(tx, o.Currency, "")
+	if err != nil {
+		return err
+	}
+	o.BaseCurrency = conv.To
+	o.ExchangeRate = conv.Rate()
+	o.BaseTotal = conv.Convert(o.Total)
+	return nil
+}
+
+// addressBookEntry copies the customer's primary address of kind (billing
+// or shipping).
+func addressBookEntry(tx *gorm.DB, user types.User, kind string) (*types.OrderAddress, error) {
+	entry, err := address.Primary(tx, user.ID, kind)
+	if entry == nil || err != nil {
+		return nil, err
+	}
+	fullName := entry.FullName
+	if fullName == "" {
+		fullName = user.FullName
+	}
+	return &types.OrderAddress{
+		FullName:     fullName,
+		Phone:        entry.Phone,
+		StreetNumber: entry.StreetNumber,
+		Directional:  entry.Directional,
+		Street:       entry.Street,
+		Suffix:       entry.Suffix,
+		UnitType:     entry.UnitType,
+		UnitNumber:   entry.UnitNumber,
+		City:         entry.City,
+		Region:       entry.Region,
+		ZipCode:      entry.ZipCode,
+		CountryCode:  entry.CountryCode,
+		Formatted:    entry.Formatted,
+	}, nil
+}
+
+// CancelUnpaid cancels orders that are still awaiting payment after the
+// reservation window and returns how many were cancelled. Orders with a
+// payment still pending, authorized or captured are left for its outcome:
+// cancelling them would charge the customer for an order they do not get.
+func CancelUnpaid(db *gorm.DB, now time.Time) (int, error) {
+	var orders []types.Order
+	if err := db.Where("status = ? AND created_at <= ?", StatusPendingPayment, now.Add(-ReservationTTL)).Find(&orders).Error; err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for i := range orders {
+		err := cancelPending(db, &orders[i], "payment not received in time")
+		if errors.Is(err, ErrStaleOrder) || errors.Is(err, ErrPaymentInProgress) {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
+}
+
+// cancelPending cancels an order awaiting payment unless a payment of it is
+// in progress, in which case ErrPaymentInProgress is returned. The order is
+// locked so no payment can start while it is cancelled.
+func cancelPending(db *gorm.DB, o *types.Order, note string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(o, o.ID).Error; err != nil {
+			return err
+		}
+		if o.Status != StatusPendingPayment {
+			return ErrStaleOrder
+		}
+		busy, err := paymentInProgress(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		if busy {
+			return ErrPaymentInProgress
+		}
+		return Transition(tx, o, StatusCancelled, note)
+	})
+}
@@ -0,0 +1,21 @@
+package order
+
+import (
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/digital"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// applyDigital hands out the downloads and license keys of an order once it
+// is paid and withdraws them when it is cancelled or refunded.
+func applyDigital(tx *gorm.DB, o types.Order, from, to string) error {
+	switch {
+	case from == StatusPendingPayment && to == StatusPaid:
+		return digital.Issue(tx, o, time.Now())
+	case to == StatusCancelled, to == StatusRefunded:
+		return digital.Revoke(tx, o.ID, time.Now())
+	}
+	return nil
+}
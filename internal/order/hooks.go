@@ -0,0 +1,28 @@
+package order
+
+import (
+	"github.com/Satishcg12/multicommers/internal/hook"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// statusEvent returns the webhook event of an order moving to status, e.g.
+// order.paid.
+func statusEvent(status string) string {
+	return "order." + status
+}
+
+// publish queues webhook event with the order, its lines, addresses and
+// discounts as they stand in tx. The order is only read back when some
+// subscription wants the event.
+func publish(tx *gorm.DB, event string, orderID uint) error {
+	wanted, err := hook.Wants(tx, event)
+	if err != nil || !wanted {
+		return err
+	}
+	o := types.Order{}
+	if err := tx.Preload("Lines").Preload("Addresses").Preload("Discounts").First(&o, orderID).Error; err != nil {
+		return err
+	}
+	return hook.Publish(tx, event, o)
+}
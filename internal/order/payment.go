@@ -0,0 +1,90 @@
+package order
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotPayable        = errors.New("order is not awaiting payment")
+	ErrPaymentInProgress = errors.New("a payment of this order is already in progress")
+)
+
+// settledIntents are the intent statuses that no longer hold or collect
+// money; any other intent of an order blocks a new payment.
+var settledIntents = []string{payment.IntentFailed, payment.IntentVoided, payment.IntentRefunded}
+
+// Pay collects the amount due on the order through provider: the payment is
+// authorized and captured right away and the order is marked paid. The
+// intent is returned even when the provider declined or timed out, so the
+// caller can report its state.
+//
+// An order has at most one payment in progress: the intent is created with
+// the order row locked and only when no other intent of the order is still
+// pending, authorized or captured, so concurrent calls cannot both reach the
+// provider. A payment that timed out blocks new ones until its outcome
+// arrives.
+func Pay(ctx context.Context, db *gorm.DB, o *types.Order, provider payment.Provider, method string) (types.PaymentIntent, error) {
+	intent := types.PaymentIntent{
+		OrderID:  o.ID,
+		Provider: provider.Name(),
+		Status:   payment.IntentPending,
+		Currency: o.Currency,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(o, o.ID).Error; err != nil {
+			return err
+		}
+		intent.Amount = AmountDue(*o)
+		intent.Currency = o.Currency
+		if o.Status != StatusPendingPayment || intent.Amount <= 0 {
+			return ErrNotPayable
+		}
+
+		busy, err := paymentInProgress(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		if busy {
+			return ErrPaymentInProgress
+		}
+		return tx.Create(&intent).Error
+	})
+	if err != nil {
+		return intent, err
+	}
+
+	if err := payment.Authorize(ctx, db, provider, &intent, method); err != nil {
+		return intent, err
+	}
+	if err := payment.Capture(ctx, db, provider, &intent, intent.Amount); err != nil {
+		return intent, err
+	}
+
+	return intent, MarkPaid(db, o, "payment captured by "+provider.Name())
+}
+
+// paymentInProgress reports whether an intent of the order still holds or
+// collects money. Callers hold the lock of the order row, so no intent can be
+// created meanwhile.
+func paymentInProgress(tx *gorm.DB, orderID uint) (bool, error) {
+	var active int64
+	err := tx.Model(&types.PaymentIntent{}).
+		Where("order_id = ? AND status NOT IN ?", orderID, settledIntents).
+		Count(&active).Error
+	return active > 0, err
+}
+
+// MarkPaid moves an order awaiting payment to paid. Orders that are already
+// past that point are left alone.
+func MarkPaid(db *gorm.DB, o *types.Order, note string) error {
+	if o.Status != StatusPendingPayment {
+		return nil
+	}
+	return Transition(db, o, StatusPaid, note)
+}
@@ -0,0 +1,359 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/giftcard"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// checkout places a guest order for two mugs at 25.00 each.
+func checkout(t *testing.T, db *gorm.DB) types.Order {
+	t.Helper()
+	product := types.Product{Title: "Mug", Handle: "mug", Price: 2500, Published: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	variant := types.ProductVariant{ProductID: product.ID, SKU: "MUG-1", Price: 2500}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatal(err)
+	}
+	warehouse := types.Warehouse{Name: "Main", Code: "MAIN", Active: true}
+	if err := db.Create(&warehouse).Error; err != nil {
+		t.Fatal(err)
+	}
+	location := types.StockLocation{WarehouseID: warehouse.ID, Name: "Bin", Code: "BIN", Active: true}
+	if err := db.Create(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Adjust(db, types.StockMovement{VariantID: variant.ID, LocationID: location.ID, Quantity: 10, Reason: inventory.ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cart.New(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cart.AddItem(db, &c, variant.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	address := types.OrderAddress{FullName: "Ada Lovelace", Street: "1 Main St", City: "Springfield", ZipCode: "12345", CountryCode: "US"}
+	billing, shipping := address, address
+	o, err := Checkout(context.Background(), db, CheckoutInput{
+		Cart:            c,
+		Email:           "ada@example.com",
+		BillingAddress:  &billing,
+		ShippingAddress: &shipping,
+	})
+	if err != nil {
+		t.Fatalf("checkout: %s", err)
+	}
+	if o.Status != StatusPendingPayment || AmountDue(o) != 5000 {
+		t.Fatalf("order %s with %d due, want pending payment with 5000 due", o.Status, AmountDue(o))
+	}
+	return o
+}
+
+func TestPayCapturesAndMarksPaid(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+
+	intent, err := Pay(context.Background(), db, &o, payment.NewFakeProvider(payment.Credentials{}), payment.FakeMethodSuccess)
+	if err != nil {
+		t.Fatalf("pay: %s", err)
+	}
+	if intent.Status != payment.IntentCaptured || intent.AmountCaptured != 5000 {
+		t.Errorf("intent %s with %d captured, want captured with 5000", intent.Status, intent.AmountCaptured)
+	}
+	if o.Status != StatusPaid {
+		t.Errorf("order is %s, want paid", o.Status)
+	}
+}
+
+func TestPayDeclined(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeDeclined)
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if !errors.Is(err, payment.ErrDeclined) {
+		t.Fatalf("pay returned %v, want a decline", err)
+	}
+	if intent.Status != payment.IntentFailed || o.Status != StatusPendingPayment {
+		t.Errorf("intent %s, order %s after a decline", intent.Status, o.Status)
+	}
+
+	// a declined payment does not block another try
+	if _, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess); err != nil {
+		t.Fatalf("pay after a decline: %s", err)
+	}
+	if o.Status != StatusPaid {
+		t.Errorf("order is %s, want paid", o.Status)
+	}
+}
+
+func TestPayTimeoutBlocksAnotherPayment(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeTimeout)
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("pay returned %v, want a timeout", err)
+	}
+	if intent.Status != payment.IntentPending || o.Status != StatusPendingPayment {
+		t.Errorf("intent %s, order %s after a timeout", intent.Status, o.Status)
+	}
+
+	// the first payment may still go through
+	if _, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess); !errors.Is(err, ErrPaymentInProgress) {
+		t.Errorf("second pay returned %v, want ErrPaymentInProgress", err)
+	}
+}
+
+func TestPayCaptureTimeout(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeSucceeded, payment.OutcomeTimeout)
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("pay returned %v, want a timeout", err)
+	}
+	if intent.Status != payment.IntentAuthorized || intent.AmountCaptured != 0 || o.Status != StatusPendingPayment {
+		t.Errorf("intent %s with %d captured, order %s after a capture timeout", intent.Status, intent.AmountCaptured, o.Status)
+	}
+}
+
+func TestCancelUnpaidKeepsPaymentInProgress(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeTimeout)
+	if _, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess); !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("pay returned %v, want a timeout", err)
+	}
+
+	cancelled, err := CancelUnpaid(db, time.Now().Add(ReservationTTL+time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&o, o.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if cancelled != 0 || o.Status != StatusPendingPayment {
+		t.Errorf("cancelled %d, order %s with a payment in progress", cancelled, o.Status)
+	}
+}
+
+func TestTendersWaitForPaymentInProgress(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeTimeout)
+	if _, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess); !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("pay returned %v, want a timeout", err)
+	}
+
+	_, code, err := giftcard.Issue(db, giftcard.IssueInput{Currency: o.Currency, Value: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyTenders(db, &o, Tenders{GiftCardCodes: []string{code}}, time.Now()); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("tenders returned %v, want ErrPaymentInProgress", err)
+	}
+	if taken, err := giftcard.Outstanding(db, o.ID); err != nil || taken != 0 {
+		t.Errorf("gift card gave %d to the order (%v), want nothing", taken, err)
+	}
+}
+
+func TestConcurrentPayCapturesOnce(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(o types.Order) {
+			defer wg.Done()
+			<-start
+			_, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+			if err != nil && !errors.Is(err, ErrPaymentInProgress) && !errors.Is(err, ErrNotPayable) {
+				t.Errorf("pay: %s", err)
+			}
+		}(o)
+	}
+	close(start)
+	wg.Wait()
+
+	var captured int64
+	if err := db.Model(&types.PaymentIntent{}).Where("order_id = ? AND status = ?", o.ID, payment.IntentCaptured).
+		Count(&captured).Error; err != nil {
+		t.Fatal(err)
+	}
+	if captured != 1 {
+		t.Errorf("%d payments captured, want 1", captured)
+	}
+}
+
+func TestRefundTimeoutKeepsClaim(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if err != nil {
+		t.Fatalf("pay: %s", err)
+	}
+
+	provider.Script(payment.OutcomeDeclined)
+	if _, err := payment.Refund(context.Background(), db, provider, &intent, 2000); !errors.Is(err, payment.ErrDeclined) {
+		t.Fatalf("refund returned %v, want a decline", err)
+	}
+	if left, err := refundableCaptured(db, o.ID); err != nil || left != 5000 {
+		t.Errorf("%d left to refund after a declined refund, want 5000 (%v)", left, err)
+	}
+
+	provider.Script(payment.OutcomeTimeout)
+	if _, err := payment.Refund(context.Background(), db, provider, &intent, 2000); !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("refund returned %v, want a timeout", err)
+	}
+	if left, err := refundableCaptured(db, o.ID); err != nil || left != 3000 {
+		t.Errorf("%d left to refund after a timed out refund, want 3000 (%v)", left, err)
+	}
+	if _, err := payment.Refund(context.Background(), db, provider, &intent, 5000); !errors.Is(err, payment.ErrInvalidAmount) {
+		t.Errorf("refunding the claimed amount again returned %v, want ErrInvalidAmount", err)
+	}
+}
+
+func TestConcurrentTenderRefundsCreditOnce(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+
+	_, code, err := giftcard.Issue(db, giftcard.IssueInput{Currency: o.Currency, Value: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyTenders(db, &o, Tenders{GiftCardCodes: []string{code}}, time.Now()); err != nil {
+		t.Fatalf("tenders: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(o types.Order) {
+			defer wg.Done()
+			<-start
+			_, err := IssueRefund(context.Background(), db, &o, 5000, "damaged", nil)
+			if err != nil && !errors.Is(err, ErrRefundExceedsBalance) {
+				t.Errorf("refund: %s", err)
+			}
+		}(o)
+	}
+	close(start)
+	wg.Wait()
+
+	var refunded int64
+	if err := db.Model(&types.Refund{}).Where("order_id = ?", o.ID).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if refunded != 5000 {
+		t.Errorf("%d refunded onto the gift card, want 5000", refunded)
+	}
+	if left, err := giftcard.Outstanding(db, o.ID); err != nil || left != 0 {
+		t.Errorf("%d left on the order's gift card (%v), want 0", left, err)
+	}
+}
+
+// deliver receives a webhook event of kind for the payment and applies it.
+func deliver(t *testing.T, db *gorm.DB, intent types.PaymentIntent, id, kind string, amount int64) types.PaymentWebhookEvent {
+	t.Helper()
+	event := payment.WebhookEvent{ID: id, Type: kind, ProviderReference: intent.ProviderReference, Amount: amount, Currency: intent.Currency}
+	stored, _, err := payment.StoreEvent(db, intent.Provider, event, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyPaymentEvent(db, &stored); err != nil {
+		t.Fatalf("applying %s: %s", kind, err)
+	}
+	return stored
+}
+
+func TestRefundEventWaitsForCapture(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+
+	provider.Script(payment.OutcomeSucceeded, payment.OutcomeTimeout)
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if !errors.Is(err, payment.ErrTimeout) {
+		t.Fatalf("pay returned %v, want a timeout", err)
+	}
+
+	refund := deliver(t, db, intent, "evt_refund", payment.EventRefunded, 5000)
+	if refund.Status != payment.WebhookIgnored {
+		t.Errorf("refund before its capture is %s, want ignored", refund.Status)
+	}
+	deliver(t, db, intent, "evt_capture", payment.EventCaptured, 5000)
+
+	if err := db.First(&refund, refund.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&intent, intent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&o, o.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if refund.Status != payment.WebhookProcessed || intent.Status != payment.IntentRefunded || intent.AmountRefunded != 5000 {
+		t.Errorf("refund event %s, intent %s with %d refunded after the capture", refund.Status, intent.Status, intent.AmountRefunded)
+	}
+	if o.Status != StatusRefunded {
+		t.Errorf("order is %s, want refunded", o.Status)
+	}
+}
+
+func TestRefundEventRecordsRefund(t *testing.T) {
+	db := testdb.Open(t)
+	o := checkout(t, db)
+	provider := payment.NewFakeProvider(payment.Credentials{})
+	intent, err := Pay(context.Background(), db, &o, provider, payment.FakeMethodSuccess)
+	if err != nil {
+		t.Fatalf("pay: %s", err)
+	}
+
+	// refunded from the admin, then confirmed by the provider
+	if _, err := IssueRefund(context.Background(), db, &o, 1000, "damaged", nil); err != nil {
+		t.Fatalf("refund: %s", err)
+	}
+	deliver(t, db, intent, "evt_1", payment.EventRefunded, 1000)
+
+	// refunded in the provider's dashboard, delivered twice
+	deliver(t, db, intent, "evt_2", payment.EventRefunded, 3000)
+	deliver(t, db, intent, "evt_3", payment.EventRefunded, 3000)
+
+	var refunds []types.Refund
+	if err := db.Where("order_id = ?", o.ID).Order("id").Find(&refunds).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 2 || refunds[0].Amount != 1000 || refunds[1].Amount != 2000 || refunds[1].Status != RefundSucceeded {
+		t.Errorf("refunds %+v, want 1000 from the admin and 2000 from the provider", refunds)
+	}
+}
@@ -0,0 +1,280 @@
+package order
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/giftcard"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/storecredit"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Refund statuses. A partial refund gave back only some of the requested
+// amount because a provider call failed half way.
+const (
+	RefundSucceeded = "succeeded"
+	RefundPartial   = "partial"
+	RefundFailed    = "failed"
+)
+
+// Refund destinations.
+const (
+	RefundToOriginal    = "original"
+	RefundToStoreCredit = "store_credit"
+)
+
+var (
+	ErrRefundExceedsBalance = errors.New("amount exceeds the refundable balance")
+	ErrGuestOrder           = errors.New("guest orders cannot be refunded to store credit")
+)
+
+// Refundable returns what is left to refund on the order: everything
+// captured on its payments or settled with gift cards and store credit,
+// minus what was already given back.
+func Refundable(db *gorm.DB, orderID uint) (int64, error) {
+	captured, err := refundableCaptured(db, orderID)
+	if err != nil {
+		return 0, err
+	}
+	cards, err := giftcard.Outstanding(db, orderID)
+	if err != nil {
+		return 0, err
+	}
+	credit, err := storecredit.Outstanding(db, orderID)
+	if err != nil {
+		return 0, err
+	}
+	return captured + cards + credit, nil
+}
+
+// refundableCaptured is what is left to refund on the order's payments.
+func refundableCaptured(db *gorm.DB, orderID uint) (int64, error) {
+	var refundable int64
+	err := db.Model(&types.PaymentIntent{}).
+		Where("order_id = ?", orderID).
+		Select("COALESCE(SUM(amount_captured - amount_refunded), 0)").
+		Scan(&refundable).Error
+	return refundable, err
+}
+
+// IssueRefund gives amount back on the order the way it was paid and records
+// the refund: through the providers of its payments, oldest payment first,
+// then onto its gift cards and store credit. The order moves to refunded
+// once nothing is left to refund. Each payment claims its part before the
+// provider is called, so concurrent refunds cannot exceed what was captured.
+func IssueRefund(ctx context.Context, db *gorm.DB, o *types.Order, amount int64, reason string, returnID *uint) (types.Refund, error) {
+	refund := types.Refund{
+		OrderID:         o.ID,
+		ReturnRequestID: returnID,
+		Requested:       amount,
+		Currency:        o.Currency,
+		Destination:     RefundToOriginal,
+		Reason:          reason,
+	}
+	if amount <= 0 {
+		return refund, payment.ErrInvalidAmount
+	}
+	refundable, err := Refundable(db, o.ID)
+	if err != nil {
+		return refund, err
+	}
+	if amount > refundable {
+		return refund, ErrRefundExceedsBalance
+	}
+
+	// refunds made to store credit come off the payments first
+	captured, err := refundableCaptured(db, o.ID)
+	if err != nil {
+		return refund, err
+	}
+	credit, err := storecredit.Outstanding(db, o.ID)
+	if err != nil {
+		return refund, err
+	}
+	// Invariant: the payments give back at most what was captured on them
+	// minus the store credit already returned beyond what the order was
+	// paid with in credit (a negative credit outstanding), since that excess
+	// was refunded in place of the card. Neither part goes below zero and
+	// the rest of the amount is left to the gift cards and store credit, so
+	// the total never exceeds Refundable.
+	creditReturned := max(-credit, 0)
+	refundableToCard := max(captured-creditReturned, 0)
+	remaining := amount
+	refundErr := refundPayments(ctx, db, o.ID, min(amount, refundableToCard), &remaining)
+	if refundErr == nil && remaining > 0 {
+		refundErr = refundTenders(db, *o, reason, &remaining)
+	}
+	if refundErr == nil && remaining > 0 {
+		// another refund took the balance in the meantime
+		refundErr = ErrRefundExceedsBalance
+	}
+
+	refund.Amount = amount - remaining
+	switch {
+	case refund.Amount == 0:
+		refund.Status = RefundFailed
+	case remaining > 0:
+		refund.Status = RefundPartial
+	default:
+		refund.Status = RefundSucceeded
+	}
+	if err := db.Create(&refund).Error; err != nil {
+		return refund, errors.Join(refundErr, err)
+	}
+	if refund.Amount == 0 {
+		return refund, refundErr
+	}
+
+	if err := markRefunded(db, o, reason); err != nil {
+		return refund, errors.Join(refundErr, err)
+	}
+	return refund, refundErr
+}
+
+// refundPayments gives up to amount back through the providers of the
+// order's payments and takes what it gave back off remaining.
+func refundPayments(ctx context.Context, db *gorm.DB, orderID uint, amount int64, remaining *int64) error {
+	if amount <= 0 {
+		return nil
+	}
+	var intents []types.PaymentIntent
+	if err := db.Where("order_id = ? AND status IN ?", orderID, []string{payment.IntentCaptured, payment.IntentPartiallyRefunded}).
+		Order("id").Find(&intents).Error; err != nil {
+		return err
+	}
+
+	for i := range intents {
+		intent := &intents[i]
+		part := min(intent.AmountCaptured-intent.AmountRefunded, amount)
+		if part <= 0 {
+			continue
+		}
+		provider, err := payment.ForTenant(db, intent.Provider)
+		if err != nil {
+			return err
+		}
+		if _, err := payment.Refund(ctx, db, provider, intent, part); err != nil {
+			return err
+		}
+		amount -= part
+		*remaining -= part
+		if amount == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// refundTenders puts what is left of remaining back on the order's gift
+// cards, then on the customer's store credit up to what the order was paid
+// with in credit. The order is locked and what is left to refund is read
+// again under the lock, so concurrent refunds cannot both credit the same
+// balance.
+func refundTenders(db *gorm.DB, o types.Order, reason string, remaining *int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&types.Order{}, o.ID).Error; err != nil {
+			return err
+		}
+		refundable, err := Refundable(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		credit, err := storecredit.Outstanding(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		// what is over the balance is left on remaining for the caller
+		budget := min(*remaining, max(refundable, 0))
+
+		given, err := giftcard.Refund(tx, o.ID, budget, reason)
+		if err != nil {
+			return err
+		}
+		*remaining -= given
+		budget -= given
+
+		part := min(budget, credit)
+		if part <= 0 || o.UserID == nil {
+			return nil
+		}
+		if _, err := storecredit.Record(tx, storecredit.Entry{
+			UserID:   *o.UserID,
+			Currency: o.Currency,
+			Kind:     storecredit.KindRefund,
+			Amount:   part,
+			OrderID:  &o.ID,
+			Note:     reason,
+		}); err != nil {
+			return err
+		}
+		*remaining -= part
+		return nil
+	})
+}
+
+// RefundToCredit gives amount back on the order as store credit of its
+// customer, whatever the order was paid with.
+func RefundToCredit(db *gorm.DB, o *types.Order, amount int64, reason string, returnID *uint) (types.Refund, error) {
+	refund := types.Refund{
+		OrderID:         o.ID,
+		ReturnRequestID: returnID,
+		Requested:       amount,
+		Amount:          amount,
+		Currency:        o.Currency,
+		Status:          RefundSucceeded,
+		Destination:     RefundToStoreCredit,
+		Reason:          reason,
+	}
+	if amount <= 0 {
+		return refund, payment.ErrInvalidAmount
+	}
+	if o.UserID == nil {
+		return refund, ErrGuestOrder
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// lock the order so concurrent refunds see each other
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&types.Order{}, o.ID).Error; err != nil {
+			return err
+		}
+		refundable, err := Refundable(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		if amount > refundable {
+			return ErrRefundExceedsBalance
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+		_, err = storecredit.Record(tx, storecredit.Entry{
+			UserID:   *o.UserID,
+			Currency: o.Currency,
+			Kind:     storecredit.KindRefund,
+			Amount:   amount,
+			OrderID:  &o.ID,
+			RefundID: &refund.ID,
+			Note:     reason,
+		})
+		return err
+	})
+	if err != nil {
+		return refund, err
+	}
+	return refund, markRefunded(db, o, reason)
+}
+
+// markRefunded moves the order to refunded once nothing is left to refund.
+func markRefunded(db *gorm.DB, o *types.Order, reason string) error {
+	left, err := Refundable(db, o.ID)
+	if err != nil {
+		return err
+	}
+	if left == 0 && CanTransition(o.Status, StatusRefunded) {
+		return Transition(db, o, StatusRefunded, reason)
+	}
+	return nil
+}
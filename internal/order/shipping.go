@@ -0,0 +1,48 @@
+package order
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/shipping"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+var ErrMissingShippingMethod = errors.New("shipping method is required")
+
+// ShippingRequest describes the priced cart as a shipment to address.
+func ShippingRequest(summary cart.Summary, address types.OrderAddress, currency string) shipping.Request {
+	req := shipping.Request{
+		Destination:  shipping.Address{CountryCode: address.CountryCode, PostalCode: address.ZipCode},
+		Subtotal:     summary.Subtotal - summary.DiscountTotal,
+		Currency:     currency,
+		FreeShipping: summary.FreeShipping,
+	}
+	for _, line := range summary.Lines {
+		req.Items = append(req.Items, shipping.Item{VariantID: line.VariantID, Quantity: line.Quantity})
+	}
+	return req
+}
+
+// applyShipping prices the chosen shipping method onto the order. Stores
+// without shipping zones ship for free and need no method.
+func applyShipping(ctx context.Context, tx *gorm.DB, o *types.Order, summary cart.Summary, address types.OrderAddress, methodID *uint) error {
+	configured, err := shipping.Configured(tx)
+	if err != nil || !configured {
+		return err
+	}
+	if methodID == nil {
+		return ErrMissingShippingMethod
+	}
+
+	option, err := shipping.Choose(ctx, tx, ShippingRequest(summary, address, o.Currency), *methodID)
+	if err != nil {
+		return err
+	}
+	o.ShippingMethodID = &option.MethodID
+	o.ShippingMethod = option.Name
+	o.ShippingTotal = option.Amount
+	return nil
+}
@@ -0,0 +1,223 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Order statuses.
+const (
+	StatusPendingPayment = "pending_payment"
+	StatusPaid           = "paid"
+	StatusFulfilling     = "fulfilling"
+	StatusShipped        = "shipped"
+	StatusDelivered      = "delivered"
+	StatusCancelled      = "cancelled"
+	StatusRefunded       = "refunded"
+)
+
+// transitions lists the statuses each status may move to.
+var transitions = map[string][]string{
+	StatusPendingPayment: {StatusPaid, StatusCancelled},
+	StatusPaid:           {StatusFulfilling, StatusCancelled, StatusRefunded},
+	StatusFulfilling:     {StatusShipped, StatusCancelled, StatusRefunded},
+	StatusShipped:        {StatusDelivered, StatusRefunded},
+	StatusDelivered:      {StatusRefunded},
+}
+
+// manualTransitions lists the moves staff may make by hand. Marking an order
+// paid, cancelling a paid order and refunding one move money, so they follow
+// from payments and refunds instead.
+var manualTransitions = map[string][]string{
+	StatusPendingPayment: {StatusCancelled},
+	StatusPaid:           {StatusFulfilling},
+	StatusFulfilling:     {StatusShipped},
+	StatusShipped:        {StatusDelivered},
+}
+
+var ErrStaleOrder = errors.New("order was changed concurrently")
+
+// InvalidTransitionError is returned when an order cannot move from its
+// current status to the requested one.
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot move order from %s to %s", e.From, e.To)
+}
+
+// ValidStatus reports whether status is a known order status.
+func ValidStatus(status string) bool {
+	if _, ok := transitions[status]; ok {
+		return true
+	}
+	return status == StatusCancelled || status == StatusRefunded
+}
+
+// CanTransition reports whether an order may move from one status to another.
+func CanTransition(from, to string) bool {
+	return canMove(transitions, from, to)
+}
+
+func canMove(moves map[string][]string, from, to string) bool {
+	for _, next := range moves[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ManualTransition moves the order to status to on behalf of staff. Only the
+// moves in manualTransitions are allowed, and an order awaiting payment is
+// not cancelled while a payment of it is in progress.
+func ManualTransition(db *gorm.DB, o *types.Order, to, note string) error {
+	if !canMove(manualTransitions, o.Status, to) {
+		return &InvalidTransitionError{From: o.Status, To: to}
+	}
+	if to == StatusCancelled {
+		return cancelPending(db, o, note)
+	}
+	return Transition(db, o, to, note)
+}
+
+// Reference is the inventory reference under which stock is held and sold
+// for an order.
+func Reference(o types.Order) string {
+	return "order:" + o.Number
+}
+
+// Transition moves the order to status to, records the change in the order
+// history, applies its inventory and digital delivery side effects and
+// publishes the order.<status> webhook event. The status is switched with a
+// conditional update so two concurrent transitions cannot both win.
+func Transition(db *gorm.DB, o *types.Order, to, note string) error {
+	from := o.Status
+	if !CanTransition(from, to) {
+		return &InvalidTransitionError{From: from, To: to}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&types.Order{}).
+			Where("id = ? AND status = ?", o.ID, from).
+			Update("status", to)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStaleOrder
+		}
+
+		extra, err := applyStock(tx, *o, from, to)
+		if err != nil {
+			return err
+		}
+		if extra != "" {
+			if note != "" {
+				note += "; "
+			}
+			note += extra
+		}
+		if err := applyDigital(tx, *o, from, to); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&types.OrderEvent{
+			OrderID:    o.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Note:       note,
+		}).Error; err != nil {
+			return err
+		}
+		if err := publish(tx, statusEvent(to), o.ID); err != nil {
+			return err
+		}
+		o.Status = to
+		return nil
+	})
+}
+
+// applyStock keeps inventory in line with the order: payment turns the
+// reservation into a sale, cancelling gives the stock back (and, for an
+// unpaid order, its promotion uses and tenders). It returns a
+// note for the order history when stock could not be handled as expected.
+func applyStock(tx *gorm.DB, o types.Order, from, to string) (string, error) {
+	reference := Reference(o)
+
+	switch {
+	case from == StatusPendingPayment && to == StatusPaid:
+		err := inventory.Commit(tx, reference)
+		if !errors.Is(err, inventory.ErrNoReservation) {
+			return "", err
+		}
+		// the reservation expired before payment arrived, try to allocate again
+		return allocate(tx, o, reference)
+
+	case from == StatusPendingPayment && to == StatusCancelled:
+		// the order never went through, its promotion uses and the gift
+		// card value and store credit it took are given back
+		if err := promotion.Release(tx, o.ID); err != nil {
+			return "", err
+		}
+		if err := releaseTenders(tx, o); err != nil {
+			return "", err
+		}
+		return "", inventory.Release(tx, reference)
+
+	case to == StatusCancelled:
+		// paid stock has already left the shelves, put it back
+		return "", restock(tx, reference)
+	}
+	return "", nil
+}
+
+// allocate reserves and sells the order lines in one go.
+func allocate(tx *gorm.DB, o types.Order, reference string) (string, error) {
+	var lines []types.OrderLine
+	if err := tx.Where("order_id = ?", o.ID).Find(&lines).Error; err != nil {
+		return "", err
+	}
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		for _, line := range lines {
+			if _, err := inventory.Reserve(tx, line.VariantID, line.Quantity, reference, time.Minute); err != nil {
+				return err
+			}
+		}
+		return inventory.Commit(tx, reference)
+	})
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		return "stock reservation had expired and stock is no longer available", nil
+	}
+	return "", err
+}
+
+// restock returns the units sold for reference to the locations they were
+// taken from.
+func restock(tx *gorm.DB, reference string) error {
+	var movements []types.StockMovement
+	if err := tx.Where("reference = ? AND reason = ?", reference, inventory.ReasonSold).Find(&movements).Error; err != nil {
+		return err
+	}
+	for _, m := range movements {
+		if err := inventory.Adjust(tx, types.StockMovement{
+			VariantID:  m.VariantID,
+			LocationID: m.LocationID,
+			Quantity:   -m.Quantity,
+			Reason:     inventory.ReasonCancelled,
+			Reference:  reference,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
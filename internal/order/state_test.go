@@ -0,0 +1,27 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+func TestManualTransitionRefusesMoneyMoves(t *testing.T) {
+	cases := []struct{ from, to string }{
+		{StatusPendingPayment, StatusPaid},
+		{StatusPaid, StatusCancelled},
+		{StatusPaid, StatusRefunded},
+		{StatusFulfilling, StatusCancelled},
+		{StatusFulfilling, StatusRefunded},
+		{StatusShipped, StatusRefunded},
+		{StatusDelivered, StatusRefunded},
+	}
+	for _, c := range cases {
+		o := types.Order{Status: c.from}
+		var invalid *InvalidTransitionError
+		if err := ManualTransition(nil, &o, c.to, ""); !errors.As(err, &invalid) {
+			t.Errorf("%s to %s by hand returned %v, want an invalid transition", c.from, c.to, err)
+		}
+	}
+}
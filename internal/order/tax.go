@@ -0,0 +1,65 @@
+package order
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/tax"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// calculateTax taxes the cart lines, after discounts, for delivery to the
+// shipping address. It sets the tax totals of the order and returns the tax
+// of every line.
+func calculateTax(ctx context.Context, tx *gorm.DB, o *types.Order, lines []cart.Line, shipping types.OrderAddress) ([]int64, error) {
+	provider, settings, err := tax.ForTenant(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+	}
+	var products []types.Product
+	if err := tx.Select("id", "tax_class_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	classes := map[uint]*uint{}
+	for _, product := range products {
+		classes[product.ID] = product.TaxClassID
+	}
+
+	req := tax.Request{
+		Address:          tax.Address{CountryCode: shipping.CountryCode, ZipCode: shipping.ZipCode},
+		Currency:         o.Currency,
+		PricesIncludeTax: settings.PricesIncludeTax,
+		RoundingMode:     settings.RoundingMode,
+	}
+	for i, line := range lines {
+		req.Lines = append(req.Lines, tax.Line{
+			Reference:  strconv.Itoa(i),
+			ProductID:  line.ProductID,
+			TaxClassID: classes[line.ProductID],
+			Quantity:   line.Quantity,
+			Amount:     line.LineTotal - line.Discount,
+		})
+	}
+
+	res, err := provider.Calculate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := make([]int64, len(lines))
+	for i, line := range res.Lines {
+		if i < len(amounts) {
+			amounts[i] = line.Amount
+		}
+	}
+	o.TaxTotal = res.Total
+	o.TaxIncluded = settings.PricesIncludeTax
+	return amounts, nil
+}
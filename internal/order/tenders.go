@@ -0,0 +1,100 @@
+package order
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/giftcard"
+	"github.com/Satishcg12/multicommers/internal/storecredit"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrGuestStoreCredit = errors.New("store credit needs a customer account")
+
+// Tenders are what an order is paid with besides a payment provider: gift
+// card codes, used in turn, and the customer's store credit.
+type Tenders struct {
+	GiftCardCodes  []string
+	UseStoreCredit bool
+}
+
+// AmountDue is the part of the order total left to charge through a payment
+// provider.
+func AmountDue(o types.Order) int64 {
+	return o.Total - o.GiftCardTotal - o.StoreCreditTotal
+}
+
+// ApplyTenders settles what it can of the amount due with the gift cards and
+// store credit of t. Gift cards are only partially used when they hold more
+// than is due. The order is marked paid once nothing is left to charge,
+// otherwise the rest is charged with Pay. Tenders cannot be added while a
+// payment of the order is in progress, as that payment may still collect
+// the full amount due. Tenders stay on the order until it is paid, or are
+// given back when it is cancelled.
+func ApplyTenders(db *gorm.DB, o *types.Order, t Tenders, now time.Time) error {
+	if t.UseStoreCredit && o.UserID == nil {
+		return ErrGuestStoreCredit
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(o, o.ID).Error; err != nil {
+			return err
+		}
+		if o.Status != StatusPendingPayment {
+			return ErrNotPayable
+		}
+		// a payment in progress may still collect the amount due
+		busy, err := paymentInProgress(tx, o.ID)
+		if err != nil {
+			return err
+		}
+		if busy {
+			return ErrPaymentInProgress
+		}
+
+		for _, code := range t.GiftCardCodes {
+			due := AmountDue(*o)
+			if due <= 0 {
+				break
+			}
+			taken, err := giftcard.Redeem(tx, code, o.Currency, due, o.ID, now)
+			if err != nil {
+				return err
+			}
+			o.GiftCardTotal += taken
+		}
+		if due := AmountDue(*o); t.UseStoreCredit && due > 0 {
+			taken, err := storecredit.Redeem(tx, *o.UserID, o.Currency, due, o.ID)
+			if err != nil {
+				return err
+			}
+			o.StoreCreditTotal += taken
+		}
+
+		if err := tx.Model(o).Updates(map[string]interface{}{
+			"gift_card_total":    o.GiftCardTotal,
+			"store_credit_total": o.StoreCreditTotal,
+		}).Error; err != nil {
+			return err
+		}
+		if AmountDue(*o) > 0 {
+			return nil
+		}
+		return Transition(tx, o, StatusPaid, "paid with gift card and store credit")
+	})
+}
+
+// releaseTenders gives back the gift card value and store credit taken by an
+// order that was never paid.
+func releaseTenders(tx *gorm.DB, o types.Order) error {
+	if o.GiftCardTotal > 0 {
+		if err := giftcard.Release(tx, o.ID); err != nil {
+			return err
+		}
+	}
+	if o.StoreCreditTotal > 0 {
+		return storecredit.Release(tx, o)
+	}
+	return nil
+}
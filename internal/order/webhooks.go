@@ -0,0 +1,130 @@
+package order
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyPaymentEvent applies a stored webhook event to its payment and then
+// brings the order in line with the payment. The outcome is recorded on the
+// event. Applying the same event again, or events in any order, is safe:
+// payments only move forward and the order follows the payment state rather
+// than the event. Refunds arriving before their capture are left ignored and
+// applied once the capture is.
+func ApplyPaymentEvent(db *gorm.DB, stored *types.PaymentWebhookEvent) error {
+	intent, changed, err := applyEvent(db, stored)
+	if errors.Is(err, payment.ErrUnknownPayment) || errors.Is(err, payment.ErrRefundBeforeCapture) {
+		return finishEvent(db, stored, payment.WebhookIgnored, err.Error())
+	}
+	if err != nil {
+		return errors.Join(err, finishEvent(db, stored, payment.WebhookFailed, err.Error()))
+	}
+
+	note, err := followPayment(db, intent)
+	if err != nil {
+		return errors.Join(err, finishEvent(db, stored, payment.WebhookFailed, err.Error()))
+	}
+	if note == "" && !changed {
+		note = "payment already up to date"
+	}
+	if err := finishEvent(db, stored, payment.WebhookProcessed, note); err != nil {
+		return err
+	}
+	if changed && intent.Status == payment.IntentCaptured {
+		return applyWaitingRefunds(db, intent)
+	}
+	return nil
+}
+
+// applyEvent applies the stored event to its payment. A refund made at the
+// provider rather than through IssueRefund raises what was refunded on the
+// payment; the difference is recorded as a refund of the order, so the
+// ledger books it like any other.
+func applyEvent(db *gorm.DB, stored *types.PaymentWebhookEvent) (types.PaymentIntent, bool, error) {
+	intent := types.PaymentIntent{}
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// lock the payment so the refunded amount cannot move in between
+		before := types.PaymentIntent{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_reference = ?", stored.Provider, stored.ProviderReference).
+			Limit(1).Find(&before).Error; err != nil {
+			return err
+		}
+		var err error
+		intent, changed, err = payment.ApplyEvent(tx, stored.Provider, payment.EventOf(*stored))
+		if err != nil || intent.AmountRefunded <= before.AmountRefunded {
+			return err
+		}
+		given := intent.AmountRefunded - before.AmountRefunded
+		return tx.Create(&types.Refund{
+			OrderID:     intent.OrderID,
+			Requested:   given,
+			Amount:      given,
+			Currency:    intent.Currency,
+			Status:      RefundSucceeded,
+			Destination: RefundToOriginal,
+			Reason:      "payment refunded by " + intent.Provider,
+		}).Error
+	})
+	return intent, changed, err
+}
+
+// applyWaitingRefunds applies the refund events of intent that arrived
+// before its capture.
+func applyWaitingRefunds(db *gorm.DB, intent types.PaymentIntent) error {
+	var waiting []types.PaymentWebhookEvent
+	if err := db.Where("provider = ? AND provider_reference = ? AND type = ? AND status = ?",
+		intent.Provider, intent.ProviderReference, payment.EventRefunded, payment.WebhookIgnored).
+		Order("id").Find(&waiting).Error; err != nil {
+		return err
+	}
+	for i := range waiting {
+		if err := ApplyPaymentEvent(db, &waiting[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// followPayment moves the order of intent to the status its payment calls
+// for. The returned note describes anything that needs a person's attention.
+func followPayment(db *gorm.DB, intent types.PaymentIntent) (string, error) {
+	o := types.Order{}
+	if err := db.First(&o, intent.OrderID).Error; err != nil {
+		return "", err
+	}
+
+	switch intent.Status {
+	case payment.IntentCaptured:
+		if o.Status == StatusCancelled {
+			// the order timed out before the provider confirmed the payment
+			return "order was cancelled before the payment was captured, refund required", nil
+		}
+		return "", MarkPaid(db, &o, "payment captured by "+intent.Provider)
+	case payment.IntentRefunded:
+		if CanTransition(o.Status, StatusRefunded) {
+			return "", Transition(db, &o, StatusRefunded, "payment refunded by "+intent.Provider)
+		}
+	}
+	return "", nil
+}
+
+func finishEvent(db *gorm.DB, stored *types.PaymentWebhookEvent, status, note string) error {
+	now := time.Now()
+	stored.Status = status
+	stored.Note = note
+	stored.Attempts++
+	stored.ProcessedAt = &now
+	return db.Model(stored).Updates(map[string]interface{}{
+		"status":       status,
+		"note":         note,
+		"attempts":     gorm.Expr("attempts + 1"),
+		"processed_at": now,
+	}).Error
+}
@@ -0,0 +1,184 @@
+package payment
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook event types. The amount of a refunded event is the total refunded
+// so far on the payment, not the amount of the last refund.
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventVoided     = "payment.voided"
+	EventRefunded   = "payment.refunded"
+)
+
+// Stored webhook event statuses.
+const (
+	WebhookReceived  = "received"
+	WebhookProcessed = "processed"
+	WebhookIgnored   = "ignored"
+	WebhookFailed    = "failed"
+)
+
+var (
+	ErrUnknownPayment      = errors.New("no payment matches the event")
+	ErrRefundBeforeCapture = errors.New("refund arrived before the payment was captured")
+)
+
+// intentRank orders the statuses a payment goes through. Events may only
+// move an intent to a higher rank.
+var intentRank = map[string]int{
+	IntentPending:           0,
+	IntentAuthorized:        1,
+	IntentCaptured:          2,
+	IntentPartiallyRefunded: 3,
+	IntentRefunded:          4,
+}
+
+// StoreEvent saves a verified webhook event. It reports whether the event
+// was already processed, in which case it must not be applied again. Events
+// stored earlier that failed or matched no payment are handed back for
+// another try.
+func StoreEvent(db *gorm.DB, provider string, event WebhookEvent, payload []byte) (types.PaymentWebhookEvent, bool, error) {
+	stored := types.PaymentWebhookEvent{
+		Provider:          provider,
+		EventID:           event.ID,
+		Type:              event.Type,
+		ProviderReference: event.ProviderReference,
+		Amount:            event.Amount,
+		Currency:          event.Currency,
+		Payload:           string(payload),
+		Status:            WebhookReceived,
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&stored)
+	if res.Error != nil {
+		return stored, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return stored, false, nil
+	}
+
+	if err := db.Where("provider = ? AND event_id = ?", provider, event.ID).First(&stored).Error; err != nil {
+		return stored, false, err
+	}
+	return stored, stored.Status == WebhookProcessed, nil
+}
+
+// EventOf rebuilds the event a stored webhook was decoded to.
+func EventOf(stored types.PaymentWebhookEvent) WebhookEvent {
+	return WebhookEvent{
+		ID:                stored.EventID,
+		Type:              stored.Type,
+		ProviderReference: stored.ProviderReference,
+		Amount:            stored.Amount,
+		Currency:          stored.Currency,
+	}
+}
+
+// ApplyEvent brings the intent named by the event up to date and reports
+// whether it changed. Events only ever move an intent forward, so replays
+// and events arriving out of order leave it untouched. A refund of a payment
+// not captured yet returns ErrRefundBeforeCapture instead, so it can be
+// applied once the capture is known.
+func ApplyEvent(db *gorm.DB, provider string, event WebhookEvent) (types.PaymentIntent, bool, error) {
+	intent := types.PaymentIntent{}
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND provider_reference = ?", provider, event.ProviderReference).
+			First(&intent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownPayment
+			}
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		kind := ""
+		switch event.Type {
+		case EventAuthorized:
+			if advances(intent.Status, IntentAuthorized) {
+				updates["status"] = IntentAuthorized
+				kind = KindAuthorize
+			}
+		case EventCaptured:
+			if advances(intent.Status, IntentCaptured) {
+				amount := event.Amount
+				if amount <= 0 || amount > intent.Amount {
+					amount = intent.Amount
+				}
+				updates["status"] = IntentCaptured
+				updates["amount_captured"] = amount
+				kind = KindCapture
+			}
+		case EventFailed:
+			if intent.Status == IntentPending || intent.Status == IntentAuthorized {
+				updates["status"] = IntentFailed
+				kind = KindAuthorize
+			}
+		case EventVoided:
+			if intent.Status == IntentPending || intent.Status == IntentAuthorized {
+				updates["status"] = IntentVoided
+				kind = KindVoid
+			}
+		case EventRefunded:
+			if intent.Status == IntentPending || intent.Status == IntentAuthorized {
+				return ErrRefundBeforeCapture
+			}
+			refunded := event.Amount
+			if refunded > intent.AmountCaptured {
+				refunded = intent.AmountCaptured
+			}
+			// a refund that timed out already claimed its amount, so the
+			// event may confirm the total without raising it
+			if refunded > 0 && refunded >= intent.AmountRefunded {
+				status := IntentPartiallyRefunded
+				if refunded == intent.AmountCaptured {
+					status = IntentRefunded
+				}
+				if refunded > intent.AmountRefunded || status != intent.Status {
+					updates["amount_refunded"] = refunded
+					updates["status"] = status
+					kind = KindRefund
+				}
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&intent).Updates(updates).Error; err != nil {
+			return err
+		}
+		changed = true
+		status := OutcomeSucceeded
+		if event.Type == EventFailed {
+			status = OutcomeFailed
+		}
+		return tx.Create(&types.PaymentTransaction{
+			PaymentIntentID:   intent.ID,
+			Kind:              kind,
+			Status:            status,
+			Amount:            event.Amount,
+			Currency:          intent.Currency,
+			ProviderReference: event.ProviderReference,
+			Message:           "webhook " + event.ID,
+		}).Error
+	})
+	return intent, changed, err
+}
+
+func advances(from, to string) bool {
+	current, ok := intentRank[from]
+	if !ok {
+		// failed and voided payments are final
+		return false
+	}
+	return intentRank[to] > current
+}
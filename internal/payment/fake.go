@@ -0,0 +1,169 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+)
+
+const FakeProviderName = "fake"
+
+// Payment method tokens understood by the fake provider.
+const (
+	FakeMethodSuccess = "fake_success"
+	FakeMethodDecline = "fake_decline"
+	FakeMethodTimeout = "fake_timeout"
+)
+
+// FakeSignatureHeader carries the webhook signature of the fake provider in
+// the form "t=<unix seconds>,v1=<hex hmac-sha256>".
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// webhookTolerance bounds how old a signed webhook may be.
+const webhookTolerance = 5 * time.Minute
+
+// FakeProvider is an offline provider for development and tests. Every call
+// succeeds unless told otherwise: outcomes queued with Script are used first,
+// then the "outcome" credential (succeeded, declined or timeout), then the
+// payment method token passed to Authorize.
+type FakeProvider struct {
+	mu      sync.Mutex
+	secret  string
+	outcome string
+	script  []string
+}
+
+func NewFakeProvider(creds Credentials) *FakeProvider {
+	return &FakeProvider{
+		secret:  creds["webhook_secret"],
+		outcome: creds["outcome"],
+	}
+}
+
+// Script queues the outcomes of the next calls.
+func (p *FakeProvider) Script(outcomes ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.script = append(p.script, outcomes...)
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	outcome := OutcomeSucceeded
+	switch req.PaymentMethod {
+	case FakeMethodDecline:
+		outcome = OutcomeDeclined
+	case FakeMethodTimeout:
+		outcome = OutcomeTimeout
+	}
+	if err := p.next(ctx, outcome); err != nil {
+		return Result{}, err
+	}
+	return Result{ProviderReference: "fake_" + randomString.GenerateSecureToken(12), Message: "authorized"}, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, providerReference string, amount int64) (Result, error) {
+	if err := p.next(ctx, OutcomeSucceeded); err != nil {
+		return Result{}, err
+	}
+	return Result{ProviderReference: providerReference, Message: "captured"}, nil
+}
+
+func (p *FakeProvider) Void(ctx context.Context, providerReference string) (Result, error) {
+	if err := p.next(ctx, OutcomeSucceeded); err != nil {
+		return Result{}, err
+	}
+	return Result{ProviderReference: providerReference, Message: "voided"}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, providerReference string, amount int64) (Result, error) {
+	if err := p.next(ctx, OutcomeSucceeded); err != nil {
+		return Result{}, err
+	}
+	return Result{ProviderReference: "fake_re_" + randomString.GenerateSecureToken(12), Message: "refunded"}, nil
+}
+
+// VerifyWebhook checks the signature header against the webhook_secret
+// credential and decodes the event.
+func (p *FakeProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	event := WebhookEvent{}
+	if p.secret == "" {
+		return event, ErrInvalidSignature
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(header.Get(FakeSignatureHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return event, ErrInvalidSignature
+	}
+	signedAt := time.Unix(seconds, 0)
+	if time.Since(signedAt) > webhookTolerance || time.Until(signedAt) > webhookTolerance {
+		return event, ErrInvalidSignature
+	}
+	expected := SignFakeWebhook(p.secret, body, signedAt)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(FakeSignatureHeader))) {
+		return event, ErrInvalidSignature
+	}
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		return event, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return event, nil
+}
+
+// SignFakeWebhook returns the signature header value the fake provider sends
+// with body at time t.
+func SignFakeWebhook(secret string, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// next resolves the outcome of a call and turns it into an error.
+func (p *FakeProvider) next(ctx context.Context, fallback string) error {
+	p.mu.Lock()
+	outcome := fallback
+	if len(p.script) > 0 {
+		outcome, p.script = p.script[0], p.script[1:]
+	} else if p.outcome != "" && fallback == OutcomeSucceeded {
+		outcome = p.outcome
+	}
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return ErrTimeout
+	}
+	switch outcome {
+	case OutcomeDeclined:
+		return ErrDeclined
+	case OutcomeTimeout:
+		return ErrTimeout
+	case OutcomeFailed:
+		return fmt.Errorf("fake provider failure")
+	}
+	return nil
+}
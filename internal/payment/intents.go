@@ -0,0 +1,223 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Payment intent statuses. An intent stays pending when the provider did not
+// answer, its real outcome then arrives through a webhook.
+const (
+	IntentPending           = "pending"
+	IntentAuthorized        = "authorized"
+	IntentCaptured          = "captured"
+	IntentPartiallyRefunded = "partially_refunded"
+	IntentRefunded          = "refunded"
+	IntentVoided            = "voided"
+	IntentFailed            = "failed"
+)
+
+// Transaction kinds.
+const (
+	KindAuthorize = "authorize"
+	KindCapture   = "capture"
+	KindVoid      = "void"
+	KindRefund    = "refund"
+)
+
+var (
+	ErrProviderNotConfigured = errors.New("payment provider is not configured")
+	ErrInvalidAmount         = errors.New("invalid amount")
+	ErrInvalidIntentState    = errors.New("payment is not in a state that allows this operation")
+)
+
+// ForTenant builds the provider name from the credentials the tenant stored
+// for it.
+func ForTenant(db *gorm.DB, name string) (Provider, error) {
+	config := types.PaymentProviderConfig{}
+	if err := db.Where("provider = ? AND enabled = ?", name, true).First(&config).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProviderNotConfigured
+		}
+		return nil, err
+	}
+	creds := Credentials{}
+	if config.Credentials != "" {
+		if err := json.Unmarshal([]byte(config.Credentials), &creds); err != nil {
+			return nil, err
+		}
+	}
+	return New(name, creds)
+}
+
+// Authorize asks the provider to hold the intent amount on method.
+func Authorize(ctx context.Context, db *gorm.DB, provider Provider, intent *types.PaymentIntent, method string) error {
+	if intent.Status != IntentPending {
+		return ErrInvalidIntentState
+	}
+
+	res, err := provider.Authorize(ctx, AuthorizeRequest{
+		Amount:        intent.Amount,
+		Currency:      intent.Currency,
+		PaymentMethod: method,
+		Reference:     fmt.Sprintf("intent_%d", intent.ID),
+	})
+	if recordErr := record(db, intent, KindAuthorize, intent.Amount, res, err); recordErr != nil {
+		return recordErr
+	}
+
+	switch outcomeOf(err) {
+	case OutcomeSucceeded:
+		intent.ProviderReference = res.ProviderReference
+		if updateErr := setStatus(db, intent, IntentPending, IntentAuthorized, map[string]interface{}{
+			"provider_reference": res.ProviderReference,
+		}); updateErr != nil {
+			return updateErr
+		}
+	case OutcomeDeclined, OutcomeFailed:
+		if updateErr := setStatus(db, intent, IntentPending, IntentFailed, nil); updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// Capture collects amount of an authorized intent.
+func Capture(ctx context.Context, db *gorm.DB, provider Provider, intent *types.PaymentIntent, amount int64) error {
+	if intent.Status != IntentAuthorized {
+		return ErrInvalidIntentState
+	}
+	if amount <= 0 || amount > intent.Amount {
+		return ErrInvalidAmount
+	}
+
+	res, err := provider.Capture(ctx, intent.ProviderReference, amount)
+	if recordErr := record(db, intent, KindCapture, amount, res, err); recordErr != nil {
+		return recordErr
+	}
+	if err != nil {
+		return err
+	}
+
+	intent.AmountCaptured = amount
+	return setStatus(db, intent, IntentAuthorized, IntentCaptured, map[string]interface{}{
+		"amount_captured": amount,
+	})
+}
+
+// Void releases an authorized intent without collecting it.
+func Void(ctx context.Context, db *gorm.DB, provider Provider, intent *types.PaymentIntent) error {
+	if intent.Status != IntentAuthorized {
+		return ErrInvalidIntentState
+	}
+
+	res, err := provider.Void(ctx, intent.ProviderReference)
+	if recordErr := record(db, intent, KindVoid, intent.Amount, res, err); recordErr != nil {
+		return recordErr
+	}
+	if err != nil {
+		return err
+	}
+	return setStatus(db, intent, IntentAuthorized, IntentVoided, nil)
+}
+
+// Refund gives back amount of a captured intent. The amount is claimed
+// against the refundable balance before the provider is called, so two
+// concurrent refunds can never exceed what was captured. Only a refund the
+// provider declined hands the claim back: when it timed out or failed
+// otherwise the money may already be on its way, and the refunded webhook
+// settles the status of the intent.
+func Refund(ctx context.Context, db *gorm.DB, provider Provider, intent *types.PaymentIntent, amount int64) (types.PaymentTransaction, error) {
+	if intent.Status != IntentCaptured && intent.Status != IntentPartiallyRefunded {
+		return types.PaymentTransaction{}, ErrInvalidIntentState
+	}
+	if amount <= 0 {
+		return types.PaymentTransaction{}, ErrInvalidAmount
+	}
+
+	claim := db.Model(&types.PaymentIntent{}).
+		Where("id = ? AND amount_captured - amount_refunded >= ?", intent.ID, amount).
+		Update("amount_refunded", gorm.Expr("amount_refunded + ?", amount))
+	if claim.Error != nil {
+		return types.PaymentTransaction{}, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return types.PaymentTransaction{}, ErrInvalidAmount
+	}
+
+	res, err := provider.Refund(ctx, intent.ProviderReference, amount)
+	transaction := newTransaction(intent, KindRefund, amount, res, err)
+	if recordErr := db.Create(&transaction).Error; recordErr != nil {
+		return transaction, recordErr
+	}
+	if errors.Is(err, ErrDeclined) {
+		// the provider refused the refund, hand the claimed amount back
+		if releaseErr := db.Model(&types.PaymentIntent{}).
+			Where("id = ?", intent.ID).
+			Update("amount_refunded", gorm.Expr("amount_refunded - ?", amount)).Error; releaseErr != nil {
+			return transaction, releaseErr
+		}
+		return transaction, err
+	}
+	if err != nil {
+		// the refund may still go through, the claim stays until the
+		// provider's refunded webhook settles it
+		return transaction, err
+	}
+
+	if err := db.First(intent, intent.ID).Error; err != nil {
+		return transaction, err
+	}
+	status := IntentPartiallyRefunded
+	if intent.AmountRefunded >= intent.AmountCaptured {
+		status = IntentRefunded
+	}
+	if err := db.Model(intent).Update("status", status).Error; err != nil {
+		return transaction, err
+	}
+	return transaction, nil
+}
+
+// setStatus moves the intent from one status to another, leaving it alone
+// when something else changed it in the meantime.
+func setStatus(db *gorm.DB, intent *types.PaymentIntent, from, to string, extra map[string]interface{}) error {
+	updates := map[string]interface{}{"status": to}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	res := db.Model(&types.PaymentIntent{}).Where("id = ? AND status = ?", intent.ID, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidIntentState
+	}
+	intent.Status = to
+	return nil
+}
+
+func record(db *gorm.DB, intent *types.PaymentIntent, kind string, amount int64, res Result, err error) error {
+	transaction := newTransaction(intent, kind, amount, res, err)
+	return db.Create(&transaction).Error
+}
+
+func newTransaction(intent *types.PaymentIntent, kind string, amount int64, res Result, err error) types.PaymentTransaction {
+	message := res.Message
+	if err != nil {
+		message = err.Error()
+	}
+	return types.PaymentTransaction{
+		PaymentIntentID:   intent.ID,
+		Kind:              kind,
+		Status:            outcomeOf(err),
+		Amount:            amount,
+		Currency:          intent.Currency,
+		ProviderReference: res.ProviderReference,
+		Message:           message,
+	}
+}
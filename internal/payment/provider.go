@@ -0,0 +1,121 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrDeclined         = errors.New("payment declined")
+	ErrTimeout          = errors.New("payment provider timed out")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+)
+
+// Outcome of a provider call.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeDeclined  = "declined"
+	OutcomeTimeout   = "timeout"
+	OutcomeFailed    = "failed"
+)
+
+type (
+	// Credentials configure a provider for one tenant, e.g. api_key and
+	// webhook_secret.
+	Credentials map[string]string
+
+	// AuthorizeRequest asks the provider to hold Amount minor units of
+	// Currency on PaymentMethod, a provider specific token.
+	AuthorizeRequest struct {
+		Amount        int64
+		Currency      string
+		PaymentMethod string
+		Reference     string
+	}
+
+	// Result is the answer of the provider to a successful call.
+	Result struct {
+		ProviderReference string
+		Message           string
+	}
+
+	// WebhookEvent is a verified notification sent by the provider.
+	WebhookEvent struct {
+		ID                string `json:"id"`
+		Type              string `json:"type"`
+		ProviderReference string `json:"provider_reference"`
+		Amount            int64  `json:"amount"`
+		Currency          string `json:"currency"`
+	}
+
+	// Provider is a payment gateway. Calls return ErrDeclined when the
+	// provider refused the payment and ErrTimeout when no answer came back,
+	// in which case the outcome is unknown until a webhook arrives.
+	Provider interface {
+		Name() string
+		Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+		Capture(ctx context.Context, providerReference string, amount int64) (Result, error)
+		Void(ctx context.Context, providerReference string) (Result, error)
+		Refund(ctx context.Context, providerReference string, amount int64) (Result, error)
+		VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error)
+	}
+
+	// Factory builds a provider from tenant credentials.
+	Factory func(Credentials) (Provider, error)
+)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		FakeProviderName: func(creds Credentials) (Provider, error) { return NewFakeProvider(creds), nil },
+	}
+)
+
+// Register makes a provider available under name, replacing any provider
+// registered under the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// New builds the provider registered under name.
+func New(name string, creds Credentials) (Provider, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return factory(creds)
+}
+
+// Providers returns the names of all registered providers.
+func Providers() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// outcomeOf maps a provider error onto the outcome recorded for a call.
+func outcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSucceeded
+	case errors.Is(err, ErrDeclined):
+		return OutcomeDeclined
+	case errors.Is(err, ErrTimeout):
+		return OutcomeTimeout
+	}
+	return OutcomeFailed
+}
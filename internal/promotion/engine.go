@@ -0,0 +1,404 @@
+package promotion
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+type (
+	// Line is a priced cart line the engine can discount.
+	Line struct {
+		VariantID uint
+		ProductID uint
+		Quantity  int
+		UnitPrice int64
+		LineTotal int64
+	}
+	// Input is what promotions are evaluated against. Customer limits are
+	// only checked once the customer is known by UserID or Email. Lines are
+	// priced in Currency, the base currency when empty.
+	Input struct {
+		Lines    []Line
+		UserID   *uint
+		Email    string
+		Codes    []string
+		Currency string
+		Now      time.Time
+	}
+	// Applied is a promotion applied to the cart and what it took off.
+	Applied struct {
+		PromotionID  uint   `json:"promotion_id"`
+		Name         string `json:"name"`
+		Code         string `json:"code,omitempty"`
+		Kind         string `json:"kind"`
+		Description  string `json:"description"`
+		Amount       int64  `json:"amount"`
+		FreeShipping bool   `json:"free_shipping,omitempty"`
+	}
+	// Rejected is an entered code that could not be applied, and why.
+	Rejected struct {
+		Code   string `json:"code"`
+		Reason string `json:"reason"`
+	}
+	// Result is the outcome of evaluating promotions. LineDiscounts holds
+	// the discount of every input line, in input order.
+	Result struct {
+		Applied       []Applied  `json:"applied"`
+		Rejected      []Rejected `json:"rejected,omitempty"`
+		DiscountTotal int64      `json:"discount_total"`
+		FreeShipping  bool       `json:"free_shipping"`
+		LineDiscounts []int64    `json:"-"`
+	}
+)
+
+// Evaluate applies the eligible automatic promotions and entered coupons to
+// the lines. Promotions are tried by descending priority, then by age, so the
+// outcome never depends on the order codes were entered in. Every promotion
+// discounts what previous ones left, so the total never exceeds the
+// subtotal. A promotion that is not stackable is only applied on its own.
+func Evaluate(db *gorm.DB, in Input) (Result, error) {
+	res := Result{Applied: []Applied{}, LineDiscounts: make([]int64, len(in.Lines))}
+	if in.Now.IsZero() {
+		in.Now = time.Now()
+	}
+
+	var subtotal int64
+	for _, line := range in.Lines {
+		subtotal += line.LineTotal
+	}
+
+	var candidates []types.Promotion
+	if err := db.Where("code IS NULL AND active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", in.Now).
+		Where("ends_at IS NULL OR ends_at > ?", in.Now).
+		Find(&candidates).Error; err != nil {
+		return res, err
+	}
+
+	codes := map[string]bool{}
+	for _, code := range in.Codes {
+		codes[NormalizeCode(code)] = true
+	}
+	if len(codes) > 0 {
+		keys := make([]string, 0, len(codes))
+		for code := range codes {
+			keys = append(keys, code)
+		}
+		var coupons []types.Promotion
+		if err := db.Where("code IN ?", keys).Find(&coupons).Error; err != nil {
+			return res, err
+		}
+		for _, coupon := range coupons {
+			delete(codes, *coupon.Code)
+		}
+		for code := range codes {
+			res.Rejected = append(res.Rejected, Rejected{Code: code, Reason: "unknown code"})
+		}
+		candidates = append(candidates, coupons...)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority > candidates[j].Priority
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	sort.Slice(res.Rejected, func(i, j int) bool { return res.Rejected[i].Code < res.Rejected[j].Code })
+
+	facts, err := loadFacts(db, in.Lines)
+	if err != nil {
+		return res, err
+	}
+	conv, err := currency.FromBase(db, in.Currency)
+	if err != nil {
+		return res, err
+	}
+
+	remaining := make([]int64, len(in.Lines))
+	for i, line := range in.Lines {
+		remaining[i] = line.LineTotal
+	}
+	exclusive := false
+
+	for _, p := range candidates {
+		p = localize(p, conv)
+		reason, err := ineligible(db, p, in, subtotal)
+		if err != nil {
+			return res, err
+		}
+		if reason == "" && (exclusive || (!p.Stackable && len(res.Applied) > 0)) {
+			reason = "cannot be combined with other promotions"
+		}
+
+		var discounts []int64
+		if reason == "" {
+			discounts, err = compute(p, in.Lines, remaining, facts)
+			if err != nil {
+				return res, err
+			}
+			if sum(discounts) == 0 && p.Kind != KindFreeShipping {
+				reason = "no eligible items in the cart"
+			}
+		}
+		if reason != "" {
+			if p.Code != nil {
+				res.Rejected = append(res.Rejected, Rejected{Code: *p.Code, Reason: reason})
+			}
+			continue
+		}
+
+		applied := Applied{
+			PromotionID: p.ID,
+			Name:        p.Name,
+			Kind:        p.Kind,
+			Description: describe(p),
+			Amount:      sum(discounts),
+		}
+		if p.Code != nil {
+			applied.Code = *p.Code
+		}
+		if p.Kind == KindFreeShipping {
+			applied.FreeShipping = true
+			res.FreeShipping = true
+		}
+		for i, d := range discounts {
+			remaining[i] -= d
+			res.LineDiscounts[i] += d
+		}
+		res.Applied = append(res.Applied, applied)
+		res.DiscountTotal += applied.Amount
+		exclusive = !p.Stackable
+	}
+	return res, nil
+}
+
+// localize converts the amounts of p, set in the base currency, into the
+// currency of the cart.
+func localize(p types.Promotion, conv currency.Converter) types.Promotion {
+	p.MinSubtotal = conv.Convert(p.MinSubtotal)
+	if p.Kind == KindFixedAmount {
+		p.Value = conv.Convert(p.Value)
+	}
+	if p.Kind == KindTiered {
+		tiers, err := ParseTiers(p.Tiers)
+		if err != nil {
+			return p
+		}
+		for i := range tiers {
+			tiers[i].MinSubtotal = conv.Convert(tiers[i].MinSubtotal)
+			tiers[i].Amount = conv.Convert(tiers[i].Amount)
+		}
+		if raw, err := json.Marshal(tiers); err == nil {
+			p.Tiers = string(raw)
+		}
+	}
+	return p
+}
+
+// ineligible returns why p cannot be applied, or an empty string when it can.
+func ineligible(db *gorm.DB, p types.Promotion, in Input, subtotal int64) (string, error) {
+	switch {
+	case !p.Active:
+		return "promotion is not active", nil
+	case p.StartsAt != nil && in.Now.Before(*p.StartsAt):
+		return "promotion has not started", nil
+	case p.EndsAt != nil && !in.Now.Before(*p.EndsAt):
+		return "promotion has ended", nil
+	case p.UsageLimit > 0 && p.UsageCount >= p.UsageLimit:
+		return "usage limit reached", nil
+	case subtotal < p.MinSubtotal:
+		return "requires a minimum order of " + formatAmount(p.MinSubtotal), nil
+	}
+
+	if p.PerCustomer > 0 && (in.UserID != nil || in.Email != "") {
+		used, err := customerUses(db, p.ID, in.UserID, in.Email)
+		if err != nil {
+			return "", err
+		}
+		if used >= int64(p.PerCustomer) {
+			return "already used the maximum number of times", nil
+		}
+	}
+	return "", nil
+}
+
+// compute returns the discount p gives each line given what is left of it.
+func compute(p types.Promotion, lines []Line, remaining []int64, facts map[uint]catalog.ProductFacts) ([]int64, error) {
+	discounts := make([]int64, len(lines))
+
+	eligible := make([]bool, len(lines))
+	var eligibleTotal int64
+	var rules catalog.RuleSet
+	if strings.TrimSpace(p.Conditions) != "" {
+		var err error
+		if rules, err = catalog.ParseRules(p.Conditions); err != nil {
+			return nil, err
+		}
+	}
+	for i, line := range lines {
+		eligible[i] = remaining[i] > 0 && (rules == nil || rules.Match(facts[line.ProductID]))
+		if eligible[i] {
+			eligibleTotal += remaining[i]
+		}
+	}
+
+	switch p.Kind {
+	case KindPercentage:
+		percentOff(discounts, eligible, remaining, p.Value)
+	case KindFixedAmount:
+		amountOff(discounts, eligible, remaining, eligibleTotal, p.Value)
+	case KindBuyXGetY:
+		buyXGetY(discounts, lines, eligible, remaining, p)
+	case KindTiered:
+		tiers, err := ParseTiers(p.Tiers)
+		if err != nil {
+			return nil, err
+		}
+		var best *Tier
+		for i := range tiers {
+			if eligibleTotal >= tiers[i].MinSubtotal {
+				best = &tiers[i]
+			}
+		}
+		if best == nil {
+			break
+		}
+		if best.Percentage > 0 {
+			percentOff(discounts, eligible, remaining, best.Percentage)
+		} else {
+			amountOff(discounts, eligible, remaining, eligibleTotal, best.Amount)
+		}
+	}
+	return discounts, nil
+}
+
+// percentOff takes percent off every eligible line, rounding half up.
+func percentOff(discounts []int64, eligible []bool, remaining []int64, percent int64) {
+	for i := range discounts {
+		if eligible[i] {
+			discounts[i] = min((remaining[i]*percent+50)/100, remaining[i])
+		}
+	}
+}
+
+// amountOff spreads amount over the eligible lines in proportion to their
+// value, the rounding remainder going to the first lines.
+func amountOff(discounts []int64, eligible []bool, remaining []int64, eligibleTotal, amount int64) {
+	if eligibleTotal == 0 {
+		return
+	}
+	amount = min(amount, eligibleTotal)
+	left := amount
+	for i := range discounts {
+		if eligible[i] {
+			discounts[i] = amount * remaining[i] / eligibleTotal
+			left -= discounts[i]
+		}
+	}
+	for i := range discounts {
+		if left == 0 {
+			break
+		}
+		if eligible[i] && discounts[i] < remaining[i] {
+			discounts[i]++
+			left--
+		}
+	}
+}
+
+// buyXGetY lines the eligible units up from the most to the least expensive
+// and, in every group of BuyQuantity + GetQuantity units, discounts the last
+// GetQuantity ones by Value percent. The customer always gets the cheaper
+// units for less.
+func buyXGetY(discounts []int64, lines []Line, eligible []bool, remaining []int64, p types.Promotion) {
+	type unit struct {
+		line  int
+		price int64
+	}
+	var units []unit
+	for i, line := range lines {
+		if !eligible[i] || line.Quantity == 0 {
+			continue
+		}
+		price := remaining[i] / int64(line.Quantity)
+		for q := 0; q < line.Quantity; q++ {
+			units = append(units, unit{line: i, price: price})
+		}
+	}
+	sort.SliceStable(units, func(i, j int) bool { return units[i].price > units[j].price })
+
+	group := p.BuyQuantity + p.GetQuantity
+	for start := 0; start+group <= len(units); start += group {
+		for _, u := range units[start+p.BuyQuantity : start+group] {
+			off := (u.price*p.Value + 50) / 100
+			discounts[u.line] = min(discounts[u.line]+off, remaining[u.line])
+		}
+	}
+}
+
+func describe(p types.Promotion) string {
+	switch p.Kind {
+	case KindPercentage:
+		return fmt.Sprintf("%d%% off", p.Value)
+	case KindFixedAmount:
+		return formatAmount(p.Value) + " off"
+	case KindBuyXGetY:
+		if p.Value == 100 {
+			return fmt.Sprintf("buy %d, get %d free", p.BuyQuantity, p.GetQuantity)
+		}
+		return fmt.Sprintf("buy %d, get %d at %d%% off", p.BuyQuantity, p.GetQuantity, p.Value)
+	case KindFreeShipping:
+		return "free shipping"
+	case KindTiered:
+		tiers, _ := ParseTiers(p.Tiers)
+		parts := make([]string, 0, len(tiers))
+		for _, tier := range tiers {
+			off := formatAmount(tier.Amount)
+			if tier.Percentage > 0 {
+				off = fmt.Sprintf("%d%%", tier.Percentage)
+			}
+			parts = append(parts, fmt.Sprintf("spend %s, get %s off", formatAmount(tier.MinSubtotal), off))
+		}
+		return strings.Join(parts, "; ")
+	}
+	return p.Name
+}
+
+// formatAmount writes minor units as a decimal amount, e.g. 1050 as 10.50.
+func formatAmount(amount int64) string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
+
+func loadFacts(db *gorm.DB, lines []Line) (map[uint]catalog.ProductFacts, error) {
+	facts := map[uint]catalog.ProductFacts{}
+	ids := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ProductID)
+	}
+	if len(ids) == 0 {
+		return facts, nil
+	}
+	var products []types.Product
+	if err := db.Preload("Tags").Preload("Variants").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	for _, product := range products {
+		facts[product.ID] = catalog.FactsFor(product)
+	}
+	return facts, nil
+}
+
+func sum(values []int64) int64 {
+	var total int64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
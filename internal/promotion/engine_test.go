@@ -0,0 +1,120 @@
+package promotion
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+// line prices quantity units at unit.
+func line(quantity int, unit int64) Line {
+	return Line{Quantity: quantity, UnitPrice: unit, LineTotal: int64(quantity) * unit}
+}
+
+func TestCompute(t *testing.T) {
+	tiers := `[{"min_subtotal":10000,"amount":2000},{"min_subtotal":5000,"percentage":10}]`
+	cases := []struct {
+		name      string
+		promotion types.Promotion
+		lines     []Line
+		remaining []int64
+		want      []int64
+	}{
+		{"percentage rounds half up", types.Promotion{Kind: KindPercentage, Value: 10},
+			[]Line{line(1, 1005), line(2, 1000)}, nil, []int64{101, 200}},
+		{"percentage of what is left", types.Promotion{Kind: KindPercentage, Value: 50},
+			[]Line{line(1, 1000), line(1, 1000)}, []int64{0, 300}, []int64{0, 150}},
+		{"fixed amount spread by value", types.Promotion{Kind: KindFixedAmount, Value: 1000},
+			[]Line{line(1, 1000), line(1, 2000)}, nil, []int64{334, 666}},
+		{"fixed amount capped at the lines", types.Promotion{Kind: KindFixedAmount, Value: 5000},
+			[]Line{line(1, 1000), line(1, 2000)}, nil, []int64{1000, 2000}},
+		{"buy two get the cheapest free", types.Promotion{Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			[]Line{line(2, 1000), line(1, 500)}, nil, []int64{0, 500}},
+		{"buy one get one half off", types.Promotion{Kind: KindBuyXGetY, BuyQuantity: 1, GetQuantity: 1, Value: 50},
+			[]Line{line(3, 1000)}, nil, []int64{500}},
+		{"buy x get y needs a full group", types.Promotion{Kind: KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Value: 100},
+			[]Line{line(2, 1000)}, nil, []int64{0}},
+		{"tier below every threshold", types.Promotion{Kind: KindTiered, Tiers: tiers},
+			[]Line{line(1, 4000)}, nil, []int64{0}},
+		{"percentage tier", types.Promotion{Kind: KindTiered, Tiers: tiers},
+			[]Line{line(1, 6000)}, nil, []int64{600}},
+		{"highest tier reached", types.Promotion{Kind: KindTiered, Tiers: tiers},
+			[]Line{line(1, 4000), line(1, 8000)}, nil, []int64{667, 1333}},
+	}
+	for _, c := range cases {
+		remaining := c.remaining
+		if remaining == nil {
+			for _, l := range c.lines {
+				remaining = append(remaining, l.LineTotal)
+			}
+		}
+		got, err := compute(c.promotion, c.lines, remaining, nil)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: discounts %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestIneligible(t *testing.T) {
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	active := types.Promotion{Kind: KindPercentage, Value: 10, Active: true}
+	with := func(change func(p *types.Promotion)) types.Promotion {
+		p := active
+		change(&p)
+		return p
+	}
+	cases := []struct {
+		name      string
+		promotion types.Promotion
+		subtotal  int64
+		want      string
+	}{
+		{"eligible", active, 1000, ""},
+		{"inactive", with(func(p *types.Promotion) { p.Active = false }), 1000, "promotion is not active"},
+		{"not started", with(func(p *types.Promotion) { p.StartsAt = &later }), 1000, "promotion has not started"},
+		{"ended", with(func(p *types.Promotion) { p.EndsAt = &now }), 1000, "promotion has ended"},
+		{"running", with(func(p *types.Promotion) { p.StartsAt, p.EndsAt = &earlier, &later }), 1000, ""},
+		{"used up", with(func(p *types.Promotion) { p.UsageLimit, p.UsageCount = 5, 5 }), 1000, "usage limit reached"},
+		{"below minimum", with(func(p *types.Promotion) { p.MinSubtotal = 1050 }), 1000, "requires a minimum order of 10.50"},
+		{"at minimum", with(func(p *types.Promotion) { p.MinSubtotal = 1000 }), 1000, ""},
+	}
+	for _, c := range cases {
+		got, err := ineligible(nil, c.promotion, Input{Now: now}, c.subtotal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want []Tier
+		err  error
+	}{
+		{"", []Tier{}, nil},
+		{`[{"min_subtotal":5000,"amount":500},{"min_subtotal":1000,"percentage":5}]`,
+			[]Tier{{MinSubtotal: 1000, Percentage: 5}, {MinSubtotal: 5000, Amount: 500}}, nil},
+		{`[{"min_subtotal":1000,"amount":500,"percentage":5}]`, nil, ErrInvalidTiers},
+		{`[{"min_subtotal":1000}]`, nil, ErrInvalidTiers},
+		{`[{"min_subtotal":1000,"percentage":101}]`, nil, ErrInvalidTiers},
+		{`{}`, nil, ErrInvalidTiers},
+	}
+	for _, c := range cases {
+		got, err := ParseTiers(c.raw)
+		if !errors.Is(err, c.err) || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: %v %v, want %v %v", c.raw, got, err, c.want, c.err)
+		}
+	}
+}
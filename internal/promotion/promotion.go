@@ -0,0 +1,99 @@
+package promotion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+// Promotion kinds. Value is a whole percentage for KindPercentage, an amount
+// in minor units for KindFixedAmount and the percentage taken off the free
+// units for KindBuyXGetY (100 makes them free).
+const (
+	KindPercentage   = "percentage"
+	KindFixedAmount  = "fixed_amount"
+	KindBuyXGetY     = "buy_x_get_y"
+	KindFreeShipping = "free_shipping"
+	KindTiered       = "tiered"
+)
+
+var (
+	ErrInvalidKind  = errors.New("invalid promotion kind")
+	ErrInvalidValue = errors.New("invalid promotion value")
+	ErrInvalidTiers = errors.New("invalid promotion tiers")
+)
+
+// Tier is a spend threshold of a tiered promotion. Reaching MinSubtotal
+// takes Percentage percent or Amount off, whichever is set.
+type Tier struct {
+	MinSubtotal int64 `json:"min_subtotal"`
+	Percentage  int64 `json:"percentage"`
+	Amount      int64 `json:"amount"`
+}
+
+// Validate checks that the settings of p make sense for its kind.
+func Validate(p types.Promotion) error {
+	switch p.Kind {
+	case KindPercentage:
+		if p.Value <= 0 || p.Value > 100 {
+			return ErrInvalidValue
+		}
+	case KindFixedAmount:
+		if p.Value <= 0 {
+			return ErrInvalidValue
+		}
+	case KindBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 || p.Value <= 0 || p.Value > 100 {
+			return ErrInvalidValue
+		}
+	case KindFreeShipping:
+	case KindTiered:
+		tiers, err := ParseTiers(p.Tiers)
+		if err != nil {
+			return err
+		}
+		if len(tiers) == 0 {
+			return ErrInvalidTiers
+		}
+	default:
+		return ErrInvalidKind
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidValue)
+	}
+	if strings.TrimSpace(p.Conditions) != "" {
+		if _, err := catalog.ParseRules(p.Conditions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTiers decodes the tiers of a tiered promotion, ordered by threshold.
+func ParseTiers(raw string) ([]Tier, error) {
+	tiers := []Tier{}
+	if strings.TrimSpace(raw) == "" {
+		return tiers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil {
+		return nil, ErrInvalidTiers
+	}
+	for _, tier := range tiers {
+		if tier.MinSubtotal < 0 || tier.Amount < 0 || tier.Percentage < 0 || tier.Percentage > 100 ||
+			(tier.Amount == 0) == (tier.Percentage == 0) {
+			return nil, ErrInvalidTiers
+		}
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinSubtotal < tiers[j].MinSubtotal })
+	return tiers, nil
+}
+
+// NormalizeCode returns the canonical form coupon codes are stored in.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
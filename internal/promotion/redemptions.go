@@ -0,0 +1,87 @@
+package promotion
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+var ErrUsageLimitReached = errors.New("promotion usage limit reached")
+
+// Redeem records the promotions applied to an order and counts their use.
+// The usage count is raised with a conditional update, so a limited code
+// cannot be used more often than allowed by concurrent checkouts. Call it
+// inside the checkout transaction.
+func Redeem(tx *gorm.DB, applied []Applied, o types.Order) error {
+	for _, a := range applied {
+		res := tx.Model(&types.Promotion{}).
+			Where("id = ? AND (usage_limit = 0 OR usage_count < usage_limit)", a.PromotionID).
+			Update("usage_count", gorm.Expr("usage_count + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUsageLimitReached
+		}
+
+		p := types.Promotion{}
+		if err := tx.First(&p, a.PromotionID).Error; err != nil {
+			return err
+		}
+		if p.PerCustomer > 0 {
+			used, err := customerUses(tx, p.ID, o.UserID, o.Email)
+			if err != nil {
+				return err
+			}
+			if used >= int64(p.PerCustomer) {
+				return ErrUsageLimitReached
+			}
+		}
+
+		if err := tx.Create(&types.PromotionRedemption{
+			PromotionID: a.PromotionID,
+			OrderID:     o.ID,
+			UserID:      o.UserID,
+			Email:       o.Email,
+			Amount:      a.Amount,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release gives back the uses of the promotions redeemed by an order, e.g.
+// when it is cancelled before payment.
+func Release(tx *gorm.DB, orderID uint) error {
+	var redemptions []types.PromotionRedemption
+	if err := tx.Where("order_id = ?", orderID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for _, r := range redemptions {
+		if err := tx.Model(&types.Promotion{}).
+			Where("id = ? AND usage_count > 0", r.PromotionID).
+			Update("usage_count", gorm.Expr("usage_count - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("order_id = ?", orderID).Delete(&types.PromotionRedemption{}).Error
+}
+
+// customerUses counts the redemptions of a promotion by a customer, matched
+// by account or by email.
+func customerUses(db *gorm.DB, promotionID uint, userID *uint, email string) (int64, error) {
+	query := db.Model(&types.PromotionRedemption{}).Where("promotion_id = ?", promotionID)
+	switch {
+	case userID != nil && email != "":
+		query = query.Where("user_id = ? OR LOWER(email) = LOWER(?)", *userID, email)
+	case userID != nil:
+		query = query.Where("user_id = ?", *userID)
+	default:
+		query = query.Where("LOWER(email) = LOWER(?)", email)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
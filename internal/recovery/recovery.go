@@ -0,0 +1,307 @@
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/notification"
+	"github.com/Satishcg12/multicommers/internal/promotion"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/dotenv"
+	"github.com/Satishcg12/multicommers/utils/email"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// Recovery statuses. An active sequence has reminders left to send, a
+// completed one sent them all. Either is recovered when its cart is checked
+// out, or stopped when the cart is gone or the customer ordered otherwise.
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusRecovered = "recovered"
+	StatusStopped   = "stopped"
+)
+
+// MaxSteps bounds the reminders of a sequence.
+const MaxSteps = 5
+
+// batchSize bounds the reminders a tenant sends per run.
+const batchSize = 100
+
+var ErrInvalidSteps = errors.New("invalid recovery steps")
+
+// Step is one reminder of the sequence, sent DelayMinutes after the cart was
+// last touched. A DiscountPercent above zero adds a single use discount code.
+type Step struct {
+	DelayMinutes    int   `json:"delay_minutes"`
+	DiscountPercent int64 `json:"discount_percent"`
+}
+
+// DefaultSteps remind after an hour, a day and three days, the last with a
+// 10% discount.
+var DefaultSteps = []Step{
+	{DelayMinutes: 60},
+	{DelayMinutes: 24 * 60},
+	{DelayMinutes: 72 * 60, DiscountPercent: 10},
+}
+
+// Settings returns the recovery settings of the tenant, or the defaults when
+// none were saved: reminders are off.
+func Settings(db *gorm.DB) (types.RecoverySettings, error) {
+	settings := types.RecoverySettings{}
+	err := db.Order("id").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.RecoverySettings{CodeValidHours: 72}, nil
+	}
+	return settings, err
+}
+
+// ParseSteps decodes the reminder sequence of the settings, DefaultSteps
+// when none is set. Delays must grow from one step to the next.
+func ParseSteps(raw string) ([]Step, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultSteps, nil
+	}
+	steps := []Step{}
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, ErrInvalidSteps
+	}
+	if len(steps) == 0 || len(steps) > MaxSteps {
+		return nil, fmt.Errorf("%w: between 1 and %d steps are allowed", ErrInvalidSteps, MaxSteps)
+	}
+	for i, step := range steps {
+		if step.DelayMinutes <= 0 || (i > 0 && step.DelayMinutes <= steps[i-1].DelayMinutes) {
+			return nil, fmt.Errorf("%w: delays must be positive and increasing", ErrInvalidSteps)
+		}
+		if step.DiscountPercent < 0 || step.DiscountPercent > 100 {
+			return nil, fmt.Errorf("%w: discount_percent must be between 0 and 100", ErrInvalidSteps)
+		}
+	}
+	return steps, nil
+}
+
+// Run settles the sequences of carts that were checked out or dropped, then,
+// when reminders are enabled, starts sequences for newly idle carts and
+// sends the reminders that are due. It returns the number of emails sent.
+func Run(db *gorm.DB, mailer email.EmailDaemonInterface, tenantID string, now time.Time) (int, error) {
+	if err := settle(db, now); err != nil {
+		return 0, err
+	}
+	settings, err := Settings(db)
+	if err != nil || !settings.Enabled {
+		return 0, err
+	}
+	steps, err := ParseSteps(settings.Steps)
+	if err != nil {
+		return 0, err
+	}
+	if err := start(db, steps[0], now); err != nil {
+		return 0, err
+	}
+
+	var recoveries []types.CartRecovery
+	if err := db.Joins("JOIN carts ON carts.id = cart_recoveries.cart_id").
+		Where("cart_recoveries.status = ? AND carts.status = ?", StatusActive, cart.StatusOpen).
+		Order("carts.last_activity_at").
+		Limit(batchSize).
+		Find(&recoveries).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range recoveries {
+		if r.Step >= len(steps) {
+			// the sequence was shortened since it started
+			if err := db.Model(&r).Update("status", StatusCompleted).Error; err != nil {
+				return sent, err
+			}
+			continue
+		}
+		c := types.Cart{}
+		if err := db.Preload("Items").First(&c, r.CartID).Error; err != nil {
+			return sent, err
+		}
+		if now.Before(c.LastActivityAt.Add(time.Duration(steps[r.Step].DelayMinutes) * time.Minute)) {
+			continue
+		}
+		ok, err := send(db, mailer, tenantID, settings, r, c, steps[r.Step], r.Step+1 == len(steps), now)
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send delivers the next reminder of r, the last one of the sequence when
+// last is set. The step is claimed with a conditional update first so a
+// reminder is never sent twice, and nothing is sent to a customer who
+// ordered since the cart went idle.
+func send(db *gorm.DB, mailer email.EmailDaemonInterface, tenantID string, settings types.RecoverySettings, r types.CartRecovery, c types.Cart, step Step, last bool, now time.Time) (bool, error) {
+	ordered, err := orderedSince(db, r.Email, c.UserID, c.LastActivityAt)
+	if err != nil {
+		return false, err
+	}
+	if ordered {
+		return false, stop(db, r.ID, StatusStopped, nil, now)
+	}
+	summary, err := cart.Calculate(db, c)
+	if err != nil {
+		return false, err
+	}
+	if len(summary.Lines) == 0 {
+		return false, stop(db, r.ID, StatusStopped, nil, now)
+	}
+
+	message := types.RecoveryEmail{RecoveryID: r.ID, Step: r.Step + 1, Token: randomString.GenerateSecureToken(16), SentAt: now}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		status := StatusActive
+		if last {
+			status = StatusCompleted
+		}
+		res := tx.Model(&types.CartRecovery{}).
+			Where("id = ? AND step = ? AND status = ?", r.ID, r.Step, StatusActive).
+			Updates(map[string]interface{}{"step": r.Step + 1, "status": status})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errClaimed
+		}
+		if step.DiscountPercent > 0 {
+			p, err := discountCode(tx, r, step, settings.CodeValidHours, now)
+			if err != nil {
+				return err
+			}
+			message.PromotionID = &p.ID
+			message.DiscountCode = *p.Code
+		}
+		return tx.Create(&message).Error
+	})
+	if errors.Is(err, errClaimed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	mailer.Send(notification.CartReminder(r.Email, summary, notification.CartReminderLinks{
+		Cart:  link(tenantID, "/api/store/recovery/"+message.Token),
+		Pixel: link(tenantID, "/api/store/recovery/"+message.Token+"/open.gif"),
+		Code:  message.DiscountCode,
+	}, tenantID))
+	return true, nil
+}
+
+var errClaimed = errors.New("reminder already sent")
+
+// start opens a sequence for every open cart with items and a known email
+// that has been idle for the first delay and has none yet.
+func start(db *gorm.DB, first Step, now time.Time) error {
+	var carts []struct {
+		ID        uint
+		Email     string
+		UserEmail string
+	}
+	if err := db.Model(&types.Cart{}).
+		Select("carts.id, carts.email, COALESCE(users.email, '') AS user_email").
+		Joins("LEFT JOIN users ON users.id = carts.user_id").
+		Where("carts.status = ? AND carts.last_activity_at <= ?", cart.StatusOpen, now.Add(-time.Duration(first.DelayMinutes)*time.Minute)).
+		Where("(carts.email <> '' OR users.email IS NOT NULL)").
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id)").
+		Where("NOT EXISTS (SELECT 1 FROM cart_recoveries WHERE cart_recoveries.cart_id = carts.id)").
+		Limit(batchSize).
+		Scan(&carts).Error; err != nil {
+		return err
+	}
+	for _, c := range carts {
+		address := c.UserEmail
+		if address == "" {
+			address = c.Email
+		}
+		r := types.CartRecovery{CartID: c.ID, Email: address, Status: StatusActive}
+		if err := db.Create(&r).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settle closes the open sequences whose cart is no longer open. A cart
+// checked out after a reminder was sent is recovered and its order
+// attributed to the campaign.
+func settle(db *gorm.DB, now time.Time) error {
+	var recoveries []types.CartRecovery
+	if err := db.Where("status IN ?", []string{StatusActive, StatusCompleted}).
+		Where("NOT EXISTS (SELECT 1 FROM carts WHERE carts.id = cart_recoveries.cart_id AND carts.status = ?)", cart.StatusOpen).
+		Find(&recoveries).Error; err != nil {
+		return err
+	}
+	for _, r := range recoveries {
+		var orderIDs []uint
+		if err := db.Model(&types.Order{}).Where("cart_id = ?", r.CartID).Order("id").Limit(1).Pluck("id", &orderIDs).Error; err != nil {
+			return err
+		}
+		if len(orderIDs) > 0 && r.Step > 0 {
+			if err := stop(db, r.ID, StatusRecovered, &orderIDs[0], now); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := stop(db, r.ID, StatusStopped, nil, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stop(db *gorm.DB, id uint, status string, orderID *uint, now time.Time) error {
+	return db.Model(&types.CartRecovery{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "order_id": orderID, "stopped_at": now}).Error
+}
+
+// orderedSince reports whether the customer placed any order after since.
+func orderedSince(db *gorm.DB, address string, userID *uint, since time.Time) (bool, error) {
+	query := db.Model(&types.Order{}).Where("created_at > ?", since)
+	if userID != nil {
+		query = query.Where("(email = ? OR user_id = ?)", address, *userID)
+	} else {
+		query = query.Where("email = ?", address)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// discountCode creates the single use coupon of a reminder.
+func discountCode(tx *gorm.DB, r types.CartRecovery, step Step, validHours int, now time.Time) (types.Promotion, error) {
+	code := promotion.NormalizeCode("BACK-" + randomString.GenerateSecureToken(4))
+	ends := now.Add(time.Duration(validHours) * time.Hour)
+	p := types.Promotion{
+		Name:        fmt.Sprintf("Cart recovery %d", r.CartID),
+		Code:        &code,
+		Kind:        promotion.KindPercentage,
+		Value:       step.DiscountPercent,
+		StartsAt:    &now,
+		EndsAt:      &ends,
+		UsageLimit:  1,
+		PerCustomer: 1,
+		Active:      true,
+	}
+	return p, tx.Create(&p).Error
+}
+
+// link makes an absolute URL of path for the tenant. PUBLIC_URL is where
+// customers reach the API, a {tenant} placeholder in it is replaced by the
+// tenant's id.
+func link(tenantID, path string) string {
+	base := strings.ReplaceAll(dotenv.GetEnvOrDefault("PUBLIC_URL", ""), "{tenant}", tenantID)
+	return strings.TrimSuffix(base, "/") + path
+}
@@ -0,0 +1,116 @@
+package recovery
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+var ErrEmailNotFound = errors.New("recovery email not found")
+
+// Open counts a load of the tracking pixel of the reminder with token.
+func Open(db *gorm.DB, token string, now time.Time) error {
+	return db.Model(&types.RecoveryEmail{}).Where("token = ?", token).
+		Updates(map[string]interface{}{
+			"opens":     gorm.Expr("opens + 1"),
+			"opened_at": gorm.Expr("COALESCE(opened_at, ?)", now),
+		}).Error
+}
+
+// Click counts a click on the cart link of the reminder with token and
+// returns the cart it restores, with the reminder's discount code entered.
+// A cart that was checked out or removed since yields cart.ErrCartNotFound.
+func Click(db *gorm.DB, token string, now time.Time) (types.RecoveryEmail, types.Cart, error) {
+	message := types.RecoveryEmail{}
+	if err := db.Where("token = ?", token).First(&message).Error; err != nil {
+		return message, types.Cart{}, ErrEmailNotFound
+	}
+	// an opened link proves the email was opened, even with images blocked
+	if err := db.Model(&message).Updates(map[string]interface{}{
+		"clicks":     gorm.Expr("clicks + 1"),
+		"clicked_at": gorm.Expr("COALESCE(clicked_at, ?)", now),
+		"opened_at":  gorm.Expr("COALESCE(opened_at, ?)", now),
+	}).Error; err != nil {
+		return message, types.Cart{}, err
+	}
+
+	r := types.CartRecovery{}
+	if err := db.First(&r, message.RecoveryID).Error; err != nil {
+		return message, types.Cart{}, err
+	}
+	c := types.Cart{}
+	if err := db.Preload("Items").Where("status = ?", cart.StatusOpen).First(&c, r.CartID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return message, c, cart.ErrCartNotFound
+		}
+		return message, c, err
+	}
+	if message.DiscountCode != "" {
+		if err := cart.ApplyCode(db, &c, message.DiscountCode); err != nil && !errors.Is(err, cart.ErrUnknownCode) {
+			return message, c, err
+		}
+	}
+	return message, c, nil
+}
+
+// StepStats counts the reminders of one step of the sequence and how they
+// were received.
+type StepStats struct {
+	Step    int   `json:"step"`
+	Sent    int64 `json:"sent"`
+	Opened  int64 `json:"opened"`
+	Clicked int64 `json:"clicked"`
+}
+
+// Report sums up the campaign. Carts counts the sequences that sent at least
+// one reminder, Revenue is the base currency total of the paid orders they
+// recovered.
+type Report struct {
+	Carts     int64       `json:"carts"`
+	Recovered int64       `json:"recovered"`
+	Revenue   int64       `json:"revenue"`
+	Steps     []StepStats `json:"steps"`
+}
+
+// Summarize reports on the sequences started in [from, to), either bound may
+// be zero.
+func Summarize(db *gorm.DB, from, to time.Time) (Report, error) {
+	report := Report{Steps: []StepStats{}}
+	recoveries := db.Model(&types.CartRecovery{})
+	if !from.IsZero() {
+		recoveries = recoveries.Where("cart_recoveries.created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		recoveries = recoveries.Where("cart_recoveries.created_at < ?", to)
+	}
+
+	if err := recoveries.Session(&gorm.Session{}).Where("cart_recoveries.step > 0").Count(&report.Carts).Error; err != nil {
+		return report, err
+	}
+	var recovered struct {
+		Recovered int64
+		Revenue   int64
+	}
+	paid := []string{order.StatusPaid, order.StatusFulfilling, order.StatusShipped, order.StatusDelivered}
+	if err := recoveries.Session(&gorm.Session{}).
+		Select("COUNT(*) AS recovered, COALESCE(SUM(orders.base_total), 0) AS revenue").
+		Joins("JOIN orders ON orders.id = cart_recoveries.order_id").
+		Where("cart_recoveries.status = ? AND orders.status IN ?", StatusRecovered, paid).
+		Scan(&recovered).Error; err != nil {
+		return report, err
+	}
+	report.Recovered, report.Revenue = recovered.Recovered, recovered.Revenue
+	if err := recoveries.Session(&gorm.Session{}).
+		Select(`recovery_emails.step, COUNT(*) AS sent,
+			COUNT(recovery_emails.opened_at) AS opened, COUNT(recovery_emails.clicked_at) AS clicked`).
+		Joins("JOIN recovery_emails ON recovery_emails.recovery_id = cart_recoveries.id").
+		Group("recovery_emails.step").Order("recovery_emails.step").
+		Scan(&report.Steps).Error; err != nil {
+		return report, err
+	}
+	return report, nil
+}
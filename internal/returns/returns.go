@@ -0,0 +1,339 @@
+package returns
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Return request statuses. A request is approved or rejected by staff, an
+// approved return is received at the warehouse and then inspected.
+const (
+	StatusRequested = "requested"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusReceived  = "received"
+	StatusInspected = "inspected"
+)
+
+// Return reason codes.
+const (
+	ReasonDamaged        = "damaged"
+	ReasonDefective      = "defective"
+	ReasonWrongItem      = "wrong_item"
+	ReasonNotAsDescribed = "not_as_described"
+	ReasonNoLongerNeeded = "no_longer_needed"
+	ReasonOther          = "other"
+)
+
+var (
+	ErrNotReturnable   = errors.New("order cannot be returned")
+	ErrInvalidLine     = errors.New("line does not belong to the return")
+	ErrInvalidQuantity = errors.New("quantity exceeds what can be returned")
+	ErrInvalidReason   = errors.New("invalid return reason")
+	ErrInvalidStatus   = errors.New("return is not in a state that allows this operation")
+	ErrNoLocation      = errors.New("no location to restock to")
+	ErrNothingToReturn = errors.New("no lines to return")
+)
+
+// returnableStatuses lists the order statuses a return can be requested in.
+var returnableStatuses = []string{order.StatusPaid, order.StatusFulfilling, order.StatusShipped, order.StatusDelivered}
+
+// LineInput is a quantity of an order line a customer wants to return.
+type LineInput struct {
+	OrderLineID uint
+	Quantity    int
+	Reason      string
+	Note        string
+}
+
+// RestockInput puts quantity units of a return line back on the shelf. The
+// location defaults to the one the units were sold from.
+type RestockInput struct {
+	ReturnLineID uint
+	Quantity     int
+	LocationID   uint
+}
+
+// ValidReason reports whether reason is a known return reason code.
+func ValidReason(reason string) bool {
+	switch reason {
+	case ReasonDamaged, ReasonDefective, ReasonWrongItem, ReasonNotAsDescribed, ReasonNoLongerNeeded, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// Reference is the inventory reference restocked units are recorded under.
+func Reference(r types.ReturnRequest) string {
+	return "return:" + r.Number
+}
+
+// Create opens a return request for lines of the order. A line can never be
+// returned more often than it was bought, counting every return that was
+// not rejected.
+func Create(db *gorm.DB, o types.Order, lines []LineInput, note string) (types.ReturnRequest, error) {
+	r := types.ReturnRequest{
+		Number:       "R" + strings.ToUpper(randomString.GenerateSecureToken(5)),
+		OrderID:      o.ID,
+		Status:       StatusRequested,
+		CustomerNote: note,
+	}
+	if !returnable(o.Status) {
+		return r, ErrNotReturnable
+	}
+	if len(lines) == 0 {
+		return r, ErrNothingToReturn
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// serialise returns of the same order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&types.Order{}, o.ID).Error; err != nil {
+			return err
+		}
+
+		var orderLines []types.OrderLine
+		if err := tx.Where("order_id = ?", o.ID).Find(&orderLines).Error; err != nil {
+			return err
+		}
+		bought := map[uint]int{}
+		for _, line := range orderLines {
+			bought[line.ID] = line.Quantity
+		}
+
+		returned, err := returnedQuantities(tx, o.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, in := range lines {
+			if _, ok := bought[in.OrderLineID]; !ok {
+				return ErrInvalidLine
+			}
+			if !ValidReason(in.Reason) {
+				return ErrInvalidReason
+			}
+			returned[in.OrderLineID] += in.Quantity
+			if in.Quantity <= 0 || returned[in.OrderLineID] > bought[in.OrderLineID] {
+				return ErrInvalidQuantity
+			}
+			r.Lines = append(r.Lines, types.ReturnLine{
+				OrderLineID: in.OrderLineID,
+				Quantity:    in.Quantity,
+				Reason:      in.Reason,
+				Note:        in.Note,
+			})
+		}
+		return tx.Create(&r).Error
+	})
+	return r, err
+}
+
+func Approve(db *gorm.DB, r *types.ReturnRequest, note string) error {
+	now := time.Now()
+	if err := setStatus(db, r, StatusRequested, StatusApproved, map[string]interface{}{"staff_note": note, "approved_at": now}); err != nil {
+		return err
+	}
+	r.StaffNote = note
+	r.ApprovedAt = &now
+	return nil
+}
+
+func Reject(db *gorm.DB, r *types.ReturnRequest, note string) error {
+	if err := setStatus(db, r, StatusRequested, StatusRejected, map[string]interface{}{"staff_note": note}); err != nil {
+		return err
+	}
+	r.StaffNote = note
+	return nil
+}
+
+// Receive records that the returned items arrived.
+func Receive(db *gorm.DB, r *types.ReturnRequest) error {
+	now := time.Now()
+	if err := setStatus(db, r, StatusApproved, StatusReceived, map[string]interface{}{"received_at": now}); err != nil {
+		return err
+	}
+	r.ReceivedAt = &now
+	return nil
+}
+
+// Inspect completes the inspection of a received return and puts the units
+// found fit for sale back into inventory.
+func Inspect(db *gorm.DB, r *types.ReturnRequest, restock []RestockInput, note string) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := setStatus(tx, r, StatusReceived, StatusInspected, map[string]interface{}{"inspected_at": now}); err != nil {
+			return err
+		}
+		if note != "" {
+			if err := tx.Model(r).Update("staff_note", note).Error; err != nil {
+				return err
+			}
+			r.StaffNote = note
+		}
+		r.InspectedAt = &now
+
+		for _, in := range restock {
+			line := findLine(r, in.ReturnLineID)
+			if line == nil {
+				return ErrInvalidLine
+			}
+			if in.Quantity <= 0 || line.QuantityRestocked+in.Quantity > line.Quantity {
+				return ErrInvalidQuantity
+			}
+
+			orderLine := types.OrderLine{}
+			if err := tx.First(&orderLine, line.OrderLineID).Error; err != nil {
+				return err
+			}
+			locationID := in.LocationID
+			if locationID == 0 {
+				id, err := soldFrom(tx, r.OrderID, orderLine.VariantID)
+				if err != nil {
+					return err
+				}
+				locationID = id
+			}
+
+			if err := inventory.Adjust(tx, types.StockMovement{
+				VariantID:  orderLine.VariantID,
+				LocationID: locationID,
+				Quantity:   in.Quantity,
+				Reason:     inventory.ReasonReturned,
+				Reference:  Reference(*r),
+			}); err != nil {
+				return err
+			}
+			line.QuantityRestocked += in.Quantity
+			if err := tx.Model(line).Update("quantity_restocked", line.QuantityRestocked).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RefundDue returns the value of the returned units at the price paid for
+// them, tax included, less what was already refunded against the return.
+func RefundDue(db *gorm.DB, r types.ReturnRequest) (int64, error) {
+	o := types.Order{}
+	if err := db.First(&o, r.OrderID).Error; err != nil {
+		return 0, err
+	}
+
+	var due int64
+	for _, line := range r.Lines {
+		orderLine := types.OrderLine{}
+		if err := db.First(&orderLine, line.OrderLineID).Error; err != nil {
+			return 0, err
+		}
+		if orderLine.Quantity == 0 {
+			continue
+		}
+		paid := orderLine.LineTotal - orderLine.Discount
+		if !o.TaxIncluded {
+			paid += orderLine.TaxAmount
+		}
+		due += paid * int64(line.Quantity) / int64(orderLine.Quantity)
+	}
+
+	var refunded int64
+	if err := db.Model(&types.Refund{}).
+		Where("return_request_id = ?", r.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refunded).Error; err != nil {
+		return 0, err
+	}
+	if refunded >= due {
+		return 0, nil
+	}
+	return due - refunded, nil
+}
+
+// Refundable reports whether money may be given back against the return.
+func Refundable(r types.ReturnRequest) bool {
+	return r.Status == StatusApproved || r.Status == StatusReceived || r.Status == StatusInspected
+}
+
+func returnable(status string) bool {
+	for _, s := range returnableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// returnedQuantities sums the quantities of each order line on the returns
+// of the order that were not rejected.
+func returnedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderLineID uint
+		Quantity    int
+	}
+	if err := tx.Table("return_lines").
+		Select("return_lines.order_line_id, SUM(return_lines.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_lines.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status <> ? AND return_requests.deleted_at IS NULL", orderID, StatusRejected).
+		Group("return_lines.order_line_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	returned := map[uint]int{}
+	for _, row := range rows {
+		returned[row.OrderLineID] = row.Quantity
+	}
+	return returned, nil
+}
+
+// soldFrom finds the location a variant of the order was sold from.
+func soldFrom(tx *gorm.DB, orderID uint, variantID uint) (uint, error) {
+	o := types.Order{}
+	if err := tx.First(&o, orderID).Error; err != nil {
+		return 0, err
+	}
+	movement := types.StockMovement{}
+	err := tx.Where("reference = ? AND reason = ? AND variant_id = ?", order.Reference(o), inventory.ReasonSold, variantID).
+		Order("id").First(&movement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrNoLocation
+	}
+	return movement.LocationID, err
+}
+
+func findLine(r *types.ReturnRequest, id uint) *types.ReturnLine {
+	for i := range r.Lines {
+		if r.Lines[i].ID == id {
+			return &r.Lines[i]
+		}
+	}
+	return nil
+}
+
+// setStatus moves the return from one status to another with a conditional
+// update, so two members of staff cannot both act on it.
+func setStatus(db *gorm.DB, r *types.ReturnRequest, from, to string, extra map[string]interface{}) error {
+	if r.Status != from {
+		return ErrInvalidStatus
+	}
+	updates := map[string]interface{}{"status": to}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	res := db.Model(&types.ReturnRequest{}).Where("id = ? AND status = ?", r.ID, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidStatus
+	}
+	r.Status = to
+	return nil
+}
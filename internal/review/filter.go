@@ -0,0 +1,83 @@
+package review
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Filter flags.
+const (
+	FlagProfanity = "profanity"
+	FlagLink      = "link"
+)
+
+// profanity is the built in list of words that hold a review for a
+// moderator. Tenants add their own through the settings.
+var profanity = []string{
+	"arse", "arsehole", "asshole", "bastard", "bitch", "bollocks", "bullshit",
+	"cock", "crap", "cunt", "dick", "dickhead", "fuck", "fucker", "fucking",
+	"motherfucker", "piss", "prick", "pussy", "shit", "shitty", "slut",
+	"twat", "wanker", "whore",
+}
+
+// leet maps the look-alike characters used to dodge word lists back to
+// letters.
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+// linkPattern matches URLs, www. hosts and bare domains on common top level
+// domains.
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|\b[a-z0-9-]+\.(com|net|org|info|biz|io|co|me|ly|xyz|ru|cn|app|shop|store|site|online|top|link|click)\b)`)
+
+// Check runs the automatic filters over a review's text and returns the
+// flags it raised. extra holds the tenant's own blocked words.
+func Check(text string, extra []string, allowLinks bool) []string {
+	flags := []string{}
+	if containsWord(text, extra) {
+		flags = append(flags, FlagProfanity)
+	}
+	if !allowLinks && linkPattern.MatchString(text) {
+		flags = append(flags, FlagLink)
+	}
+	return flags
+}
+
+// containsWord reports whether text has one of the profane words, or of
+// extra, as a whole word once look-alike characters are undone.
+func containsWord(text string, extra []string) bool {
+	words := map[string]bool{}
+	for _, word := range profanity {
+		words[word] = true
+	}
+	for _, word := range extra {
+		words[strings.ToLower(word)] = true
+	}
+
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), separator) {
+		field = strings.Trim(field, "!")
+		if words[field] || words[leet.Replace(field)] {
+			return true
+		}
+	}
+	return false
+}
+
+// separator splits words on spaces and punctuation, except for the
+// characters leet spelling uses.
+func separator(r rune) bool {
+	if r == '@' || r == '$' || r == '!' {
+		return false
+	}
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// ParseWords splits the blocked words setting into words.
+func ParseWords(value string) []string {
+	words := []string{}
+	for _, word := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
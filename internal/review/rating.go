@@ -0,0 +1,88 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// adjust adds delta reviews of rating to the summary of productID, creating
+// it on the first review. The average is recomputed from the running count
+// and total in the same statement.
+func adjust(tx *gorm.DB, productID uint, rating, delta int) error {
+	stars := fmt.Sprintf("stars_%d", rating)
+	row := map[string]interface{}{
+		"product_id": productID,
+		"count":      delta,
+		"total":      delta * rating,
+		"average":    rating,
+		stars:        delta,
+	}
+	return tx.Model(&types.ProductRating{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count": gorm.Expr("product_ratings.count + ?", delta),
+			"total": gorm.Expr("product_ratings.total + ?", delta*rating),
+			"average": gorm.Expr("COALESCE(ROUND((product_ratings.total + ?)::numeric / NULLIF(product_ratings.count + ?, 0), 2), 0)",
+				delta*rating, delta),
+			stars: gorm.Expr("product_ratings."+stars+" + ?", delta),
+		}),
+	}).Create(row).Error
+}
+
+// Vote records whether userID found an approved review helpful, replacing
+// an earlier vote of theirs. The counts on the review follow the change.
+func Vote(db *gorm.DB, r types.Review, userID uint, helpful bool) error {
+	if r.Status != StatusApproved {
+		return ErrNotApproved
+	}
+	if r.UserID == userID {
+		return ErrOwnReview
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := unvote(tx, r.ID, userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&types.ReviewVote{ReviewID: r.ID, UserID: userID, Helpful: helpful}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&types.Review{}).Where("id = ?", r.ID).
+			Update(voteColumn(helpful), gorm.Expr(voteColumn(helpful)+" + 1")).Error
+	})
+}
+
+// Unvote withdraws the vote of userID on a review, if any.
+func Unvote(db *gorm.DB, reviewID, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return unvote(tx, reviewID, userID)
+	})
+}
+
+func unvote(tx *gorm.DB, reviewID, userID uint) error {
+	vote := types.ReviewVote{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("review_id = ? AND user_id = ?", reviewID, userID).
+		First(&vote).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Delete(&vote).Error; err != nil {
+		return err
+	}
+	column := voteColumn(vote.Helpful)
+	return tx.Model(&types.Review{}).Where("id = ?", reviewID).
+		Update(column, gorm.Expr(column+" - 1")).Error
+}
+
+func voteColumn(helpful bool) string {
+	if helpful {
+		return "helpful_count"
+	}
+	return "unhelpful_count"
+}
@@ -0,0 +1,254 @@
+package review
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Review statuses. Pending reviews wait in the moderation queue.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// MaxImages bounds the photos of a review.
+const MaxImages = 5
+
+var (
+	ErrTooManyImages   = errors.New("review already has the maximum number of images")
+	ErrAlreadyReviewed = errors.New("product has already been reviewed")
+	ErrInvalidRating   = errors.New("rating must be between 1 and 5")
+	ErrOwnReview       = errors.New("cannot vote on your own review")
+	ErrNotApproved     = errors.New("review is not published")
+)
+
+// purchasedStatuses are the order statuses that make a review verified.
+var purchasedStatuses = []string{order.StatusPaid, order.StatusFulfilling, order.StatusShipped, order.StatusDelivered}
+
+// Input is what a customer writes in a review.
+type Input struct {
+	AuthorName string
+	Rating     int
+	Title      string
+	Body       string
+}
+
+// Settings returns the review settings of the tenant, or the defaults when
+// none were saved: every review is moderated and links are filtered.
+func Settings(db *gorm.DB) (types.ReviewSettings, error) {
+	settings := types.ReviewSettings{}
+	err := db.Order("id").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.ReviewSettings{}, nil
+	}
+	return settings, err
+}
+
+// Submit records the review of userID for productID. It is verified when the
+// customer has a paid order of the product, and published right away only
+// when the tenant auto approves and the filters raised no flag.
+func Submit(db *gorm.DB, productID, userID uint, in Input) (types.Review, error) {
+	if in.Rating < 1 || in.Rating > 5 {
+		return types.Review{}, ErrInvalidRating
+	}
+	r := types.Review{ProductID: productID, UserID: userID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&types.Review{}).Where("product_id = ? AND user_id = ?", productID, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReviewed
+		}
+		orderID, err := purchase(tx, productID, userID)
+		if err != nil {
+			return err
+		}
+		r.OrderID = orderID
+		r.Verified = orderID != nil
+		if err := moderate(tx, &r, in); err != nil {
+			return err
+		}
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+		if r.Status == StatusApproved {
+			return adjust(tx, r.ProductID, r.Rating, 1)
+		}
+		return nil
+	})
+	return r, err
+}
+
+// Edit replaces the text and rating of a review, which goes through
+// moderation again.
+func Edit(db *gorm.DB, r *types.Review, in Input) error {
+	if in.Rating < 1 || in.Rating > 5 {
+		return ErrInvalidRating
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, r); err != nil {
+			return err
+		}
+		if r.Status == StatusApproved {
+			if err := adjust(tx, r.ProductID, r.Rating, -1); err != nil {
+				return err
+			}
+		}
+		if err := moderate(tx, r, in); err != nil {
+			return err
+		}
+		if err := tx.Model(r).Select("author_name", "rating", "title", "body", "status", "flags", "moderation_note").Updates(r).Error; err != nil {
+			return err
+		}
+		if r.Status == StatusApproved {
+			return adjust(tx, r.ProductID, r.Rating, 1)
+		}
+		return nil
+	})
+}
+
+// Approve publishes a review and counts it in the product's rating.
+func Approve(db *gorm.DB, r *types.Review, note string) error {
+	return setStatus(db, r, StatusApproved, note)
+}
+
+// Reject keeps a review off the storefront, taking it out of the rating if it
+// was published.
+func Reject(db *gorm.DB, r *types.Review, note string) error {
+	return setStatus(db, r, StatusRejected, note)
+}
+
+// Remove deletes a review with its votes and images. The image files are
+// left to the caller to delete with media.Delete.
+func Remove(db *gorm.DB, r *types.Review) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, r); err != nil {
+			return err
+		}
+		if r.Status == StatusApproved {
+			if err := adjust(tx, r.ProductID, r.Rating, -1); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("review_id = ?", r.ID).Delete(&types.ReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("review_id = ?", r.ID).Delete(&types.ReviewImage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.Review{}, r.ID).Error
+	})
+}
+
+// AddImage attaches an uploaded photo to r. Unless the tenant auto approves
+// reviews, a published review goes back to the moderation queue so the photo
+// is seen by a moderator before shoppers.
+func AddImage(db *gorm.DB, r *types.Review, mediaID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, r); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&types.ReviewImage{}).Where("review_id = ?", r.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxImages {
+			return ErrTooManyImages
+		}
+		if err := tx.Create(&types.ReviewImage{ReviewID: r.ID, MediaID: mediaID, Position: int(count)}).Error; err != nil {
+			return err
+		}
+		settings, err := Settings(tx)
+		if err != nil {
+			return err
+		}
+		if r.Status == StatusApproved && !settings.AutoApprove {
+			return setStatus(tx, r, StatusPending, "")
+		}
+		return nil
+	})
+}
+
+// Reply sets the vendor's public answer to a review, an empty body removes
+// it.
+func Reply(db *gorm.DB, r *types.Review, body string, now time.Time) error {
+	r.Reply = strings.TrimSpace(body)
+	r.RepliedAt = &now
+	if r.Reply == "" {
+		r.RepliedAt = nil
+	}
+	return db.Model(r).Select("reply", "replied_at").Updates(r).Error
+}
+
+func setStatus(db *gorm.DB, r *types.Review, to, note string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx, r); err != nil {
+			return err
+		}
+		from := r.Status
+		if err := tx.Model(r).Updates(map[string]interface{}{"status": to, "moderation_note": note}).Error; err != nil {
+			return err
+		}
+		r.Status = to
+		r.ModerationNote = note
+		switch {
+		case from != StatusApproved && to == StatusApproved:
+			return adjust(tx, r.ProductID, r.Rating, 1)
+		case from == StatusApproved && to != StatusApproved:
+			return adjust(tx, r.ProductID, r.Rating, -1)
+		}
+		return nil
+	})
+}
+
+// lock reloads r under a row lock, so concurrent changes of its status
+// cannot count it in the rating twice.
+func lock(tx *gorm.DB, r *types.Review) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(r, r.ID).Error
+}
+
+// moderate fills in the text of r and decides its status from the filters
+// and the tenant's settings.
+func moderate(tx *gorm.DB, r *types.Review, in Input) error {
+	settings, err := Settings(tx)
+	if err != nil {
+		return err
+	}
+	r.AuthorName = strings.TrimSpace(in.AuthorName)
+	r.Rating = in.Rating
+	r.Title = strings.TrimSpace(in.Title)
+	r.Body = strings.TrimSpace(in.Body)
+	r.ModerationNote = ""
+
+	flags := Check(r.AuthorName+"\n"+r.Title+"\n"+r.Body, ParseWords(settings.BlockedWords), settings.AllowLinks)
+	r.Flags = strings.Join(flags, ",")
+	r.Status = StatusPending
+	if settings.AutoApprove && len(flags) == 0 {
+		r.Status = StatusApproved
+	}
+	return nil
+}
+
+// purchase returns the most recent paid order of userID holding productID,
+// if any.
+func purchase(tx *gorm.DB, productID, userID uint) (*uint, error) {
+	var ids []uint
+	err := tx.Model(&types.Order{}).
+		Joins("JOIN order_lines ON order_lines.order_id = orders.id").
+		Where("orders.user_id = ? AND orders.status IN ? AND order_lines.product_id = ?", userID, purchasedStatuses, productID).
+		Order("orders.id DESC").
+		Limit(1).
+		Pluck("orders.id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	return &ids[0], nil
+}
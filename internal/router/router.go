@@ -14,6 +14,34 @@ func Init(e *echo.Echo) {
 	api := e.Group("/api")
 	{
 		routes.RegisterVendorAuthRoutes(api)
+		routes.RegisterCustomerAuthRoutes(api)
+		routes.RegisterCatalogRoutes(api)
+		routes.RegisterStorefrontRoutes(api)
+		routes.RegisterInventoryRoutes(api)
+		routes.RegisterCartRoutes(api)
+		routes.RegisterOrderRoutes(api)
+		routes.RegisterPaymentRoutes(api)
+		routes.RegisterWebhookRoutes(api)
+		routes.RegisterReturnRoutes(api)
+		routes.RegisterPromotionRoutes(api)
+		routes.RegisterTaxRoutes(api)
+		routes.RegisterShippingRoutes(api)
+		routes.RegisterFulfillmentRoutes(api)
+		routes.RegisterCurrencyRoutes(api)
+		routes.RegisterReportRoutes(api)
+		routes.RegisterLedgerRoutes(api)
+		routes.RegisterBillingRoutes(api)
+		routes.RegisterSubscriptionRoutes(api)
+		routes.RegisterGiftCardRoutes(api)
+		routes.RegisterSearchRoutes(api)
+		routes.RegisterBulkRoutes(api)
+		routes.RegisterMediaRoutes(api)
+		routes.RegisterDigitalRoutes(api)
+		routes.RegisterReviewRoutes(api)
+		routes.RegisterWishlistRoutes(api)
+		routes.RegisterRecoveryRoutes(api)
+		routes.RegisterAddressRoutes(api)
+		routes.RegisterWebhookSubscriptionRoutes(api)
 
 	}
 
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterAddressRoutes function
+func RegisterAddressRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewAddressHandler()
+
+	store := e.Group("/store/addresses")
+	{
+		store.GET("", h.List)
+		store.POST("", h.Create)
+		store.POST("/validate", h.Validate)
+		store.GET("/:id", h.Get)
+		store.PUT("/:id", h.Update)
+		store.DELETE("/:id", h.Delete)
+	}
+
+	e.GET("/admin/customers/:id/addresses", h.CustomerAddresses, auth)
+
+}
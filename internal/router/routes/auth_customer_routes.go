@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterCustomerAuthRoutes function
+func RegisterCustomerAuthRoutes(e *echo.Group) {
+	h := handler.NewAuthCustomerHandler()
+
+	g := e.Group("/store/auth")
+	{
+		g.POST("/register", h.Register)
+		g.POST("/login", h.Login)
+		g.POST("/logout", h.Logout)
+		g.GET("/me", h.Me)
+	}
+
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterBillingRoutes function
+func RegisterBillingRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewBillingHandler()
+	admin := middleware.PlatformAdminMiddleware()
+
+	vendor := e.Group("/admin/billing", auth)
+	{
+		vendor.GET("/plans", h.AvailablePlans)
+		vendor.GET("/subscription", h.GetSubscription)
+		vendor.POST("/subscription", h.Subscribe)
+		vendor.PUT("/subscription", h.ChangePlan)
+		vendor.DELETE("/subscription", h.Cancel)
+		vendor.POST("/subscription/resume", h.Resume)
+		vendor.PUT("/subscription/payment-method", h.UpdatePaymentMethod)
+		vendor.GET("/invoices", h.MyInvoices)
+		vendor.GET("/usage", h.Usage)
+	}
+
+	plans := e.Group("/platform/plans", admin)
+	{
+		plans.GET("", h.ListPlans)
+		plans.POST("", h.CreatePlan)
+		plans.PUT("/:id", h.UpdatePlan)
+		plans.DELETE("/:id", h.DeletePlan)
+	}
+
+	invoices := e.Group("/platform/invoices", admin)
+	{
+		invoices.GET("", h.ListInvoices)
+		invoices.POST("/:id/retry", h.RetryInvoice)
+	}
+
+}
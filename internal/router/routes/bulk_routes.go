@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterBulkRoutes function
+func RegisterBulkRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewBulkHandler()
+
+	e.POST("/admin/products/import", h.Import, auth)
+	e.GET("/admin/products/export", h.Export, auth)
+
+	g := e.Group("/admin/imports", auth)
+	{
+		g.GET("", h.ListImports)
+		g.GET("/:id", h.GetImport)
+		g.GET("/:id/rows", h.ImportRows)
+		g.GET("/:id/errors", h.ImportErrors)
+	}
+
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterCartRoutes function
+func RegisterCartRoutes(e *echo.Group) {
+	h := handler.NewCartHandler()
+
+	g := e.Group("/store/cart")
+	{
+		g.POST("", h.Create)
+		g.GET("", h.Get)
+		g.POST("/items", h.AddItem)
+		g.PUT("/items/:variant_id", h.UpdateItem)
+		g.DELETE("/items/:variant_id", h.RemoveItem)
+		g.POST("/merge", h.Merge)
+		g.POST("/coupons", h.ApplyCode)
+		g.DELETE("/coupons/:code", h.RemoveCode)
+		g.PUT("/currency", h.SetCurrency)
+		g.PUT("/email", h.SetEmail)
+	}
+
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterCatalogRoutes function
+func RegisterCatalogRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	products := handler.NewProductHandler()
+	categories := handler.NewCategoryHandler()
+	collections := handler.NewCollectionHandler()
+
+	p := e.Group("/admin/products", auth)
+	{
+		p.GET("", products.List)
+		p.POST("", products.Create, middleware.QuotaMiddleware(billing.ResourceProducts))
+		p.GET("/:id", products.Get)
+		p.PUT("/:id", products.Update)
+		p.DELETE("/:id", products.Delete)
+	}
+
+	cat := e.Group("/admin/categories", auth)
+	{
+		cat.GET("", categories.Tree)
+		cat.POST("", categories.Create)
+		cat.POST("/reorder", categories.Reorder)
+		cat.PUT("/:id", categories.Update)
+		cat.DELETE("/:id", categories.Delete)
+		cat.POST("/:id/move", categories.Move)
+	}
+
+	col := e.Group("/admin/collections", auth)
+	{
+		col.GET("", collections.List)
+		col.POST("", collections.Create)
+		col.GET("/:id", collections.Get)
+		col.PUT("/:id", collections.Update)
+		col.DELETE("/:id", collections.Delete)
+		col.POST("/:id/products", collections.AddProduct)
+		col.DELETE("/:id/products/:product_id", collections.RemoveProduct)
+		col.POST("/:id/refresh", collections.Refresh)
+	}
+
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterCurrencyRoutes function
+func RegisterCurrencyRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewCurrencyHandler()
+
+	e.GET("/store/currencies", h.Available)
+
+	g := e.Group("/admin/currencies", auth)
+	{
+		g.GET("/settings", h.GetSettings)
+		g.PUT("/settings", h.UpdateSettings)
+		g.GET("/rates", h.ListRates)
+		g.POST("/rates/import", h.ImportRates)
+		g.GET("", h.ListCurrencies)
+		g.PUT("/:code", h.SaveCurrency)
+		g.DELETE("/:code", h.DeleteCurrency)
+	}
+
+	lists := e.Group("/admin/price-lists", auth)
+	{
+		lists.GET("", h.ListPriceLists)
+		lists.GET("/:id", h.GetPriceList)
+		lists.POST("", h.CreatePriceList)
+		lists.PUT("/:id", h.UpdatePriceList)
+		lists.DELETE("/:id", h.DeletePriceList)
+	}
+
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterDigitalRoutes function
+func RegisterDigitalRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewDigitalHandler()
+
+	store := e.Group("/store")
+	{
+		store.GET("/orders/:number/downloads", h.MyDownloads)
+		store.GET("/downloads/:id", h.Download)
+		store.POST("/licenses/verify", h.VerifyLicense)
+	}
+
+	assets := e.Group("/admin/digital-assets", auth)
+	{
+		assets.GET("", h.ListAssets)
+		assets.POST("", h.CreateAsset)
+		assets.PUT("/:id", h.UpdateAsset)
+		assets.DELETE("/:id", h.DeleteAsset)
+	}
+
+	e.GET("/admin/orders/:id/downloads", h.OrderDownloads, auth)
+	e.POST("/admin/downloads/:id/reissue", h.ReissueDownload, auth)
+
+	licenses := e.Group("/admin/licenses", auth)
+	{
+		licenses.GET("", h.ListLicenses)
+		licenses.POST("/:id/revoke", h.RevokeLicense)
+	}
+
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterFulfillmentRoutes function
+func RegisterFulfillmentRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewFulfillmentHandler()
+
+	e.GET("/store/track", h.Track)
+
+	orders := e.Group("/admin/orders/:id/shipments", auth)
+	{
+		orders.GET("", h.OrderShipments)
+		orders.POST("", h.CreateShipment)
+	}
+
+	shipments := e.Group("/admin/shipments", auth)
+	{
+		shipments.DELETE("/:id", h.DeleteShipment)
+		shipments.POST("/:id/events", h.AddEvent)
+	}
+
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterGiftCardRoutes function
+func RegisterGiftCardRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	cards := handler.NewGiftCardHandler()
+	credit := handler.NewStoreCreditHandler()
+
+	store := e.Group("/store")
+	{
+		store.POST("/gift-cards/balance", cards.CheckBalance)
+		store.GET("/store-credit", credit.MyCredit)
+	}
+
+	admin := e.Group("/admin/gift-cards", auth)
+	{
+		admin.GET("", cards.List)
+		admin.POST("", cards.Issue)
+		admin.GET("/:id", cards.Get)
+		admin.PUT("/:id", cards.Update)
+		admin.POST("/:id/adjust", cards.Adjust)
+	}
+
+	customers := e.Group("/admin/customers/:id/store-credit", auth)
+	{
+		customers.GET("", credit.CustomerCredit)
+		customers.POST("", credit.Adjust)
+	}
+
+}
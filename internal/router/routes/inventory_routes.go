@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterInventoryRoutes function
+func RegisterInventoryRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewInventoryHandler()
+
+	g := e.Group("/admin/inventory", auth)
+	{
+		g.GET("/warehouses", h.ListWarehouses)
+		g.POST("/warehouses", h.CreateWarehouse)
+		g.PUT("/warehouses/:id", h.UpdateWarehouse)
+		g.POST("/warehouses/:id/locations", h.CreateLocation)
+		g.PUT("/locations/:id", h.UpdateLocation)
+		g.GET("/levels", h.ListLevels)
+		g.POST("/adjust", h.Adjust)
+		g.POST("/transfer", h.Transfer)
+		g.GET("/movements", h.ListMovements)
+		g.GET("/reservations", h.ListReservations)
+	}
+
+}
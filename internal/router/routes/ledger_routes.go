@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterLedgerRoutes function
+func RegisterLedgerRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewLedgerHandler()
+	admin := middleware.PlatformAdminMiddleware()
+
+	vendor := e.Group("/admin/ledger", auth)
+	{
+		vendor.GET("/statement", h.MyStatement)
+		vendor.GET("/payouts", h.MyPayouts)
+	}
+
+	plans := e.Group("/platform/commission-plans", admin)
+	{
+		plans.GET("", h.ListPlans)
+		plans.POST("", h.CreatePlan)
+		plans.PUT("/:id", h.UpdatePlan)
+		plans.DELETE("/:id", h.DeletePlan)
+	}
+
+	platform := e.Group("/platform", admin)
+	{
+		platform.PUT("/vendors/:id/commission-plan", h.AssignPlan)
+		platform.GET("/vendors/:id/statement", h.VendorStatement)
+		platform.POST("/ledger/chargebacks", h.RecordChargeback)
+		platform.GET("/ledger/reconciliation", h.Reconciliation)
+		platform.GET("/payouts/batches", h.ListBatches)
+		platform.POST("/payouts/batches", h.GenerateBatches)
+		platform.GET("/payouts/batches/:id", h.GetBatch)
+		platform.POST("/payouts/batches/:id/paid", h.MarkBatchPaid)
+		platform.POST("/payouts/:id/fail", h.FailPayout)
+	}
+
+}
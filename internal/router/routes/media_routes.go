@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterMediaRoutes function
+func RegisterMediaRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewMediaHandler()
+	quota := middleware.QuotaMiddleware(billing.ResourceStorage)
+
+	e.GET("/media/*", h.Serve)
+
+	g := e.Group("/admin/media", auth)
+	{
+		g.POST("", h.Upload, quota)
+		g.GET("", h.List)
+		g.GET("/:id", h.Get)
+		g.GET("/:id/url", h.SignedURL)
+		g.DELETE("/:id", h.Delete)
+	}
+
+	e.PUT("/admin/vendor/logo", h.UploadLogo, auth, quota)
+	e.POST("/admin/products/:id/images", h.AddProductImage, auth, quota)
+	e.DELETE("/admin/products/:id/images/:image_id", h.DeleteProductImage, auth)
+
+}
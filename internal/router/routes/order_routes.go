@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterOrderRoutes function
+func RegisterOrderRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewOrderHandler()
+
+	store := e.Group("/store")
+	{
+		store.POST("/checkout", h.Checkout, middleware.QuotaMiddleware(billing.ResourceOrders))
+		store.GET("/checkout/shipping-methods", h.ShippingOptions)
+		store.GET("/orders", h.MyOrders)
+		store.GET("/orders/:number", h.MyOrder)
+	}
+
+	admin := e.Group("/admin/orders", auth)
+	{
+		admin.GET("", h.List)
+		admin.GET("/:id", h.Get)
+		admin.POST("/:id/transition", h.Transition)
+	}
+
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterPaymentRoutes function
+func RegisterPaymentRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewPaymentHandler()
+
+	e.POST("/store/orders/:number/pay", h.Pay)
+
+	admin := e.Group("/admin/payments", auth)
+	{
+		admin.GET("/providers", h.ListProviders)
+		admin.PUT("/providers/:provider", h.ConfigureProvider)
+		admin.DELETE("/providers/:provider", h.RemoveProvider)
+		admin.POST("/:id/capture", h.Capture)
+		admin.POST("/:id/void", h.Void)
+	}
+	e.GET("/admin/orders/:id/payments", h.OrderPayments, auth)
+
+}
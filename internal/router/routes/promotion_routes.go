@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterPromotionRoutes function
+func RegisterPromotionRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewPromotionHandler()
+
+	g := e.Group("/admin/promotions", auth)
+	{
+		g.GET("", h.List)
+		g.GET("/:id", h.Get)
+		g.POST("", h.Create)
+		g.PUT("/:id", h.Update)
+		g.DELETE("/:id", h.Delete)
+		g.GET("/:id/redemptions", h.Redemptions)
+	}
+
+}
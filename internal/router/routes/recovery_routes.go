@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterRecoveryRoutes function
+func RegisterRecoveryRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewRecoveryHandler()
+
+	store := e.Group("/store/recovery")
+	{
+		store.GET("/:token", h.Click)
+		store.GET("/:token/open.gif", h.Open)
+	}
+
+	admin := e.Group("/admin/cart-recovery", auth)
+	{
+		admin.GET("", h.List)
+		admin.GET("/report", h.Report)
+		admin.GET("/settings", h.GetSettings)
+		admin.PUT("/settings", h.UpdateSettings)
+		admin.GET("/:id", h.Get)
+	}
+
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterReportRoutes function
+func RegisterReportRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewReportHandler()
+
+	g := e.Group("/admin/reports", auth)
+	{
+		g.GET("/sales", h.Sales)
+	}
+
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterReturnRoutes function
+func RegisterReturnRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewReturnHandler()
+
+	store := e.Group("/store/orders/:number/returns")
+	{
+		store.POST("", h.Create)
+		store.GET("", h.MyReturns)
+	}
+
+	admin := e.Group("/admin/returns", auth)
+	{
+		admin.GET("", h.List)
+		admin.GET("/:id", h.Get)
+		admin.POST("/:id/approve", h.Approve)
+		admin.POST("/:id/reject", h.Reject)
+		admin.POST("/:id/receive", h.Receive)
+		admin.POST("/:id/inspect", h.Inspect)
+		admin.POST("/:id/refund", h.RefundReturn)
+	}
+
+	refunds := e.Group("/admin/orders/:id/refunds", auth)
+	{
+		refunds.GET("", h.OrderRefunds)
+		refunds.POST("", h.RefundOrder)
+	}
+
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/billing"
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterReviewRoutes function
+func RegisterReviewRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewReviewHandler()
+
+	e.GET("/store/products/:handle/reviews", h.ProductReviews)
+	e.POST("/store/products/:handle/reviews", h.Create)
+
+	store := e.Group("/store/reviews")
+	{
+		store.GET("", h.MyReviews)
+		store.PUT("/:id", h.Update)
+		store.DELETE("/:id", h.DeleteMine)
+		store.POST("/:id/images", h.AddImage, middleware.QuotaMiddleware(billing.ResourceStorage))
+		store.POST("/:id/vote", h.Vote)
+		store.DELETE("/:id/vote", h.Unvote)
+	}
+
+	admin := e.Group("/admin/reviews", auth)
+	{
+		admin.GET("", h.List)
+		admin.GET("/settings", h.GetSettings)
+		admin.PUT("/settings", h.UpdateSettings)
+		admin.GET("/:id", h.Get)
+		admin.POST("/:id/approve", h.Approve)
+		admin.POST("/:id/reject", h.Reject)
+		admin.PUT("/:id/reply", h.Reply)
+		admin.DELETE("/:id", h.Delete)
+	}
+
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterSearchRoutes function
+func RegisterSearchRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewSearchHandler()
+
+	e.GET("/store/search", h.Search)
+	e.POST("/admin/search/reindex", h.Reindex, auth)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterShippingRoutes function
+func RegisterShippingRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewShippingHandler()
+
+	g := e.Group("/admin/shipping", auth)
+	{
+		g.GET("/carriers", h.Carriers)
+		g.GET("/zones", h.ListZones)
+		g.GET("/zones/:id", h.GetZone)
+		g.POST("/zones", h.CreateZone)
+		g.PUT("/zones/:id", h.UpdateZone)
+		g.DELETE("/zones/:id", h.DeleteZone)
+		g.POST("/zones/:id/methods", h.CreateMethod)
+		g.PUT("/zones/:id/methods/:method_id", h.UpdateMethod)
+		g.DELETE("/zones/:id/methods/:method_id", h.DeleteMethod)
+	}
+
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterStorefrontRoutes function
+func RegisterStorefrontRoutes(e *echo.Group) {
+	h := handler.NewStorefrontHandler()
+
+	g := e.Group("/store")
+	{
+		g.GET("/categories", h.Categories)
+		g.GET("/categories/:handle/products", h.CategoryProducts)
+		g.GET("/collections/:handle/products", h.CollectionProducts)
+		g.GET("/products/:handle", h.Product)
+	}
+
+}
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterSubscriptionRoutes function
+func RegisterSubscriptionRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewSubscriptionHandler()
+
+	store := e.Group("/store")
+	{
+		store.GET("/products/:id/subscription-plans", h.ActiveProductPlans)
+		store.GET("/subscriptions", h.MySubscriptions)
+		store.POST("/subscriptions", h.Subscribe)
+		store.GET("/subscriptions/:id", h.MySubscription)
+		store.PUT("/subscriptions/:id", h.Update)
+		store.POST("/subscriptions/:id/pause", h.Pause)
+		store.POST("/subscriptions/:id/resume", h.Resume)
+		store.POST("/subscriptions/:id/skip", h.Skip)
+		store.POST("/subscriptions/:id/cancel", h.Cancel)
+	}
+
+	admin := e.Group("/admin", auth)
+	{
+		admin.GET("/products/:id/subscription-plans", h.ProductPlans)
+		admin.POST("/products/:id/subscription-plans", h.CreatePlan)
+		admin.PUT("/subscription-plans/:id", h.UpdatePlan)
+		admin.DELETE("/subscription-plans/:id", h.DeletePlan)
+		admin.GET("/subscriptions", h.List)
+		admin.POST("/subscriptions/run", h.RunRenewals)
+		admin.GET("/subscriptions/:id", h.Get)
+		admin.POST("/subscriptions/:id/cancel", h.AdminCancel)
+	}
+
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterTaxRoutes function
+func RegisterTaxRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewTaxHandler()
+
+	g := e.Group("/admin/tax", auth)
+	{
+		g.GET("/settings", h.GetSettings)
+		g.PUT("/settings", h.UpdateSettings)
+		g.GET("/classes", h.ListClasses)
+		g.POST("/classes", h.CreateClass)
+		g.PUT("/classes/:id", h.UpdateClass)
+		g.DELETE("/classes/:id", h.DeleteClass)
+		g.GET("/zones", h.ListZones)
+		g.GET("/zones/:id", h.GetZone)
+		g.POST("/zones", h.CreateZone)
+		g.PUT("/zones/:id", h.UpdateZone)
+		g.DELETE("/zones/:id", h.DeleteZone)
+	}
+
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterWebhookRoutes function
+func RegisterWebhookRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewWebhookHandler()
+
+	e.POST("/webhooks/payments/:provider/:tenant", h.PaymentWebhook)
+
+	admin := e.Group("/admin/payments/webhooks", auth)
+	{
+		admin.GET("", h.ListPaymentEvents)
+		admin.POST("/:id/replay", h.ReplayPaymentEvent)
+	}
+
+}
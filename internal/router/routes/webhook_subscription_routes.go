@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterWebhookSubscriptionRoutes function
+func RegisterWebhookSubscriptionRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewWebhookSubscriptionHandler()
+
+	subscriptions := e.Group("/admin/webhooks", auth)
+	{
+		subscriptions.GET("", h.List)
+		subscriptions.POST("", h.Create)
+		subscriptions.GET("/events", h.Events)
+		subscriptions.GET("/:id", h.Get)
+		subscriptions.PUT("/:id", h.Update)
+		subscriptions.DELETE("/:id", h.Delete)
+		subscriptions.POST("/:id/secret", h.RotateSecret)
+		subscriptions.POST("/:id/test", h.Test)
+		subscriptions.GET("/:id/deliveries", h.Deliveries)
+	}
+
+	deliveries := e.Group("/admin/webhook-deliveries", auth)
+	{
+		deliveries.GET("/:id", h.GetDelivery)
+		deliveries.POST("/:id/redeliver", h.Redeliver)
+	}
+
+}
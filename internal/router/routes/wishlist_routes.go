@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"github.com/Satishcg12/multicommers/internal/handler"
+	"github.com/Satishcg12/multicommers/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterWishlistRoutes function
+func RegisterWishlistRoutes(e *echo.Group) {
+	auth := middleware.VendorAuthMiddleware()
+	h := handler.NewWishlistHandler()
+
+	e.GET("/store/shared-wishlists/:token", h.Shared)
+	e.POST("/store/variants/:id/stock-alerts", h.Subscribe)
+	e.DELETE("/store/stock-alerts/:token", h.Unsubscribe)
+
+	store := e.Group("/store/wishlists")
+	{
+		store.GET("", h.MyWishlists)
+		store.POST("", h.Create)
+		store.GET("/:id", h.Get)
+		store.PUT("/:id", h.Rename)
+		store.DELETE("/:id", h.Delete)
+		store.POST("/:id/items", h.AddItem)
+		store.DELETE("/:id/items/:item_id", h.RemoveItem)
+		store.POST("/:id/share", h.Share)
+		store.DELETE("/:id/share", h.Unshare)
+	}
+
+	admin := e.Group("/admin/stock-alerts", auth)
+	{
+		admin.GET("", h.ListAlerts)
+		admin.GET("/most-wanted", h.MostWanted)
+	}
+
+}
@@ -0,0 +1,267 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Satishcg12/multicommers/internal/catalog"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sort orders.
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+)
+
+// TypoThreshold is the minimum trigram word similarity for a product to
+// match a query that has no full-text hit, which lets "tshrit" find "tshirt".
+const TypoThreshold = 0.4
+
+// PriceBuckets are the upper bounds, in minor units, of the price facet
+// buckets. Products priced at or above the last bound fall in an open bucket.
+var PriceBuckets = []int64{1000, 2500, 5000, 10000, 25000, 50000}
+
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// Query describes a storefront search. Options filters by option values:
+// values of the same option are alternatives, different options must all
+// match.
+type Query struct {
+	Text       string
+	CategoryID *uint
+	MinPrice   *int64
+	MaxPrice   *int64
+	Options    map[string][]string
+	InStock    bool
+	Sort       string
+}
+
+type CategoryFacet struct {
+	CategoryID uint   `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int64  `json:"count"`
+}
+
+// PriceFacet counts the products whose lowest price is in [Min, Max). A nil
+// Max marks the open top bucket.
+type PriceFacet struct {
+	Min   int64  `json:"min"`
+	Max   *int64 `json:"max"`
+	Count int64  `json:"count"`
+}
+
+type OptionFacet struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type Facets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Prices     []PriceFacet    `json:"prices"`
+	Options    []OptionFacet   `json:"options"`
+	InStock    int64           `json:"in_stock"`
+}
+
+// Result is one page of matching product ids in sort order, the total number
+// of matches and the facets of the whole match set.
+type Result struct {
+	ProductIDs []uint
+	Total      int64
+	Facets     Facets
+}
+
+// inStockSQL matches documents of products with at least one variant that
+// has unreserved stock. Stock is not part of the document since it changes
+// with every order.
+const inStockSQL = `EXISTS (
+	SELECT 1 FROM product_variants
+	JOIN inventory_levels ON inventory_levels.variant_id = product_variants.id
+	WHERE product_variants.product_id = product_search_documents.product_id
+		AND product_variants.deleted_at IS NULL
+		AND inventory_levels.on_hand > inventory_levels.reserved
+)`
+
+const optionSQL = `EXISTS (
+	SELECT 1 FROM product_variant_options
+	JOIN product_variants ON product_variants.id = product_variant_options.variant_id
+	WHERE product_variants.product_id = product_search_documents.product_id
+		AND product_variants.deleted_at IS NULL
+		AND product_variant_options.name = ? AND product_variant_options.value IN ?
+)`
+
+// tsQuery turns free text into a prefix tsquery, "red tsh" becoming
+// "red:* & tsh:*". Only letters and digits survive so the result is always
+// valid tsquery syntax.
+func tsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Search runs q against the published products and returns the requested
+// page of results.
+func Search(db *gorm.DB, q Query, offset, limit int) (Result, error) {
+	if q.Sort == "" {
+		q.Sort = SortNewest
+		if tsQuery(q.Text) != "" {
+			q.Sort = SortRelevance
+		}
+	}
+	if q.Sort != SortRelevance && q.Sort != SortPriceAsc && q.Sort != SortPriceDesc && q.Sort != SortNewest {
+		return Result{}, ErrInvalidSort
+	}
+
+	base, err := filter(db, q)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{ProductIDs: []uint{}}
+	if err := base.Session(&gorm.Session{}).Count(&res.Total).Error; err != nil {
+		return Result{}, err
+	}
+
+	page := base.Session(&gorm.Session{}).Select("product_id")
+	text := strings.TrimSpace(q.Text)
+	switch q.Sort {
+	case SortRelevance:
+		if query := tsQuery(text); query != "" {
+			page = page.Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "ts_rank_cd(document, to_tsquery('simple', ?)) + word_similarity(?, terms) DESC",
+				Vars: []interface{}{query, strings.ToLower(text)},
+			}})
+		}
+	case SortPriceAsc:
+		page = page.Order("min_price ASC")
+	case SortPriceDesc:
+		page = page.Order("min_price DESC")
+	case SortNewest:
+		page = page.Order("created_at DESC")
+	}
+	if err := page.Order("product_id DESC").Offset(offset).Limit(limit).Pluck("product_id", &res.ProductIDs).Error; err != nil {
+		return Result{}, err
+	}
+
+	if res.Facets, err = facets(db, base); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// filter builds the query over search documents that matches q.
+func filter(db *gorm.DB, q Query) (*gorm.DB, error) {
+	query := db.Model(&types.ProductSearchDocument{}).Where("product_search_documents.published = ?", true)
+
+	if text := strings.TrimSpace(q.Text); text != "" {
+		if ts := tsQuery(text); ts != "" {
+			query = query.Where("(document @@ to_tsquery('simple', ?) OR word_similarity(?, terms) >= ?)",
+				ts, strings.ToLower(text), TypoThreshold)
+		}
+	}
+	if q.CategoryID != nil {
+		ids, err := catalog.SubtreeIDs(db, *q.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("product_search_documents.category_id IN ?", ids)
+	}
+	if q.MinPrice != nil {
+		query = query.Where("max_price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		query = query.Where("min_price <= ?", *q.MaxPrice)
+	}
+	names := make([]string, 0, len(q.Options))
+	for name := range q.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		query = query.Where(optionSQL, name, q.Options[name])
+	}
+	if q.InStock {
+		query = query.Where(inStockSQL)
+	}
+	return query, nil
+}
+
+// priceBucketSQL maps min_price to the index of its PriceBuckets bucket.
+func priceBucketSQL() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range PriceBuckets {
+		fmt.Fprintf(&b, " WHEN min_price < %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(PriceBuckets))
+	return b.String()
+}
+
+// facets counts the documents matched by base per category, price bucket,
+// option value and stock status. Empty buckets are left out.
+func facets(db *gorm.DB, base *gorm.DB) (Facets, error) {
+	f := Facets{Categories: []CategoryFacet{}, Prices: []PriceFacet{}, Options: []OptionFacet{}}
+
+	err := base.Session(&gorm.Session{}).
+		Select("product_search_documents.category_id, categories.name, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = product_search_documents.category_id AND categories.deleted_at IS NULL").
+		Group("product_search_documents.category_id, categories.name").
+		Order("count DESC, categories.name").
+		Scan(&f.Categories).Error
+	if err != nil {
+		return Facets{}, err
+	}
+
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	bucket := priceBucketSQL()
+	err = base.Session(&gorm.Session{}).
+		Select(bucket + " AS bucket, COUNT(*) AS count").
+		Group("bucket").
+		Order("bucket").
+		Scan(&buckets).Error
+	if err != nil {
+		return Facets{}, err
+	}
+	for _, b := range buckets {
+		facet := PriceFacet{Count: b.Count}
+		if b.Bucket > 0 {
+			facet.Min = PriceBuckets[b.Bucket-1]
+		}
+		if b.Bucket < len(PriceBuckets) {
+			upper := PriceBuckets[b.Bucket]
+			facet.Max = &upper
+		}
+		f.Prices = append(f.Prices, facet)
+	}
+
+	err = db.Table("product_variant_options").
+		Select("product_variant_options.name, product_variant_options.value, COUNT(DISTINCT product_variants.product_id) AS count").
+		Joins("JOIN product_variants ON product_variants.id = product_variant_options.variant_id AND product_variants.deleted_at IS NULL").
+		Where("product_variants.product_id IN (?)", base.Session(&gorm.Session{}).Select("product_search_documents.product_id")).
+		Group("product_variant_options.name, product_variant_options.value").
+		Order("product_variant_options.name, product_variant_options.value").
+		Scan(&f.Options).Error
+	if err != nil {
+		return Facets{}, err
+	}
+
+	if err := base.Session(&gorm.Session{}).Where(inStockSQL).Count(&f.InStock).Error; err != nil {
+		return Facets{}, err
+	}
+	return f, nil
+}
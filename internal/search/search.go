@@ -0,0 +1,113 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// indexSQL rebuilds the search documents of the products matched by the
+// trailing condition. Titles weigh most, then tags, skus and option values,
+// then the description. Every part uses the simple configuration so prefix
+// queries match the words as typed instead of their stems.
+const indexSQL = `
+INSERT INTO product_search_documents
+	(product_id, title, document, terms, category_id, min_price, max_price, published, created_at, updated_at)
+SELECT p.id, p.title,
+	setweight(to_tsvector('simple', p.title), 'A') ||
+	setweight(to_tsvector('simple', concat_ws(' ', t.names, v.skus, o.vals)), 'B') ||
+	setweight(to_tsvector('simple', coalesce(p.description, '')), 'C'),
+	lower(concat_ws(' ', p.title, t.names, v.skus, o.vals)),
+	p.category_id,
+	coalesce(v.min_price, p.price),
+	coalesce(v.max_price, p.price),
+	coalesce(p.published, false),
+	p.created_at,
+	now()
+FROM products p
+LEFT JOIN LATERAL (
+	SELECT string_agg(name, ' ') AS names FROM product_tags WHERE product_id = p.id
+) t ON true
+LEFT JOIN LATERAL (
+	SELECT string_agg(concat_ws(' ', sku, title), ' ') AS skus,
+		min(CASE WHEN price > 0 THEN price ELSE p.price END) AS min_price,
+		max(CASE WHEN price > 0 THEN price ELSE p.price END) AS max_price
+	FROM product_variants WHERE product_id = p.id AND deleted_at IS NULL
+) v ON true
+LEFT JOIN LATERAL (
+	SELECT string_agg(DISTINCT product_variant_options.value, ' ') AS vals
+	FROM product_variant_options
+	JOIN product_variants ON product_variants.id = product_variant_options.variant_id
+	WHERE product_variants.product_id = p.id AND product_variants.deleted_at IS NULL
+) o ON true
+WHERE p.deleted_at IS NULL %s
+ON CONFLICT (product_id) DO UPDATE SET
+	title = EXCLUDED.title,
+	document = EXCLUDED.document,
+	terms = EXCLUDED.terms,
+	category_id = EXCLUDED.category_id,
+	min_price = EXCLUDED.min_price,
+	max_price = EXCLUDED.max_price,
+	published = EXCLUDED.published,
+	updated_at = EXCLUDED.updated_at`
+
+// Setup installs the trigram extension and the GIN indexes the search
+// queries rely on, and builds the index of a tenant that has none yet. It is
+// registered as a tenant setup function and safe to run repeatedly.
+func Setup(db *gorm.DB) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_product_search_documents_document ON product_search_documents USING gin (document)",
+		"CREATE INDEX IF NOT EXISTS idx_product_search_documents_terms ON product_search_documents USING gin (terms gin_trgm_ops)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	var indexed int64
+	if err := db.Model(&types.ProductSearchDocument{}).Count(&indexed).Error; err != nil {
+		return err
+	}
+	if indexed > 0 {
+		return nil
+	}
+	_, err := Reindex(db)
+	return err
+}
+
+// Index refreshes the search document of a single product, dropping it when
+// the product no longer exists. It is called whenever a product is saved.
+func Index(db *gorm.DB, productID uint) error {
+	res := db.Exec(fmt.Sprintf(indexSQL, "AND p.id = ?"), productID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return Remove(db, productID)
+	}
+	return nil
+}
+
+// Remove drops the search document of a product.
+func Remove(db *gorm.DB, productID uint) error {
+	return db.Where("product_id = ?", productID).Delete(&types.ProductSearchDocument{}).Error
+}
+
+// Reindex rebuilds the documents of every product and drops the ones of
+// deleted products. It returns the number of documents written.
+func Reindex(db *gorm.DB) (int64, error) {
+	var written int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(fmt.Sprintf(indexSQL, ""))
+		if res.Error != nil {
+			return res.Error
+		}
+		written = res.RowsAffected
+		return tx.Where("product_id NOT IN (?)", tx.Model(&types.Product{}).Select("id")).
+			Delete(&types.ProductSearchDocument{}).Error
+	})
+	return written, err
+}
@@ -5,9 +5,14 @@ import (
 	"log"
 	"time"
 
+	"github.com/Satishcg12/multicommers/internal/address"
+	"github.com/Satishcg12/multicommers/internal/clock"
 	"github.com/Satishcg12/multicommers/internal/database"
+	"github.com/Satishcg12/multicommers/internal/jobs"
 	myMiddleware "github.com/Satishcg12/multicommers/internal/middleware"
 	"github.com/Satishcg12/multicommers/internal/router"
+	"github.com/Satishcg12/multicommers/internal/search"
+	"github.com/Satishcg12/multicommers/internal/storage"
 	"github.com/Satishcg12/multicommers/internal/types"
 	"github.com/Satishcg12/multicommers/utils/dotenv"
 	"github.com/Satishcg12/multicommers/utils/email"
@@ -54,13 +59,34 @@ func (s *Server) Start() error {
 		types.VendorIPAddress{},
 		types.Vendor{},
 		types.VendorPassword{},
+		types.VendorSession{},
 		types.VendorPhysicalAddress{},
 		types.VendorSiteVisit{},
+		types.CommissionPlan{},
+		types.LedgerAccount{},
+		types.LedgerTransaction{},
+		types.LedgerEntry{},
+		types.LedgerCursor{},
+		types.PayoutBatch{},
+		types.Payout{},
+		types.PaymentProviderConfig{},
+		types.Plan{},
+		types.VendorSubscription{},
+		types.VendorInvoice{},
+		types.VendorInvoiceLine{},
 	)
 	if err != nil {
 		log.Fatalf("Error initializing main db: %s", err)
 	}
 
+	// register tenant models
+	tenantManager.RegisterTenantModels(types.TenantModels()...)
+	tenantManager.RegisterTenantSetup(search.Setup)
+	tenantManager.RegisterTenantSetup(address.Migrate)
+	if err := tenantManager.MigrateTenants(); err != nil {
+		log.Fatalf("Error migrating tenants: %s", err)
+	}
+
 	// set tenant manager
 	TenantManager = tenantManager
 
@@ -73,10 +99,28 @@ func (s *Server) Start() error {
 	)
 	mailServer.Start()
 
+	// clock scheduling work
+	clk := clock.System
+
+	// file storage, STORAGE_DRIVER picks the local disk or an S3 bucket
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Error initializing storage: %s", err)
+	}
+
+	// background jobs
+	scheduler := jobs.NewScheduler()
+	registerJobs(scheduler, tenantManager, clk, mailServer)
+	scheduler.Start()
+
 	// middlewares
 	s.e.Use(middleware.Logger())
 	s.e.Use(middleware.Recover())
 	s.e.Use(myMiddleware.TenantDBMiddleware(tenantManager))
+	s.e.Use(myMiddleware.CustomerAuthMiddleware())
+	s.e.Use(myMiddleware.MailerMiddleware(mailServer))
+	s.e.Use(myMiddleware.ClockMiddleware(clk))
+	s.e.Use(myMiddleware.StorageMiddleware(store))
 
 	// custom validator
 	s.e.Validator = validators.NewValidator()
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// TTL is how long a customer stays signed in without using the session.
+const TTL = 30 * 24 * time.Hour
+
+// touchEvery bounds how often the use of a session is written back.
+const touchEvery = time.Hour
+
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// Create signs a customer in and returns the bearer token of the new
+// session. Only the hash of the token is stored.
+func Create(db *gorm.DB, userID uint, now time.Time) (string, types.UserSession, error) {
+	token := randomString.GenerateSecureToken(32)
+	session := types.UserSession{
+		UserID:    userID,
+		TokenHash: hash(token),
+		ExpiresAt: now.Add(TTL),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", session, err
+	}
+	return token, session, nil
+}
+
+// Lookup returns the live session of a bearer token. Using a session
+// extends it to TTL from now.
+func Lookup(db *gorm.DB, token string, now time.Time) (types.UserSession, error) {
+	session := types.UserSession{}
+	if token == "" {
+		return session, ErrInvalidSession
+	}
+	err := db.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hash(token), now).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, ErrInvalidSession
+	}
+	if err != nil {
+		return session, err
+	}
+
+	// sliding expiry, written at most once per touchEvery
+	if session.LastUsedAt == nil || now.Sub(*session.LastUsedAt) >= touchEvery {
+		session.LastUsedAt = &now
+		session.ExpiresAt = now.Add(TTL)
+		if err := db.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   session.ExpiresAt,
+		}).Error; err != nil {
+			return session, err
+		}
+	}
+	return session, nil
+}
+
+// Revoke signs the session of a bearer token out.
+func Revoke(db *gorm.DB, token string, now time.Time) error {
+	return db.Model(&types.UserSession{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hash(token)).
+		Update("revoked_at", now).Error
+}
+
+// RevokeAll signs a customer out everywhere, e.g. after a password change.
+func RevokeAll(db *gorm.DB, userID uint, now time.Time) error {
+	return db.Model(&types.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
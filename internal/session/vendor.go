@@ -0,0 +1,67 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// VendorTTL is how long a vendor stays signed in to the admin without using
+// the session. It is shorter than a customer's since the admin can move
+// money.
+const VendorTTL = 12 * time.Hour
+
+// CreateVendor signs a vendor in and returns the token of the new session.
+// Vendor sessions live in the main database.
+func CreateVendor(main *gorm.DB, vendorID uint, now time.Time) (string, types.VendorSession, error) {
+	token := randomString.GenerateSecureToken(32)
+	session := types.VendorSession{
+		VendorID:  vendorID,
+		TokenHash: hash(token),
+		ExpiresAt: now.Add(VendorTTL),
+	}
+	if err := main.Create(&session).Error; err != nil {
+		return "", session, err
+	}
+	return token, session, nil
+}
+
+// LookupVendor returns the live vendor session of a token, with its vendor.
+// Using a session extends it to VendorTTL from now.
+func LookupVendor(main *gorm.DB, token string, now time.Time) (types.VendorSession, error) {
+	session := types.VendorSession{}
+	if token == "" {
+		return session, ErrInvalidSession
+	}
+	err := main.Preload("Vendor").
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hash(token), now).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return session, ErrInvalidSession
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if session.LastUsedAt == nil || now.Sub(*session.LastUsedAt) >= touchEvery {
+		session.LastUsedAt = &now
+		session.ExpiresAt = now.Add(VendorTTL)
+		if err := main.Model(&session).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   session.ExpiresAt,
+		}).Error; err != nil {
+			return session, err
+		}
+	}
+	return session, nil
+}
+
+// RevokeVendor signs the vendor session of a token out.
+func RevokeVendor(main *gorm.DB, token string, now time.Time) error {
+	return main.Model(&types.VendorSession{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hash(token)).
+		Update("revoked_at", now).Error
+}
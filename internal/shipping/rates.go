@@ -0,0 +1,260 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/currency"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Shipping method kinds.
+const (
+	KindFlat    = "flat"
+	KindWeight  = "weight"
+	KindPrice   = "price"
+	KindCarrier = "carrier"
+)
+
+var (
+	ErrInvalidKind        = errors.New("invalid shipping method kind")
+	ErrMethodUnavailable  = errors.New("shipping method is not available for this address")
+	ErrNoShippingToRegion = errors.New("no shipping methods for this address")
+)
+
+type (
+	// Item is a cart line to ship.
+	Item struct {
+		VariantID uint
+		Quantity  int
+	}
+	// Request describes what is shipped where. Subtotal is the value of
+	// the goods after discounts in minor units of Currency, FreeShipping is set when a promotion waives
+	// the shipping cost.
+	Request struct {
+		Destination  Address
+		Items        []Item
+		Subtotal     int64
+		Currency     string
+		FreeShipping bool
+	}
+	// Option is a shipping method offered for a request and what it costs.
+	Option struct {
+		MethodID uint   `json:"method_id"`
+		Name     string `json:"name"`
+		Kind     string `json:"kind"`
+		Amount   int64  `json:"amount"`
+		Free     bool   `json:"free"`
+	}
+)
+
+// ValidKind reports whether kind is a known shipping method kind.
+func ValidKind(kind string) bool {
+	switch kind {
+	case KindFlat, KindWeight, KindPrice, KindCarrier:
+		return true
+	}
+	return false
+}
+
+// Configured reports whether the tenant set up any shipping zone. Stores
+// without one ship for free.
+func Configured(db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Model(&types.ShippingZone{}).Count(&count).Error
+	return count > 0, err
+}
+
+// ZoneFor finds the zone of the address. Zones listing postal patterns are
+// preferred over zones covering the whole country.
+func ZoneFor(db *gorm.DB, address Address) (*types.ShippingZone, error) {
+	var zones []types.ShippingZone
+	if err := db.Preload("Methods", "active = ?", true).Preload("Methods.Tiers").
+		Where("UPPER(country_code) = ?", strings.ToUpper(strings.TrimSpace(address.CountryCode))).
+		Order("id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+
+	var fallback *types.ShippingZone
+	postal := NormalizePostalCode(address.PostalCode)
+	for i := range zones {
+		if strings.TrimSpace(zones[i].PostalPatterns) == "" {
+			if fallback == nil {
+				fallback = &zones[i]
+			}
+			continue
+		}
+		for _, pattern := range strings.Split(zones[i].PostalPatterns, ",") {
+			if matched, _ := path.Match(NormalizePostalCode(pattern), postal); matched {
+				return &zones[i], nil
+			}
+		}
+	}
+	return fallback, nil
+}
+
+// NormalizePostalCode upper-cases a postal code and drops spaces and dashes.
+func NormalizePostalCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// Options lists the methods that can ship the request in the configured
+// order. Methods that cannot quote, e.g. a weight table without a matching
+// tier or a carrier that did not answer, are left out.
+func Options(ctx context.Context, db *gorm.DB, req Request) ([]Option, error) {
+	options := []Option{}
+	zone, err := ZoneFor(db, req.Destination)
+	if err != nil || zone == nil {
+		return options, err
+	}
+	weight, err := Weight(db, req.Items)
+	if err != nil {
+		return options, err
+	}
+	conv, err := currency.FromBase(db, req.Currency)
+	if err != nil {
+		return options, err
+	}
+
+	methods := zone.Methods
+	sortMethods(methods)
+	for _, method := range methods {
+		if option, ok := quote(ctx, localize(method, conv), req, weight); ok {
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
+
+// Choose prices the method with the given id for the request.
+func Choose(ctx context.Context, db *gorm.DB, req Request, methodID uint) (Option, error) {
+	options, err := Options(ctx, db, req)
+	if err != nil {
+		return Option{}, err
+	}
+	if len(options) == 0 {
+		return Option{}, ErrNoShippingToRegion
+	}
+	for _, option := range options {
+		if option.MethodID == methodID {
+			return option, nil
+		}
+	}
+	return Option{}, ErrMethodUnavailable
+}
+
+// Weight returns the total weight of the items in grams.
+func Weight(db *gorm.DB, items []Item) (int, error) {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.VariantID)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var variants []types.ProductVariant
+	if err := db.Select("id", "weight").Where("id IN ?", ids).Find(&variants).Error; err != nil {
+		return 0, err
+	}
+	weights := map[uint]int{}
+	for _, variant := range variants {
+		weights[variant.ID] = variant.Weight
+	}
+	total := 0
+	for _, item := range items {
+		total += weights[item.VariantID] * item.Quantity
+	}
+	return total, nil
+}
+
+func quote(ctx context.Context, method types.ShippingMethod, req Request, weight int) (Option, bool) {
+	option := Option{MethodID: method.ID, Name: method.Name, Kind: method.Kind}
+
+	switch method.Kind {
+	case KindFlat:
+		option.Amount = method.FlatRate
+	case KindWeight, KindPrice:
+		value := int64(weight)
+		if method.Kind == KindPrice {
+			value = req.Subtotal
+		}
+		rate, ok := tierRate(method.Tiers, value)
+		if !ok {
+			return option, false
+		}
+		option.Amount = rate
+	case KindCarrier:
+		carrier, err := CarrierByName(method.Carrier)
+		if err != nil {
+			return option, false
+		}
+		rate, err := carrier.Rate(ctx, RateRequest{
+			Destination: req.Destination,
+			Service:     method.Service,
+			Weight:      weight,
+			Value:       req.Subtotal,
+			Currency:    req.Currency,
+		})
+		if err != nil {
+			return option, false
+		}
+		option.Amount = rate
+	default:
+		return option, false
+	}
+
+	if req.FreeShipping || (method.FreeAbove > 0 && req.Subtotal >= method.FreeAbove) {
+		option.Amount = 0
+		option.Free = true
+	}
+	return option, true
+}
+
+// localize converts the amounts of a method, set in the base currency, into
+// the currency of the request. Carriers quote in that currency themselves.
+func localize(method types.ShippingMethod, conv currency.Converter) types.ShippingMethod {
+	method.FlatRate = conv.Convert(method.FlatRate)
+	method.FreeAbove = conv.Convert(method.FreeAbove)
+	tiers := make([]types.ShippingRateTier, len(method.Tiers))
+	for i, tier := range method.Tiers {
+		tier.Rate = conv.Convert(tier.Rate)
+		if method.Kind == KindPrice {
+			tier.MinValue = conv.Convert(tier.MinValue)
+		}
+		tiers[i] = tier
+	}
+	method.Tiers = tiers
+	return method
+}
+
+// tierRate picks the rate of the tier with the highest threshold not above
+// value.
+func tierRate(tiers []types.ShippingRateTier, value int64) (int64, bool) {
+	found := false
+	var best types.ShippingRateTier
+	for _, tier := range tiers {
+		if tier.MinValue <= value && (!found || tier.MinValue > best.MinValue) {
+			best = tier
+			found = true
+		}
+	}
+	return best.Rate, found
+}
+
+func sortMethods(methods []types.ShippingMethod) {
+	sort.SliceStable(methods, func(i, j int) bool {
+		if methods[i].Position != methods[j].Position {
+			return methods[i].Position < methods[j].Position
+		}
+		return methods[i].ID < methods[j].ID
+	})
+}
@@ -0,0 +1,179 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+// failingCarrier never answers, like a carrier whose API is down.
+type failingCarrier struct{}
+
+func (c failingCarrier) Name() string { return "failing" }
+
+func (c failingCarrier) Rate(ctx context.Context, req RateRequest) (int64, error) {
+	return 0, errors.New("carrier unavailable")
+}
+
+func init() {
+	RegisterCarrier(failingCarrier{})
+}
+
+func TestLocalCarrierRate(t *testing.T) {
+	carrier := &LocalCarrier{}
+	cases := []struct {
+		weight  int
+		service string
+		want    int64
+	}{
+		{0, ServiceStandard, 500},
+		{1, ServiceStandard, 600},
+		{1000, ServiceStandard, 600},
+		{1001, ServiceStandard, 700},
+		{2500, ServiceExpress, 1600},
+	}
+	for _, c := range cases {
+		got, err := carrier.Rate(context.Background(), RateRequest{Weight: c.weight, Service: c.service})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s parcel of %dg costs %d, want %d", c.service, c.weight, got, c.want)
+		}
+	}
+}
+
+func TestTierRate(t *testing.T) {
+	tiers := []types.ShippingRateTier{
+		{MinValue: 1000, Rate: 800},
+		{MinValue: 0, Rate: 500},
+		{MinValue: 5000, Rate: 1500},
+	}
+	cases := []struct {
+		value int64
+		want  int64
+	}{
+		{0, 500},
+		{999, 500},
+		{1000, 800},
+		{4999, 800},
+		{5000, 1500},
+		{100000, 1500},
+	}
+	for _, c := range cases {
+		got, ok := tierRate(tiers, c.value)
+		if !ok || got != c.want {
+			t.Errorf("tier rate of %d is %d (%v), want %d", c.value, got, ok, c.want)
+		}
+	}
+
+	if _, ok := tierRate(tiers[2:], 100); ok {
+		t.Error("a value below every tier found a rate")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tiers := []types.ShippingRateTier{{MinValue: 0, Rate: 400}, {MinValue: 2000, Rate: 900}}
+	cases := []struct {
+		name     string
+		method   types.ShippingMethod
+		req      Request
+		weight   int
+		want     int64
+		free     bool
+		unquoted bool
+	}{
+		{name: "flat", method: types.ShippingMethod{Kind: KindFlat, FlatRate: 700}, want: 700},
+		{name: "weight tier", method: types.ShippingMethod{Kind: KindWeight, Tiers: tiers}, weight: 2500, want: 900},
+		{name: "price tier", method: types.ShippingMethod{Kind: KindPrice, Tiers: tiers}, req: Request{Subtotal: 1999}, weight: 5000, want: 400},
+		{name: "carrier", method: types.ShippingMethod{Kind: KindCarrier, Carrier: LocalCarrierName, Service: ServiceExpress}, weight: 1500, want: 1400},
+		{name: "free above threshold", method: types.ShippingMethod{Kind: KindFlat, FlatRate: 700, FreeAbove: 5000}, req: Request{Subtotal: 5000}, want: 0, free: true},
+		{name: "below threshold", method: types.ShippingMethod{Kind: KindFlat, FlatRate: 700, FreeAbove: 5000}, req: Request{Subtotal: 4999}, want: 700},
+		{name: "no threshold", method: types.ShippingMethod{Kind: KindFlat, FlatRate: 700}, req: Request{Subtotal: 1000000}, want: 700},
+		{name: "free shipping promotion", method: types.ShippingMethod{Kind: KindCarrier, Carrier: LocalCarrierName}, req: Request{FreeShipping: true}, want: 0, free: true},
+		{name: "carrier threshold", method: types.ShippingMethod{Kind: KindCarrier, Carrier: LocalCarrierName, FreeAbove: 3000}, req: Request{Subtotal: 3000}, weight: 800, want: 0, free: true},
+		{name: "no matching tier", method: types.ShippingMethod{Kind: KindWeight, Tiers: tiers[1:]}, weight: 100, unquoted: true},
+		{name: "carrier down", method: types.ShippingMethod{Kind: KindCarrier, Carrier: "failing"}, unquoted: true},
+		{name: "unknown carrier", method: types.ShippingMethod{Kind: KindCarrier, Carrier: "nowhere"}, unquoted: true},
+		{name: "unknown kind", method: types.ShippingMethod{Kind: "pigeon"}, unquoted: true},
+	}
+	for _, c := range cases {
+		option, ok := quote(context.Background(), c.method, c.req, c.weight)
+		if ok == c.unquoted {
+			t.Errorf("%s: quoted %v, want %v", c.name, ok, !c.unquoted)
+			continue
+		}
+		if ok && (option.Amount != c.want || option.Free != c.free) {
+			t.Errorf("%s: %d (free %v), want %d (free %v)", c.name, option.Amount, option.Free, c.want, c.free)
+		}
+	}
+}
+
+func TestOptionsPicksZoneAndOrder(t *testing.T) {
+	db := testdb.Open(t)
+
+	product := types.Product{Title: "Box", Handle: "box"}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	variant := types.ProductVariant{ProductID: product.ID, SKU: "BOX-1", Weight: 1200}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatal(err)
+	}
+	country := types.ShippingZone{Name: "US", CountryCode: "US", Methods: []types.ShippingMethod{
+		{Name: "Ground", Kind: KindFlat, FlatRate: 900, Active: true},
+	}}
+	local := types.ShippingZone{Name: "Local", CountryCode: "US", PostalPatterns: "123*", Methods: []types.ShippingMethod{
+		{Name: "Express", Kind: KindCarrier, Carrier: LocalCarrierName, Service: ServiceExpress, Active: true, Position: 2},
+		{Name: "Courier", Kind: KindFlat, FlatRate: 300, FreeAbove: 5000, Active: true, Position: 1},
+		{Name: "Retired", Kind: KindFlat, FlatRate: 100, Active: false},
+	}}
+	for _, zone := range []*types.ShippingZone{&country, &local} {
+		if err := db.Create(zone).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := Request{
+		Destination: Address{CountryCode: "us", PostalCode: "123-45"},
+		Items:       []Item{{VariantID: variant.ID, Quantity: 2}},
+		Subtotal:    4000,
+		Currency:    "USD",
+	}
+	options, err := Options(context.Background(), db, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 2 || options[0].Name != "Courier" || options[1].Name != "Express" {
+		t.Fatalf("options %+v, want Courier then Express", options)
+	}
+	if options[0].Amount != 300 || options[1].Amount != 1600 {
+		t.Errorf("courier %d, express %d, want 300 and 1600 for 2.4kg", options[0].Amount, options[1].Amount)
+	}
+
+	req.Subtotal = 5000
+	courier, err := Choose(context.Background(), db, req, options[0].MethodID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !courier.Free || courier.Amount != 0 {
+		t.Errorf("courier above the threshold costs %d", courier.Amount)
+	}
+
+	req.Destination.PostalCode = "99999"
+	options, err = Options(context.Background(), db, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].Name != "Ground" {
+		t.Errorf("options outside the local zone %+v, want Ground", options)
+	}
+
+	req.Destination.CountryCode = "CA"
+	if _, err := Choose(context.Background(), db, req, options[0].MethodID); !errors.Is(err, ErrNoShippingToRegion) {
+		t.Errorf("choosing for an unserved country returned %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reader returns a function reading the whole object a Get or GetRange
+// call returned.
+func reader(t *testing.T) func(io.ReadCloser, error) string {
+	return func(r io.ReadCloser, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+}
+
+func TestLocalPutGetDelete(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root, "/api/media/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	read := reader(t)
+
+	body := "0123456789"
+	if err := store.Put(ctx, "tenant/products/1/photo.jpg", strings.NewReader(body), int64(len(body)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tenant", "products", "1", "photo.jpg")); err != nil {
+		t.Errorf("object not stored below the root: %s", err)
+	}
+	if got := read(store.Get(ctx, "tenant/products/1/photo.jpg")); got != body {
+		t.Errorf("read back %q", got)
+	}
+	if got := read(store.GetRange(ctx, "tenant/products/1/photo.jpg", 3, 4)); got != "3456" {
+		t.Errorf("range read %q, want 3456", got)
+	}
+	if got := read(store.GetRange(ctx, "tenant/products/1/photo.jpg", 8, 100)); got != "89" {
+		t.Errorf("range past the end read %q, want 89", got)
+	}
+
+	// overwriting replaces the whole object and leaves no temporary files
+	if err := store.Put(ctx, "tenant/products/1/photo.jpg", strings.NewReader("new"), 3, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(store.Get(ctx, "tenant/products/1/photo.jpg")); got != "new" {
+		t.Errorf("read back %q after overwriting", got)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "tenant", "products", "1"))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the object (%v)", len(entries), err)
+	}
+
+	if err := store.Delete(ctx, "tenant/products/1/photo.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "tenant/products/1/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete returned %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "tenant/products/1/photo.jpg"); err != nil {
+		t.Errorf("deleting a missing object returned %v", err)
+	}
+}
+
+func TestLocalRefusesKeysOutsideRoot(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "/api/media", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"", "../escape", "a/../../escape", "/absolute", "a//b", "a/./b", "a/"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("put %q returned %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("get %q returned %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.SignedURL(key, time.Now().Add(time.Hour)); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("signing %q returned %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "https://shop.example/api/media", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	key := "tenant/digital/1/manual v2.pdf"
+
+	if got := store.PublicURL(key); got != "https://shop.example/api/media/tenant/digital/1/manual%20v2.pdf" {
+		t.Errorf("public url %s", got)
+	}
+
+	signed, err := store.SignedURL(key, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	if !store.Verify(key, expires, signature, now) {
+		t.Error("a fresh signature did not verify")
+	}
+	if !store.Verify(key, expires, signature, now.Add(time.Hour)) {
+		t.Error("a signature did not verify at its expiry")
+	}
+	if store.Verify(key, expires, signature, now.Add(time.Hour+time.Second)) {
+		t.Error("an expired signature verified")
+	}
+	if store.Verify("tenant/digital/1/other.pdf", expires, signature, now) {
+		t.Error("a signature verified for another key")
+	}
+	if store.Verify(key, "9999999999", signature, now) {
+		t.Error("a signature verified with a pushed back expiry")
+	}
+
+	other, err := NewLocal(t.TempDir(), "https://shop.example/api/media", "another secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Verify(key, expires, signature, now) {
+		t.Error("a signature verified with another secret")
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Satishcg12/multicommers/utils/dotenv"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage keeps uploaded files. Keys are slash separated paths; objects
+// meant to be public are reachable at PublicURL while every other object is
+// only served through a SignedURL.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange reads length bytes of an object starting at offset.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// PublicURL returns the permanent URL of a public object.
+	PublicURL(key string) string
+	// SignedURL returns a URL that grants read access to key until expires.
+	SignedURL(key string, expires time.Time) (string, error)
+}
+
+// FromEnv builds the storage selected by STORAGE_DRIVER, "local" (the
+// default) or "s3".
+func FromEnv() (Storage, error) {
+	switch driver := dotenv.GetEnvOrDefault("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocal(
+			dotenv.GetEnvOrDefault("STORAGE_PATH", "./storage"),
+			dotenv.GetEnvOrDefault("STORAGE_URL", "/api/media"),
+			dotenv.GetEnvOrDefault("STORAGE_SECRET", ""),
+		)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  dotenv.GetEnvOrDefault("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    dotenv.GetEnvOrDefault("S3_REGION", "us-east-1"),
+			Bucket:    dotenv.GetEnvOrDefault("S3_BUCKET", ""),
+			AccessKey: dotenv.GetEnvOrDefault("S3_ACCESS_KEY", ""),
+			SecretKey: dotenv.GetEnvOrDefault("S3_SECRET_KEY", ""),
+			PublicURL: dotenv.GetEnvOrDefault("S3_PUBLIC_URL", ""),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
@@ -0,0 +1,160 @@
+package storecredit
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Transaction kinds. Refund adds money given back on an order, redeem takes
+// credit off to pay an order and release gives it back when that order is
+// cancelled unpaid.
+const (
+	KindRefund     = "refund"
+	KindAdjustment = "adjustment"
+	KindRedeem     = "redeem"
+	KindRelease    = "release"
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrNoCredit      = errors.New("no store credit available")
+)
+
+// Account returns the store credit account of the customer in currency,
+// creating it when needed.
+func Account(tx *gorm.DB, userID uint, currency string) (types.StoreCreditAccount, error) {
+	account := types.StoreCreditAccount{UserID: userID, Currency: currency}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error
+	if err != nil {
+		return account, err
+	}
+	err = tx.Where("user_id = ? AND currency = ?", userID, currency).First(&account).Error
+	return account, err
+}
+
+// Balance is the credit left on the account.
+func Balance(db *gorm.DB, accountID uint) (int64, error) {
+	var balance int64
+	err := db.Model(&types.StoreCreditTransaction{}).
+		Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// Entry is a change to a customer's credit. OrderID and RefundID tie it to
+// what caused it.
+type Entry struct {
+	UserID   uint
+	Currency string
+	Kind     string
+	Amount   int64
+	OrderID  *uint
+	RefundID *uint
+	Note     string
+}
+
+// Record appends e to the customer's account. The balance cannot go below
+// zero.
+func Record(db *gorm.DB, e Entry) (types.StoreCreditTransaction, error) {
+	transaction := types.StoreCreditTransaction{}
+	if e.Amount == 0 {
+		return transaction, ErrInvalidAmount
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		account, err := lock(tx, e.UserID, e.Currency)
+		if err != nil {
+			return err
+		}
+		if e.Amount < 0 {
+			balance, err := Balance(tx, account.ID)
+			if err != nil {
+				return err
+			}
+			if balance+e.Amount < 0 {
+				return ErrInvalidAmount
+			}
+		}
+		transaction = types.StoreCreditTransaction{
+			AccountID: account.ID,
+			Kind:      e.Kind,
+			Amount:    e.Amount,
+			OrderID:   e.OrderID,
+			RefundID:  e.RefundID,
+			Note:      e.Note,
+		}
+		return tx.Create(&transaction).Error
+	})
+	return transaction, err
+}
+
+// Redeem takes up to amount of the customer's credit for the order and
+// returns what it took.
+func Redeem(tx *gorm.DB, userID uint, currency string, amount int64, orderID uint) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	account, err := lock(tx, userID, currency)
+	if err != nil {
+		return 0, err
+	}
+	balance, err := Balance(tx, account.ID)
+	if err != nil {
+		return 0, err
+	}
+	if balance <= 0 {
+		return 0, ErrNoCredit
+	}
+	taken := min(amount, balance)
+	err = tx.Create(&types.StoreCreditTransaction{
+		AccountID: account.ID,
+		Kind:      KindRedeem,
+		Amount:    -taken,
+		OrderID:   &orderID,
+	}).Error
+	return taken, err
+}
+
+// Outstanding is the store credit the order was paid with and has not given
+// back yet. It is negative once refunds to store credit passed that.
+func Outstanding(db *gorm.DB, orderID uint) (int64, error) {
+	var total int64
+	err := db.Model(&types.StoreCreditTransaction{}).
+		Where("order_id = ? AND kind IN ?", orderID, []string{KindRedeem, KindRelease, KindRefund}).
+		Select("COALESCE(-SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// Release gives back the credit an order cancelled before it was paid took.
+func Release(tx *gorm.DB, o types.Order) error {
+	if o.UserID == nil {
+		return nil
+	}
+	held, err := Outstanding(tx, o.ID)
+	if err != nil || held <= 0 {
+		return err
+	}
+	_, err = Record(tx, Entry{
+		UserID:   *o.UserID,
+		Currency: o.Currency,
+		Kind:     KindRelease,
+		Amount:   held,
+		OrderID:  &o.ID,
+		Note:     "order cancelled",
+	})
+	return err
+}
+
+// lock returns the customer's account locked for update.
+func lock(tx *gorm.DB, userID uint, currency string) (types.StoreCreditAccount, error) {
+	account, err := Account(tx, userID, currency)
+	if err != nil {
+		return account, err
+	}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, account.ID).Error
+	return account, err
+}
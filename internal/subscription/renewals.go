@@ -0,0 +1,282 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/cart"
+	"github.com/Satishcg12/multicommers/internal/notification"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"gorm.io/gorm"
+)
+
+// Renewal results.
+const (
+	ResultRenewed   = "renewed"
+	ResultRetrying  = "retrying"
+	ResultCancelled = "cancelled"
+	ResultPending   = "pending"
+)
+
+// billingLease keeps other runs off a subscription while it is being billed.
+const billingLease = 10 * time.Minute
+
+// pendingRecheck is the wait before a renewal order whose payment was in
+// progress is looked at again.
+const pendingRecheck = 15 * time.Minute
+
+var errPaymentNotReceived = errors.New("renewal order was cancelled before it was paid")
+
+// RetrySchedule is the wait before each new attempt at a failed renewal. The
+// subscription is cancelled when the schedule runs out.
+var RetrySchedule = []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 5 * 24 * time.Hour}
+
+// Outcome tells what billing a subscription led to. Order is the renewal
+// order when one was placed, Reason why the renewal failed.
+type Outcome struct {
+	Subscription types.CustomerSubscription `json:"subscription"`
+	Order        *types.Order               `json:"order,omitempty"`
+	Result       string                     `json:"result"`
+	Reason       string                     `json:"reason,omitempty"`
+}
+
+// Renew resumes the subscriptions whose pause is over, then bills every
+// subscription that is due or due for another attempt.
+func Renew(ctx context.Context, db *gorm.DB, now time.Time) ([]Outcome, error) {
+	if err := db.Model(&types.CustomerSubscription{}).
+		Where("status = ? AND paused_until IS NOT NULL AND paused_until <= ?", StatusPaused, now).
+		Updates(map[string]interface{}{
+			"status":          StatusActive,
+			"paused_until":    nil,
+			"next_billing_at": gorm.Expr("GREATEST(next_billing_at, ?)", now),
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	var due []types.CustomerSubscription
+	if err := db.Where("(status = ? AND next_billing_at <= ?) OR status = ?", StatusActive, now, StatusPastDue).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", now).
+		Order("next_billing_at").
+		Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	outcomes := []Outcome{}
+	for i := range due {
+		outcome, err := Bill(ctx, db, &due[i], now)
+		if errors.Is(err, ErrInvalidState) {
+			// billed by another run
+			continue
+		}
+		if err != nil {
+			return outcomes, err
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, nil
+}
+
+// Bill places the renewal order of sub and charges its saved payment method.
+// A failed renewal is retried along RetrySchedule and cancels the
+// subscription once the schedule ran out. When the provider did not answer
+// the renewal is pending: the period is not advanced, no attempt is counted
+// and no order is placed until the pending order is paid or cancelled.
+// ErrInvalidState is returned when sub changed since it was read.
+func Bill(ctx context.Context, db *gorm.DB, sub *types.CustomerSubscription, now time.Time) (Outcome, error) {
+	// claim the subscription so concurrent runs do not charge it twice
+	claim := db.Model(&types.CustomerSubscription{}).
+		Where("id = ? AND status = ? AND failed_attempts = ? AND next_billing_at = ?", sub.ID, sub.Status, sub.FailedAttempts, sub.NextBillingAt).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", now).
+		Update("next_retry_at", now.Add(billingLease))
+	if claim.Error != nil {
+		return Outcome{}, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return Outcome{}, ErrInvalidState
+	}
+	if err := reload(db, sub); err != nil {
+		return Outcome{}, err
+	}
+	if sub.PendingOrderID != nil {
+		return settle(db, sub, now)
+	}
+
+	o, err := placeOrder(ctx, db, *sub)
+	if err == nil {
+		err = charge(ctx, db, sub, &o)
+	}
+	if paymentPending(err) {
+		return wait(db, sub, &o, err, now)
+	}
+	if err != nil {
+		var placed *types.Order
+		if o.ID != 0 {
+			placed = &o
+		}
+		return fail(db, sub, placed, err, now)
+	}
+	return renewed(db, sub, &o, now)
+}
+
+// settle follows up on the pending renewal order of sub: a paid order
+// renews the subscription, a cancelled one counts as a failed attempt.
+func settle(db *gorm.DB, sub *types.CustomerSubscription, now time.Time) (Outcome, error) {
+	o := types.Order{}
+	if err := db.First(&o, *sub.PendingOrderID).Error; err != nil {
+		return Outcome{}, err
+	}
+	switch o.Status {
+	case order.StatusPendingPayment:
+		return wait(db, sub, &o, order.ErrPaymentInProgress, now)
+	case order.StatusCancelled:
+		return fail(db, sub, &o, errPaymentNotReceived, now)
+	}
+	return renewed(db, sub, &o, now)
+}
+
+// renewed moves sub to its next period, paid with o.
+func renewed(db *gorm.DB, sub *types.CustomerSubscription, o *types.Order, now time.Time) (Outcome, error) {
+	next := advance(sub.NextBillingAt, sub.Plan)
+	if !next.After(now) {
+		next = advance(now, sub.Plan)
+	}
+	if err := db.Model(sub).Omit("Plan").Updates(map[string]interface{}{
+		"status":           StatusActive,
+		"next_billing_at":  next,
+		"failed_attempts":  0,
+		"next_retry_at":    nil,
+		"last_order_id":    o.ID,
+		"pending_order_id": nil,
+	}).Error; err != nil {
+		return Outcome{}, err
+	}
+	if err := reload(db, sub); err != nil {
+		return Outcome{}, err
+	}
+	return Outcome{Subscription: *sub, Order: o, Result: ResultRenewed}, nil
+}
+
+// wait keeps o as the pending renewal order of sub and looks at it again
+// after pendingRecheck.
+func wait(db *gorm.DB, sub *types.CustomerSubscription, o *types.Order, cause error, now time.Time) (Outcome, error) {
+	outcome := Outcome{Order: o, Result: ResultPending, Reason: cause.Error()}
+	if err := db.Model(sub).Omit("Plan").Updates(map[string]interface{}{
+		"next_retry_at":    now.Add(pendingRecheck),
+		"last_order_id":    o.ID,
+		"pending_order_id": o.ID,
+	}).Error; err != nil {
+		return outcome, err
+	}
+	if err := reload(db, sub); err != nil {
+		return outcome, err
+	}
+	outcome.Subscription = *sub
+	return outcome, nil
+}
+
+// paymentPending reports whether err leaves the outcome of a payment open.
+func paymentPending(err error) bool {
+	return errors.Is(err, payment.ErrTimeout) || errors.Is(err, order.ErrPaymentInProgress)
+}
+
+// placeOrder checks out a cart holding the subscribed variant, at the
+// plan's discount.
+func placeOrder(ctx context.Context, db *gorm.DB, sub types.CustomerSubscription) (types.Order, error) {
+	o := types.Order{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		c, err := cart.New(tx, &sub.UserID)
+		if err != nil {
+			return err
+		}
+		if sub.Currency != "" {
+			if err := cart.SetCurrency(tx, &c, sub.Currency); err != nil {
+				return err
+			}
+		}
+		if err := cart.AddItem(tx, &c, sub.VariantID, sub.Quantity); err != nil {
+			return err
+		}
+		if err := tx.Preload("Items").First(&c, c.ID).Error; err != nil {
+			return err
+		}
+
+		o, err = order.Checkout(ctx, tx, order.CheckoutInput{
+			Cart:                c,
+			Email:               sub.Email,
+			ShippingMethodID:    sub.ShippingMethodID,
+			DiscountRate:        sub.Plan.DiscountRate,
+			DiscountDescription: "Subscription: " + sub.Plan.Name,
+		})
+		if err != nil {
+			return err
+		}
+		o.SubscriptionID = &sub.ID
+		return tx.Model(&o).Update("subscription_id", sub.ID).Error
+	})
+	if err != nil {
+		return types.Order{}, err
+	}
+	return o, nil
+}
+
+// charge pays o with the payment method saved on sub. An order whose payment
+// was refused is cancelled so its stock is released. When the provider did
+// not answer, or another payment of the order is in progress, the order is
+// left to its webhook and Bill waits for it.
+func charge(ctx context.Context, db *gorm.DB, sub *types.CustomerSubscription, o *types.Order) error {
+	provider, err := payment.ForTenant(db, sub.PaymentProvider)
+	if err == nil {
+		_, err = order.Pay(ctx, db, o, provider, sub.PaymentMethod)
+	}
+	if err != nil && !paymentPending(err) && o.Status == order.StatusPendingPayment {
+		if cancelErr := order.Transition(db, o, order.StatusCancelled, "subscription payment failed"); cancelErr != nil {
+			return cancelErr
+		}
+	}
+	return err
+}
+
+// fail records a failed renewal and schedules the next attempt, or cancels
+// the subscription when there is none left.
+func fail(db *gorm.DB, sub *types.CustomerSubscription, o *types.Order, cause error, now time.Time) (Outcome, error) {
+	attempts := sub.FailedAttempts + 1
+	outcome := Outcome{Order: o, Result: ResultRetrying, Reason: cause.Error()}
+
+	updates := map[string]interface{}{"failed_attempts": attempts, "pending_order_id": nil}
+	if attempts > len(RetrySchedule) {
+		outcome.Result = ResultCancelled
+		updates["status"] = StatusCancelled
+		updates["cancelled_at"] = now
+		updates["next_retry_at"] = nil
+	} else {
+		updates["status"] = StatusPastDue
+		updates["next_retry_at"] = now.Add(RetrySchedule[attempts-1])
+	}
+	if o != nil {
+		updates["last_order_id"] = o.ID
+	}
+	if err := db.Model(sub).Omit("Plan").Updates(updates).Error; err != nil {
+		return outcome, err
+	}
+	if err := reload(db, sub); err != nil {
+		return outcome, err
+	}
+	outcome.Subscription = *sub
+	return outcome, nil
+}
+
+// Notify sends the dunning emails for failed renewals.
+func Notify(mailer email.EmailDaemonInterface, outcomes ...Outcome) {
+	for _, outcome := range outcomes {
+		switch outcome.Result {
+		case ResultRetrying:
+			mailer.Send(notification.SubscriptionPaymentFailed(outcome.Subscription, outcome.Reason))
+		case ResultCancelled:
+			mailer.Send(notification.SubscriptionCancelled(outcome.Subscription, outcome.Reason))
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package subscription
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/clock"
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/order"
+	"github.com/Satishcg12/multicommers/internal/payment"
+	"github.com/Satishcg12/multicommers/internal/testdb"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	"gorm.io/gorm"
+)
+
+// recordingMailer keeps the messages it is asked to send.
+type recordingMailer struct {
+	sent []email.EmailMessage
+}
+
+func (m *recordingMailer) Start()                           {}
+func (m *recordingMailer) Send(msg email.EmailMessage)      { m.sent = append(m.sent, msg) }
+func (m *recordingMailer) SendEmail(msg email.EmailMessage) { m.sent = append(m.sent, msg) }
+
+// subscribe starts a monthly coffee subscription, paid with the fake
+// provider, at the time of clk.
+func subscribe(t *testing.T, db *gorm.DB, clk clock.Clock) types.CustomerSubscription {
+	t.Helper()
+	product := types.Product{Title: "Coffee", Handle: "coffee", Price: 1500, Published: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	variant := types.ProductVariant{ProductID: product.ID, SKU: "COFFEE-1", Price: 1500}
+	if err := db.Create(&variant).Error; err != nil {
+		t.Fatal(err)
+	}
+	warehouse := types.Warehouse{Name: "Main", Code: "MAIN", Active: true}
+	if err := db.Create(&warehouse).Error; err != nil {
+		t.Fatal(err)
+	}
+	location := types.StockLocation{WarehouseID: warehouse.ID, Name: "Bin", Code: "BIN", Active: true}
+	if err := db.Create(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.Adjust(db, types.StockMovement{VariantID: variant.ID, LocationID: location.ID, Quantity: 100, Reason: inventory.ReasonReceived}); err != nil {
+		t.Fatal(err)
+	}
+
+	user := types.User{FullName: "Ada Lovelace", Email: "ada@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	home := types.UserPhysicalAddress{
+		UserID: user.ID, Street: "1 Main St", City: "Springfield", ZipCode: "12345", CountryCode: "US",
+		Active: true, IsBilling: true, IsShipping: true, PrimaryBilling: true, PrimaryShipping: true,
+	}
+	if err := db.Create(&home).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(&types.PaymentProviderConfig{Provider: payment.FakeProviderName, Credentials: "{}", Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	plan := types.SubscriptionPlan{ProductID: product.ID, Name: "Monthly", Interval: IntervalMonth, IntervalCount: 1, Active: true}
+	if err := db.Create(&plan).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sub, outcome, err := Subscribe(context.Background(), db, SubscribeInput{
+		UserID:          user.ID,
+		PlanID:          plan.ID,
+		VariantID:       variant.ID,
+		Quantity:        1,
+		PaymentProvider: payment.FakeProviderName,
+		PaymentMethod:   payment.FakeMethodSuccess,
+	}, clk.Now())
+	if err != nil {
+		t.Fatalf("subscribe: %s", err)
+	}
+	if outcome.Result != ResultRenewed || outcome.Order == nil || outcome.Order.Status != order.StatusPaid {
+		t.Fatalf("first order: %+v", outcome)
+	}
+	return sub
+}
+
+// declinePayments makes the fake provider refuse every payment from now on,
+// or accept them again.
+func declinePayments(t *testing.T, db *gorm.DB, decline bool) {
+	t.Helper()
+	outcome := ""
+	if decline {
+		outcome = payment.OutcomeDeclined
+	}
+	paymentOutcome(t, db, outcome)
+}
+
+// paymentOutcome sets the outcome of every payment with the fake provider
+// from now on, empty for success.
+func paymentOutcome(t *testing.T, db *gorm.DB, outcome string) {
+	t.Helper()
+	credentials := "{}"
+	if outcome != "" {
+		credentials = `{"outcome":"` + outcome + `"}`
+	}
+	if err := db.Model(&types.PaymentProviderConfig{}).Where("provider = ?", payment.FakeProviderName).
+		Update("credentials", credentials).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func renew(t *testing.T, db *gorm.DB, clk clock.Clock) []Outcome {
+	t.Helper()
+	outcomes, err := Renew(context.Background(), db, clk.Now())
+	if err != nil {
+		t.Fatalf("renew at %s: %s", clk.Now(), err)
+	}
+	return outcomes
+}
+
+func TestRenewalsFollowTheClock(t *testing.T) {
+	db := testdb.Open(t)
+	start := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewManual(start)
+	sub := subscribe(t, db, clk)
+
+	want := time.Date(2026, time.March, 3, 9, 0, 0, 0, time.UTC)
+	if !sub.NextBillingAt.Equal(want) {
+		t.Fatalf("next billing at %s, want %s", sub.NextBillingAt, want)
+	}
+
+	if outcomes := renew(t, db, clk); len(outcomes) != 0 {
+		t.Errorf("renewed %d subscriptions right after subscribing", len(outcomes))
+	}
+	clk.Set(want.Add(-time.Minute))
+	if outcomes := renew(t, db, clk); len(outcomes) != 0 {
+		t.Errorf("renewed %d subscriptions a minute early", len(outcomes))
+	}
+
+	clk.Set(want)
+	outcomes := renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultRenewed {
+		t.Fatalf("outcomes %+v, want one renewal", outcomes)
+	}
+	if outcomes[0].Order.Status != order.StatusPaid {
+		t.Errorf("renewal order is %s, want paid", outcomes[0].Order.Status)
+	}
+	next := time.Date(2026, time.April, 3, 9, 0, 0, 0, time.UTC)
+	if !outcomes[0].Subscription.NextBillingAt.Equal(next) {
+		t.Errorf("next billing at %s, want %s", outcomes[0].Subscription.NextBillingAt, next)
+	}
+
+	// a run late by more than an interval bills once and catches up
+	clk.Set(next.AddDate(0, 2, 0))
+	outcomes = renew(t, db, clk)
+	if len(outcomes) != 1 {
+		t.Fatalf("%d renewals after two missed months, want 1", len(outcomes))
+	}
+	if !outcomes[0].Subscription.NextBillingAt.After(clk.Now()) {
+		t.Errorf("next billing at %s is not after %s", outcomes[0].Subscription.NextBillingAt, clk.Now())
+	}
+}
+
+func TestDunningRetriesThenCancels(t *testing.T) {
+	db := testdb.Open(t)
+	clk := clock.NewManual(time.Date(2026, time.May, 1, 9, 0, 0, 0, time.UTC))
+	sub := subscribe(t, db, clk)
+	mailer := &recordingMailer{}
+
+	declinePayments(t, db, true)
+	clk.Set(sub.NextBillingAt)
+	for attempt, wait := range RetrySchedule {
+		outcomes := renew(t, db, clk)
+		if len(outcomes) != 1 || outcomes[0].Result != ResultRetrying {
+			t.Fatalf("attempt %d: outcomes %+v, want a retry", attempt+1, outcomes)
+		}
+		Notify(mailer, outcomes...)
+		got := outcomes[0].Subscription
+		if got.Status != StatusPastDue || got.FailedAttempts != attempt+1 {
+			t.Errorf("attempt %d: %s with %d failed attempts", attempt+1, got.Status, got.FailedAttempts)
+		}
+		if got.NextRetryAt == nil || !got.NextRetryAt.Equal(clk.Now().Add(wait)) {
+			t.Errorf("attempt %d: next retry at %v, want %s", attempt+1, got.NextRetryAt, clk.Now().Add(wait))
+		}
+		if outcomes[0].Order == nil || outcomes[0].Order.Status != order.StatusCancelled {
+			t.Errorf("attempt %d: the unpaid renewal order was not cancelled", attempt+1)
+		}
+
+		// nothing happens before the retry is due
+		clk.Advance(wait - time.Minute)
+		if outcomes := renew(t, db, clk); len(outcomes) != 0 {
+			t.Errorf("attempt %d: retried %d times early", attempt+1, len(outcomes))
+		}
+		clk.Advance(time.Minute)
+	}
+
+	outcomes := renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultCancelled {
+		t.Fatalf("outcomes %+v, want the subscription cancelled", outcomes)
+	}
+	Notify(mailer, outcomes...)
+	if outcomes[0].Subscription.Status != StatusCancelled || outcomes[0].Subscription.CancelledAt == nil {
+		t.Errorf("subscription is %s", outcomes[0].Subscription.Status)
+	}
+	if len(mailer.sent) != len(RetrySchedule)+1 {
+		t.Errorf("%d dunning emails, want %d", len(mailer.sent), len(RetrySchedule)+1)
+	}
+
+	clk.Advance(365 * 24 * time.Hour)
+	if outcomes := renew(t, db, clk); len(outcomes) != 0 {
+		t.Errorf("a cancelled subscription was billed again: %+v", outcomes)
+	}
+}
+
+func TestTimedOutRenewalWaitsForItsOrder(t *testing.T) {
+	db := testdb.Open(t)
+	clk := clock.NewManual(time.Date(2026, time.May, 1, 9, 0, 0, 0, time.UTC))
+	sub := subscribe(t, db, clk)
+	mailer := &recordingMailer{}
+
+	paymentOutcome(t, db, payment.OutcomeTimeout)
+	clk.Set(sub.NextBillingAt)
+	outcomes := renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultPending || outcomes[0].Order == nil {
+		t.Fatalf("outcomes %+v, want a pending renewal", outcomes)
+	}
+	Notify(mailer, outcomes...)
+	pending := *outcomes[0].Order
+	got := outcomes[0].Subscription
+	if got.Status != StatusActive || got.FailedAttempts != 0 || !got.NextBillingAt.Equal(sub.NextBillingAt) {
+		t.Errorf("after a timeout: %s with %d failed attempts, next billing at %s", got.Status, got.FailedAttempts, got.NextBillingAt)
+	}
+	if got.PendingOrderID == nil || *got.PendingOrderID != pending.ID || pending.Status != order.StatusPendingPayment {
+		t.Errorf("pending order %v, want order %d awaiting payment", got.PendingOrderID, pending.ID)
+	}
+
+	// payments work again, but the first order may still be paid
+	paymentOutcome(t, db, "")
+	clk.Advance(pendingRecheck)
+	outcomes = renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultPending || outcomes[0].Order.ID != pending.ID {
+		t.Fatalf("outcomes %+v, want the same order still pending", outcomes)
+	}
+	Notify(mailer, outcomes...)
+	if len(mailer.sent) != 0 {
+		t.Errorf("%d dunning emails for a pending payment", len(mailer.sent))
+	}
+
+	if err := order.MarkPaid(db, &pending, "payment captured"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(pendingRecheck)
+	outcomes = renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultRenewed || outcomes[0].Order.ID != pending.ID {
+		t.Fatalf("outcomes %+v, want a renewal with the pending order", outcomes)
+	}
+	got = outcomes[0].Subscription
+	if want := sub.NextBillingAt.AddDate(0, 1, 0); !got.NextBillingAt.Equal(want) || got.PendingOrderID != nil {
+		t.Errorf("after the payment: next billing at %s, pending order %v, want %s and none", got.NextBillingAt, got.PendingOrderID, want)
+	}
+
+	var renewals int64
+	if err := db.Model(&types.Order{}).Where("subscription_id = ?", sub.ID).Count(&renewals).Error; err != nil {
+		t.Fatal(err)
+	}
+	if renewals != 2 {
+		t.Errorf("%d orders for the subscription, want the first and one renewal", renewals)
+	}
+}
+
+func TestDunningRecovers(t *testing.T) {
+	db := testdb.Open(t)
+	clk := clock.NewManual(time.Date(2026, time.May, 1, 9, 0, 0, 0, time.UTC))
+	sub := subscribe(t, db, clk)
+
+	declinePayments(t, db, true)
+	clk.Set(sub.NextBillingAt)
+	if outcomes := renew(t, db, clk); len(outcomes) != 1 || outcomes[0].Result != ResultRetrying {
+		t.Fatalf("outcomes %+v, want a retry", outcomes)
+	}
+
+	declinePayments(t, db, false)
+	clk.Advance(RetrySchedule[0])
+	outcomes := renew(t, db, clk)
+	if len(outcomes) != 1 || outcomes[0].Result != ResultRenewed {
+		t.Fatalf("outcomes %+v, want a renewal", outcomes)
+	}
+	got := outcomes[0].Subscription
+	if got.Status != StatusActive || got.FailedAttempts != 0 || got.NextRetryAt != nil {
+		t.Errorf("after recovering: %s with %d failed attempts, retry at %v", got.Status, got.FailedAttempts, got.NextRetryAt)
+	}
+	// the billing date stays on the original schedule
+	want := sub.NextBillingAt.AddDate(0, 1, 0)
+	if !got.NextBillingAt.Equal(want) {
+		t.Errorf("next billing at %s, want %s", got.NextBillingAt, want)
+	}
+}
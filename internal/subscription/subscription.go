@@ -0,0 +1,198 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+// Subscription statuses.
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusPastDue   = "past_due"
+	StatusCancelled = "cancelled"
+)
+
+// Plan intervals.
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+var (
+	ErrPlanInactive       = errors.New("subscription plan is not available")
+	ErrVariantMismatch    = errors.New("variant does not belong to the plan's product")
+	ErrInvalidState       = errors.New("subscription is not in a state that allows this operation")
+	ErrInvalidPauseDate   = errors.New("pause must end in the future")
+	ErrFirstPaymentFailed = errors.New("first subscription order could not be paid")
+)
+
+// ValidInterval reports whether interval is a supported plan interval.
+func ValidInterval(interval string) bool {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		return true
+	}
+	return false
+}
+
+// advance is the billing date following t on plan.
+func advance(t time.Time, plan types.SubscriptionPlan) time.Time {
+	count := plan.IntervalCount
+	if count < 1 {
+		count = 1
+	}
+	switch plan.Interval {
+	case IntervalDay:
+		return t.AddDate(0, 0, count)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7*count)
+	case IntervalYear:
+		return t.AddDate(count, 0, 0)
+	}
+	return t.AddDate(0, count, 0)
+}
+
+// SubscribeInput describes a new subscription. Currency empty means the base
+// currency, ShippingMethodID is needed when the store ships with zones.
+type SubscribeInput struct {
+	UserID           uint
+	PlanID           uint
+	VariantID        uint
+	Quantity         int
+	Currency         string
+	ShippingMethodID *uint
+	PaymentProvider  string
+	PaymentMethod    string
+}
+
+// Subscribe starts a subscription and places its first order right away. When
+// that order cannot be paid the subscription is cancelled and an error
+// wrapping ErrFirstPaymentFailed is returned along with the outcome. A first
+// payment still in progress leaves the subscription pending on its order.
+func Subscribe(ctx context.Context, db *gorm.DB, in SubscribeInput, now time.Time) (types.CustomerSubscription, Outcome, error) {
+	plan := types.SubscriptionPlan{}
+	if err := db.First(&plan, in.PlanID).Error; err != nil {
+		return types.CustomerSubscription{}, Outcome{}, err
+	}
+	if !plan.Active {
+		return types.CustomerSubscription{}, Outcome{}, ErrPlanInactive
+	}
+	if err := db.Where("id = ? AND product_id = ?", in.VariantID, plan.ProductID).First(&types.ProductVariant{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.CustomerSubscription{}, Outcome{}, ErrVariantMismatch
+		}
+		return types.CustomerSubscription{}, Outcome{}, err
+	}
+	user := types.User{}
+	if err := db.First(&user, in.UserID).Error; err != nil {
+		return types.CustomerSubscription{}, Outcome{}, err
+	}
+
+	sub := types.CustomerSubscription{
+		UserID:           in.UserID,
+		Email:            user.Email,
+		PlanID:           plan.ID,
+		VariantID:        in.VariantID,
+		Quantity:         in.Quantity,
+		Currency:         in.Currency,
+		ShippingMethodID: in.ShippingMethodID,
+		Status:           StatusActive,
+		NextBillingAt:    now,
+		PaymentProvider:  in.PaymentProvider,
+		PaymentMethod:    in.PaymentMethod,
+	}
+	if err := db.Omit("Plan").Create(&sub).Error; err != nil {
+		return sub, Outcome{}, err
+	}
+
+	outcome, err := Bill(ctx, db, &sub, now)
+	if err != nil {
+		return sub, outcome, err
+	}
+	if outcome.Result != ResultRenewed && outcome.Result != ResultPending {
+		if err := Cancel(db, &sub, now); err != nil {
+			return sub, outcome, err
+		}
+		return sub, outcome, fmt.Errorf("%w: %s", ErrFirstPaymentFailed, outcome.Reason)
+	}
+	return sub, outcome, nil
+}
+
+// Pause stops renewals until until, or until resumed when it is nil.
+func Pause(db *gorm.DB, sub *types.CustomerSubscription, until *time.Time, now time.Time) error {
+	if until != nil && !until.After(now) {
+		return ErrInvalidPauseDate
+	}
+	return transition(db, sub, []string{StatusActive}, map[string]interface{}{
+		"status":       StatusPaused,
+		"paused_until": until,
+	})
+}
+
+// Resume restarts a paused subscription. A billing date missed while paused
+// is moved to now.
+func Resume(db *gorm.DB, sub *types.CustomerSubscription, now time.Time) error {
+	next := sub.NextBillingAt
+	if next.Before(now) {
+		next = now
+	}
+	return transition(db, sub, []string{StatusPaused}, map[string]interface{}{
+		"status":          StatusActive,
+		"paused_until":    nil,
+		"next_billing_at": next,
+	})
+}
+
+// Skip moves the next renewal one interval later.
+func Skip(db *gorm.DB, sub *types.CustomerSubscription) error {
+	plan := types.SubscriptionPlan{}
+	if err := db.First(&plan, sub.PlanID).Error; err != nil {
+		return err
+	}
+	res := db.Model(&types.CustomerSubscription{}).
+		Where("id = ? AND status = ? AND next_billing_at = ?", sub.ID, StatusActive, sub.NextBillingAt).
+		Update("next_billing_at", advance(sub.NextBillingAt, plan))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidState
+	}
+	return reload(db, sub)
+}
+
+// Cancel ends the subscription, no further orders are placed.
+func Cancel(db *gorm.DB, sub *types.CustomerSubscription, now time.Time) error {
+	return transition(db, sub, []string{StatusActive, StatusPaused, StatusPastDue}, map[string]interface{}{
+		"status":        StatusCancelled,
+		"cancelled_at":  now,
+		"next_retry_at": nil,
+	})
+}
+
+// transition applies updates when the subscription is still in one of from,
+// so concurrent changes cannot both win.
+func transition(db *gorm.DB, sub *types.CustomerSubscription, from []string, updates map[string]interface{}) error {
+	res := db.Model(&types.CustomerSubscription{}).
+		Where("id = ? AND status IN ?", sub.ID, from).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidState
+	}
+	return reload(db, sub)
+}
+
+func reload(db *gorm.DB, sub *types.CustomerSubscription) error {
+	return db.Preload("Plan").First(sub, sub.ID).Error
+}
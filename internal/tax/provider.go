@@ -0,0 +1,127 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownProvider = errors.New("unknown tax provider")
+
+type (
+	// Address is where the goods are delivered, which decides the tax.
+	Address struct {
+		CountryCode string
+		ZipCode     string
+	}
+	// Line is a priced line to tax. Amount is the line price after discounts.
+	Line struct {
+		Reference  string
+		ProductID  uint
+		TaxClassID *uint
+		Quantity   int
+		Amount     int64
+	}
+	// Request asks for the tax of some lines. When PricesIncludeTax is set
+	// the tax is taken out of the line amounts instead of added to them.
+	Request struct {
+		Address          Address
+		Currency         string
+		PricesIncludeTax bool
+		RoundingMode     string
+		Lines            []Line
+	}
+	// AppliedRate is one rate charged on a line.
+	AppliedRate struct {
+		Name   string `json:"name"`
+		Rate   int64  `json:"rate"`
+		Amount int64  `json:"amount"`
+	}
+	// LineTax is the tax of a line.
+	LineTax struct {
+		Reference string        `json:"reference"`
+		Amount    int64         `json:"amount"`
+		Rates     []AppliedRate `json:"rates"`
+	}
+	// Result is the tax of a request, lines in request order.
+	Result struct {
+		Lines []LineTax `json:"lines"`
+		Total int64     `json:"total"`
+	}
+
+	// Provider calculates tax. The built-in table provider uses the zones
+	// and rates stored for the tenant, others may call an external service.
+	Provider interface {
+		Name() string
+		Calculate(ctx context.Context, req Request) (Result, error)
+	}
+
+	// Factory builds a provider for a tenant database.
+	Factory func(db *gorm.DB) Provider
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	Register(TableProviderName, func(db *gorm.DB) Provider {
+		return &TableProvider{db: db}
+	})
+}
+
+// Register makes a provider available under name.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// New builds the provider registered under name.
+func New(name string, db *gorm.DB) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return factory(db), nil
+}
+
+// Providers returns the names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings returns the tax settings of the tenant, or the defaults when
+// none were saved: the table provider, exclusive prices, rounding half up.
+func Settings(db *gorm.DB) (types.TaxSettings, error) {
+	settings := types.TaxSettings{}
+	err := db.Order("id").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.TaxSettings{Provider: TableProviderName, RoundingMode: RoundHalfUp}, nil
+	}
+	return settings, err
+}
+
+// ForTenant returns the provider and settings the tenant calculates tax with.
+func ForTenant(db *gorm.DB) (Provider, types.TaxSettings, error) {
+	settings, err := Settings(db)
+	if err != nil {
+		return nil, settings, err
+	}
+	provider, err := New(settings.Provider, db)
+	return provider, settings, err
+}
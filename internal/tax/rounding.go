@@ -0,0 +1,51 @@
+package tax
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Rounding modes, applied to the tax of every rate on every line.
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundDown     = "down"
+	RoundUp       = "up"
+)
+
+var ErrInvalidRoundingMode = errors.New("invalid rounding mode")
+
+// ValidRoundingMode reports whether mode is a known rounding mode.
+func ValidRoundingMode(mode string) bool {
+	switch mode {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+		return true
+	}
+	return false
+}
+
+// Round rounds a non-negative amount to whole minor units.
+func Round(amount *big.Rat, mode string) int64 {
+	num, denom := amount.Num(), amount.Denom()
+	quo, rem := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if rem.Sign() == 0 {
+		return quo.Int64()
+	}
+
+	// compare twice the remainder with the denominator to find the half
+	half := new(big.Int).Lsh(rem, 1).Cmp(denom)
+	up := false
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		up = true
+	case RoundHalfEven:
+		up = half > 0 || (half == 0 && quo.Bit(0) == 1)
+	default:
+		up = half >= 0
+	}
+	if up {
+		quo.Add(quo, big.NewInt(1))
+	}
+	return quo.Int64()
+}
@@ -0,0 +1,27 @@
+package tax
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRound(t *testing.T) {
+	cases := []struct {
+		amount                     *big.Rat
+		halfUp, halfEven, down, up int64
+	}{
+		{big.NewRat(12, 1), 12, 12, 12, 12},
+		{big.NewRat(124, 10), 12, 12, 12, 13},
+		{big.NewRat(25, 2), 13, 12, 12, 13},
+		{big.NewRat(27, 2), 14, 14, 13, 14},
+		{big.NewRat(126, 10), 13, 13, 12, 13},
+		{big.NewRat(1, 3), 0, 0, 0, 1},
+	}
+	for _, c := range cases {
+		for mode, want := range map[string]int64{RoundHalfUp: c.halfUp, RoundHalfEven: c.halfEven, RoundDown: c.down, RoundUp: c.up} {
+			if got := Round(c.amount, mode); got != want {
+				t.Errorf("%s rounded %s is %d, want %d", c.amount.RatString(), mode, got, want)
+			}
+		}
+	}
+}
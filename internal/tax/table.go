@@ -0,0 +1,133 @@
+package tax
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	"gorm.io/gorm"
+)
+
+const TableProviderName = "table"
+
+// TableProvider calculates tax from the zones and rates stored for the
+// tenant. Addresses outside every zone are not taxed.
+type TableProvider struct {
+	db *gorm.DB
+}
+
+func (p *TableProvider) Name() string {
+	return TableProviderName
+}
+
+func (p *TableProvider) Calculate(ctx context.Context, req Request) (Result, error) {
+	res := Result{Lines: make([]LineTax, 0, len(req.Lines))}
+
+	zone, err := p.zoneFor(ctx, req.Address)
+	if err != nil {
+		return res, err
+	}
+	var rates []types.TaxRate
+	if zone != nil {
+		rates = zone.Rates
+	}
+
+	for _, line := range req.Lines {
+		lineTax := calculateLine(line, ratesFor(rates, line.TaxClassID), req.PricesIncludeTax, req.RoundingMode)
+		res.Lines = append(res.Lines, lineTax)
+		res.Total += lineTax.Amount
+	}
+	return res, nil
+}
+
+// zoneFor finds the zone of the address: the one of its country with the
+// longest zip prefix the zip code starts with.
+func (p *TableProvider) zoneFor(ctx context.Context, address Address) (*types.TaxZone, error) {
+	var zones []types.TaxZone
+	if err := p.db.WithContext(ctx).Preload("Rates").
+		Where("UPPER(country_code) = ?", strings.ToUpper(strings.TrimSpace(address.CountryCode))).
+		Order("LENGTH(zip_prefix) DESC").
+		Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	zip := NormalizeZip(address.ZipCode)
+	for i := range zones {
+		if strings.HasPrefix(zip, NormalizeZip(zones[i].ZipPrefix)) {
+			return &zones[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// NormalizeZip upper-cases a zip code and drops spaces and dashes, so
+// "sw1a 1aa" and "SW1A1AA" compare equal.
+func NormalizeZip(zip string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(zip)))
+}
+
+// ratesFor picks the rates that apply to a tax class, in the order they are
+// charged. Rates without a class are the default: they apply to classes the
+// zone has no rates of its own for, and to products without a class.
+func ratesFor(rates []types.TaxRate, classID *uint) []types.TaxRate {
+	var picked, defaults []types.TaxRate
+	for _, rate := range rates {
+		switch {
+		case rate.TaxClassID == nil:
+			defaults = append(defaults, rate)
+		case classID != nil && *rate.TaxClassID == *classID:
+			picked = append(picked, rate)
+		}
+	}
+	if len(picked) == 0 {
+		picked = defaults
+	}
+	sort.SliceStable(picked, func(i, j int) bool {
+		if picked[i].Priority != picked[j].Priority {
+			return picked[i].Priority < picked[j].Priority
+		}
+		return picked[i].ID < picked[j].ID
+	})
+	return picked
+}
+
+// calculateLine charges the rates on a line. Every rate is a fraction of the
+// net price: a simple rate its own percentage, a compound rate its
+// percentage of one plus the fractions before it. Exclusive prices are the
+// net price, inclusive prices are divided by one plus all fractions first.
+// Each rate is rounded on its own.
+func calculateLine(line Line, rates []types.TaxRate, inclusive bool, mode string) LineTax {
+	lineTax := LineTax{Reference: line.Reference, Rates: []AppliedRate{}}
+	if len(rates) == 0 || line.Amount <= 0 {
+		return lineTax
+	}
+
+	fractions := make([]*big.Rat, len(rates))
+	total := new(big.Rat)
+	for i, rate := range rates {
+		fraction := big.NewRat(rate.Rate, 10000)
+		if rate.Compound {
+			fraction.Mul(fraction, new(big.Rat).Add(big.NewRat(1, 1), total))
+		}
+		fractions[i] = fraction
+		total = new(big.Rat).Add(total, fraction)
+	}
+
+	net := new(big.Rat).SetInt64(line.Amount)
+	if inclusive {
+		net.Quo(net, new(big.Rat).Add(big.NewRat(1, 1), total))
+	}
+
+	for i, rate := range rates {
+		amount := Round(new(big.Rat).Mul(net, fractions[i]), mode)
+		lineTax.Rates = append(lineTax.Rates, AppliedRate{Name: rate.Name, Rate: rate.Rate, Amount: amount})
+		lineTax.Amount += amount
+	}
+	return lineTax
+}
@@ -0,0 +1,74 @@
+package tax
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+)
+
+func TestRatesFor(t *testing.T) {
+	food, books := uint(1), uint(2)
+	rates := []types.TaxRate{
+		{ID: 1, Name: "State", Rate: 600, Priority: 1},
+		{ID: 2, Name: "City", Rate: 200, Priority: 2},
+		{ID: 3, Name: "Food", TaxClassID: &food, Rate: 100, Priority: 1},
+		{ID: 4, Name: "Food city", TaxClassID: &food, Rate: 50, Priority: 0},
+	}
+	cases := []struct {
+		name  string
+		class *uint
+		want  []uint
+	}{
+		{"no class", nil, []uint{1, 2}},
+		{"food", &food, []uint{4, 3}},
+		{"books", &books, []uint{1, 2}},
+	}
+	for _, c := range cases {
+		var got []uint
+		for _, rate := range ratesFor(rates, c.class) {
+			got = append(got, rate.ID)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s picks rates %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCalculateLine(t *testing.T) {
+	state := types.TaxRate{Name: "State", Rate: 825}
+	vat := types.TaxRate{Name: "VAT", Rate: 2000}
+	gst := types.TaxRate{Name: "GST", Rate: 500}
+	qst := types.TaxRate{Name: "QST", Rate: 1000, Compound: true}
+	cases := []struct {
+		name      string
+		amount    int64
+		rates     []types.TaxRate
+		inclusive bool
+		mode      string
+		want      []int64
+	}{
+		{"exclusive", 10000, []types.TaxRate{state}, false, RoundHalfUp, []int64{825}},
+		{"exclusive rounded half up", 999, []types.TaxRate{state}, false, RoundHalfUp, []int64{82}},
+		{"exclusive rounded up", 999, []types.TaxRate{state}, false, RoundUp, []int64{83}},
+		{"compound on the tax before it", 10000, []types.TaxRate{gst, qst}, false, RoundHalfUp, []int64{500, 1050}},
+		{"inclusive", 10825, []types.TaxRate{state}, true, RoundHalfUp, []int64{825}},
+		{"inclusive rounded half up", 1000, []types.TaxRate{vat}, true, RoundHalfUp, []int64{167}},
+		{"inclusive rounded down", 1000, []types.TaxRate{vat}, true, RoundDown, []int64{166}},
+		{"inclusive compound", 11550, []types.TaxRate{gst, qst}, true, RoundHalfUp, []int64{500, 1050}},
+		{"no rates", 10000, nil, false, RoundHalfUp, nil},
+		{"free line", 0, []types.TaxRate{state}, false, RoundHalfUp, nil},
+	}
+	for _, c := range cases {
+		got := calculateLine(Line{Amount: c.amount}, c.rates, c.inclusive, c.mode)
+		var amounts []int64
+		var total int64
+		for _, rate := range got.Rates {
+			amounts = append(amounts, rate.Amount)
+			total += rate.Amount
+		}
+		if !reflect.DeepEqual(amounts, c.want) || got.Amount != total {
+			t.Errorf("%s: rates %v totalling %d, want %v", c.name, amounts, got.Amount, c.want)
+		}
+	}
+}
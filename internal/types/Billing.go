@@ -0,0 +1,82 @@
+package types
+
+import (
+	"time"
+)
+
+// Plan represents the plans table in the main database, a SaaS plan vendors
+// subscribe to. Price is charged every Interval in minor units of Currency.
+// A limit of zero means unlimited.
+type Plan struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code              string    `gorm:"type:varchar(50);not null;unique" json:"code"`
+	Name              string    `gorm:"type:varchar(100);not null" json:"name"`
+	Price             int64     `gorm:"not null;default:0" json:"price"`
+	Currency          string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Interval          string    `gorm:"type:varchar(10);not null" json:"interval"`
+	TrialDays         int       `gorm:"not null;default:0" json:"trial_days"`
+	MaxProducts       int64     `gorm:"not null;default:0" json:"max_products"`
+	MaxStaff          int64     `gorm:"not null;default:0" json:"max_staff"`
+	MaxOrdersPerMonth int64     `gorm:"not null;default:0" json:"max_orders_per_month"`
+	MaxStorageMB      int64     `gorm:"not null;default:0" json:"max_storage_mb"`
+	Active            bool      `gorm:"not null" json:"active"`
+	Position          int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// VendorSubscription represents the vendor_subscriptions table in the main
+// database, the plan a vendor pays for. Credit is what downgrades left over
+// and is taken off the next invoices.
+type VendorSubscription struct {
+	ID                 uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	VendorID           uint       `gorm:"not null;unique" json:"vendor_id"`
+	PlanID             uint       `gorm:"not null;index" json:"plan_id"`
+	Status             string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	CurrentPeriodStart time.Time  `gorm:"type:timestamp;not null" json:"current_period_start"`
+	CurrentPeriodEnd   time.Time  `gorm:"type:timestamp;not null;index" json:"current_period_end"`
+	TrialEndsAt        *time.Time `gorm:"type:timestamp" json:"trial_ends_at"`
+	CancelAtPeriodEnd  bool       `gorm:"not null;default:false" json:"cancel_at_period_end"`
+	CancelledAt        *time.Time `gorm:"type:timestamp" json:"cancelled_at"`
+	PaymentProvider    string     `gorm:"type:varchar(50)" json:"payment_provider"`
+	PaymentMethod      string     `gorm:"type:varchar(255)" json:"-"`
+	Credit             int64      `gorm:"not null;default:0" json:"credit"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Plan Plan `gorm:"foreignKey:PlanID" json:"plan"`
+}
+
+// VendorInvoice represents the vendor_invoices table in the main database,
+// what the platform bills a vendor for a period of its subscription.
+type VendorInvoice struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Number            string     `gorm:"type:varchar(32);not null;unique" json:"number"`
+	VendorID          uint       `gorm:"not null;index" json:"vendor_id"`
+	SubscriptionID    uint       `gorm:"not null;index" json:"subscription_id"`
+	Status            string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Amount            int64      `gorm:"not null" json:"amount"`
+	Currency          string     `gorm:"type:varchar(3);not null" json:"currency"`
+	PeriodStart       time.Time  `gorm:"type:timestamp;not null" json:"period_start"`
+	PeriodEnd         time.Time  `gorm:"type:timestamp;not null" json:"period_end"`
+	Attempts          int        `gorm:"not null;default:0" json:"attempts"`
+	LastError         string     `gorm:"type:text" json:"last_error"`
+	NextAttemptAt     *time.Time `gorm:"type:timestamp;index" json:"next_attempt_at"`
+	ProviderReference string     `gorm:"type:varchar(255)" json:"provider_reference"`
+	PaidAt            *time.Time `gorm:"type:timestamp" json:"paid_at"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Lines []VendorInvoiceLine `gorm:"foreignKey:InvoiceID;constraint:OnDelete:CASCADE;" json:"lines,omitempty"`
+}
+
+// VendorInvoiceLine represents the vendor_invoice_lines table in the main
+// database. Credits have a negative Amount.
+type VendorInvoiceLine struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	InvoiceID   uint   `gorm:"not null;index" json:"invoice_id"`
+	Description string `gorm:"type:varchar(255);not null" json:"description"`
+	Amount      int64  `gorm:"not null" json:"amount"`
+}
@@ -0,0 +1,37 @@
+package types
+
+import (
+	"time"
+)
+
+// Cart represents the carts table. Guest carts are found by their Token,
+// customer carts by UserID. Prices are in Currency, the base currency when
+// empty. Email is the address a guest left before checkout, used to remind
+// them of the cart.
+type Cart struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Token          string    `gorm:"type:varchar(64);not null;unique" json:"token"`
+	UserID         *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email          string    `gorm:"type:varchar(255)" json:"email"`
+	Status         string    `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	Currency       string    `gorm:"type:varchar(3)" json:"currency"`
+	LastActivityAt time.Time `gorm:"type:timestamp;not null" json:"last_activity_at"`
+	ExpiresAt      time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Items []CartItem `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE;" json:"items,omitempty"`
+}
+
+// CartItem represents the cart_items table. UnitPrice is the price the
+// customer last saw, so price changes can be pointed out when the cart is read.
+type CartItem struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CartID    uint      `gorm:"not null;uniqueIndex:idx_cart_item_variant" json:"cart_id"`
+	VariantID uint      `gorm:"not null;uniqueIndex:idx_cart_item_variant" json:"variant_id"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	UnitPrice int64     `gorm:"not null;default:0" json:"unit_price"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
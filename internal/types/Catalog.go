@@ -0,0 +1,40 @@
+package types
+
+import (
+	"gorm.io/gorm"
+)
+
+// Category represents the categories table. Categories form a tree stored as
+// a materialized path: Path holds the ids from the root down to and including
+// the category itself, e.g. "/1/4/9/".
+type Category struct {
+	gorm.Model
+	ParentID *uint  `gorm:"index" json:"parent_id,omitempty"`
+	Name     string `gorm:"type:varchar(255);not null" json:"name"`
+	Handle   string `gorm:"type:varchar(255);not null;unique" json:"handle"`
+	Path     string `gorm:"type:varchar(1024);not null;index" json:"path"`
+	Depth    int    `gorm:"default:0" json:"depth"`
+	Position int    `gorm:"default:0" json:"position"`
+}
+
+// Collection represents the collections table. Manual collections hold a
+// hand-picked list of products, smart collections are filled by evaluating
+// Rules against the catalog.
+type Collection struct {
+	gorm.Model
+	Title       string `gorm:"type:varchar(255);not null" json:"title"`
+	Handle      string `gorm:"type:varchar(255);not null;unique" json:"handle"`
+	Description string `gorm:"type:text" json:"description"`
+	Smart       bool   `gorm:"default:false" json:"smart"`
+	Rules       string `gorm:"type:text" json:"rules"`
+
+	// Associations
+	Products []CollectionProduct `gorm:"foreignKey:CollectionID;constraint:OnDelete:CASCADE;" json:"products,omitempty"`
+}
+
+// CollectionProduct represents the collection_products table.
+type CollectionProduct struct {
+	CollectionID uint `gorm:"primaryKey" json:"collection_id"`
+	ProductID    uint `gorm:"primaryKey;index" json:"product_id"`
+	Position     int  `gorm:"default:0" json:"position"`
+}
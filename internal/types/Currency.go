@@ -0,0 +1,65 @@
+package types
+
+import (
+	"time"
+)
+
+// CurrencySettings represents the currency_settings table, a single row
+// holding the currency catalog prices are entered in and how converted
+// amounts are rounded.
+type CurrencySettings struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	BaseCurrency string    `gorm:"type:varchar(3);not null" json:"base_currency"`
+	RoundingMode string    `gorm:"type:varchar(20);not null" json:"rounding_mode"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Currency represents the currencies table, a presentment currency
+// customers may shop in. RoundingMode overrides the one of the settings,
+// RoundingIncrement rounds converted prices to a multiple of that many minor
+// units (5 rounds CHF to 0.05).
+type Currency struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code              string    `gorm:"type:varchar(3);not null;unique" json:"code"`
+	Enabled           bool      `gorm:"not null" json:"enabled"`
+	RoundingMode      string    `gorm:"type:varchar(20)" json:"rounding_mode"`
+	RoundingIncrement int64     `gorm:"not null;default:0" json:"rounding_increment"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PriceList represents the price_lists table, how variants are priced in a
+// presentment currency. An override list sets prices per variant and
+// converts the others, a convert list converts every base price.
+type PriceList struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Currency  string    `gorm:"type:varchar(3);not null;unique" json:"currency"`
+	Mode      string    `gorm:"type:varchar(20);not null" json:"mode"`
+	Active    bool      `gorm:"not null" json:"active"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Entries []PriceListEntry `gorm:"foreignKey:PriceListID;constraint:OnDelete:CASCADE;" json:"entries,omitempty"`
+}
+
+// PriceListEntry represents the price_list_entries table, the price of a
+// variant in minor units of the list's currency.
+type PriceListEntry struct {
+	ID          uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	PriceListID uint  `gorm:"not null;uniqueIndex:idx_price_list_variant" json:"price_list_id"`
+	VariantID   uint  `gorm:"not null;uniqueIndex:idx_price_list_variant" json:"variant_id"`
+	Price       int64 `gorm:"not null" json:"price"`
+}
+
+// ExchangeRate represents the exchange_rates table, how many units of
+// ToCurrency one unit of FromCurrency buys, as imported from a rate file.
+type ExchangeRate struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	FromCurrency string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"from_currency"`
+	ToCurrency   string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"to_currency"`
+	Rate         string    `gorm:"type:numeric(24,12);not null" json:"rate"`
+	Source       string    `gorm:"type:varchar(255)" json:"source"`
+	ImportedAt   time.Time `gorm:"type:timestamp;not null" json:"imported_at"`
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"time"
+)
+
+// DigitalAsset represents the digital_assets table, a file delivered to the
+// buyers of a variant. The file is a private media file, buyers reach it
+// through download links that expire ExpiryHours after payment and allow
+// MaxDownloads downloads. With IssueLicense set every order line of the
+// variant also gets a license key.
+type DigitalAsset struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID    uint      `gorm:"not null;index" json:"variant_id"`
+	MediaID      uint      `gorm:"not null;index" json:"media_id"`
+	Name         string    `gorm:"type:varchar(255);not null" json:"name"`
+	MaxDownloads int       `gorm:"not null;default:5" json:"max_downloads"`
+	ExpiryHours  int       `gorm:"not null;default:168" json:"expiry_hours"`
+	IssueLicense bool      `gorm:"not null;default:false" json:"issue_license"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Media MediaFile `gorm:"foreignKey:MediaID" json:"media"`
+}
+
+// DownloadGrant represents the download_grants table, the right of an order
+// line to download one asset. Links to it are signed with Secret, and the
+// limits are copied from the asset when the order is paid so later changes
+// to the asset do not affect orders already placed.
+type DownloadGrant struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID        uint       `gorm:"not null;index" json:"order_id"`
+	OrderLineID    uint       `gorm:"not null;uniqueIndex:idx_download_grant" json:"order_line_id"`
+	AssetID        uint       `gorm:"not null;uniqueIndex:idx_download_grant" json:"asset_id"`
+	Secret         string     `gorm:"type:varchar(64);not null" json:"-"`
+	MaxDownloads   int        `gorm:"not null" json:"max_downloads"`
+	Downloads      int        `gorm:"not null;default:0" json:"downloads"`
+	BytesServed    int64      `gorm:"not null;default:0" json:"bytes_served"`
+	ExpiresAt      time.Time  `gorm:"type:timestamp;not null" json:"expires_at"`
+	Revoked        bool       `gorm:"not null;default:false" json:"revoked"`
+	LastDownloadAt *time.Time `gorm:"type:timestamp" json:"last_download_at"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Asset DigitalAsset `gorm:"foreignKey:AssetID" json:"asset"`
+}
+
+// LicenseKey represents the license_keys table, one key per order line of a
+// variant that issues licenses. Seats is the quantity bought.
+type LicenseKey struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string     `gorm:"type:varchar(64);not null;unique" json:"key"`
+	OrderID     uint       `gorm:"not null;index" json:"order_id"`
+	OrderLineID uint       `gorm:"not null;unique" json:"order_line_id"`
+	ProductID   uint       `gorm:"not null" json:"product_id"`
+	VariantID   uint       `gorm:"not null;index" json:"variant_id"`
+	SKU         string     `gorm:"type:varchar(100);not null" json:"sku"`
+	Seats       int        `gorm:"not null;default:1" json:"seats"`
+	Status      string     `gorm:"type:varchar(20);not null" json:"status"`
+	RevokedAt   *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"time"
+)
+
+// GiftCard represents the gift_cards table. Only a hash of the code is
+// stored, Last4 lets staff and customers tell cards apart. The balance is the
+// sum of the card's transactions.
+type GiftCard struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CodeHash       string     `gorm:"type:varchar(64);not null;unique" json:"-"`
+	Last4          string     `gorm:"type:varchar(4);not null" json:"last4"`
+	Currency       string     `gorm:"type:varchar(3);not null" json:"currency"`
+	InitialValue   int64      `gorm:"not null" json:"initial_value"`
+	ExpiresAt      *time.Time `gorm:"type:timestamp" json:"expires_at"`
+	Disabled       bool       `gorm:"not null;default:false" json:"disabled"`
+	RecipientEmail string     `gorm:"type:varchar(255)" json:"recipient_email"`
+	Note           string     `gorm:"type:text" json:"note"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Transactions []GiftCardTransaction `gorm:"foreignKey:GiftCardID" json:"transactions,omitempty"`
+}
+
+// GiftCardTransaction represents the gift_card_transactions table, an
+// append only record of every change to a card's balance. Amount is
+// positive when value is added to the card.
+type GiftCardTransaction struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	GiftCardID uint      `gorm:"not null;index" json:"gift_card_id"`
+	Kind       string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Amount     int64     `gorm:"not null" json:"amount"`
+	OrderID    *uint     `gorm:"index" json:"order_id,omitempty"`
+	Note       string    `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// StoreCreditAccount represents the store_credit_accounts table, one per
+// customer and currency. The balance is the sum of its transactions.
+type StoreCreditAccount struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_store_credit_user_currency" json:"user_id"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_store_credit_user_currency" json:"currency"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Transactions []StoreCreditTransaction `gorm:"foreignKey:AccountID" json:"transactions,omitempty"`
+}
+
+// StoreCreditTransaction represents the store_credit_transactions table, an
+// append only record of every change to a store credit balance. Amount is
+// positive when credit is added.
+type StoreCreditTransaction struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID uint      `gorm:"not null;index" json:"account_id"`
+	Kind      string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	OrderID   *uint     `gorm:"index" json:"order_id,omitempty"`
+	RefundID  *uint     `json:"refund_id,omitempty"`
+	Note      string    `gorm:"type:text" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"time"
+)
+
+// ImportJob represents the import_jobs table. The uploaded file is kept in
+// Data so a background worker can process it and the failed rows can later
+// be served back as an error file.
+type ImportJob struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Format     string     `gorm:"type:varchar(10);not null" json:"format"`
+	Filename   string     `gorm:"type:varchar(255)" json:"filename"`
+	Data       []byte     `gorm:"type:bytea" json:"-"`
+	Status     string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	TotalRows  int        `gorm:"not null;default:0" json:"total_rows"`
+	Processed  int        `gorm:"not null;default:0" json:"processed"`
+	Created    int        `gorm:"not null;default:0" json:"created"`
+	Updated    int        `gorm:"not null;default:0" json:"updated"`
+	Failed     int        `gorm:"not null;default:0" json:"failed"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ImportRow represents the import_rows table, the outcome of one row of an
+// import. Line is the CSV line number or the 1-based index of the product in
+// a JSON file, so the variants of one JSON product share a line.
+type ImportRow struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	JobID     uint   `gorm:"not null;index" json:"job_id"`
+	Line      int    `gorm:"not null" json:"line"`
+	Handle    string `gorm:"type:varchar(255)" json:"handle"`
+	SKU       string `gorm:"type:varchar(100)" json:"sku"`
+	Status    string `gorm:"type:varchar(20);not null;index" json:"status"`
+	Error     string `gorm:"type:text" json:"error,omitempty"`
+	ProductID *uint  `json:"product_id,omitempty"`
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Warehouse represents the warehouses table.
+type Warehouse struct {
+	gorm.Model
+	Name   string `gorm:"type:varchar(255);not null" json:"name"`
+	Code   string `gorm:"type:varchar(50);not null;unique" json:"code"`
+	Active bool   `gorm:"default:true" json:"active"`
+
+	// Associations
+	Locations []StockLocation `gorm:"foreignKey:WarehouseID" json:"locations,omitempty"`
+}
+
+// StockLocation represents the stock_locations table, a place inside a
+// warehouse (aisle, shelf, bin) that holds stock.
+type StockLocation struct {
+	gorm.Model
+	WarehouseID uint   `gorm:"not null;index" json:"warehouse_id"`
+	Name        string `gorm:"type:varchar(255);not null" json:"name"`
+	Code        string `gorm:"type:varchar(50);not null;unique" json:"code"`
+	Priority    int    `gorm:"default:0" json:"priority"`
+	Active      bool   `gorm:"default:true" json:"active"`
+}
+
+// InventoryLevel represents the inventory_levels table. Available stock is
+// OnHand minus Reserved and never drops below zero.
+type InventoryLevel struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID  uint      `gorm:"not null;uniqueIndex:idx_inventory_level" json:"variant_id"`
+	LocationID uint      `gorm:"not null;uniqueIndex:idx_inventory_level" json:"location_id"`
+	OnHand     int       `gorm:"not null;default:0" json:"on_hand"`
+	Reserved   int       `gorm:"not null;default:0;check:chk_inventory_levels_stock,reserved >= 0 AND on_hand >= reserved" json:"reserved"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Variant  ProductVariant `gorm:"foreignKey:VariantID" json:"-"`
+	Location StockLocation  `gorm:"foreignKey:LocationID" json:"-"`
+}
+
+// Available returns the quantity that can still be reserved or sold.
+func (l InventoryLevel) Available() int {
+	return l.OnHand - l.Reserved
+}
+
+// StockMovement represents the stock_movements table. It is an append-only
+// ledger: every change of OnHand writes exactly one row.
+type StockMovement struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID  uint      `gorm:"not null;index" json:"variant_id"`
+	LocationID uint      `gorm:"not null;index" json:"location_id"`
+	Quantity   int       `gorm:"not null" json:"quantity"`
+	Reason     string    `gorm:"type:varchar(30);not null" json:"reason"`
+	Reference  string    `gorm:"type:varchar(255)" json:"reference"`
+	Note       string    `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// StockReservation represents the stock_reservations table. Active
+// reservations hold stock until they are committed, released or expire.
+type StockReservation struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID  uint      `gorm:"not null;index" json:"variant_id"`
+	LocationID uint      `gorm:"not null" json:"location_id"`
+	Quantity   int       `gorm:"not null" json:"quantity"`
+	Reference  string    `gorm:"type:varchar(255);not null;index" json:"reference"`
+	Status     string    `gorm:"type:varchar(20);not null;default:active;index" json:"status"`
+	ExpiresAt  time.Time `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"time"
+)
+
+// CommissionPlan represents the commission_plans table in the main database,
+// the cut the platform takes from the orders of the vendors on the plan.
+// Rate is in hundredths of a percent of the goods value, FixedFee in minor
+// units per order. The Default plan applies to vendors without a plan.
+type CommissionPlan struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null;unique" json:"name"`
+	Rate      int64     `gorm:"not null;default:0" json:"rate"`
+	FixedFee  int64     `gorm:"not null;default:0" json:"fixed_fee"`
+	Default   bool      `gorm:"not null;default:false" json:"default"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// LedgerAccount represents the ledger_accounts table in the main database.
+// Platform accounts have VendorID 0. Every account holds one currency.
+type LedgerAccount struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VendorID  uint      `gorm:"not null;default:0;uniqueIndex:idx_ledger_account" json:"vendor_id"`
+	Kind      string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_ledger_account" json:"kind"`
+	Currency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_ledger_account" json:"currency"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// LedgerTransaction represents the ledger_transactions table in the main
+// database, one balanced movement of money. Kind and Reference are unique so
+// the same business event is never booked twice.
+type LedgerTransaction struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VendorID    *uint     `gorm:"index" json:"vendor_id,omitempty"`
+	Kind        string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_ledger_transaction_reference" json:"kind"`
+	Reference   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_ledger_transaction_reference" json:"reference"`
+	Currency    string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Description string    `gorm:"type:text" json:"description"`
+	OccurredAt  time.Time `gorm:"type:timestamp;not null;index" json:"occurred_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE;" json:"entries,omitempty"`
+}
+
+// LedgerEntry represents the ledger_entries table in the main database.
+// Debits are positive and credits negative, the entries of a transaction
+// add up to zero.
+type LedgerEntry struct {
+	ID            uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	TransactionID uint  `gorm:"not null;index" json:"transaction_id"`
+	AccountID     uint  `gorm:"not null;index" json:"account_id"`
+	Amount        int64 `gorm:"not null" json:"amount"`
+}
+
+// LedgerCursor represents the ledger_cursors table in the main database, how
+// far the order events and refunds of a vendor's store were booked.
+type LedgerCursor struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	VendorID         uint      `gorm:"not null;unique" json:"vendor_id"`
+	LastOrderEventID uint      `gorm:"not null;default:0" json:"last_order_event_id"`
+	LastRefundID     uint      `gorm:"not null;default:0" json:"last_refund_id"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PayoutBatch represents the payout_batches table in the main database, the
+// payouts of one currency generated together.
+type PayoutBatch struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Currency  string     `gorm:"type:varchar(3);not null" json:"currency"`
+	Status    string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Total     int64      `gorm:"not null;default:0" json:"total"`
+	Threshold int64      `gorm:"not null;default:0" json:"threshold"`
+	PaidAt    *time.Time `gorm:"type:timestamp" json:"paid_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Payouts []Payout `gorm:"foreignKey:BatchID;constraint:OnDelete:CASCADE;" json:"payouts,omitempty"`
+}
+
+// Payout represents the payouts table in the main database, money sent to a
+// vendor.
+type Payout struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BatchID   uint      `gorm:"not null;index" json:"batch_id"`
+	VendorID  uint      `gorm:"not null;index" json:"vendor_id"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	Currency  string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Status    string    `gorm:"type:varchar(20);not null;index" json:"status"`
+	Note      string    `gorm:"type:text" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+)
+
+// MediaFile represents the media_files table, one uploaded file. Images are
+// stored re-encoded without their metadata and come with generated
+// renditions in Variants. URL is only set for public files; private files
+// are reached through signed URLs.
+type MediaFile struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string    `gorm:"type:varchar(500);not null;unique" json:"key"`
+	Filename    string    `gorm:"type:varchar(255)" json:"filename"`
+	ContentType string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size        int64     `gorm:"not null;default:0" json:"size"`
+	Private     bool      `gorm:"not null;default:false" json:"private"`
+	Width       int       `gorm:"not null;default:0" json:"width,omitempty"`
+	Height      int       `gorm:"not null;default:0" json:"height,omitempty"`
+	URL         string    `gorm:"type:varchar(1000)" json:"url,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Variants []MediaVariant `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE;" json:"variants,omitempty"`
+}
+
+// MediaVariant represents the media_variants table, a resized rendition of
+// an image such as its thumbnail.
+type MediaVariant struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	MediaID     uint   `gorm:"not null;uniqueIndex:idx_media_variant" json:"media_id"`
+	Name        string `gorm:"type:varchar(20);not null;uniqueIndex:idx_media_variant" json:"name"`
+	Key         string `gorm:"type:varchar(500);not null;unique" json:"key"`
+	ContentType string `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size        int64  `gorm:"not null;default:0" json:"size"`
+	Width       int    `gorm:"not null" json:"width"`
+	Height      int    `gorm:"not null" json:"height"`
+	URL         string `gorm:"type:varchar(1000)" json:"url,omitempty"`
+}
+
+// ProductImage represents the product_images table, linking a product to
+// an uploaded image in display order.
+type ProductImage struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID uint   `gorm:"not null;index" json:"product_id"`
+	MediaID   uint   `gorm:"not null;index" json:"media_id"`
+	Alt       string `gorm:"type:varchar(255)" json:"alt"`
+	Position  int    `gorm:"not null;default:0" json:"position"`
+
+	// Associations
+	Media MediaFile `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE;" json:"media"`
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Order represents the orders table. Amounts are in minor units of Currency
+// and are snapshots taken at checkout, later catalog changes do not touch them.
+// When TaxIncluded is set the prices already contain TaxTotal. BaseTotal is
+// Total in the tenant's base currency at ExchangeRate, for reporting. The
+// part of Total not settled with gift cards and store credit is charged
+// through a payment provider.
+type Order struct {
+	gorm.Model
+	Number           string `gorm:"type:varchar(32);not null;unique" json:"number"`
+	UserID           *uint  `gorm:"index" json:"user_id,omitempty"`
+	CartID           *uint  `json:"cart_id,omitempty"`
+	SubscriptionID   *uint  `gorm:"index" json:"subscription_id,omitempty"`
+	Email            string `gorm:"type:varchar(255);not null;index" json:"email"`
+	Status           string `gorm:"type:varchar(30);not null;index" json:"status"`
+	Currency         string `gorm:"type:varchar(3);not null" json:"currency"`
+	Subtotal         int64  `gorm:"not null;default:0" json:"subtotal"`
+	DiscountTotal    int64  `gorm:"not null;default:0" json:"discount_total"`
+	ShippingTotal    int64  `gorm:"not null;default:0" json:"shipping_total"`
+	ShippingMethodID *uint  `json:"shipping_method_id,omitempty"`
+	ShippingMethod   string `gorm:"type:varchar(100)" json:"shipping_method,omitempty"`
+	TaxTotal         int64  `gorm:"not null;default:0" json:"tax_total"`
+	TaxIncluded      bool   `gorm:"not null;default:false" json:"tax_included"`
+	Total            int64  `gorm:"not null;default:0" json:"total"`
+	GiftCardTotal    int64  `gorm:"not null;default:0" json:"gift_card_total"`
+	StoreCreditTotal int64  `gorm:"not null;default:0" json:"store_credit_total"`
+	BaseCurrency     string `gorm:"type:varchar(3)" json:"base_currency"`
+	ExchangeRate     string `gorm:"type:numeric(24,12);not null;default:1" json:"exchange_rate"`
+	BaseTotal        int64  `gorm:"not null;default:0" json:"base_total"`
+
+	// Associations
+	Lines     []OrderLine     `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"lines,omitempty"`
+	Addresses []OrderAddress  `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"addresses,omitempty"`
+	Events    []OrderEvent    `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"events,omitempty"`
+	Discounts []OrderDiscount `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE;" json:"discounts,omitempty"`
+	Shipments []Shipment      `gorm:"foreignKey:OrderID" json:"shipments,omitempty"`
+}
+
+// OrderLine represents the order_lines table.
+type OrderLine struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID   uint   `gorm:"not null;index" json:"order_id"`
+	ProductID uint   `gorm:"not null" json:"product_id"`
+	VariantID uint   `gorm:"not null;index" json:"variant_id"`
+	SKU       string `gorm:"type:varchar(100);not null" json:"sku"`
+	Title     string `gorm:"type:varchar(255);not null" json:"title"`
+	Quantity  int    `gorm:"not null" json:"quantity"`
+	UnitPrice int64  `gorm:"not null" json:"unit_price"`
+	Discount  int64  `gorm:"not null;default:0" json:"discount"`
+	TaxAmount int64  `gorm:"not null;default:0" json:"tax_amount"`
+	LineTotal int64  `gorm:"not null" json:"line_total"`
+}
+
+// OrderAddress represents the order_addresses table, a copy of a
+// billing or shipping address as it was when the order was placed.
+type OrderAddress struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID      uint   `gorm:"not null;index" json:"order_id"`
+	Kind         string `gorm:"type:varchar(10);not null" json:"kind"`
+	FullName     string `gorm:"type:varchar(255)" json:"full_name"`
+	Phone        string `gorm:"type:varchar(30)" json:"phone"`
+	StreetNumber string `gorm:"type:varchar(20)" json:"street_number"`
+	Directional  string `gorm:"type:varchar(10)" json:"directional"`
+	Street       string `gorm:"type:varchar(255);not null" json:"street"`
+	Suffix       string `gorm:"type:varchar(50)" json:"suffix"`
+	UnitType     string `gorm:"type:varchar(50)" json:"unit_type"`
+	UnitNumber   string `gorm:"type:varchar(20)" json:"unit_number"`
+	City         string `gorm:"type:varchar(100)" json:"city"`
+	Region       string `gorm:"type:varchar(100)" json:"region"`
+	ZipCode      string `gorm:"type:varchar(20);not null" json:"zip_code"`
+	CountryCode  string `gorm:"type:varchar(5);not null" json:"country_code"`
+	Formatted    string `gorm:"type:text" json:"formatted"`
+}
+
+// OrderEvent represents the order_events table, the history of status
+// changes of an order.
+type OrderEvent struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID    uint      `gorm:"not null;index" json:"order_id"`
+	FromStatus string    `gorm:"type:varchar(30)" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(30);not null" json:"to_status"`
+	Note       string    `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
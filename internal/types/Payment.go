@@ -0,0 +1,69 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PaymentProviderConfig represents the payment_provider_configs table, the
+// credentials a tenant uses for a payment provider. Credentials is a JSON
+// object whose keys depend on the provider.
+type PaymentProviderConfig struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider    string    `gorm:"type:varchar(50);not null;unique" json:"provider"`
+	Credentials string    `gorm:"type:text;not null" json:"-"`
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PaymentIntent represents the payment_intents table, one attempt to collect
+// money for an order. Amounts are in minor units of Currency.
+type PaymentIntent struct {
+	gorm.Model
+	OrderID           uint   `gorm:"not null;index" json:"order_id"`
+	Provider          string `gorm:"type:varchar(50);not null" json:"provider"`
+	ProviderReference string `gorm:"type:varchar(255);index" json:"provider_reference"`
+	Status            string `gorm:"type:varchar(30);not null;index" json:"status"`
+	Amount            int64  `gorm:"not null" json:"amount"`
+	AmountCaptured    int64  `gorm:"not null;default:0" json:"amount_captured"`
+	AmountRefunded    int64  `gorm:"not null;default:0" json:"amount_refunded"`
+	Currency          string `gorm:"type:varchar(3);not null" json:"currency"`
+
+	// Associations
+	Transactions []PaymentTransaction `gorm:"foreignKey:PaymentIntentID;constraint:OnDelete:CASCADE;" json:"transactions,omitempty"`
+}
+
+// PaymentTransaction represents the payment_transactions table, every call
+// made to the provider for an intent and its outcome.
+type PaymentTransaction struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PaymentIntentID   uint      `gorm:"not null;index" json:"payment_intent_id"`
+	Kind              string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Status            string    `gorm:"type:varchar(20);not null" json:"status"`
+	Amount            int64     `gorm:"not null" json:"amount"`
+	Currency          string    `gorm:"type:varchar(3);not null" json:"currency"`
+	ProviderReference string    `gorm:"type:varchar(255)" json:"provider_reference"`
+	Message           string    `gorm:"type:text" json:"message"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// PaymentWebhookEvent represents the payment_webhook_events table, the raw
+// notifications received from payment providers. EventID is unique per
+// provider so redeliveries are recognised.
+type PaymentWebhookEvent struct {
+	ID                uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider          string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_payment_webhook_event" json:"provider"`
+	EventID           string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_payment_webhook_event" json:"event_id"`
+	Type              string     `gorm:"type:varchar(50);not null" json:"type"`
+	ProviderReference string     `gorm:"type:varchar(255);index" json:"provider_reference"`
+	Amount            int64      `gorm:"not null;default:0" json:"amount"`
+	Currency          string     `gorm:"type:varchar(3)" json:"currency"`
+	Payload           string     `gorm:"type:text;not null" json:"payload"`
+	Status            string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Note              string     `gorm:"type:text" json:"note"`
+	Attempts          int        `gorm:"default:0" json:"attempts"`
+	ReceivedAt        time.Time  `gorm:"autoCreateTime" json:"received_at"`
+	ProcessedAt       *time.Time `gorm:"type:timestamp" json:"processed_at"`
+}
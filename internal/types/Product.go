@@ -0,0 +1,58 @@
+package types
+
+import (
+	"gorm.io/gorm"
+)
+
+// Product represents the products table. Prices are stored in minor units
+// (cents) of the store currency.
+type Product struct {
+	gorm.Model
+	Title       string `gorm:"type:varchar(255);not null" json:"title"`
+	Handle      string `gorm:"type:varchar(255);not null;unique" json:"handle"`
+	Description string `gorm:"type:text" json:"description"`
+	Price       int64  `gorm:"not null;default:0" json:"price"`
+	Published   bool   `gorm:"default:false" json:"published"`
+	CategoryID  *uint  `gorm:"index" json:"category_id,omitempty"`
+	TaxClassID  *uint  `gorm:"index" json:"tax_class_id,omitempty"`
+
+	// Associations
+	Category *Category        `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Tags     []ProductTag     `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"tags,omitempty"`
+	Variants []ProductVariant `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"variants,omitempty"`
+	Images   []ProductImage   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE;" json:"images,omitempty"`
+	Rating   *ProductRating   `gorm:"foreignKey:ProductID" json:"rating,omitempty"`
+}
+
+// ProductVariant represents the product_variants table. Weight is in grams.
+// Stock is reported low once the available quantity drops to
+// LowStockThreshold; zero turns the report off.
+type ProductVariant struct {
+	gorm.Model
+	ProductID         uint   `gorm:"not null;index" json:"product_id"`
+	SKU               string `gorm:"type:varchar(100);not null;unique" json:"sku"`
+	Title             string `gorm:"type:varchar(255)" json:"title"`
+	Price             int64  `gorm:"not null;default:0" json:"price"`
+	Weight            int    `gorm:"not null;default:0" json:"weight"`
+	Position          int    `gorm:"default:0" json:"position"`
+	LowStockThreshold int    `gorm:"not null;default:5" json:"low_stock_threshold"`
+
+	// Associations
+	Options []ProductVariantOption `gorm:"foreignKey:VariantID;constraint:OnDelete:CASCADE;" json:"options,omitempty"`
+}
+
+// ProductVariantOption represents the product_variant_options table. Each row
+// is one option value of a variant, e.g. Color: Red.
+type ProductVariantOption struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID uint   `gorm:"not null;uniqueIndex:idx_variant_option" json:"variant_id"`
+	Name      string `gorm:"type:varchar(100);not null;uniqueIndex:idx_variant_option" json:"name"`
+	Value     string `gorm:"type:varchar(100);not null;index" json:"value"`
+}
+
+// ProductTag represents the product_tags table.
+type ProductTag struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID uint   `gorm:"not null;index" json:"product_id"`
+	Name      string `gorm:"type:varchar(100);not null;index" json:"name"`
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Promotion represents the promotions table. A promotion with a Code is a
+// coupon the customer has to enter, one without is applied automatically.
+// Conditions is a rule expression in the smart collection syntax limiting
+// the cart lines the promotion applies to; empty means every line.
+type Promotion struct {
+	gorm.Model
+	Name        string     `gorm:"type:varchar(255);not null" json:"name"`
+	Code        *string    `gorm:"type:varchar(50);unique" json:"code,omitempty"`
+	Kind        string     `gorm:"type:varchar(20);not null" json:"kind"`
+	Value       int64      `gorm:"not null;default:0" json:"value"`
+	BuyQuantity int        `gorm:"not null;default:0" json:"buy_quantity"`
+	GetQuantity int        `gorm:"not null;default:0" json:"get_quantity"`
+	Tiers       string     `gorm:"type:text" json:"tiers"`
+	Conditions  string     `gorm:"type:text" json:"conditions"`
+	MinSubtotal int64      `gorm:"not null;default:0" json:"min_subtotal"`
+	StartsAt    *time.Time `gorm:"type:timestamp" json:"starts_at"`
+	EndsAt      *time.Time `gorm:"type:timestamp" json:"ends_at"`
+	UsageLimit  int        `gorm:"not null;default:0" json:"usage_limit"`
+	UsageCount  int        `gorm:"not null;default:0" json:"usage_count"`
+	PerCustomer int        `gorm:"not null;default:0" json:"per_customer"`
+	Stackable   bool       `gorm:"default:false" json:"stackable"`
+	Priority    int        `gorm:"not null;default:0" json:"priority"`
+	Active      bool       `gorm:"not null" json:"active"`
+}
+
+// PromotionRedemption represents the promotion_redemptions table, one use of
+// a promotion by an order.
+type PromotionRedemption struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PromotionID uint      `gorm:"not null;index" json:"promotion_id"`
+	OrderID     uint      `gorm:"not null;index" json:"order_id"`
+	UserID      *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email       string    `gorm:"type:varchar(255);not null;index" json:"email"`
+	Amount      int64     `gorm:"not null" json:"amount"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// CartCoupon represents the cart_coupons table, the codes entered on a cart.
+type CartCoupon struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CartID    uint      `gorm:"not null;uniqueIndex:idx_cart_coupon" json:"cart_id"`
+	Code      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_cart_coupon" json:"code"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// OrderDiscount represents the order_discounts table, the promotions applied
+// to an order as explained to the customer at checkout.
+type OrderDiscount struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID     uint   `gorm:"not null;index" json:"order_id"`
+	PromotionID uint   `gorm:"not null;index" json:"promotion_id"`
+	Code        string `gorm:"type:varchar(50)" json:"code"`
+	Description string `gorm:"type:text;not null" json:"description"`
+	Amount      int64  `gorm:"not null" json:"amount"`
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"time"
+)
+
+// RecoverySettings represents the recovery_settings table, the abandoned
+// cart reminders of the tenant. Steps is a JSON list of reminders, each
+// sent a number of minutes after the cart was last touched and optionally
+// carrying a discount code valid for CodeValidHours. CartURL is the
+// storefront page that restores a cart from its token.
+type RecoverySettings struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Enabled        bool      `gorm:"not null;default:false" json:"enabled"`
+	Steps          string    `gorm:"type:text" json:"steps"`
+	CodeValidHours int       `gorm:"not null;default:72" json:"code_valid_hours"`
+	CartURL        string    `gorm:"type:varchar(255)" json:"cart_url"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// CartRecovery represents the cart_recoveries table, the reminder sequence
+// of one abandoned cart. Step counts the reminders sent. OrderID is set once
+// the cart is checked out after a reminder, which attributes the order to
+// the campaign.
+type CartRecovery struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CartID    uint       `gorm:"not null;unique" json:"cart_id"`
+	Email     string     `gorm:"type:varchar(255);not null;index" json:"email"`
+	Status    string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Step      int        `gorm:"not null;default:0" json:"step"`
+	OrderID   *uint      `gorm:"index" json:"order_id,omitempty"`
+	StoppedAt *time.Time `gorm:"type:timestamp" json:"stopped_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Emails []RecoveryEmail `gorm:"foreignKey:RecoveryID;constraint:OnDelete:CASCADE;" json:"emails,omitempty"`
+}
+
+// RecoveryEmail represents the recovery_emails table, one reminder sent.
+// Token identifies it in the tracking pixel and the cart link.
+type RecoveryEmail struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	RecoveryID   uint       `gorm:"not null;index" json:"recovery_id"`
+	Step         int        `gorm:"not null" json:"step"`
+	Token        string     `gorm:"type:varchar(64);not null;unique" json:"-"`
+	PromotionID  *uint      `gorm:"index" json:"promotion_id,omitempty"`
+	DiscountCode string     `gorm:"type:varchar(50)" json:"discount_code"`
+	SentAt       time.Time  `gorm:"type:timestamp;not null" json:"sent_at"`
+	Opens        int        `gorm:"not null;default:0" json:"opens"`
+	OpenedAt     *time.Time `gorm:"type:timestamp" json:"opened_at"`
+	Clicks       int        `gorm:"not null;default:0" json:"clicks"`
+	ClickedAt    *time.Time `gorm:"type:timestamp" json:"clicked_at"`
+}
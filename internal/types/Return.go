@@ -0,0 +1,53 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReturnRequest represents the return_requests table, a customer's request
+// to send back some of the lines of an order (an RMA).
+type ReturnRequest struct {
+	gorm.Model
+	Number       string     `gorm:"type:varchar(32);not null;unique" json:"number"`
+	OrderID      uint       `gorm:"not null;index" json:"order_id"`
+	Status       string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	CustomerNote string     `gorm:"type:text" json:"customer_note"`
+	StaffNote    string     `gorm:"type:text" json:"staff_note"`
+	ApprovedAt   *time.Time `gorm:"type:timestamp" json:"approved_at"`
+	ReceivedAt   *time.Time `gorm:"type:timestamp" json:"received_at"`
+	InspectedAt  *time.Time `gorm:"type:timestamp" json:"inspected_at"`
+
+	// Associations
+	Lines []ReturnLine `gorm:"foreignKey:ReturnRequestID;constraint:OnDelete:CASCADE;" json:"lines,omitempty"`
+}
+
+// ReturnLine represents the return_lines table, a quantity of one order line
+// being returned.
+type ReturnLine struct {
+	ID                uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReturnRequestID   uint   `gorm:"not null;index" json:"return_request_id"`
+	OrderLineID       uint   `gorm:"not null;index" json:"order_line_id"`
+	Quantity          int    `gorm:"not null" json:"quantity"`
+	Reason            string `gorm:"type:varchar(30);not null" json:"reason"`
+	Note              string `gorm:"type:text" json:"note"`
+	QuantityRestocked int    `gorm:"not null;default:0" json:"quantity_restocked"`
+}
+
+// Refund represents the refunds table, money given back on an order. A
+// refund may be spread over several payments of the order, Amount is what
+// was actually given back out of Requested. Destination is "original" for
+// money given back the way it was paid and "store_credit" otherwise.
+type Refund struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID         uint      `gorm:"not null;index" json:"order_id"`
+	ReturnRequestID *uint     `gorm:"index" json:"return_request_id,omitempty"`
+	Requested       int64     `gorm:"not null" json:"requested"`
+	Amount          int64     `gorm:"not null" json:"amount"`
+	Currency        string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Status          string    `gorm:"type:varchar(20);not null" json:"status"`
+	Destination     string    `gorm:"type:varchar(20);not null;default:original" json:"destination"`
+	Reason          string    `gorm:"type:text" json:"reason"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
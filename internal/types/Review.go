@@ -0,0 +1,83 @@
+package types
+
+import (
+	"time"
+)
+
+// Review represents the reviews table, one customer's review of a product.
+// Verified is set when the customer had ordered the product, OrderID being
+// that order. Flags lists what the automatic filters found, such reviews
+// always wait for a moderator. Only approved reviews are shown and counted
+// in the product's rating.
+type Review struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID      uint       `gorm:"not null;index;uniqueIndex:idx_review_product_user" json:"product_id"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_review_product_user" json:"user_id"`
+	OrderID        *uint      `json:"order_id,omitempty"`
+	AuthorName     string     `gorm:"type:varchar(100);not null" json:"author_name"`
+	Rating         int        `gorm:"not null" json:"rating"`
+	Title          string     `gorm:"type:varchar(255);not null" json:"title"`
+	Body           string     `gorm:"type:text;not null" json:"body"`
+	Verified       bool       `gorm:"not null;default:false" json:"verified"`
+	Status         string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Flags          string     `gorm:"type:varchar(100)" json:"flags"`
+	ModerationNote string     `gorm:"type:text" json:"moderation_note,omitempty"`
+	HelpfulCount   int        `gorm:"not null;default:0" json:"helpful_count"`
+	UnhelpfulCount int        `gorm:"not null;default:0" json:"unhelpful_count"`
+	Reply          string     `gorm:"type:text" json:"reply"`
+	RepliedAt      *time.Time `gorm:"type:timestamp" json:"replied_at"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Images []ReviewImage `gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE;" json:"images,omitempty"`
+}
+
+// ReviewImage represents the review_images table, a photo attached to a
+// review.
+type ReviewImage struct {
+	ID       uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReviewID uint `gorm:"not null;index" json:"review_id"`
+	MediaID  uint `gorm:"not null;index" json:"media_id"`
+	Position int  `gorm:"not null;default:0" json:"position"`
+
+	// Associations
+	Media MediaFile `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE;" json:"media"`
+}
+
+// ReviewVote represents the review_votes table, whether a customer found a
+// review helpful. Each customer votes once per review.
+type ReviewVote struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReviewID  uint      `gorm:"not null;uniqueIndex:idx_review_vote" json:"review_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_review_vote" json:"user_id"`
+	Helpful   bool      `gorm:"not null" json:"helpful"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ProductRating represents the product_ratings table, the running summary
+// of a product's approved reviews. It is adjusted as reviews are approved,
+// changed or removed rather than recomputed.
+type ProductRating struct {
+	ProductID uint    `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	Count     int     `gorm:"not null;default:0" json:"count"`
+	Total     int     `gorm:"not null;default:0" json:"total"`
+	Average   float64 `gorm:"type:numeric(3,2);not null;default:0" json:"average"`
+	Stars1    int     `gorm:"column:stars_1;not null;default:0" json:"stars_1"`
+	Stars2    int     `gorm:"column:stars_2;not null;default:0" json:"stars_2"`
+	Stars3    int     `gorm:"column:stars_3;not null;default:0" json:"stars_3"`
+	Stars4    int     `gorm:"column:stars_4;not null;default:0" json:"stars_4"`
+	Stars5    int     `gorm:"column:stars_5;not null;default:0" json:"stars_5"`
+}
+
+// ReviewSettings represents the review_settings table, a single row holding
+// how the tenant moderates reviews. With AutoApprove reviews the filters
+// find nothing wrong with are published right away. BlockedWords adds
+// words to the built in profanity list, one per line or comma separated.
+type ReviewSettings struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	AutoApprove  bool      `gorm:"not null;default:false" json:"auto_approve"`
+	AllowLinks   bool      `gorm:"not null;default:false" json:"allow_links"`
+	BlockedWords string    `gorm:"type:text" json:"blocked_words"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
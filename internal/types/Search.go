@@ -0,0 +1,19 @@
+package types
+
+import "time"
+
+// ProductSearchDocument represents the product_search_documents table. It is
+// a denormalized copy of a product kept for full-text search; Document holds
+// the weighted tsvector and Terms the plain text used for trigram matching.
+type ProductSearchDocument struct {
+	ProductID  uint      `gorm:"primaryKey;autoIncrement:false" json:"product_id"`
+	Title      string    `gorm:"type:varchar(255);not null" json:"title"`
+	Document   string    `gorm:"type:tsvector" json:"-"`
+	Terms      string    `gorm:"type:text;not null" json:"-"`
+	CategoryID *uint     `gorm:"index" json:"category_id,omitempty"`
+	MinPrice   int64     `gorm:"not null;default:0;index" json:"min_price"`
+	MaxPrice   int64     `gorm:"not null;default:0" json:"max_price"`
+	Published  bool      `gorm:"not null;default:false;index" json:"published"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Shipment represents the shipments table, a parcel sent from a stock
+// location with some of the lines of an order.
+type Shipment struct {
+	gorm.Model
+	OrderID        uint       `gorm:"not null;index" json:"order_id"`
+	LocationID     uint       `gorm:"not null;index" json:"location_id"`
+	Status         string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Carrier        string     `gorm:"type:varchar(50);not null" json:"carrier"`
+	TrackingNumber string     `gorm:"type:varchar(100);index" json:"tracking_number"`
+	ShippedAt      *time.Time `gorm:"type:timestamp" json:"shipped_at"`
+	DeliveredAt    *time.Time `gorm:"type:timestamp" json:"delivered_at"`
+
+	// Associations
+	Lines  []ShipmentLine  `gorm:"foreignKey:ShipmentID;constraint:OnDelete:CASCADE;" json:"lines,omitempty"`
+	Events []ShipmentEvent `gorm:"foreignKey:ShipmentID;constraint:OnDelete:CASCADE;" json:"events,omitempty"`
+}
+
+// ShipmentLine represents the shipment_lines table, a quantity of an order
+// line packed in a shipment.
+type ShipmentLine struct {
+	ID          uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShipmentID  uint `gorm:"not null;index" json:"shipment_id"`
+	OrderLineID uint `gorm:"not null;index" json:"order_line_id"`
+	Quantity    int  `gorm:"not null" json:"quantity"`
+}
+
+// ShipmentEvent represents the shipment_events table, the tracking history
+// of a shipment as reported by staff or the carrier.
+type ShipmentEvent struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShipmentID  uint      `gorm:"not null;index" json:"shipment_id"`
+	Status      string    `gorm:"type:varchar(20);not null" json:"status"`
+	Description string    `gorm:"type:text" json:"description"`
+	Location    string    `gorm:"type:varchar(255)" json:"location"`
+	OccurredAt  time.Time `gorm:"type:timestamp;not null" json:"occurred_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"time"
+)
+
+// ShippingZone represents the shipping_zones table. A zone covers a country,
+// or the postal codes of a country matching one of the comma separated
+// PostalPatterns, where * stands for any characters (e.g. "90*,91*").
+type ShippingZone struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string    `gorm:"type:varchar(100);not null" json:"name"`
+	CountryCode    string    `gorm:"type:varchar(5);not null;index" json:"country_code"`
+	PostalPatterns string    `gorm:"type:text" json:"postal_patterns"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Methods []ShippingMethod `gorm:"foreignKey:ShippingZoneID;constraint:OnDelete:CASCADE;" json:"methods,omitempty"`
+}
+
+// ShippingMethod represents the shipping_methods table. Flat methods charge
+// FlatRate, weight and price methods look their rate up in Tiers, carrier
+// methods ask the Carrier for a quote. Orders worth FreeAbove or more ship
+// for free when it is set.
+type ShippingMethod struct {
+	ID             uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShippingZoneID uint   `gorm:"not null;index" json:"shipping_zone_id"`
+	Name           string `gorm:"type:varchar(100);not null" json:"name"`
+	Kind           string `gorm:"type:varchar(20);not null" json:"kind"`
+	FlatRate       int64  `gorm:"not null;default:0" json:"flat_rate"`
+	Carrier        string `gorm:"type:varchar(50)" json:"carrier,omitempty"`
+	Service        string `gorm:"type:varchar(50)" json:"service,omitempty"`
+	FreeAbove      int64  `gorm:"not null;default:0" json:"free_above"`
+	Active         bool   `gorm:"not null" json:"active"`
+	Position       int    `gorm:"not null;default:0" json:"position"`
+
+	// Associations
+	Tiers []ShippingRateTier `gorm:"foreignKey:ShippingMethodID;constraint:OnDelete:CASCADE;" json:"tiers,omitempty"`
+}
+
+// ShippingRateTier represents the shipping_rate_tiers table. The tier with
+// the highest MinValue not above the cart weight (grams) or subtotal (minor
+// units) sets the rate.
+type ShippingRateTier struct {
+	ID               uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	ShippingMethodID uint  `gorm:"not null;index" json:"shipping_method_id"`
+	MinValue         int64 `gorm:"not null" json:"min_value"`
+	Rate             int64 `gorm:"not null" json:"rate"`
+}
@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+)
+
+// SubscriptionPlan represents the subscription_plans table, a way to buy a
+// product on repeat: every IntervalCount Interval at DiscountRate basis
+// points off.
+type SubscriptionPlan struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID     uint      `gorm:"not null;index" json:"product_id"`
+	Name          string    `gorm:"type:varchar(100);not null" json:"name"`
+	Interval      string    `gorm:"type:varchar(10);not null" json:"interval"`
+	IntervalCount int       `gorm:"not null;default:1" json:"interval_count"`
+	DiscountRate  int64     `gorm:"not null;default:0" json:"discount_rate"`
+	Active        bool      `gorm:"not null" json:"active"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// CustomerSubscription represents the customer_subscriptions table. A
+// renewal order is placed and charged to PaymentMethod every time
+// NextBillingAt passes. Paused subscriptions resume at PausedUntil, or by
+// hand when it is nil. PendingOrderID is a renewal order whose payment was
+// still in progress when it was charged; nothing is billed again until it
+// is paid or cancelled.
+type CustomerSubscription struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	Email            string     `gorm:"type:varchar(255);not null" json:"email"`
+	PlanID           uint       `gorm:"not null;index" json:"plan_id"`
+	VariantID        uint       `gorm:"not null" json:"variant_id"`
+	Quantity         int        `gorm:"not null" json:"quantity"`
+	Currency         string     `gorm:"type:varchar(3)" json:"currency"`
+	ShippingMethodID *uint      `json:"shipping_method_id,omitempty"`
+	Status           string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	NextBillingAt    time.Time  `gorm:"type:timestamp;not null;index" json:"next_billing_at"`
+	PausedUntil      *time.Time `gorm:"type:timestamp" json:"paused_until"`
+	PaymentProvider  string     `gorm:"type:varchar(50);not null" json:"payment_provider"`
+	PaymentMethod    string     `gorm:"type:varchar(255);not null" json:"-"`
+	FailedAttempts   int        `gorm:"not null;default:0" json:"failed_attempts"`
+	NextRetryAt      *time.Time `gorm:"type:timestamp;index" json:"next_retry_at"`
+	LastOrderID      *uint      `json:"last_order_id,omitempty"`
+	PendingOrderID   *uint      `json:"pending_order_id,omitempty"`
+	CancelledAt      *time.Time `gorm:"type:timestamp" json:"cancelled_at"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Plan SubscriptionPlan `gorm:"foreignKey:PlanID" json:"plan"`
+}
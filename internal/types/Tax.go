@@ -0,0 +1,53 @@
+package types
+
+import (
+	"time"
+)
+
+// TaxClass represents the tax_classes table, e.g. standard or reduced.
+// Products without a class are taxed at the rates that apply to every class.
+type TaxClass struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Code      string    `gorm:"type:varchar(50);not null;unique" json:"code"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TaxZone represents the tax_zones table. A zone covers a country, or the
+// zip codes of a country starting with ZipPrefix. The zone with the longest
+// matching prefix wins.
+type TaxZone struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	CountryCode string    `gorm:"type:varchar(5);not null;uniqueIndex:idx_tax_zone_area" json:"country_code"`
+	ZipPrefix   string    `gorm:"type:varchar(20);not null;default:'';uniqueIndex:idx_tax_zone_area" json:"zip_prefix"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Rates []TaxRate `gorm:"foreignKey:TaxZoneID;constraint:OnDelete:CASCADE;" json:"rates,omitempty"`
+}
+
+// TaxRate represents the tax_rates table. Rate is in hundredths of a
+// percent (825 is 8.25%). Rates apply in Priority order, a compound rate is
+// charged on the price plus the taxes before it.
+type TaxRate struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaxZoneID  uint   `gorm:"not null;index" json:"tax_zone_id"`
+	TaxClassID *uint  `gorm:"index" json:"tax_class_id,omitempty"`
+	Name       string `gorm:"type:varchar(100);not null" json:"name"`
+	Rate       int64  `gorm:"not null" json:"rate"`
+	Compound   bool   `gorm:"not null;default:false" json:"compound"`
+	Priority   int    `gorm:"not null;default:0" json:"priority"`
+}
+
+// TaxSettings represents the tax_settings table, a single row holding how
+// the tenant calculates tax.
+type TaxSettings struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	Provider         string    `gorm:"type:varchar(50);not null" json:"provider"`
+	PricesIncludeTax bool      `gorm:"not null;default:false" json:"prices_include_tax"`
+	RoundingMode     string    `gorm:"type:varchar(20);not null" json:"rounding_mode"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
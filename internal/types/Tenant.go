@@ -0,0 +1,87 @@
+package types
+
+// TenantModels returns the models that are migrated into every tenant database.
+func TenantModels() []interface{} {
+	return []interface{}{
+		UserIPAddress{},
+		User{},
+		UserPassword{},
+		UserPhysicalAddress{},
+		UserSiteVisit{},
+		UserEmailVerification{},
+		UserSession{},
+		Category{},
+		Product{},
+		ProductVariant{},
+		ProductTag{},
+		ProductVariantOption{},
+		Collection{},
+		CollectionProduct{},
+		Warehouse{},
+		StockLocation{},
+		InventoryLevel{},
+		StockMovement{},
+		StockReservation{},
+		Cart{},
+		CartItem{},
+		Order{},
+		OrderLine{},
+		OrderAddress{},
+		OrderEvent{},
+		PaymentProviderConfig{},
+		PaymentIntent{},
+		PaymentTransaction{},
+		PaymentWebhookEvent{},
+		ReturnRequest{},
+		ReturnLine{},
+		Refund{},
+		Promotion{},
+		PromotionRedemption{},
+		CartCoupon{},
+		OrderDiscount{},
+		TaxClass{},
+		TaxZone{},
+		TaxRate{},
+		TaxSettings{},
+		ShippingZone{},
+		ShippingMethod{},
+		ShippingRateTier{},
+		Shipment{},
+		ShipmentLine{},
+		ShipmentEvent{},
+		CurrencySettings{},
+		Currency{},
+		PriceList{},
+		PriceListEntry{},
+		ExchangeRate{},
+		SubscriptionPlan{},
+		CustomerSubscription{},
+		GiftCard{},
+		GiftCardTransaction{},
+		StoreCreditAccount{},
+		StoreCreditTransaction{},
+		ProductSearchDocument{},
+		ImportJob{},
+		ImportRow{},
+		MediaFile{},
+		MediaVariant{},
+		ProductImage{},
+		DigitalAsset{},
+		DownloadGrant{},
+		LicenseKey{},
+		Review{},
+		ReviewImage{},
+		ReviewVote{},
+		ProductRating{},
+		ReviewSettings{},
+		Wishlist{},
+		WishlistItem{},
+		StockAlert{},
+		RecoverySettings{},
+		CartRecovery{},
+		RecoveryEmail{},
+		WebhookSubscription{},
+		WebhookDelivery{},
+		WebhookAttempt{},
+	}
+}
@@ -48,23 +48,34 @@ type UserPassword struct {
 	User            User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"user"`
 }
 
-// PhysicalAddress represents the physical_addresses table.
+// PhysicalAddress represents the physical_addresses table, the address book
+// of a customer. The structured fields are stored normalized for the
+// country and Formatted holds the address laid out for a label. A customer
+// has at most one PrimaryBilling and one PrimaryShipping address.
 type UserPhysicalAddress struct {
-	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID       uint   `gorm:"not null" json:"user_id"`
-	StreetNumber int    `gorm:"not null" json:"street_number"`
-	Directional  string `gorm:"type:varchar(10)" json:"directional"`
-	Street       string `gorm:"type:varchar(255);not null" json:"street"`
-	Suffix       string `gorm:"type:varchar(50)" json:"suffix"`
-	UnitType     string `gorm:"type:varchar(50)" json:"unit_type"`
-	UnitNumber   int    `json:"unit_number"`
-	ZipCode      string `gorm:"type:varchar(20);not null" json:"zip_code"`
-	CountryCode  string `gorm:"type:varchar(5);not null" json:"country_code"`
-	Primary      bool   `gorm:"default:false" json:"primary"`
-	Active       bool   `gorm:"default:true" json:"active"`
-	IsBilling    bool   `gorm:"default:false" json:"is_billing"`
-	IsShipping   bool   `gorm:"default:false" json:"is_shipping"`
-	User         User   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"user"`
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          uint      `gorm:"not null;index" json:"user_id"`
+	FullName        string    `gorm:"type:varchar(255)" json:"full_name"`
+	Phone           string    `gorm:"type:varchar(30)" json:"phone"`
+	StreetNumber    string    `gorm:"type:varchar(20)" json:"street_number"`
+	Directional     string    `gorm:"type:varchar(10)" json:"directional"`
+	Street          string    `gorm:"type:varchar(255);not null" json:"street"`
+	Suffix          string    `gorm:"type:varchar(50)" json:"suffix"`
+	UnitType        string    `gorm:"type:varchar(50)" json:"unit_type"`
+	UnitNumber      string    `gorm:"type:varchar(20)" json:"unit_number"`
+	City            string    `gorm:"type:varchar(100)" json:"city"`
+	Region          string    `gorm:"type:varchar(100)" json:"region"`
+	ZipCode         string    `gorm:"type:varchar(20);not null" json:"zip_code"`
+	CountryCode     string    `gorm:"type:varchar(5);not null" json:"country_code"`
+	Formatted       string    `gorm:"type:text" json:"formatted"`
+	Active          bool      `gorm:"default:true" json:"active"`
+	IsBilling       bool      `gorm:"default:false" json:"is_billing"`
+	IsShipping      bool      `gorm:"default:false" json:"is_shipping"`
+	PrimaryBilling  bool      `gorm:"default:false" json:"primary_billing"`
+	PrimaryShipping bool      `gorm:"default:false" json:"primary_shipping"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	User            User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
 }
 
 // SiteVisit represents the site_visits table.
@@ -85,3 +96,16 @@ type UserEmailVerification struct {
 	Email     string    `gorm:"type:varchar(255);not null" json:"email"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
+
+// UserSession represents the user_sessions table, a signed in customer. The
+// bearer token itself is never stored, only its SHA-256 hash.
+type UserSession struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;unique" json:"-"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"type:timestamp" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+}
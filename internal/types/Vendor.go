@@ -21,7 +21,7 @@ type VendorIPAddress struct {
 
 type Vendor struct {
 	gorm.Model
-	TenantID    string `gorm:"type:varchar(255);not null" json:"tenant_id"`
+	TenantID    string `gorm:"type:varchar(255);not null;index" json:"tenant_id"`
 	Logo        string `gorm:"type:varchar(255)" json:"logo"`
 	CompanyName string `gorm:"type:varchar(255);not null" json:"company_name"`
 	TradingName string `gorm:"type:varchar(255);not null" json:"trading_name"`
@@ -33,6 +33,8 @@ type Vendor struct {
 	IPAddressID   *uint  `json:"ip_address_id,omitempty"`
 	TryCount      int    `gorm:"default:0" json:"try_count"`
 
+	CommissionPlanID *uint `json:"commission_plan_id,omitempty"`
+
 	// Associations
 	OTPs       []VendorOTP             `gorm:"foreignKey:VendorID" json:"otps,omitempty"`
 	Passwords  []VendorPassword        `gorm:"foreignKey:VendorID" json:"passwords,omitempty"`
@@ -40,6 +42,20 @@ type Vendor struct {
 	SiteVisits []VendorSiteVisit       `gorm:"foreignKey:VendorID" json:"site_visits,omitempty"`
 }
 
+// VendorSession represents the vendor_sessions table, a vendor signed in to
+// the admin of their store. Like customer sessions only the SHA-256 hash of
+// the token is stored.
+type VendorSession struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	VendorID   uint       `gorm:"not null;index" json:"vendor_id"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;unique" json:"-"`
+	ExpiresAt  time.Time  `gorm:"type:timestamp;not null;index" json:"expires_at"`
+	LastUsedAt *time.Time `gorm:"type:timestamp" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	Vendor     Vendor     `gorm:"foreignKey:VendorID;constraint:OnDelete:CASCADE;" json:"-"`
+}
+
 type VendorPassword struct {
 	ID              uint       `gorm:"primaryKey;autoIncrement" json:"id"`
 	VendorID        uint       `gorm:"not null;unique" json:"vendor_id"`
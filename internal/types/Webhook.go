@@ -0,0 +1,64 @@
+package types
+
+import (
+	"time"
+)
+
+// WebhookSubscription represents the webhook_subscriptions table, an
+// endpoint of the tenant's own systems notified of store events. Events is a
+// comma separated list of event types, or "*" for all of them. Secret signs
+// every delivery. FailedDeliveries counts the deliveries given up on since
+// the last successful one; the subscription is disabled when it reaches the
+// limit.
+type WebhookSubscription struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	URL              string     `gorm:"type:varchar(2048);not null" json:"url"`
+	Description      string     `gorm:"type:varchar(255)" json:"description"`
+	Events           string     `gorm:"type:text;not null" json:"events"`
+	Secret           string     `gorm:"type:varchar(100);not null" json:"-"`
+	Active           bool       `gorm:"not null;default:true;index" json:"active"`
+	FailedDeliveries int        `gorm:"not null;default:0" json:"failed_deliveries"`
+	LastSuccessAt    *time.Time `gorm:"type:timestamp" json:"last_success_at"`
+	DisabledAt       *time.Time `gorm:"type:timestamp" json:"disabled_at"`
+	DisabledReason   string     `gorm:"type:varchar(255)" json:"disabled_reason"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookDelivery represents the webhook_deliveries table, one event to be
+// sent to one subscription. Payload is the exact body sent on every attempt
+// and EventID is shared by the deliveries of the same event, so receivers
+// can recognise redeliveries. NextAttemptAt is when a pending delivery is
+// tried next; without it the delivery is only sent on request.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID uint       `gorm:"not null;index" json:"subscription_id"`
+	EventID        string     `gorm:"type:varchar(64);not null;index" json:"event_id"`
+	Event          string     `gorm:"type:varchar(50);not null;index" json:"event"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	LastStatusCode int        `gorm:"not null;default:0" json:"last_status_code"`
+	NextAttemptAt  *time.Time `gorm:"type:timestamp;index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `gorm:"type:timestamp" json:"delivered_at"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	History []WebhookAttempt `gorm:"foreignKey:DeliveryID;constraint:OnDelete:CASCADE;" json:"history,omitempty"`
+}
+
+// WebhookAttempt represents the webhook_attempts table, one request made for
+// a delivery. StatusCode is zero when no response was received, Error then
+// says why. ResponseBody holds the start of the response. Manual attempts
+// were requested by the tenant rather than made by the retry schedule.
+type WebhookAttempt struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeliveryID   uint      `gorm:"not null;index" json:"delivery_id"`
+	StatusCode   int       `gorm:"not null;default:0" json:"status_code"`
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	Error        string    `gorm:"type:text" json:"error"`
+	DurationMs   int64     `gorm:"not null;default:0" json:"duration_ms"`
+	Manual       bool      `gorm:"not null;default:false" json:"manual"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
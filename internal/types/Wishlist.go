@@ -0,0 +1,55 @@
+package types
+
+import (
+	"time"
+)
+
+// Wishlist represents the wishlists table, a named list of products a
+// customer keeps. A list with a ShareToken can be viewed by anyone holding
+// its share link.
+type Wishlist struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+	ShareToken *string   `gorm:"type:varchar(64);unique" json:"share_token,omitempty"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Items []WishlistItem `gorm:"foreignKey:WishlistID;constraint:OnDelete:CASCADE;" json:"items,omitempty"`
+}
+
+// WishlistItem represents the wishlist_items table, a product on a list,
+// optionally narrowed to one of its variants.
+type WishlistItem struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WishlistID uint      `gorm:"not null;index" json:"wishlist_id"`
+	ProductID  uint      `gorm:"not null;index" json:"product_id"`
+	VariantID  *uint     `gorm:"index" json:"variant_id,omitempty"`
+	Note       string    `gorm:"type:varchar(255)" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	// Associations
+	Product Product         `gorm:"foreignKey:ProductID" json:"product"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+}
+
+// StockAlert represents the stock_alerts table, a request to be emailed
+// when an out of stock variant is available again. Each email subscribes
+// once per variant. Alerts are queued when stock comes back and sent in
+// batches; Token identifies the alert in its unsubscribe link.
+type StockAlert struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	VariantID  uint       `gorm:"not null;uniqueIndex:idx_stock_alert_variant_email" json:"variant_id"`
+	Email      string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_stock_alert_variant_email" json:"email"`
+	UserID     *uint      `gorm:"index" json:"user_id,omitempty"`
+	Token      string     `gorm:"type:varchar(64);not null;unique" json:"-"`
+	Status     string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	QueuedAt   *time.Time `gorm:"type:timestamp" json:"queued_at"`
+	NotifiedAt *time.Time `gorm:"type:timestamp" json:"notified_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	Variant ProductVariant `gorm:"foreignKey:VariantID" json:"variant"`
+}
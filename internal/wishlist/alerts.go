@@ -0,0 +1,186 @@
+package wishlist
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Satishcg12/multicommers/internal/inventory"
+	"github.com/Satishcg12/multicommers/internal/notification"
+	"github.com/Satishcg12/multicommers/internal/types"
+	"github.com/Satishcg12/multicommers/utils/email"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// maxAlertEmails bounds the back-in-stock emails a tenant sends per run, so
+// a popular restock is spread over several runs rather than flooding the
+// mail queue.
+const maxAlertEmails = 50
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrInStock         = errors.New("variant is in stock")
+	ErrAlertNotFound   = errors.New("stock alert not found")
+)
+
+// Subscribe asks for an email to address when an out of stock variant is
+// available again. Subscribing twice returns the alert already waiting, and
+// an alert that was sent before waits again.
+func Subscribe(db *gorm.DB, variantID uint, address string, userID *uint) (types.StockAlert, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	alert := types.StockAlert{}
+	if err := db.First(&types.ProductVariant{}, variantID).Error; err != nil {
+		return alert, ErrVariantNotFound
+	}
+	available, err := inventory.Available(db, variantID)
+	if err != nil {
+		return alert, err
+	}
+	if available > 0 {
+		return alert, ErrInStock
+	}
+
+	err = db.Where("variant_id = ? AND email = ?", variantID, address).First(&alert).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		alert = types.StockAlert{
+			VariantID: variantID,
+			Email:     address,
+			UserID:    userID,
+			Token:     randomString.GenerateSecureToken(16),
+			Status:    inventory.AlertWaiting,
+		}
+		return alert, db.Create(&alert).Error
+	}
+	if err != nil || alert.Status != inventory.AlertNotified {
+		return alert, err
+	}
+	alert.Status = inventory.AlertWaiting
+	alert.QueuedAt = nil
+	return alert, db.Model(&alert).Updates(map[string]interface{}{"status": inventory.AlertWaiting, "queued_at": nil}).Error
+}
+
+// Unsubscribe removes the alert of token.
+func Unsubscribe(db *gorm.DB, token string) error {
+	res := db.Where("token = ?", token).Delete(&types.StockAlert{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAlertNotFound
+	}
+	return nil
+}
+
+// SendQueued emails the queued alerts, one email per address listing all
+// of its variants, to at most maxAlertEmails addresses. Variants that sold
+// out again in the meantime go back to waiting. An alert is marked notified
+// before its email is handed to the mailer so it is never sent twice. It
+// returns the number of emails sent.
+func SendQueued(db *gorm.DB, mailer email.EmailDaemonInterface, storeName string, now time.Time) (int, error) {
+	var addresses []string
+	if err := db.Model(&types.StockAlert{}).
+		Where("status = ?", inventory.AlertQueued).
+		Group("email").
+		Order("MIN(queued_at)").
+		Limit(maxAlertEmails).
+		Pluck("email", &addresses).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, address := range addresses {
+		var alerts []types.StockAlert
+		if err := db.Preload("Variant").Where("email = ? AND status = ?", address, inventory.AlertQueued).Order("id").Find(&alerts).Error; err != nil {
+			return sent, err
+		}
+		items := []notification.BackInStockItem{}
+		ids := []uint{}
+		for _, alert := range alerts {
+			available, err := inventory.Available(db, alert.VariantID)
+			if err != nil {
+				return sent, err
+			}
+			if available <= 0 {
+				if err := db.Model(&alert).Updates(map[string]interface{}{"status": inventory.AlertWaiting, "queued_at": nil}).Error; err != nil {
+					return sent, err
+				}
+				continue
+			}
+			product := types.Product{}
+			if err := db.First(&product, alert.Variant.ProductID).Error; err != nil {
+				// the product is gone, there is nothing to come back for
+				if err := db.Delete(&alert).Error; err != nil {
+					return sent, err
+				}
+				continue
+			}
+			items = append(items, notification.BackInStockItem{Product: product, Variant: alert.Variant})
+			ids = append(ids, alert.ID)
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		res := db.Model(&types.StockAlert{}).
+			Where("id IN ? AND status = ?", ids, inventory.AlertQueued).
+			Updates(map[string]interface{}{"status": inventory.AlertNotified, "notified_at": now})
+		if res.Error != nil {
+			return sent, res.Error
+		}
+		if res.RowsAffected == 0 {
+			// another run got here first
+			continue
+		}
+		mailer.Send(notification.BackInStock(address, items, storeName))
+		sent++
+	}
+	return sent, nil
+}
+
+// WantedItem is a row of the most wanted report: an out of stock variant
+// with how many customers wait for it and have it on a wishlist.
+type WantedItem struct {
+	VariantID    uint   `json:"variant_id"`
+	SKU          string `json:"sku"`
+	VariantTitle string `json:"variant_title"`
+	ProductID    uint   `json:"product_id"`
+	ProductTitle string `json:"product_title"`
+	Available    int    `json:"available"`
+	Alerts       int64  `json:"alerts"`
+	Wishlists    int64  `json:"wishlists"`
+}
+
+// MostWanted ranks the variants without available stock by waiting alerts,
+// then by the wishlists holding them. Wishlist items naming only the
+// product count for each of its variants.
+func MostWanted(db *gorm.DB, limit int) ([]WantedItem, error) {
+	available := db.Model(&types.InventoryLevel{}).
+		Select("inventory_levels.variant_id, SUM(inventory_levels.on_hand - inventory_levels.reserved) AS available").
+		Joins("JOIN stock_locations ON stock_locations.id = inventory_levels.location_id AND stock_locations.active AND stock_locations.deleted_at IS NULL").
+		Group("inventory_levels.variant_id")
+	alerts := db.Model(&types.StockAlert{}).
+		Select("variant_id, COUNT(*) AS alerts").
+		Where("status = ?", inventory.AlertWaiting).
+		Group("variant_id")
+
+	items := []WantedItem{}
+	err := db.Table("product_variants").
+		Select(`product_variants.id AS variant_id, product_variants.sku, product_variants.title AS variant_title,
+			products.id AS product_id, products.title AS product_title,
+			COALESCE(stock.available, 0) AS available, COALESCE(waiting.alerts, 0) AS alerts,
+			(SELECT COUNT(DISTINCT wishlist_items.wishlist_id) FROM wishlist_items
+				WHERE wishlist_items.variant_id = product_variants.id
+				OR (wishlist_items.variant_id IS NULL AND wishlist_items.product_id = products.id)) AS wishlists`).
+		Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+		Joins("LEFT JOIN (?) AS stock ON stock.variant_id = product_variants.id", available).
+		Joins("LEFT JOIN (?) AS waiting ON waiting.variant_id = product_variants.id", alerts).
+		Where("product_variants.deleted_at IS NULL AND COALESCE(stock.available, 0) <= 0").
+		Where(`(waiting.alerts > 0 OR EXISTS (SELECT 1 FROM wishlist_items
+			WHERE wishlist_items.variant_id = product_variants.id
+			OR (wishlist_items.variant_id IS NULL AND wishlist_items.product_id = products.id)))`).
+		Order("alerts DESC, wishlists DESC, product_variants.id").
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
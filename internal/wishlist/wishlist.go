@@ -0,0 +1,78 @@
+package wishlist
+
+import (
+	"errors"
+
+	"github.com/Satishcg12/multicommers/internal/types"
+	randomString "github.com/Satishcg12/multicommers/utils/string"
+	"gorm.io/gorm"
+)
+
+// DefaultName names the list created when a customer saves a product
+// without picking one.
+const DefaultName = "Wishlist"
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantMismatch = errors.New("variant does not belong to the product")
+)
+
+// Default returns the first list of userID, creating it when the customer
+// has none yet.
+func Default(db *gorm.DB, userID uint) (types.Wishlist, error) {
+	list := types.Wishlist{}
+	err := db.Where("user_id = ?", userID).Order("id").First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		list = types.Wishlist{UserID: userID, Name: DefaultName}
+		err = db.Create(&list).Error
+	}
+	return list, err
+}
+
+// AddItem puts a published product, or one of its variants, on list. An
+// item already on the list is returned as is.
+func AddItem(db *gorm.DB, list types.Wishlist, productID uint, variantID *uint, note string) (types.WishlistItem, error) {
+	product := types.Product{}
+	if err := db.Where("published = ?", true).First(&product, productID).Error; err != nil {
+		return types.WishlistItem{}, ErrProductNotFound
+	}
+	if variantID != nil {
+		var count int64
+		if err := db.Model(&types.ProductVariant{}).Where("id = ? AND product_id = ?", *variantID, productID).Count(&count).Error; err != nil {
+			return types.WishlistItem{}, err
+		}
+		if count == 0 {
+			return types.WishlistItem{}, ErrVariantMismatch
+		}
+	}
+
+	item := types.WishlistItem{}
+	query := db.Where("wishlist_id = ? AND product_id = ?", list.ID, productID)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+	err := query.First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		item = types.WishlistItem{WishlistID: list.ID, ProductID: productID, VariantID: variantID, Note: note}
+		err = db.Create(&item).Error
+	}
+	return item, err
+}
+
+// Share gives list a share token, keeping the one it has.
+func Share(db *gorm.DB, list *types.Wishlist) error {
+	if list.ShareToken != nil {
+		return nil
+	}
+	token := randomString.GenerateSecureToken(16)
+	list.ShareToken = &token
+	return db.Model(list).Update("share_token", token).Error
+}
+
+// Unshare removes the share token of list, old share links stop working.
+func Unshare(db *gorm.DB, list *types.Wishlist) error {
+	list.ShareToken = nil
+	return db.Model(list).Update("share_token", nil).Error
+}
@@ -1,6 +1,7 @@
 package email
 
 import (
+	"log"
 	"strconv"
 
 	"gopkg.in/gomail.v2"
@@ -53,7 +54,8 @@ func (e *EmailDaemon) SendEmail(email EmailMessage) {
 	m.SetHeader("Subject", email.Subject)
 	m.SetBody("text/html", email.Body)
 
+	// a failed delivery must not take the daemon down with it
 	if err := e.dialer.DialAndSend(m); err != nil {
-		panic(err)
+		log.Printf("Error sending email to %s: %s", email.To, err)
 	}
 }
@@ -1,6 +1,10 @@
 package randomString
 
-import "math/rand"
+import (
+	cryptoRand "crypto/rand"
+	"encoding/hex"
+	"math/rand"
+)
 
 func GenerateRandomString(length int) string {
 	// Define the character set
@@ -19,3 +23,13 @@ func GenerateRandomString(length int) string {
 func RandomInt(max int) int {
 	return rand.Intn(max)
 }
+
+// GenerateSecureToken returns a hex encoded token built from n bytes of
+// cryptographically secure randomness, suitable for opaque identifiers.
+func GenerateSecureToken(n int) string {
+	b := make([]byte, n)
+	if _, err := cryptoRand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}